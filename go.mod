@@ -1,12 +1,20 @@
 module github.com/in-toto/attestation
 
-go 1.23
+go 1.23.0
 
 toolchain go1.24.1
 
 require (
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/opencontainers/go-digest v1.0.0
+	github.com/opencontainers/image-spec v1.1.1
 	github.com/stretchr/testify v1.12.0
+	golang.org/x/crypto v0.40.0
 	google.golang.org/protobuf v1.36.12
 )
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require (
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,95 @@
+/*
+Streaming APIs for the in-toto attestation Bundle layer, a JSON Lines file of
+envelopes described in spec/v1/bundle.md.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// MediaType is the media type of a Bundle in storage systems.
+const MediaType = "application/vnd.in-toto.bundle"
+
+// FileSuffix is the suffix of a Bundle file.
+const FileSuffix = ".intoto.jsonl"
+
+var (
+	ErrUnrecognizedLine = errors.New("line is neither an envelope nor a Statement")
+	ErrInvalidEnvelope  = errors.New("invalid envelope")
+	ErrInvalidStatement = errors.New("invalid Statement")
+	ErrLineTooLong      = errors.New("line exceeds the maximum line size")
+)
+
+// Entry is one line of a Bundle.
+type Entry struct {
+	// Line is the 1-based line number of the entry.
+	Line int
+	// Raw is the line as read, without its line terminator.
+	Raw []byte
+	// Envelope is set if the line is a DSSE envelope.
+	Envelope *dsse.Envelope
+	// Statement is set if the line is an unsigned Statement.
+	Statement *ita1.Statement
+	// Err explains why the line was not recognized. Consumers must ignore
+	// such lines.
+	Err error
+}
+
+// lineHeader holds the fields that tell envelopes and Statements apart.
+type lineHeader struct {
+	PayloadType *string `json:"payloadType"`
+	Type        *string `json:"_type"`
+}
+
+// parseLine classifies and decodes a single Bundle line.
+func parseLine(number int, line []byte) *Entry {
+	e := &Entry{Line: number, Raw: line}
+
+	var header lineHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		e.Err = fmt.Errorf("%w: %v", ErrUnrecognizedLine, err)
+		return e
+	}
+
+	switch {
+	case header.PayloadType != nil:
+		env, err := dsse.Parse(line)
+		if err != nil {
+			e.Err = fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+			return e
+		}
+		e.Envelope = env
+	case header.Type != nil:
+		s := &ita1.Statement{}
+		// consumers must ignore unrecognized fields, per the spec's parsing
+		// rules
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(line, s); err != nil {
+			e.Err = fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+			return e
+		}
+
+		if err := s.Validate(); err != nil {
+			e.Err = fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+			return e
+		}
+		e.Statement = s
+	default:
+		e.Err = ErrUnrecognizedLine
+	}
+
+	return e
+}
+
+// isBlank indicates if a line holds only whitespace.
+func isBlank(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0
+}
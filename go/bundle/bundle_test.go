@@ -0,0 +1,136 @@
+/*
+Tests for Bundle line parsing, and helpers shared by the Bundle tests.
+*/
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// createTestStatement returns a Statement about the named subject, whose
+// digest is derived from the name.
+func createTestStatement(t testing.TB, subject, predicateType string) *ita1.Statement {
+	t.Helper()
+
+	pred, err := structpb.NewStruct(map[string]interface{}{
+		"keyObj": map[string]interface{}{
+			"subKey": "subVal"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(subject))
+	return &ita1.Statement{
+		Type: ita1.StatementTypeUri,
+		Subject: []*ita1.ResourceDescriptor{{
+			Name:   subject,
+			Digest: map[string]string{"sha256": hex.EncodeToString(sum[:])},
+		}},
+		PredicateType: predicateType,
+		Predicate:     pred,
+	}
+}
+
+func createTestEnvelope(t testing.TB, s *ita1.Statement, signers ...signature.Signer) *dsse.Envelope {
+	t.Helper()
+
+	env, err := dsse.NewEnvelope(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.Sign(context.Background(), signers...); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func marshalTestLine(t testing.TB, v any) string {
+	t.Helper()
+
+	var data []byte
+	var err error
+	if s, ok := v.(*ita1.Statement); ok {
+		data, err = protojson.Marshal(s)
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestParseLine(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	env := createTestEnvelope(t, createTestStatement(t, "foo", "https://example.com/pred"), signer)
+
+	tests := map[string]struct {
+		line              string
+		expectedEnvelope  bool
+		expectedStatement bool
+		expectedErr       error
+	}{
+		"envelope": {
+			line:             marshalTestLine(t, env),
+			expectedEnvelope: true,
+		},
+		"statement": {
+			line:              marshalTestLine(t, createTestStatement(t, "foo", "https://example.com/pred")),
+			expectedStatement: true,
+		},
+		"not json": {
+			line:        "hello world",
+			expectedErr: ErrUnrecognizedLine,
+		},
+		"json array": {
+			line:        `["payloadType"]`,
+			expectedErr: ErrUnrecognizedLine,
+		},
+		"unknown object": {
+			line:        `{"foo": "bar"}`,
+			expectedErr: ErrUnrecognizedLine,
+		},
+		"unsigned envelope": {
+			line:        `{"payloadType": "application/vnd.in-toto+json", "payload": "e30=", "signatures": []}`,
+			expectedErr: ErrInvalidEnvelope,
+		},
+		"statement without subject": {
+			line:        `{"_type": "https://in-toto.io/Statement/v1", "predicateType": "https://example.com/pred", "predicate": {}}`,
+			expectedErr: ErrInvalidStatement,
+		},
+		"statement with bad field": {
+			line:        `{"_type": "https://in-toto.io/Statement/v1", "subject": "foo"}`,
+			expectedErr: ErrInvalidStatement,
+		},
+	}
+
+	for name, test := range tests {
+		e := parseLine(7, []byte(test.line))
+		assert.Equal(t, 7, e.Line, fmt.Sprintf("wrong line number in test '%s'", name))
+		assert.Equal(t, test.line, string(e.Raw), fmt.Sprintf("wrong raw line in test '%s'", name))
+		assert.Equal(t, test.expectedEnvelope, e.Envelope != nil, fmt.Sprintf("wrong envelope in test '%s'", name))
+		assert.Equal(t, test.expectedStatement, e.Statement != nil, fmt.Sprintf("wrong statement in test '%s'", name))
+		if test.expectedErr == nil {
+			assert.NoError(t, e.Err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, e.Err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+}
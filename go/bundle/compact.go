@@ -0,0 +1,425 @@
+/*
+Compaction of long-lived Bundles, dropping duplicate attestations and
+attestations superseded by later ones of the same predicate.
+*/
+
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/predicates"
+	svrv01 "github.com/in-toto/attestation/go/predicates/svr/v01"
+	vsav0 "github.com/in-toto/attestation/go/predicates/vsa/v0"
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	vulnsv01 "github.com/in-toto/attestation/go/predicates/vulns/v01"
+	vulnsv02 "github.com/in-toto/attestation/go/predicates/vulns/v02"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrRulePredicateTypeRequired = errors.New("rule predicateType required")
+	ErrRuleKeyRequired           = errors.New("rule key function required")
+	ErrPredicateFieldRequired    = errors.New("predicate field required for supersession")
+)
+
+// DropReason explains why compaction dropped a line.
+type DropReason string
+
+const (
+	// DropDuplicate is an envelope with the same payload as an earlier one.
+	// Its signatures are merged into the kept copy.
+	DropDuplicate DropReason = "duplicate"
+	// DropSuperseded is an attestation superseded by a later one.
+	DropSuperseded DropReason = "superseded"
+	// DropManifest is a Bundle manifest, which no longer matches the
+	// compacted Bundle and must be re-issued.
+	DropManifest DropReason = "manifest"
+	// DropNotEnvelope is an unsigned Statement or an unrecognized line.
+	DropNotEnvelope DropReason = "not an envelope"
+)
+
+// Rule is a supersession rule for a predicate.
+type Rule struct {
+	// PredicateType selects the attestations the rule applies to, as
+	// defined by predicates.MatchesType.
+	PredicateType string
+	// Key returns the key grouping the attestations that supersede one
+	// another, and the attestation's time. Attestations are only grouped
+	// with attestations of the same predicate signed by the same
+	// attesters. The latest attestations of a group supersede the others.
+	// Attestations with a zero time, and those for which Key fails, are
+	// kept.
+	Key func(s *ita1.Statement) (string, time.Time, error)
+}
+
+// Dropped is a line dropped by compaction.
+type Dropped struct {
+	// Line is the line number in the Bundle being compacted.
+	Line int
+	// Digest is the hex-encoded AttestationDigest of an envelope.
+	Digest        string
+	PredicateType string
+	Reason        DropReason
+	// By is the line of the envelope that duplicates or supersedes this
+	// one, if any.
+	By int
+	// Detail explains the reason, e.g. the times that were compared.
+	Detail string
+}
+
+// CompactionReport describes the outcome of compaction.
+type CompactionReport struct {
+	// Kept are the line numbers of the envelopes that were written, in
+	// order.
+	Kept    []int
+	Dropped []Dropped
+}
+
+// Compactor drops duplicate and superseded attestations from Bundles. Only
+// attestations signed by a recognized attester supersede, or are
+// superseded by, other attestations: unauthenticated attestations are
+// never dropped for being superseded.
+type Compactor struct {
+	attesters []dsse.Attester
+	rules     []Rule
+}
+
+// NewCompactor returns a Compactor that authenticates attestations
+// against the recognized attesters and applies the DefaultRules.
+func NewCompactor(attesters ...dsse.Attester) (*Compactor, error) {
+	if len(attesters) == 0 {
+		return nil, dsse.ErrAttesterRequired
+	}
+
+	for _, a := range attesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Compactor{attesters: attesters, rules: DefaultRules()}, nil
+}
+
+// AddRule adds a supersession rule, which takes precedence over the rules
+// already added for matching predicate types.
+func (c *Compactor) AddRule(rule Rule) error {
+	if rule.PredicateType == "" {
+		return ErrRulePredicateTypeRequired
+	}
+
+	if rule.Key == nil {
+		return ErrRuleKeyRequired
+	}
+
+	c.rules = append([]Rule{rule}, c.rules...)
+
+	return nil
+}
+
+func (c *Compactor) rule(predicateType string) (Rule, bool) {
+	for _, r := range c.rules {
+		if predicates.MatchesType(r.PredicateType, predicateType) {
+			return r, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// Compact compacts a Bundle with the DefaultRules.
+func Compact(ctx context.Context, w *Writer, r *Reader, attesters ...dsse.Attester) (*CompactionReport, error) {
+	c, err := NewCompactor(attesters...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Compact(ctx, w, r)
+}
+
+// compactEntry is a distinct envelope of the Bundle being compacted.
+type compactEntry struct {
+	line          int
+	digest        string
+	predicateType string
+	group         string
+	time          time.Time
+	dropped       bool
+}
+
+// Compact reads a whole Bundle and writes the envelopes that are neither
+// duplicated nor superseded, in the order they first appear. Copies of an
+// envelope are merged into the first, with the union of their
+// signatures.
+func (c *Compactor) Compact(ctx context.Context, w *Writer, r *Reader) (*CompactionReport, error) {
+	report := &CompactionReport{Kept: []int{}, Dropped: []Dropped{}}
+	set := NewSet()
+	entries := []*compactEntry{}
+	first := map[string]int{}
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Envelope == nil {
+			detail := "unsigned Statement"
+			if e.Err != nil {
+				detail = e.Err.Error()
+			}
+			report.Dropped = append(report.Dropped, Dropped{Line: e.Line, Reason: DropNotEnvelope, Detail: detail})
+			continue
+		}
+
+		digest := AttestationDigest(e.Envelope)
+		if _, err := set.Add(e.Envelope); err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.Line, err)
+		}
+
+		if line, dup := first[digest]; dup {
+			report.Dropped = append(report.Dropped, Dropped{Line: e.Line, Digest: digest, Reason: DropDuplicate, By: line})
+			continue
+		}
+		first[digest] = e.Line
+		entries = append(entries, &compactEntry{line: e.Line, digest: digest})
+	}
+
+	groups := map[string][]*compactEntry{}
+	predicateTypes := map[string]string{}
+	for _, entry := range entries {
+		env := set.attestations[entry.digest].envelope()
+		if err := c.classify(ctx, entry, env); err != nil {
+			return nil, err
+		}
+		predicateTypes[entry.digest] = entry.predicateType
+
+		if entry.predicateType != "" && predicates.MatchesType(ManifestPredicateType, entry.predicateType) {
+			entry.dropped = true
+			report.Dropped = append(report.Dropped, Dropped{Line: entry.line, Digest: entry.digest, PredicateType: entry.predicateType, Reason: DropManifest})
+		} else if entry.group != "" {
+			groups[entry.group] = append(groups[entry.group], entry)
+		}
+	}
+
+	for i, d := range report.Dropped {
+		if d.Reason == DropDuplicate {
+			report.Dropped[i].PredicateType = predicateTypes[d.Digest]
+		}
+	}
+
+	for _, group := range groups {
+		report.Dropped = append(report.Dropped, supersede(group)...)
+	}
+
+	for _, entry := range entries {
+		if entry.dropped {
+			continue
+		}
+
+		if err := w.WriteEnvelope(set.attestations[entry.digest].envelope()); err != nil {
+			return nil, err
+		}
+		report.Kept = append(report.Kept, entry.line)
+	}
+
+	sort.Slice(report.Dropped, func(i, j int) bool {
+		return report.Dropped[i].Line < report.Dropped[j].Line
+	})
+
+	return report, nil
+}
+
+// classify sets the predicateType of an entry and, if a rule applies and
+// the envelope is signed by a recognized attester, its group and time.
+func (c *Compactor) classify(ctx context.Context, entry *compactEntry, env *dsse.Envelope) error {
+	if !dsse.IsInTotoPayloadType(env.PayloadType) {
+		return nil
+	}
+
+	s, err := env.Statement()
+	if err != nil {
+		return nil
+	}
+	entry.predicateType = s.GetPredicateType()
+
+	rule, ok := c.rule(entry.predicateType)
+	if !ok {
+		return nil
+	}
+
+	key, t, err := rule.Key(s)
+	if err != nil {
+		return nil
+	}
+
+	names, err := env.AttesterNames(ctx, c.attesters...)
+	if errors.Is(err, dsse.ErrNoRecognizedAttester) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("line %d: %w", entry.line, err)
+	}
+	sort.Strings(names)
+
+	predicate := entry.predicateType
+	if spec, ok := predicates.SpecForPredicateType(predicate); ok {
+		predicate = spec.Name
+	}
+
+	entry.group = strings.Join([]string{predicate, strings.Join(names, ","), key}, "\n")
+	entry.time = t
+
+	return nil
+}
+
+// supersede drops the entries of a group that are older than the latest.
+// Entries without a time are kept, as nothing orders them but their lines,
+// on which the processing of a Bundle must not depend.
+func supersede(group []*compactEntry) []Dropped {
+	var latest *compactEntry
+	for _, entry := range group {
+		if !entry.time.IsZero() && (latest == nil || !entry.time.Before(latest.time)) {
+			latest = entry
+		}
+	}
+
+	dropped := []Dropped{}
+	if latest == nil {
+		return dropped
+	}
+
+	for _, entry := range group {
+		if entry.time.IsZero() || !entry.time.Before(latest.time) {
+			continue
+		}
+
+		entry.dropped = true
+		dropped = append(dropped, Dropped{
+			Line:          entry.line,
+			Digest:        entry.digest,
+			PredicateType: entry.predicateType,
+			Reason:        DropSuperseded,
+			By:            latest.line,
+			Detail:        fmt.Sprintf("%s is older than %s", entry.time.Format(time.RFC3339), latest.time.Format(time.RFC3339)),
+		})
+	}
+
+	return dropped
+}
+
+// DefaultRules returns the supersession rules of the vetted predicates
+// that describe a point in time:
+//   - VSAs by the same verifier about the same subjects and resource are
+//     superseded by the latest timeVerified.
+//   - SVRs by the same verifier about the same subjects are superseded by
+//     the latest timeCreated.
+//   - Vulnerability scans by the same scanner of the same subjects are
+//     superseded by the latest scanFinishedOn.
+//
+// Releases are never superseded: the release predicate records no time, and
+// the order of a Bundle's lines cannot tell which release of a purl is the
+// latest.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			PredicateType: vsav1.PredicateTypeUri + vsav1.PredicateVersion,
+			Key: func(s *ita1.Statement) (string, time.Time, error) {
+				p := &vsav1.VerificationSummary{}
+				if err := decodePredicate(s, p); err != nil {
+					return "", time.Time{}, err
+				}
+				t, err := requireTime(p.GetTimeVerified(), "timeVerified")
+				return subjectKey(s, p.GetVerifier().GetId(), p.GetResourceUri()), t, err
+			},
+		},
+		{
+			PredicateType: vsav0.PredicateTypeUri + vsav0.PredicateVersion,
+			Key: func(s *ita1.Statement) (string, time.Time, error) {
+				p := &vsav0.VerificationSummary{}
+				if err := decodePredicate(s, p); err != nil {
+					return "", time.Time{}, err
+				}
+				t, err := requireTime(p.GetTimeVerified(), "time_verified")
+				return subjectKey(s, p.GetVerifier().GetId(), p.GetResourceUri()), t, err
+			},
+		},
+		{
+			PredicateType: svrv01.PredicateTypeUri + svrv01.PredicateVersion,
+			Key: func(s *ita1.Statement) (string, time.Time, error) {
+				p := &svrv01.SimpleVerificationResult{}
+				if err := decodePredicate(s, p); err != nil {
+					return "", time.Time{}, err
+				}
+				t, err := requireTime(p.GetTimeCreated(), "timeCreated")
+				return subjectKey(s, p.GetVerifier().GetId()), t, err
+			},
+		},
+		{
+			PredicateType: vulnsv02.PredicateTypeUri + vulnsv02.PredicateVersion,
+			Key: func(s *ita1.Statement) (string, time.Time, error) {
+				p := &vulnsv02.Vulns{}
+				if err := decodePredicate(s, p); err != nil {
+					return "", time.Time{}, err
+				}
+				t, err := requireTime(p.GetMetadata().GetScanFinishedOn(), "metadata.scanFinishedOn")
+				return subjectKey(s, p.GetScanner().GetUri()), t, err
+			},
+		},
+		{
+			PredicateType: vulnsv01.PredicateTypeUri + vulnsv01.PredicateVersion,
+			Key: func(s *ita1.Statement) (string, time.Time, error) {
+				p := &vulnsv01.Vulns{}
+				if err := decodePredicate(s, p); err != nil {
+					return "", time.Time{}, err
+				}
+				t, err := requireTime(p.GetScanMetadata().GetScanFinishedOn(), "scanMetadata.scanFinishedOn")
+				return subjectKey(s, p.GetScanner().GetUri()), t, err
+			},
+		},
+	}
+}
+
+// decodePredicate decodes the predicate of a Statement into a predicate
+// proto, ignoring unknown fields.
+func decodePredicate(s *ita1.Statement, p proto.Message) error {
+	data, err := protojson.Marshal(s.GetPredicate())
+	if err != nil {
+		return err
+	}
+
+	return (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, p)
+}
+
+// subjectKey returns a key identifying the set of subject digests of a
+// Statement, followed by additional fields.
+func subjectKey(s *ita1.Statement, fields ...string) string {
+	var digests []string
+	for _, rd := range s.GetSubject() {
+		for alg, value := range rd.GetDigest() {
+			digests = append(digests, digestKey(alg, value))
+		}
+	}
+	sort.Strings(digests)
+
+	return strings.Join(append([]string{strings.Join(digests, ",")}, fields...), "\n")
+}
+
+func requireTime(ts *timestamppb.Timestamp, field string) (time.Time, error) {
+	if ts == nil {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrPredicateFieldRequired, field)
+	}
+
+	return ts.AsTime(), nil
+}
@@ -0,0 +1,343 @@
+/*
+Tests for Bundle compaction.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	testReleaseType = "https://in-toto.io/attestation/release/v0.2"
+	testSVRType     = "https://in-toto.io/attestation/svr/v0.1"
+	testVulnsType   = "https://in-toto.io/attestation/vulns/v0.2"
+	testVerifierID  = "https://verifier.example.com"
+)
+
+// createTestPredicateStatement returns a Statement about the named subject
+// with the given predicate.
+func createTestPredicateStatement(t *testing.T, subject, predicateType string, predicate map[string]interface{}) *ita1.Statement {
+	t.Helper()
+
+	s := createTestStatement(t, subject, predicateType)
+	pred, err := structpb.NewStruct(predicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Predicate = pred
+
+	return s
+}
+
+func vsaPredicate(verifierID, timeVerified, result string) map[string]interface{} {
+	p := map[string]interface{}{
+		"verifier":           map[string]interface{}{"id": verifierID},
+		"resourceUri":        "https://example.com/fooly.apk",
+		"policy":             map[string]interface{}{"uri": "https://example.com/policy"},
+		"verificationResult": result,
+	}
+	if timeVerified != "" {
+		p["timeVerified"] = timeVerified
+	}
+
+	return p
+}
+
+type testCompaction struct {
+	builder, verifier, releaser signature.SignerVerifier
+	bundle                      *bytes.Buffer
+}
+
+func (c *testCompaction) attesters() []dsse.Attester {
+	return []dsse.Attester{
+		{Name: "builder", Verifier: c.builder},
+		{Name: "verifier", Verifier: c.verifier},
+		{Name: "releaser", Verifier: c.releaser},
+	}
+}
+
+// createTestCompaction writes a long-lived Bundle, with one line per line
+// number below:
+//
+//  1. VSA v1 for fooly.apk, verified on 2024-01-01
+//  2. provenance for fooly.apk by the builder
+//  3. VSA v1 for fooly.apk, verified on 2024-03-01
+//  4. VSA v0.2 for fooly.apk, verified on 2024-02-01
+//  5. VSA v1 for other.apk, verified on 2024-01-01
+//  6. VSA v1 for fooly.apk, verified on 2024-04-01 by an unknown key
+//  7. release of pkg:npm/fooly@1.0.0 with fooly.apk by the releaser
+//  8. provenance of line 2, co-signed by the verifier
+//  9. release of pkg:npm/fooly@1.0.0 with other.apk by the releaser
+//  10. an unsigned Statement
+//  11. VSA v1 for fooly.apk by another verifier ID, verified on 2023-01-01
+//  12. a manifest signed by the releaser
+//  13. failed VSA v1 for fooly.apk, verified on 2024-03-01
+//  14. vulnerability scan of fooly.apk finished on 2024-01-01
+//  15. vulnerability scan of fooly.apk finished on 2024-02-01
+//  16. SVR for fooly.apk created on 2024-02-01
+//  17. SVR for fooly.apk created on 2024-01-01
+//  18. VSA v1 for fooly.apk without timeVerified
+//  19. an unrecognized line
+//
+// VSAs, scans and SVRs are signed by the verifier unless noted.
+func createTestCompaction(t *testing.T) *testCompaction {
+	t.Helper()
+
+	c := &testCompaction{
+		builder:  testutil.NewSigner(t),
+		verifier: testutil.NewSigner(t),
+		releaser: testutil.NewSigner(t),
+		bundle:   &bytes.Buffer{},
+	}
+	unknown := testutil.NewSigner(t)
+
+	vsa := func(subject, predicateType, verifierID, timeVerified, result string, signer signature.Signer) *dsse.Envelope {
+		p := vsaPredicate(verifierID, timeVerified, result)
+		if predicateType == testVSAV02Type && timeVerified != "" {
+			delete(p, "timeVerified")
+			p["time_verified"] = timeVerified
+		}
+		if predicateType == testVSAV02Type {
+			p["resource_uri"] = p["resourceUri"]
+			delete(p, "resourceUri")
+		}
+		return createTestEnvelope(t, createTestPredicateStatement(t, subject, predicateType, p), signer)
+	}
+	release := func(subject string) *dsse.Envelope {
+		p := map[string]interface{}{"purl": "pkg:npm/fooly@1.0.0"}
+		return createTestEnvelope(t, createTestPredicateStatement(t, subject, testReleaseType, p), c.releaser)
+	}
+	scan := func(finishedOn string) *dsse.Envelope {
+		p := map[string]interface{}{
+			"scanner":  map[string]interface{}{"uri": "pkg:github/example/scanner@v1"},
+			"metadata": map[string]interface{}{"scanStartedOn": "2023-12-31T00:00:00Z", "scanFinishedOn": finishedOn},
+		}
+		return createTestEnvelope(t, createTestPredicateStatement(t, "fooly.apk", testVulnsType, p), c.verifier)
+	}
+	svr := func(timeCreated string) *dsse.Envelope {
+		p := map[string]interface{}{"verifier": map[string]interface{}{"id": testVerifierID}, "timeCreated": timeCreated}
+		return createTestEnvelope(t, createTestPredicateStatement(t, "fooly.apk", testSVRType, p), c.verifier)
+	}
+
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), c.builder)
+	first := []*dsse.Envelope{
+		vsa("fooly.apk", testVSAV1Type, testVerifierID, "2024-01-01T00:00:00Z", "PASSED", c.verifier),
+		prov,
+		vsa("fooly.apk", testVSAV1Type, testVerifierID, "2024-03-01T00:00:00Z", "PASSED", c.verifier),
+		vsa("fooly.apk", testVSAV02Type, testVerifierID, "2024-02-01T00:00:00Z", "PASSED", c.verifier),
+		vsa("other.apk", testVSAV1Type, testVerifierID, "2024-01-01T00:00:00Z", "PASSED", c.verifier),
+		vsa("fooly.apk", testVSAV1Type, testVerifierID, "2024-04-01T00:00:00Z", "PASSED", unknown),
+		release("fooly.apk"),
+		cosign(t, prov, c.verifier),
+		release("other.apk"),
+	}
+
+	manifest, err := NewManifest(first, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWriter(c.bundle)
+	for _, env := range first {
+		assert.NoError(t, w.WriteEnvelope(env))
+	}
+	assert.NoError(t, w.WriteStatement(createTestStatement(t, "fooly.apk", testProvenanceType)))
+	for _, env := range []*dsse.Envelope{
+		vsa("fooly.apk", testVSAV1Type, "https://other-verifier.example.com", "2023-01-01T00:00:00Z", "PASSED", c.verifier),
+		createTestEnvelope(t, manifest, c.releaser),
+		vsa("fooly.apk", testVSAV1Type, testVerifierID, "2024-03-01T00:00:00Z", "FAILED", c.verifier),
+		scan("2024-01-01T00:00:00Z"),
+		scan("2024-02-01T00:00:00Z"),
+		svr("2024-02-01T00:00:00Z"),
+		svr("2024-01-01T00:00:00Z"),
+		vsa("fooly.apk", testVSAV1Type, testVerifierID, "", "PASSED", c.verifier),
+	} {
+		assert.NoError(t, w.WriteEnvelope(env))
+	}
+	c.bundle.WriteString("not an attestation\n")
+
+	return c
+}
+
+type testDrop struct {
+	reason DropReason
+	by     int
+}
+
+func drops(report *CompactionReport) map[int]testDrop {
+	out := map[int]testDrop{}
+	for _, d := range report.Dropped {
+		out[d.Line] = testDrop{reason: d.Reason, by: d.By}
+	}
+
+	return out
+}
+
+func TestCompact(t *testing.T) {
+	c := createTestCompaction(t)
+
+	var out bytes.Buffer
+	report, err := Compact(context.Background(), NewWriter(&out), NewReader(c.bundle), c.attesters()...)
+	assert.NoError(t, err)
+
+	// both releases are kept, as nothing but their lines orders them
+	assert.Equal(t, []int{2, 3, 5, 6, 7, 9, 11, 13, 15, 16, 18}, report.Kept)
+	assert.Equal(t, map[int]testDrop{
+		1:  {reason: DropSuperseded, by: 13},
+		4:  {reason: DropSuperseded, by: 13},
+		8:  {reason: DropDuplicate, by: 2},
+		10: {reason: DropNotEnvelope},
+		12: {reason: DropManifest},
+		14: {reason: DropSuperseded, by: 15},
+		17: {reason: DropSuperseded, by: 16},
+		19: {reason: DropNotEnvelope},
+	}, drops(report))
+
+	for _, d := range report.Dropped {
+		switch d.Line {
+		case 1:
+			assert.Equal(t, testVSAV1Type, d.PredicateType)
+			assert.Equal(t, "2024-01-01T00:00:00Z is older than 2024-03-01T00:00:00Z", d.Detail)
+		case 8:
+			assert.Equal(t, testProvenanceType, d.PredicateType)
+		case 19:
+			assert.Contains(t, d.Detail, ErrUnrecognizedLine.Error())
+		}
+	}
+
+	entries := readAll(t, NewReader(&out))
+	assert.Len(t, entries, len(report.Kept))
+
+	// the duplicate's signature is merged into the kept provenance
+	matched, err := entries[0].Envelope.Verify(context.Background(), c.builder, c.verifier)
+	assert.NoError(t, err)
+	assert.Len(t, matched, 2)
+}
+
+func TestCompactIsStable(t *testing.T) {
+	c := createTestCompaction(t)
+
+	var once, twice bytes.Buffer
+	_, err := Compact(context.Background(), NewWriter(&once), NewReader(c.bundle), c.attesters()...)
+	assert.NoError(t, err)
+
+	compacted := bytes.Clone(once.Bytes())
+	report, err := Compact(context.Background(), NewWriter(&twice), NewReader(&once), c.attesters()...)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Dropped)
+	assert.Equal(t, compacted, twice.Bytes())
+}
+
+func TestCompactOnlyTrustsRecognizedAttesters(t *testing.T) {
+	c := createTestCompaction(t)
+
+	// without the verifier, no VSA, scan or SVR is authenticated
+	var out bytes.Buffer
+	report, err := Compact(context.Background(), NewWriter(&out), NewReader(c.bundle),
+		dsse.Attester{Name: "builder", Verifier: c.builder},
+		dsse.Attester{Name: "releaser", Verifier: c.releaser},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]testDrop{
+		8:  {reason: DropDuplicate, by: 2},
+		10: {reason: DropNotEnvelope},
+		12: {reason: DropManifest},
+		19: {reason: DropNotEnvelope},
+	}, drops(report))
+}
+
+func TestCompactorAddRule(t *testing.T) {
+	c := createTestCompaction(t)
+	compactor, err := NewCompactor(c.attesters()...)
+	assert.NoError(t, err)
+
+	// the latest VSA for a subject wins, whoever verified it
+	assert.NoError(t, compactor.AddRule(Rule{
+		PredicateType: testVSAV1Type,
+		Key: func(s *ita1.Statement) (string, time.Time, error) {
+			p := &vsav1.VerificationSummary{}
+			if err := decodePredicate(s, p); err != nil {
+				return "", time.Time{}, err
+			}
+			t, err := requireTime(p.GetTimeVerified(), "timeVerified")
+			return subjectKey(s), t, err
+		},
+	}))
+
+	var out bytes.Buffer
+	report, err := compactor.Compact(context.Background(), NewWriter(&out), NewReader(c.bundle))
+	assert.NoError(t, err)
+
+	dropped := drops(report)
+	for _, line := range []int{1, 11} {
+		assert.Equal(t, testDrop{reason: DropSuperseded, by: 13}, dropped[line], fmt.Sprintf("line %d", line))
+	}
+	for _, line := range []int{3, 4, 5, 6, 13, 18} {
+		assert.NotContains(t, dropped, line)
+	}
+}
+
+func TestCompactorRuleWithoutTime(t *testing.T) {
+	c := createTestCompaction(t)
+	compactor, err := NewCompactor(c.attesters()...)
+	assert.NoError(t, err)
+
+	// without a time, nothing but the Bundle order tells the VSAs apart
+	assert.NoError(t, compactor.AddRule(Rule{
+		PredicateType: testVSAV1Type,
+		Key: func(s *ita1.Statement) (string, time.Time, error) {
+			return subjectKey(s), time.Time{}, nil
+		},
+	}))
+
+	var out bytes.Buffer
+	report, err := compactor.Compact(context.Background(), NewWriter(&out), NewReader(c.bundle))
+	assert.NoError(t, err)
+
+	dropped := drops(report)
+	for _, line := range []int{1, 3, 5, 6, 11, 13, 18} {
+		assert.NotContains(t, dropped, line)
+	}
+}
+
+func TestCompactorErrors(t *testing.T) {
+	_, err := NewCompactor()
+	assert.ErrorIs(t, err, dsse.ErrAttesterRequired)
+
+	_, err = Compact(context.Background(), NewWriter(&bytes.Buffer{}), NewReader(&bytes.Buffer{}), dsse.Attester{Name: "nobody"})
+	assert.ErrorIs(t, err, dsse.ErrVerifierRequired)
+
+	compactor, err := NewCompactor(dsse.Attester{Name: "builder", Verifier: testutil.NewSigner(t)})
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		rule        Rule
+		expectedErr error
+	}{
+		"missing predicateType": {
+			rule:        Rule{Key: func(*ita1.Statement) (string, time.Time, error) { return "", time.Time{}, nil }},
+			expectedErr: ErrRulePredicateTypeRequired,
+		},
+		"missing key": {
+			rule:        Rule{PredicateType: testProvenanceType},
+			expectedErr: ErrRuleKeyRequired,
+		},
+	}
+
+	for name, test := range tests {
+		assert.ErrorIs(t, compactor.AddRule(test.rule), test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
@@ -0,0 +1,270 @@
+/*
+In-memory index over the attestations of a Bundle, for picking out the
+attestations relevant to an artifact.
+*/
+
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/predicates"
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+// Attestation is an indexed Bundle entry.
+type Attestation struct {
+	Entry     *Entry
+	Statement *ita1.Statement
+	// Attesters are the names of the recognized attesters that signed the
+	// envelope. It is empty for unsigned Statements and for envelopes
+	// signed by no recognized attester, which are not authenticated.
+	Attesters []string
+}
+
+// Query selects attestations from an Index. Empty fields match every
+// attestation.
+type Query struct {
+	// Digest matches attestations with a subject that has at least one of
+	// the digests in the set.
+	Digest map[string]string
+	// PredicateType matches attestations whose predicateType satisfies it,
+	// as defined by predicates.MatchesType.
+	PredicateType string
+	// Attester matches attestations signed by the named attester.
+	Attester string
+}
+
+// Index is an in-memory index over the attestations of a Bundle, keyed by
+// subject digest, predicateType and attester. It is not safe for
+// concurrent modification.
+type Index struct {
+	attesters    []dsse.Attester
+	attestations []*Attestation
+	skipped      []*Entry
+
+	byDigest        map[string][]int
+	byPredicateType map[string][]int
+	byAttester      map[string][]int
+}
+
+// NewIndex returns an empty Index that verifies envelopes against the
+// given recognized attesters. Without attesters, no attestation is
+// authenticated.
+func NewIndex(attesters ...dsse.Attester) (*Index, error) {
+	for _, a := range attesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Index{
+		attesters:       attesters,
+		byDigest:        map[string][]int{},
+		byPredicateType: map[string][]int{},
+		byAttester:      map[string][]int{},
+	}, nil
+}
+
+// BuildIndex reads a whole Bundle into a new Index.
+func BuildIndex(ctx context.Context, r *Reader, attesters ...dsse.Attester) (*Index, error) {
+	x, err := NewIndex(attesters...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := x.ReadFrom(ctx, r); err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+// ReadFrom adds every entry of a Bundle to the index.
+func (x *Index) ReadFrom(ctx context.Context, r *Reader) error {
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := x.Add(ctx, e); err != nil {
+			return err
+		}
+	}
+}
+
+// Add indexes a Bundle entry. Entries that do not hold an in-toto
+// Statement, such as unrecognized lines and envelopes with other payload
+// types, are set aside and available from Skipped.
+func (x *Index) Add(ctx context.Context, e *Entry) error {
+	a := &Attestation{Entry: e, Statement: e.Statement}
+	if e.Envelope != nil {
+		if !dsse.IsInTotoPayloadType(e.Envelope.PayloadType) {
+			x.skipped = append(x.skipped, e)
+			return nil
+		}
+
+		s, err := e.Envelope.Statement()
+		if err != nil {
+			x.skipped = append(x.skipped, e)
+			return nil
+		}
+		a.Statement = s
+
+		if len(x.attesters) > 0 {
+			names, err := e.Envelope.AttesterNames(ctx, x.attesters...)
+			if err != nil && !errors.Is(err, dsse.ErrNoRecognizedAttester) {
+				return fmt.Errorf("line %d: %w", e.Line, err)
+			}
+			a.Attesters = names
+		}
+	}
+
+	if a.Statement == nil {
+		x.skipped = append(x.skipped, e)
+		return nil
+	}
+
+	i := len(x.attestations)
+	x.attestations = append(x.attestations, a)
+
+	seen := map[string]bool{}
+	for _, rd := range a.Statement.GetSubject() {
+		for alg, value := range rd.GetDigest() {
+			key := digestKey(alg, value)
+			if !seen[key] {
+				x.byDigest[key] = append(x.byDigest[key], i)
+				seen[key] = true
+			}
+		}
+	}
+
+	pt := a.Statement.GetPredicateType()
+	x.byPredicateType[pt] = append(x.byPredicateType[pt], i)
+	for _, name := range a.Attesters {
+		x.byAttester[name] = append(x.byAttester[name], i)
+	}
+
+	return nil
+}
+
+// Len returns the number of indexed attestations.
+func (x *Index) Len() int {
+	return len(x.attestations)
+}
+
+// Attestations returns all indexed attestations in Bundle order.
+func (x *Index) Attestations() []*Attestation {
+	out := make([]*Attestation, len(x.attestations))
+	copy(out, x.attestations)
+
+	return out
+}
+
+// Skipped returns the entries that were not indexed, in Bundle order.
+func (x *Index) Skipped() []*Entry {
+	out := make([]*Entry, len(x.skipped))
+	copy(out, x.skipped)
+
+	return out
+}
+
+// Query returns the attestations matching all of the query's fields, in
+// Bundle order.
+func (x *Index) Query(q Query) []*Attestation {
+	var sets [][]int
+	if len(q.Digest) > 0 {
+		var ids []int
+		for alg, value := range q.Digest {
+			ids = union(ids, x.byDigest[digestKey(alg, value)])
+		}
+		sets = append(sets, ids)
+	}
+
+	if q.PredicateType != "" {
+		var ids []int
+		for pt, ptIDs := range x.byPredicateType {
+			if predicates.MatchesType(q.PredicateType, pt) {
+				ids = union(ids, ptIDs)
+			}
+		}
+		sets = append(sets, ids)
+	}
+
+	if q.Attester != "" {
+		sets = append(sets, x.byAttester[q.Attester])
+	}
+
+	if len(sets) == 0 {
+		return x.Attestations()
+	}
+
+	ids := sets[0]
+	for _, s := range sets[1:] {
+		ids = intersect(ids, s)
+	}
+
+	out := make([]*Attestation, 0, len(ids))
+	for _, i := range ids {
+		out = append(out, x.attestations[i])
+	}
+
+	return out
+}
+
+// digestKey is the index key of a digest. Digests of hex-encoded algorithms
+// are compared case-insensitively, other encodings exactly.
+func digestKey(alg, value string) string {
+	if ita1.HashAlgorithm(alg).Encoding() == ita1.EncodingHex {
+		value = strings.ToLower(value)
+	}
+
+	return alg + ":" + value
+}
+
+// union merges two sorted lists of attestation indices.
+func union(a, b []int) []int {
+	out := make([]int, 0, len(a)+len(b))
+	out = append(out, a...)
+	out = append(out, b...)
+	sort.Ints(out)
+
+	n := 0
+	for i, v := range out {
+		if i == 0 || v != out[n-1] {
+			out[n] = v
+			n++
+		}
+	}
+
+	return out[:n]
+}
+
+// intersect returns the indices in both sorted lists.
+func intersect(a, b []int) []int {
+	out := []int{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+
+	return out
+}
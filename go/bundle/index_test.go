@@ -0,0 +1,228 @@
+/*
+Tests for the Bundle index.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testProvenanceType = "https://slsa.dev/provenance/v1"
+	testVSAV1Type      = "https://slsa.dev/verification_summary/v1"
+	testVSAV02Type     = "https://slsa.dev/verification_summary/v0.2"
+)
+
+func testDigest(subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(sum[:])
+}
+
+// createTestIndex indexes a Bundle like the one in spec/v1/bundle.md, with
+// one line per line number below:
+//
+//  1. provenance for fooly.apk by the builder
+//  2. VSA v1 for fooly.apk by the verifier
+//  3. VSA v1.1 for fooly.apk by an unknown key
+//  4. VSA v0.2 for fooly.apk by the verifier
+//  5. VSA v1 for other.apk by the verifier
+//  6. unsigned VSA v1 for fooly.apk
+//  7. a non in-toto envelope
+//  8. an unrecognized line
+//  9. provenance for fooly.apk by the builder and the verifier
+func createTestIndex(t *testing.T) *Index {
+	t.Helper()
+
+	builder := testutil.NewSigner(t)
+	verifier := testutil.NewSigner(t)
+	unknown := testutil.NewSigner(t)
+
+	novulz := &dsse.Envelope{PayloadType: "application/vnd.novulz+cbor", Payload: []byte{0xa0}}
+	assert.NoError(t, novulz.Sign(context.Background(), verifier))
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), builder)))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV1Type), verifier)))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV1Type+".1"), unknown)))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV02Type), verifier)))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "other.apk", testVSAV1Type), verifier)))
+	assert.NoError(t, w.WriteStatement(createTestStatement(t, "fooly.apk", testVSAV1Type)))
+	assert.NoError(t, w.WriteEnvelope(novulz))
+	buf.WriteString("not an attestation\n")
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), builder, verifier)))
+
+	x, err := BuildIndex(context.Background(), NewReader(&buf),
+		dsse.Attester{Name: "builder", Verifier: builder},
+		dsse.Attester{Name: "verifier", Verifier: verifier},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return x
+}
+
+func lines(attestations []*Attestation) []int {
+	out := []int{}
+	for _, a := range attestations {
+		out = append(out, a.Entry.Line)
+	}
+
+	return out
+}
+
+func TestIndexQuery(t *testing.T) {
+	x := createTestIndex(t)
+	fooly := testDigest("fooly.apk")
+
+	tests := map[string]struct {
+		query         Query
+		expectedLines []int
+	}{
+		"everything": {
+			query:         Query{},
+			expectedLines: []int{1, 2, 3, 4, 5, 6, 9},
+		},
+		"by digest": {
+			query:         Query{Digest: map[string]string{"sha256": fooly}},
+			expectedLines: []int{1, 2, 3, 4, 6, 9},
+		},
+		"by uppercase digest": {
+			query:         Query{Digest: map[string]string{"sha256": strings.ToUpper(fooly)}},
+			expectedLines: []int{1, 2, 3, 4, 6, 9},
+		},
+		"by any digest in the set": {
+			query:         Query{Digest: map[string]string{"sha256": testDigest("other.apk"), "sha512": fooly}},
+			expectedLines: []int{5},
+		},
+		"by unknown digest": {
+			query:         Query{Digest: map[string]string{"sha256": testDigest("nothing")}},
+			expectedLines: []int{},
+		},
+		"by major version": {
+			query:         Query{PredicateType: testVSAV1Type},
+			expectedLines: []int{2, 3, 5, 6},
+		},
+		"by 0.x version": {
+			query:         Query{PredicateType: testVSAV02Type},
+			expectedLines: []int{4},
+		},
+		"by any version": {
+			query:         Query{PredicateType: "https://slsa.dev/verification_summary"},
+			expectedLines: []int{2, 3, 4, 5, 6},
+		},
+		"by attester": {
+			query:         Query{Attester: "builder"},
+			expectedLines: []int{1, 9},
+		},
+		"by unknown attester": {
+			query:         Query{Attester: "mallory"},
+			expectedLines: []int{},
+		},
+		"VSAs about fooly.apk signed by verifier": {
+			query: Query{
+				Digest:        map[string]string{"sha256": fooly},
+				PredicateType: testVSAV1Type,
+				Attester:      "verifier",
+			},
+			expectedLines: []int{2},
+		},
+		"provenance signed by verifier": {
+			query:         Query{PredicateType: testProvenanceType, Attester: "verifier"},
+			expectedLines: []int{9},
+		},
+	}
+
+	for name, test := range tests {
+		got := x.Query(test.query)
+		assert.Equal(t, test.expectedLines, lines(got), fmt.Sprintf("wrong attestations in test '%s'", name))
+	}
+}
+
+func TestIndexContents(t *testing.T) {
+	x := createTestIndex(t)
+	assert.Equal(t, 7, x.Len())
+
+	skipped := x.Skipped()
+	assert.Len(t, skipped, 2)
+	assert.Equal(t, 7, skipped[0].Line)
+	assert.Equal(t, 8, skipped[1].Line)
+
+	all := x.Attestations()
+	assert.Equal(t, []string{"builder"}, all[0].Attesters)
+	assert.Empty(t, all[2].Attesters, "unknown key was recognized")
+	assert.Empty(t, all[5].Attesters, "unsigned Statement was authenticated")
+	assert.Nil(t, all[5].Entry.Envelope)
+	assert.Equal(t, []string{"builder", "verifier"}, all[6].Attesters)
+}
+
+func TestIndexWithoutAttesters(t *testing.T) {
+	signer := testutil.NewSigner(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, NewWriter(&buf).WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "foo", testVSAV1Type), signer)))
+
+	x, err := BuildIndex(context.Background(), NewReader(&buf))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, x.Len())
+	assert.Empty(t, x.Attestations()[0].Attesters)
+}
+
+func TestNewIndexInvalidAttester(t *testing.T) {
+	_, err := NewIndex(dsse.Attester{Name: "nobody"})
+	assert.ErrorIs(t, err, dsse.ErrVerifierRequired)
+}
+
+func TestIndexSubjectWithSeveralDigests(t *testing.T) {
+	s := createTestStatement(t, "foo", testVSAV1Type)
+	s.Subject = append(s.Subject, &ita1.ResourceDescriptor{
+		Name:   "foo-copy",
+		Digest: map[string]string{"sha256": testDigest("foo"), "sha1": strings.Repeat("a", 40)},
+	})
+
+	x, err := NewIndex()
+	assert.NoError(t, err)
+	assert.NoError(t, x.Add(context.Background(), &Entry{Line: 1, Statement: s}))
+
+	// the attestation is returned once, even though two subjects match
+	got := x.Query(Query{Digest: map[string]string{"sha256": testDigest("foo"), "sha1": strings.Repeat("A", 40)}})
+	assert.Len(t, got, 1)
+}
+
+// testBase64Algorithm is a registered algorithm whose digests are
+// base64-encoded, and so case-sensitive.
+const testBase64Algorithm ita1.HashAlgorithm = "testBundleBase64"
+
+var errRegisterTestAlgorithm = ita1.RegisterHashAlgorithm(testBase64Algorithm, ita1.HashAlgorithmSpec{
+	Sizes:    []int{3},
+	Encoding: ita1.EncodingBase64,
+})
+
+func TestIndexBase64DigestsAreCaseSensitive(t *testing.T) {
+	assert.NoError(t, errRegisterTestAlgorithm)
+
+	x, err := NewIndex()
+	assert.NoError(t, err)
+	for i, digest := range []string{"AbCd", "abcd"} {
+		s := createTestStatement(t, "foo", testVSAV1Type)
+		s.Subject[0].Digest = map[string]string{string(testBase64Algorithm): digest}
+		assert.NoError(t, x.Add(context.Background(), &Entry{Line: i + 1, Statement: s}))
+	}
+
+	assert.Equal(t, []int{1}, lines(x.Query(Query{Digest: map[string]string{string(testBase64Algorithm): "AbCd"}})))
+	assert.Equal(t, []int{2}, lines(x.Query(Query{Digest: map[string]string{string(testBase64Algorithm): "abcd"}})))
+	assert.Empty(t, x.Query(Query{Digest: map[string]string{string(testBase64Algorithm): "ABCD"}}))
+}
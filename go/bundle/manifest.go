@@ -0,0 +1,431 @@
+/*
+Signed Bundle manifests, which list every envelope of a Bundle so that
+deleted, replayed and injected attestations can be detected.
+*/
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/predicates"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ManifestPredicateType is the predicateType of a Bundle manifest. It is
+// specific to this package, not a predicate of the in-toto specification.
+const ManifestPredicateType = "https://github.com/in-toto/attestation/go/bundle/manifest/v0.1"
+
+// Annotation keys of the manifest's subjects
+const (
+	annotationPredicateType = "predicateType"
+	annotationSubject       = "subject"
+)
+
+var (
+	ErrEnvelopesRequired  = errors.New("manifest must list at least one envelope")
+	ErrNotManifest        = errors.New("statement is not a bundle manifest")
+	ErrManifestRequired   = errors.New("bundle has no manifest signed by a recognized attester")
+	ErrMultipleManifests  = errors.New("bundle has more than one manifest signed by a recognized attester")
+	ErrInvalidManifest    = errors.New("invalid bundle manifest")
+	ErrUnverifiedManifest = errors.New("manifest is not signed by a recognized attester")
+)
+
+// ManifestEntry identifies an envelope of a Bundle.
+type ManifestEntry struct {
+	// Line is the envelope's line number in the Bundle, or 0 for entries
+	// listed in a manifest.
+	Line int
+	// Digest is the hex-encoded AttestationDigest of the envelope, or the
+	// hex-encoded SHA-256 digest of a Bundle line that is not an envelope.
+	Digest        string
+	PayloadType   string
+	PredicateType string
+	// Subject holds the digests of the Statement's subjects as sorted
+	// "<algorithm>:<value>" strings.
+	Subject []string
+	// Err is set for Bundle lines that are not envelopes: it is the reason
+	// the line was not recognized, or ErrUnsignedStatement.
+	Err error
+}
+
+// Manifest is a parsed Bundle manifest.
+type Manifest struct {
+	Statement *ita1.Statement
+	Entries   []ManifestEntry
+	// IssuedOn is the time the manifest was created, if recorded.
+	IssuedOn time.Time
+}
+
+// StaleEntry is an envelope that takes the place of a different envelope
+// listed in the manifest: it has the same predicateType and subjects, but
+// another payload, such as an obsolete attestation replayed over the
+// current one.
+type StaleEntry struct {
+	Expected ManifestEntry
+	Found    ManifestEntry
+}
+
+// ManifestReport lists the differences between a Bundle and its manifest.
+type ManifestReport struct {
+	Manifest *Manifest
+	// Attesters are the names of the recognized attesters that signed the
+	// manifest.
+	Attesters []string
+	// Missing are listed envelopes that are not in the Bundle.
+	Missing []ManifestEntry
+	// Extra are envelopes in the Bundle that are not listed, including
+	// additional copies of listed envelopes and manifests that are not
+	// signed by a recognized attester, and lines that are not envelopes.
+	Extra []ManifestEntry
+	// Stale are envelopes that replace a listed envelope.
+	Stale []StaleEntry
+}
+
+// OK indicates if the Bundle holds exactly the envelopes in the manifest.
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Stale) == 0
+}
+
+// AttestationDigest returns the hex-encoded SHA-256 digest of an
+// envelope's PAE. It covers the payloadType and payload but not the
+// signatures, so that adding signatures to an envelope does not change it.
+func AttestationDigest(e *dsse.Envelope) string {
+	sum := sha256.Sum256(e.PAE())
+	return hex.EncodeToString(sum[:])
+}
+
+// newManifestEntry describes an envelope. The predicateType and subjects
+// are only recorded for in-toto Statements.
+func newManifestEntry(line int, e *dsse.Envelope) ManifestEntry {
+	m := ManifestEntry{Line: line, Digest: AttestationDigest(e), PayloadType: e.PayloadType}
+	if !dsse.IsInTotoPayloadType(e.PayloadType) {
+		return m
+	}
+
+	s, err := e.Statement()
+	if err != nil {
+		return m
+	}
+
+	m.PredicateType = s.GetPredicateType()
+	for _, rd := range s.GetSubject() {
+		for alg, value := range rd.GetDigest() {
+			m.Subject = append(m.Subject, digestKey(alg, value))
+		}
+	}
+	sort.Strings(m.Subject)
+
+	return m
+}
+
+// newLineEntry describes a Bundle line that is not an envelope.
+func newLineEntry(e *Entry) ManifestEntry {
+	sum := sha256.Sum256(e.Raw)
+	m := ManifestEntry{Line: e.Line, Digest: hex.EncodeToString(sum[:]), Err: e.Err}
+	if m.Err == nil {
+		m.Err = ErrUnsignedStatement
+	}
+
+	return m
+}
+
+// slot identifies the attestation an entry is about, regardless of its
+// contents.
+func (m ManifestEntry) slot() string {
+	if m.PredicateType == "" {
+		return ""
+	}
+
+	return m.PredicateType + "\n" + strings.Join(m.Subject, ",")
+}
+
+// NewManifest returns an unsigned manifest Statement listing the given
+// envelopes. A zero issuedOn is not recorded. Callers are expected to sign
+// it, e.g. with dsse.NewEnvelope and Envelope.Sign, and may append it to
+// the Bundle, where consumers unaware of manifests ignore it.
+func NewManifest(envelopes []*dsse.Envelope, issuedOn time.Time) (*ita1.Statement, error) {
+	if len(envelopes) == 0 {
+		return nil, ErrEnvelopesRequired
+	}
+
+	subject := make([]*ita1.ResourceDescriptor, 0, len(envelopes))
+	for i, e := range envelopes {
+		if err := e.Validate(); err != nil {
+			return nil, fmt.Errorf("envelopes[%d]: %w", i, err)
+		}
+
+		m := newManifestEntry(0, e)
+		rd := &ita1.ResourceDescriptor{
+			Digest:    map[string]string{string(ita1.AlgorithmSHA256): m.Digest},
+			MediaType: m.PayloadType,
+		}
+
+		if m.PredicateType != "" {
+			subjects := make([]interface{}, 0, len(m.Subject))
+			for _, s := range m.Subject {
+				subjects = append(subjects, s)
+			}
+
+			annotations, err := structpb.NewStruct(map[string]interface{}{
+				annotationPredicateType: m.PredicateType,
+				annotationSubject:       subjects,
+			})
+			if err != nil {
+				return nil, err
+			}
+			rd.Annotations = annotations
+		}
+
+		subject = append(subject, rd)
+	}
+
+	fields := map[string]interface{}{}
+	if !issuedOn.IsZero() {
+		fields["issuedOn"] = issuedOn.UTC().Format(time.RFC3339)
+	}
+
+	predicate, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       subject,
+		PredicateType: ManifestPredicateType,
+		Predicate:     predicate,
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// IsManifest indicates if a Statement is a Bundle manifest.
+func IsManifest(s *ita1.Statement) bool {
+	return predicates.MatchesType(ManifestPredicateType, s.GetPredicateType())
+}
+
+// ParseManifest decodes a manifest Statement.
+func ParseManifest(s *ita1.Statement) (*Manifest, error) {
+	if !IsManifest(s) {
+		return nil, fmt.Errorf("%w: predicateType %q", ErrNotManifest, s.GetPredicateType())
+	}
+
+	m := &Manifest{Statement: s}
+	if v, ok := s.GetPredicate().GetFields()["issuedOn"]; ok {
+		t, err := time.Parse(time.RFC3339, v.GetStringValue())
+		if err != nil {
+			return nil, fmt.Errorf("%w: issuedOn: %v", ErrInvalidManifest, err)
+		}
+		m.IssuedOn = t
+	}
+
+	for i, rd := range s.GetSubject() {
+		digest := strings.ToLower(rd.GetDigest()[string(ita1.AlgorithmSHA256)])
+		if digest == "" {
+			return nil, fmt.Errorf("%w: subject[%d] has no sha256 digest", ErrInvalidManifest, i)
+		}
+
+		e := ManifestEntry{Digest: digest, PayloadType: rd.GetMediaType()}
+		annotations := rd.GetAnnotations().GetFields()
+		e.PredicateType = annotations[annotationPredicateType].GetStringValue()
+		for _, v := range annotations[annotationSubject].GetListValue().GetValues() {
+			e.Subject = append(e.Subject, v.GetStringValue())
+		}
+		sort.Strings(e.Subject)
+
+		m.Entries = append(m.Entries, e)
+	}
+
+	return m, nil
+}
+
+// Check compares a Bundle with the manifest. Copies of the manifest in the
+// Bundle are set aside; other manifests are reported as Extra, like lines
+// that are not envelopes.
+func (m *Manifest) Check(ctx context.Context, r *Reader) (*ManifestReport, error) {
+	found, err := readManifestEntries(ctx, r, func(_ *dsse.Envelope, s *ita1.Statement) (bool, error) {
+		return proto.Equal(s, m.Statement), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.compare(found), nil
+}
+
+// compare matches the envelopes found in a Bundle with the manifest.
+func (m *Manifest) compare(found []ManifestEntry) *ManifestReport {
+	report := &ManifestReport{Manifest: m}
+
+	// listed envelopes, by digest, in manifest order
+	listed := map[string][]int{}
+	for i, e := range m.Entries {
+		listed[e.Digest] = append(listed[e.Digest], i)
+	}
+
+	matched := make([]bool, len(m.Entries))
+	var unlisted []ManifestEntry
+	for _, f := range found {
+		if ids := listed[f.Digest]; len(ids) > 0 {
+			matched[ids[0]] = true
+			listed[f.Digest] = ids[1:]
+			continue
+		}
+
+		unlisted = append(unlisted, f)
+	}
+
+	// missing envelopes, by slot, in manifest order
+	missing := map[string][]int{}
+	for i, e := range m.Entries {
+		if !matched[i] && e.slot() != "" {
+			missing[e.slot()] = append(missing[e.slot()], i)
+		}
+	}
+
+	for _, f := range unlisted {
+		if ids := missing[f.slot()]; f.slot() != "" && len(ids) > 0 {
+			matched[ids[0]] = true
+			missing[f.slot()] = ids[1:]
+			report.Stale = append(report.Stale, StaleEntry{Expected: m.Entries[ids[0]], Found: f})
+			continue
+		}
+
+		report.Extra = append(report.Extra, f)
+	}
+
+	for i, e := range m.Entries {
+		if !matched[i] {
+			report.Missing = append(report.Missing, e)
+		}
+	}
+
+	return report
+}
+
+// VerifyManifest checks a Bundle against a manifest signed by one of the
+// recognized attesters. If manifest is nil, the manifest is taken from the
+// Bundle, which must hold exactly one manifest signed by a recognized
+// attester.
+//
+// The manifest only authenticates the set of envelopes in the Bundle:
+// each envelope's signatures must still be verified.
+func VerifyManifest(ctx context.Context, r *Reader, manifest *dsse.Envelope, attesters ...dsse.Attester) (*ManifestReport, error) {
+	if len(attesters) == 0 {
+		return nil, dsse.ErrAttesterRequired
+	}
+
+	var embedded []verifiedManifest
+	found, err := readManifestEntries(ctx, r, func(e *dsse.Envelope, _ *ita1.Statement) (bool, error) {
+		names, err := e.AttesterNames(ctx, attesters...)
+		if errors.Is(err, dsse.ErrNoRecognizedAttester) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		embedded = append(embedded, verifiedManifest{envelope: e, attesters: names})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if manifest == nil {
+		switch len(embedded) {
+		case 0:
+			return nil, ErrManifestRequired
+		case 1:
+			manifest, names = embedded[0].envelope, embedded[0].attesters
+		default:
+			return nil, ErrMultipleManifests
+		}
+	} else {
+		names, err = manifest.AttesterNames(ctx, attesters...)
+		if errors.Is(err, dsse.ErrNoRecognizedAttester) {
+			return nil, ErrUnverifiedManifest
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := manifest.Statement()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+
+	m, err := ParseManifest(s)
+	if err != nil {
+		return nil, err
+	}
+
+	report := m.compare(found)
+	report.Attesters = names
+
+	return report, nil
+}
+
+// verifiedManifest is a manifest envelope found in a Bundle, with the
+// names of the recognized attesters that signed it.
+type verifiedManifest struct {
+	envelope  *dsse.Envelope
+	attesters []string
+}
+
+// readManifestEntries describes the lines of a Bundle, except for the
+// manifests that setAside accepts.
+func readManifestEntries(ctx context.Context, r *Reader, setAside func(*dsse.Envelope, *ita1.Statement) (bool, error)) ([]ManifestEntry, error) {
+	var found []ManifestEntry
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return found, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Envelope == nil {
+			found = append(found, newLineEntry(e))
+			continue
+		}
+
+		entry := newManifestEntry(e.Line, e.Envelope)
+		if predicates.MatchesType(ManifestPredicateType, entry.PredicateType) {
+			s, err := e.Envelope.Statement()
+			if err != nil {
+				return nil, err
+			}
+
+			ok, err := setAside(e.Envelope, s)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+		}
+
+		found = append(found, entry)
+	}
+}
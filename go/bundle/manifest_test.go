@@ -0,0 +1,300 @@
+/*
+Tests for signed Bundle manifests.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRelease is a Bundle of attestations about a release, and a manifest
+// listing them.
+type testRelease struct {
+	releaser   signature.SignerVerifier
+	envelopes  []*dsse.Envelope
+	manifest   *dsse.Envelope
+	provenance *dsse.Envelope
+}
+
+func createTestRelease(t *testing.T) *testRelease {
+	t.Helper()
+
+	builder := testutil.NewSigner(t)
+	releaser := testutil.NewSigner(t)
+
+	novulz := &dsse.Envelope{PayloadType: "application/vnd.novulz+cbor", Payload: []byte{0xa0}}
+	assert.NoError(t, novulz.Sign(context.Background(), builder))
+
+	r := &testRelease{releaser: releaser}
+	r.provenance = createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), builder)
+	r.envelopes = []*dsse.Envelope{
+		r.provenance,
+		createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV1Type), builder),
+		novulz,
+	}
+
+	s, err := NewManifest(r.envelopes, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.manifest = createTestEnvelope(t, s, releaser)
+
+	return r
+}
+
+func (r *testRelease) attesters() []dsse.Attester {
+	return []dsse.Attester{{Name: "releaser", Verifier: r.releaser}}
+}
+
+func writeTestBundle(t *testing.T, envelopes ...*dsse.Envelope) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range envelopes {
+		assert.NoError(t, w.WriteEnvelope(e))
+	}
+
+	return &buf
+}
+
+func TestVerifyManifest(t *testing.T) {
+	r := createTestRelease(t)
+	prov, vsa, novulz := r.envelopes[0], r.envelopes[1], r.envelopes[2]
+
+	// an obsolete provenance for the same artifact, and an unrelated VSA
+	builder := testutil.NewSigner(t)
+	oldStatement := createTestStatement(t, "fooly.apk", testProvenanceType)
+	oldStatement.Predicate.Fields["old"] = oldStatement.Predicate.Fields["keyObj"]
+	oldProv := createTestEnvelope(t, oldStatement, builder)
+	injected := createTestEnvelope(t, createTestStatement(t, "other.apk", testVSAV1Type), builder)
+
+	tests := map[string]struct {
+		envelopes       []*dsse.Envelope
+		expectedMissing []string
+		expectedExtra   []int
+		expectedStale   []int
+	}{
+		"complete": {
+			envelopes: []*dsse.Envelope{prov, vsa, novulz},
+		},
+		"any order": {
+			envelopes: []*dsse.Envelope{novulz, vsa, prov},
+		},
+		"deleted": {
+			envelopes:       []*dsse.Envelope{prov, novulz},
+			expectedMissing: []string{AttestationDigest(vsa)},
+		},
+		"deleted non in-toto envelope": {
+			envelopes:       []*dsse.Envelope{prov, vsa},
+			expectedMissing: []string{AttestationDigest(novulz)},
+		},
+		"injected": {
+			envelopes:     []*dsse.Envelope{prov, vsa, injected, novulz},
+			expectedExtra: []int{3},
+		},
+		"duplicated": {
+			envelopes:     []*dsse.Envelope{prov, vsa, novulz, vsa},
+			expectedExtra: []int{4},
+		},
+		"replayed over current": {
+			envelopes:     []*dsse.Envelope{oldProv, vsa, novulz},
+			expectedStale: []int{1},
+		},
+		"replayed next to current": {
+			envelopes:     []*dsse.Envelope{prov, oldProv, vsa, novulz},
+			expectedExtra: []int{2},
+		},
+	}
+
+	for name, test := range tests {
+		bundle := writeTestBundle(t, append(test.envelopes, r.manifest)...)
+		report, err := VerifyManifest(context.Background(), NewReader(bundle), nil, r.attesters()...)
+		if !assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name)) {
+			continue
+		}
+
+		assert.Equal(t, []string{"releaser"}, report.Attesters, fmt.Sprintf("wrong attesters in test '%s'", name))
+		assert.Equal(t, len(test.expectedMissing) == 0 && len(test.expectedExtra) == 0 && len(test.expectedStale) == 0, report.OK(), fmt.Sprintf("wrong result in test '%s'", name))
+
+		missing := []string{}
+		for _, e := range report.Missing {
+			missing = append(missing, e.Digest)
+		}
+		extra := []int{}
+		for _, e := range report.Extra {
+			extra = append(extra, e.Line)
+		}
+		stale := []int{}
+		for _, e := range report.Stale {
+			stale = append(stale, e.Found.Line)
+			assert.Equal(t, AttestationDigest(prov), e.Expected.Digest, fmt.Sprintf("wrong stale entry in test '%s'", name))
+		}
+
+		assert.ElementsMatch(t, test.expectedMissing, missing, fmt.Sprintf("wrong missing entries in test '%s'", name))
+		assert.ElementsMatch(t, test.expectedExtra, extra, fmt.Sprintf("wrong extra entries in test '%s'", name))
+		assert.ElementsMatch(t, test.expectedStale, stale, fmt.Sprintf("wrong stale entries in test '%s'", name))
+	}
+}
+
+func TestVerifyManifestErrors(t *testing.T) {
+	r := createTestRelease(t)
+	mallory := testutil.NewSigner(t)
+
+	forged, err := NewManifest(r.envelopes[:1], time.Time{})
+	assert.NoError(t, err)
+	forgedManifest := createTestEnvelope(t, forged, mallory)
+
+	tests := map[string]struct {
+		bundle      []*dsse.Envelope
+		manifest    *dsse.Envelope
+		attesters   []dsse.Attester
+		expectedErr error
+	}{
+		"no manifest": {
+			bundle:      r.envelopes,
+			attesters:   r.attesters(),
+			expectedErr: ErrManifestRequired,
+		},
+		"only forged manifest": {
+			bundle:      append(r.envelopes, forgedManifest),
+			attesters:   r.attesters(),
+			expectedErr: ErrManifestRequired,
+		},
+		"two manifests": {
+			bundle:      append(r.envelopes, r.manifest, r.manifest),
+			attesters:   r.attesters(),
+			expectedErr: ErrMultipleManifests,
+		},
+		"detached forged manifest": {
+			bundle:      r.envelopes,
+			manifest:    forgedManifest,
+			attesters:   r.attesters(),
+			expectedErr: ErrUnverifiedManifest,
+		},
+		"detached non-manifest": {
+			bundle:      r.envelopes,
+			manifest:    createTestEnvelope(t, createTestStatement(t, "foo", testVSAV1Type), r.releaser),
+			attesters:   r.attesters(),
+			expectedErr: ErrNotManifest,
+		},
+		"no attesters": {
+			bundle:      append(r.envelopes, r.manifest),
+			expectedErr: dsse.ErrAttesterRequired,
+		},
+	}
+
+	for name, test := range tests {
+		bundle := writeTestBundle(t, test.bundle...)
+		_, err := VerifyManifest(context.Background(), NewReader(bundle), test.manifest, test.attesters...)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestVerifyDetachedManifest(t *testing.T) {
+	r := createTestRelease(t)
+
+	bundle := writeTestBundle(t, append(r.envelopes, r.manifest)...)
+	report, err := VerifyManifest(context.Background(), NewReader(bundle), r.manifest, r.attesters()...)
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), report.Manifest.IssuedOn)
+
+	// a forged manifest in the Bundle is reported in favor of the detached
+	// one
+	mallory := testutil.NewSigner(t)
+	forged, err := NewManifest(r.envelopes[:1], time.Time{})
+	assert.NoError(t, err)
+
+	bundle = writeTestBundle(t, append(r.envelopes, createTestEnvelope(t, forged, mallory))...)
+	report, err = VerifyManifest(context.Background(), NewReader(bundle), r.manifest, r.attesters()...)
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	if assert.Len(t, report.Extra, 1) {
+		assert.Equal(t, 4, report.Extra[0].Line)
+		assert.Equal(t, ManifestPredicateType, report.Extra[0].PredicateType)
+	}
+}
+
+func TestVerifyManifestReportsUnrecognizedLines(t *testing.T) {
+	r := createTestRelease(t)
+	mallory := testutil.NewSigner(t)
+	forged, err := NewManifest(r.envelopes, time.Time{})
+	assert.NoError(t, err)
+
+	bundle := writeTestBundle(t, append(r.envelopes, r.manifest, createTestEnvelope(t, forged, mallory))...)
+	w := NewWriter(bundle)
+	assert.NoError(t, w.WriteStatement(createTestStatement(t, "fooly.apk", testVSAV1Type)))
+	bundle.WriteString("{\"payloadType\":\"application/vnd.in-toto+json\",\"payload\":\"e30=\",\"signatures\":[]}\n")
+	bundle.WriteString("not json\n")
+
+	report, err := VerifyManifest(context.Background(), NewReader(bundle), nil, r.attesters()...)
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Empty(t, report.Missing)
+	assert.Empty(t, report.Stale)
+
+	lines := []int{}
+	errs := []error{}
+	for _, e := range report.Extra {
+		lines = append(lines, e.Line)
+		errs = append(errs, e.Err)
+	}
+	assert.Equal(t, []int{5, 6, 7, 8}, lines)
+	assert.Nil(t, errs[0], "forged manifest")
+	assert.ErrorIs(t, errs[1], ErrUnsignedStatement)
+	assert.ErrorIs(t, errs[2], ErrInvalidEnvelope)
+	assert.ErrorIs(t, errs[3], ErrUnrecognizedLine)
+}
+
+func TestManifestIgnoresSignatures(t *testing.T) {
+	r := createTestRelease(t)
+
+	// signatures added after the manifest was created do not change the
+	// attestation digest
+	cosigned := *r.provenance
+	cosigned.Signatures = append([]dsse.Signature{}, r.provenance.Signatures...)
+	assert.NoError(t, cosigned.Sign(context.Background(), testutil.NewSigner(t)))
+
+	s, err := r.manifest.Statement()
+	assert.NoError(t, err)
+	m, err := ParseManifest(s)
+	assert.NoError(t, err)
+
+	report, err := m.Check(context.Background(), NewReader(writeTestBundle(t, &cosigned, r.envelopes[1], r.envelopes[2], r.manifest)))
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Nil(t, report.Attesters)
+
+	// other manifests are not set aside
+	other, err := NewManifest(r.envelopes[:1], time.Time{})
+	assert.NoError(t, err)
+	report, err = m.Check(context.Background(), NewReader(writeTestBundle(t, append(r.envelopes, createTestEnvelope(t, other, r.releaser))...)))
+	assert.NoError(t, err)
+	if assert.Len(t, report.Extra, 1) {
+		assert.Equal(t, 4, report.Extra[0].Line)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.Check(ctx, NewReader(writeTestBundle(t, r.envelopes...)))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewManifestErrors(t *testing.T) {
+	_, err := NewManifest(nil, time.Time{})
+	assert.ErrorIs(t, err, ErrEnvelopesRequired)
+
+	_, err = NewManifest([]*dsse.Envelope{{PayloadType: dsse.PayloadType, Payload: []byte("{}")}}, time.Time{})
+	assert.ErrorIs(t, err, dsse.ErrSignaturesRequired)
+}
@@ -0,0 +1,230 @@
+/*
+Order-independent merging and de-duplication of Bundles, and a Bundle
+content digest that does not depend on the order of its lines.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/cjson"
+)
+
+// Set is a de-duplicated set of envelopes, keyed by AttestationDigest.
+// Copies of the same envelope are merged into one holding the union of
+// their signatures. The contents of a Set do not depend on the order in
+// which envelopes were added.
+type Set struct {
+	attestations map[string]*setEntry
+}
+
+// setEntry is an envelope of a Set, with its signatures keyed like
+// dsse.Envelope.AddSignature de-duplicates them, by keyid and signature
+// bytes.
+type setEntry struct {
+	payloadType string
+	payload     []byte
+	signatures  map[string]dsse.Signature
+}
+
+// NewSet returns an empty Set.
+func NewSet() *Set {
+	return &Set{attestations: map[string]*setEntry{}}
+}
+
+// Len returns the number of distinct envelopes in the set.
+func (s *Set) Len() int {
+	return len(s.attestations)
+}
+
+// Add adds an envelope to the set, merging its signatures into an
+// identical envelope already in the set. It reports whether the envelope
+// was new. The envelope is copied, not retained.
+//
+// If two copies hold different signatures with the same keyid, such as
+// two ECDSA signatures by the same key, or a valid and a forged one, both
+// are kept: an unverified signature must not mask a valid one. Signatures
+// are not verified: consumers must verify the envelopes.
+func (s *Set) Add(e *dsse.Envelope) (bool, error) {
+	if e == nil {
+		return false, dsse.ErrEnvelopeRequired
+	}
+
+	if err := e.Validate(); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+
+	digest := AttestationDigest(e)
+	entry, ok := s.attestations[digest]
+	if !ok {
+		entry = &setEntry{
+			payloadType: e.PayloadType,
+			payload:     bytes.Clone(e.Payload),
+			signatures:  map[string]dsse.Signature{},
+		}
+		s.attestations[digest] = entry
+	}
+
+	for _, sig := range e.Signatures {
+		key := signatureKey(sig)
+		if _, dup := entry.signatures[key]; !dup {
+			entry.signatures[key] = dsse.Signature{KeyID: sig.KeyID, Sig: bytes.Clone(sig.Sig)}
+		}
+	}
+
+	return !ok, nil
+}
+
+// AddFrom adds every envelope of a Bundle to the set. Lines that are not
+// envelopes, including unsigned Statements, are dropped.
+func (s *Set) AddFrom(r *Reader) error {
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if e.Envelope == nil {
+			continue
+		}
+
+		if _, err := s.Add(e.Envelope); err != nil {
+			return fmt.Errorf("line %d: %w", e.Line, err)
+		}
+	}
+}
+
+// signatureKey identifies duplicate signatures: those with the same keyid
+// and signature bytes. Keys sort by keyid, then signature bytes.
+func signatureKey(sig dsse.Signature) string {
+	return hex.EncodeToString([]byte(sig.KeyID)) + "." + hex.EncodeToString(sig.Sig)
+}
+
+// digests returns the attestation digests of the set, sorted.
+func (s *Set) digests() []string {
+	digests := make([]string, 0, len(s.attestations))
+	for d := range s.attestations {
+		digests = append(digests, d)
+	}
+	sort.Strings(digests)
+
+	return digests
+}
+
+// envelope returns a new envelope for an entry, with its signatures in
+// canonical order.
+func (e *setEntry) envelope() *dsse.Envelope {
+	keys := make([]string, 0, len(e.signatures))
+	for k := range e.signatures {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := &dsse.Envelope{
+		PayloadType: e.payloadType,
+		Payload:     bytes.Clone(e.payload),
+		Signatures:  make([]dsse.Signature, 0, len(keys)),
+	}
+	for _, k := range keys {
+		sig := e.signatures[k]
+		env.Signatures = append(env.Signatures, dsse.Signature{KeyID: sig.KeyID, Sig: bytes.Clone(sig.Sig)})
+	}
+
+	return env
+}
+
+// Envelopes returns the envelopes of the set in canonical order: sorted by
+// AttestationDigest, with signatures sorted by keyid and signature bytes.
+func (s *Set) Envelopes() []*dsse.Envelope {
+	out := make([]*dsse.Envelope, 0, len(s.attestations))
+	for _, d := range s.digests() {
+		out = append(out, s.attestations[d].envelope())
+	}
+
+	return out
+}
+
+// WriteTo writes the envelopes of the set in canonical order.
+func (s *Set) WriteTo(w *Writer) error {
+	for _, e := range s.Envelopes() {
+		if err := w.WriteEnvelope(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// digestEntry is the canonical JSON form of a set entry hashed by Digest.
+type digestEntry struct {
+	Digest     string            `json:"digest"`
+	Signatures []digestSignature `json:"signatures"`
+}
+
+type digestSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Digest returns the hex-encoded SHA-256 content digest of the set: the
+// digest of the canonical JSON encoding of its attestation digests and
+// signatures, in canonical order. Two Bundles with the same envelopes and
+// signatures have the same digest, regardless of the order and
+// duplication of their lines.
+func (s *Set) Digest() (string, error) {
+	entries := make([]digestEntry, 0, len(s.attestations))
+	for _, d := range s.digests() {
+		entry := digestEntry{Digest: d, Signatures: []digestSignature{}}
+		for _, sig := range s.attestations[d].envelope().Signatures {
+			entry.Signatures = append(entry.Signatures, digestSignature{
+				KeyID: sig.KeyID,
+				Sig:   base64.StdEncoding.EncodeToString(sig.Sig),
+			})
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := cjson.Encode(entries)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Merge merges Bundles into a de-duplicated Bundle written in canonical
+// order. Lines that are not envelopes are dropped.
+func Merge(w *Writer, bundles ...*Reader) error {
+	s := NewSet()
+	for i, r := range bundles {
+		if err := s.AddFrom(r); err != nil {
+			return fmt.Errorf("bundles[%d]: %w", i, err)
+		}
+	}
+
+	return s.WriteTo(w)
+}
+
+// Digest returns the content digest of a Bundle, as defined by
+// Set.Digest.
+func Digest(r *Reader) (string, error) {
+	s := NewSet()
+	if err := s.AddFrom(r); err != nil {
+		return "", err
+	}
+
+	return s.Digest()
+}
@@ -0,0 +1,208 @@
+/*
+Tests for Bundle merging, de-duplication and content digests.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+// cosign returns a copy of an envelope with additional signatures.
+func cosign(t *testing.T, e *dsse.Envelope, signers ...signature.Signer) *dsse.Envelope {
+	t.Helper()
+
+	out := *e
+	out.Signatures = append([]dsse.Signature{}, e.Signatures...)
+	for _, s := range signers {
+		if err := out.Sign(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &out
+}
+
+func bundleDigest(t *testing.T, envelopes ...*dsse.Envelope) string {
+	t.Helper()
+
+	d, err := Digest(NewReader(writeTestBundle(t, envelopes...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestMerge(t *testing.T) {
+	build, scan, test := testutil.NewSigner(t), testutil.NewSigner(t), testutil.NewSigner(t)
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), build)
+	vsa := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV1Type), scan)
+
+	// each stage re-publishes the provenance, the test stage co-signs it
+	buildBundle := writeTestBundle(t, prov)
+	scanBundle := writeTestBundle(t, prov, vsa, vsa)
+	testBundle := writeTestBundle(t, cosign(t, prov, test))
+	testBundle.WriteString("not an attestation\n")
+
+	var out bytes.Buffer
+	assert.NoError(t, Merge(NewWriter(&out), NewReader(buildBundle), NewReader(scanBundle), NewReader(testBundle)))
+
+	entries := readAll(t, NewReader(&out))
+	assert.Len(t, entries, 2)
+
+	for _, e := range entries {
+		switch AttestationDigest(e.Envelope) {
+		case AttestationDigest(prov):
+			matched, err := e.Envelope.Verify(context.Background(), build, test)
+			assert.NoError(t, err)
+			assert.Len(t, matched, 2, "signatures were not merged")
+			assert.Len(t, e.Envelope.Signatures, 2)
+		case AttestationDigest(vsa):
+			assert.Len(t, e.Envelope.Signatures, 1)
+		default:
+			t.Errorf("unexpected envelope on line %d", e.Line)
+		}
+	}
+}
+
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	signer, other := testutil.NewSigner(t), testutil.NewSigner(t)
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), signer)
+
+	s := NewSet()
+	isNew, err := s.Add(prov)
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+
+	isNew, err = s.Add(cosign(t, prov, other))
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+
+	assert.Len(t, prov.Signatures, 1)
+	assert.Len(t, s.Envelopes()[0].Signatures, 2)
+}
+
+// a forged signature with a signer's keyid must not replace their real one
+func TestMergeKeepsSignaturesWithSameKeyID(t *testing.T) {
+	ctx := context.Background()
+	signer := testutil.NewSigner(t)
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), signer)
+
+	keyID, _ := signer.KeyID()
+	forged := *prov
+	forged.Signatures = []dsse.Signature{{KeyID: keyID, Sig: []byte("\x00")}}
+
+	for name, order := range map[string][]*dsse.Envelope{
+		"forged last":  {prov, &forged},
+		"forged first": {&forged, prov},
+	} {
+		s := NewSet()
+		for _, e := range order {
+			_, err := s.Add(e)
+			assert.NoError(t, err)
+		}
+
+		envelopes := s.Envelopes()
+		if !assert.Len(t, envelopes, 1, fmt.Sprintf("unexpected envelopes in test '%s'", name)) {
+			continue
+		}
+		assert.Len(t, envelopes[0].Signatures, 2, fmt.Sprintf("signature dropped in test '%s'", name))
+		_, err := envelopes[0].Verify(ctx, signer)
+		assert.NoError(t, err, fmt.Sprintf("valid signature dropped in test '%s'", name))
+	}
+
+	var out bytes.Buffer
+	_, err := Compact(ctx, NewWriter(&out), NewReader(writeTestBundle(t, prov, &forged)), dsse.Attester{Name: "builder", Verifier: signer})
+	assert.NoError(t, err)
+	entries := readAll(t, NewReader(&out))
+	if assert.Len(t, entries, 1) {
+		_, err := entries[0].Envelope.Verify(ctx, signer)
+		assert.NoError(t, err)
+	}
+}
+
+func TestSetAddErrors(t *testing.T) {
+	s := NewSet()
+
+	_, err := s.Add(nil)
+	assert.ErrorIs(t, err, dsse.ErrEnvelopeRequired)
+
+	_, err = s.Add(&dsse.Envelope{PayloadType: dsse.PayloadType, Payload: []byte("{}")})
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+	assert.Zero(t, s.Len())
+}
+
+func TestBundleDigest(t *testing.T) {
+	a, b := testutil.NewSigner(t), testutil.NewSigner(t)
+	ecdsa := testutil.NewECDSASigner(t)
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), a)
+	vsa := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testVSAV1Type), b)
+	provAB := cosign(t, prov, b)
+	provBA := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), b, a)
+	ecdsa1 := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), ecdsa)
+	ecdsa2 := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), ecdsa)
+
+	base := bundleDigest(t, prov, vsa)
+	tests := map[string]struct {
+		envelopes []*dsse.Envelope
+		same      bool
+	}{
+		"reordered": {
+			envelopes: []*dsse.Envelope{vsa, prov},
+			same:      true,
+		},
+		"duplicated": {
+			envelopes: []*dsse.Envelope{prov, vsa, prov, vsa},
+			same:      true,
+		},
+		"missing attestation": {
+			envelopes: []*dsse.Envelope{prov},
+		},
+		"additional signature": {
+			envelopes: []*dsse.Envelope{provAB, vsa},
+		},
+		"signatures split across copies": {
+			envelopes: []*dsse.Envelope{prov, vsa, cosign(t, vsa, a)},
+		},
+	}
+
+	for name, test := range tests {
+		got := bundleDigest(t, test.envelopes...)
+		if test.same {
+			assert.Equal(t, base, got, fmt.Sprintf("digest changed in test '%s'", name))
+		} else {
+			assert.NotEqual(t, base, got, fmt.Sprintf("digest did not change in test '%s'", name))
+		}
+	}
+
+	// signature order and splitting do not matter
+	assert.Equal(t, bundleDigest(t, provAB, vsa), bundleDigest(t, provBA, vsa))
+	assert.Equal(t, bundleDigest(t, provAB, vsa), bundleDigest(t, vsa, cosign(t, prov), createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), b)))
+
+	// conflicting signatures by the same key resolve the same way in any
+	// order
+	assert.NotEqual(t, ecdsa1.Signatures[0].Sig, ecdsa2.Signatures[0].Sig)
+	assert.Equal(t, bundleDigest(t, ecdsa1, ecdsa2), bundleDigest(t, ecdsa2, ecdsa1))
+}
+
+func TestBundleDigestIgnoresOtherLines(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	prov := createTestEnvelope(t, createTestStatement(t, "fooly.apk", testProvenanceType), signer)
+
+	withNoise := writeTestBundle(t, prov)
+	withNoise.WriteString("not an attestation\n")
+	assert.NoError(t, NewWriter(withNoise).WriteStatement(createTestStatement(t, "foo", testVSAV1Type)))
+
+	got, err := Digest(NewReader(withNoise))
+	assert.NoError(t, err)
+	assert.Equal(t, bundleDigest(t, prov), got)
+}
@@ -0,0 +1,118 @@
+/*
+Streaming Bundle reader.
+*/
+
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// DefaultMaxLineSize is the default limit on the size of a single line.
+const DefaultMaxLineSize = 64 << 20
+
+// Reader reads a Bundle one line at a time, so that memory use is bounded
+// by the largest line rather than the size of the Bundle.
+type Reader struct {
+	r           *bufio.Reader
+	line        int
+	maxLineSize int
+	err         error
+}
+
+// ReaderOption configures a Reader.
+type ReaderOption func(*Reader)
+
+// WithMaxLineSize limits the size of a single line. Longer lines are
+// skipped and reported with ErrLineTooLong.
+func WithMaxLineSize(n int) ReaderOption {
+	return func(r *Reader) {
+		r.maxLineSize = n
+	}
+}
+
+// NewReader returns a Reader for the Bundle read from r.
+func NewReader(r io.Reader, opts ...ReaderOption) *Reader {
+	br := &Reader{r: bufio.NewReader(r), maxLineSize: DefaultMaxLineSize}
+	for _, opt := range opts {
+		opt(br)
+	}
+
+	return br
+}
+
+// Next returns the next non-blank line of the Bundle. Lines that are not
+// valid envelopes or Statements are returned with Entry.Err set, and do
+// not stop the stream. Next returns io.EOF at the end of the Bundle, and
+// any other error only if reading from the underlying reader fails.
+func (r *Reader) Next() (*Entry, error) {
+	for {
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		line, tooLong, err := r.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			r.err = err
+			return nil, err
+		}
+
+		if errors.Is(err, io.EOF) {
+			r.err = io.EOF
+			if line == nil && !tooLong {
+				return nil, io.EOF
+			}
+		}
+
+		r.line++
+		if tooLong {
+			return &Entry{Line: r.line, Err: ErrLineTooLong}, nil
+		}
+
+		if isBlank(line) {
+			continue
+		}
+
+		return parseLine(r.line, line), nil
+	}
+}
+
+// readLine reads the next line without its terminator. Lines longer than
+// the maximum size are discarded up to their end. At the end of the input,
+// it returns the final unterminated line, if any, with io.EOF.
+func (r *Reader) readLine() ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > r.maxLineSize && len(trimTerminator(line)) > r.maxLineSize {
+				tooLong = true
+				line = nil
+			}
+		}
+
+		switch {
+		case err == nil:
+			return trimTerminator(line), tooLong, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if line == nil && !tooLong {
+				return nil, false, io.EOF
+			}
+			return trimTerminator(line), tooLong, io.EOF
+		default:
+			return nil, false, err
+		}
+	}
+}
+
+func trimTerminator(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r"))
+}
@@ -0,0 +1,201 @@
+/*
+Tests for the streaming Bundle reader.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// readAll reads every entry of a Bundle.
+func readAll(t *testing.T, r *Reader) []*Entry {
+	t.Helper()
+
+	var entries []*Entry
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func TestReaderMixedLines(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	env := marshalTestLine(t, createTestEnvelope(t, createTestStatement(t, "foo", "https://example.com/pred"), signer))
+	st := marshalTestLine(t, createTestStatement(t, "bar", "https://example.com/pred"))
+
+	input := strings.Join([]string{
+		env,
+		"",
+		"not an attestation",
+		st + "\r",
+		"   ",
+		`{"payloadType": "application/vnd.in-toto+json"}`,
+		env,
+	}, "\n")
+
+	entries := readAll(t, NewReader(strings.NewReader(input)))
+	assert.Len(t, entries, 5)
+
+	assert.Equal(t, 1, entries[0].Line)
+	assert.NotNil(t, entries[0].Envelope)
+	assert.NoError(t, entries[0].Err)
+
+	assert.Equal(t, 3, entries[1].Line)
+	assert.ErrorIs(t, entries[1].Err, ErrUnrecognizedLine)
+
+	assert.Equal(t, 4, entries[2].Line)
+	assert.NotNil(t, entries[2].Statement)
+	assert.Equal(t, st, string(entries[2].Raw), "line terminator was not trimmed")
+
+	assert.Equal(t, 6, entries[3].Line)
+	assert.ErrorIs(t, entries[3].Err, ErrInvalidEnvelope)
+
+	// the last line has no terminator
+	assert.Equal(t, 7, entries[4].Line)
+	assert.NotNil(t, entries[4].Envelope)
+}
+
+func TestReaderEmpty(t *testing.T) {
+	for _, input := range []string{"", "\n", "\n\n  \n"} {
+		e, err := NewReader(strings.NewReader(input)).Next()
+		assert.Nil(t, e)
+		assert.ErrorIs(t, err, io.EOF)
+	}
+}
+
+func TestReaderLineTooLong(t *testing.T) {
+	st := marshalTestLine(t, createTestStatement(t, "bar", "https://example.com/pred"))
+	long := `{"padding": "` + strings.Repeat("x", 10000) + `"}`
+	input := strings.Join([]string{st, long, st, long}, "\n")
+
+	entries := readAll(t, NewReader(strings.NewReader(input), WithMaxLineSize(len(st))))
+	assert.Len(t, entries, 4)
+	assert.NotNil(t, entries[0].Statement)
+	assert.ErrorIs(t, entries[1].Err, ErrLineTooLong)
+	assert.Nil(t, entries[1].Raw)
+	assert.Equal(t, 3, entries[2].Line)
+	assert.NotNil(t, entries[2].Statement)
+	assert.Equal(t, 4, entries[3].Line)
+	assert.ErrorIs(t, entries[3].Err, ErrLineTooLong)
+}
+
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestReaderIOError(t *testing.T) {
+	st := marshalTestLine(t, createTestStatement(t, "bar", "https://example.com/pred"))
+	ioErr := errors.New("disk on fire")
+	r := NewReader(&failingReader{r: strings.NewReader(st + "\n" + st), err: ioErr})
+
+	e, err := r.Next()
+	assert.NoError(t, err)
+	assert.NotNil(t, e.Statement)
+
+	_, err = r.Next()
+	assert.ErrorIs(t, err, ioErr)
+
+	// the error is sticky
+	_, err = r.Next()
+	assert.ErrorIs(t, err, ioErr)
+}
+
+// generatedBundle produces a Bundle of n copies of a line without holding
+// it in memory.
+type generatedBundle struct {
+	line    []byte
+	n       int
+	pending []byte
+}
+
+func (g *generatedBundle) Read(p []byte) (int, error) {
+	if len(g.pending) == 0 {
+		if g.n == 0 {
+			return 0, io.EOF
+		}
+		g.n--
+		g.pending = g.line
+	}
+
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+func TestReaderLargeBundle(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	line := marshalTestLine(t, createTestEnvelope(t, createTestStatement(t, "foo", "https://example.com/pred"), signer)) + "\n"
+	n := 20000
+	if testing.Short() {
+		n = 1000
+	}
+
+	r := NewReader(&generatedBundle{line: []byte(line), n: n})
+	count := 0
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, e.Err)
+		count++
+	}
+	assert.Equal(t, n, count)
+}
+
+func BenchmarkReader(b *testing.B) {
+	signer := testutil.NewSigner(b)
+	line := marshalTestLine(b, createTestEnvelope(b, createTestStatement(b, "foo", "https://example.com/pred"), signer)) + "\n"
+
+	b.SetBytes(int64(len(line)))
+	b.ReportAllocs()
+	r := NewReader(&generatedBundle{line: []byte(line), n: b.N})
+	for {
+		_, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReaderWriterRoundTrip(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	env := createTestEnvelope(t, createTestStatement(t, "foo", "https://example.com/pred"), signer)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.WriteEnvelope(env))
+	assert.NoError(t, w.WriteStatement(createTestStatement(t, "bar", "https://example.com/pred")))
+
+	entries := readAll(t, NewReader(&buf))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, env, entries[0].Envelope)
+	assert.Equal(t, "bar", entries[1].Statement.GetSubject()[0].GetName())
+}
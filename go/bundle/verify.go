@@ -0,0 +1,185 @@
+/*
+Concurrent verification of the envelopes of a Bundle, for consumers that
+check thousands of attestations at a time.
+*/
+
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+var (
+	ErrInvalidWorkers    = errors.New("workers must be at least 1")
+	ErrUnsignedStatement = errors.New("unsigned Statement is not authenticated")
+)
+
+// LineResult is the outcome of verifying one line of a Bundle.
+type LineResult struct {
+	// Line is the 1-based line number of the entry.
+	Line int
+	// Statement is the line's Statement. It is set for unsigned Statements
+	// too, but only trustworthy if Err is nil.
+	Statement *ita1.Statement
+	// Attesters are the names of the recognized attesters that signed the
+	// envelope, in the order the attesters were given.
+	Attesters []string
+	// Err explains why the line was rejected.
+	Err error
+}
+
+// OK indicates if the line is an authenticated and valid attestation.
+func (r *LineResult) OK() bool {
+	return r.Err == nil
+}
+
+// Verifier checks the signatures and Statements of the envelopes of a
+// Bundle, spreading the work over a bounded number of goroutines. It is
+// safe for concurrent use once configured.
+type Verifier struct {
+	attesters []dsse.Attester
+	workers   int
+}
+
+// NewVerifier returns a Verifier that trusts the given attesters and uses
+// one worker per CPU.
+func NewVerifier(attesters ...dsse.Attester) (*Verifier, error) {
+	if len(attesters) == 0 {
+		return nil, dsse.ErrAttesterRequired
+	}
+
+	for _, a := range attesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Verifier{attesters: attesters, workers: runtime.GOMAXPROCS(0)}, nil
+}
+
+// SetWorkers sets the maximum number of lines verified at the same time.
+func (v *Verifier) SetWorkers(n int) error {
+	if n < 1 {
+		return ErrInvalidWorkers
+	}
+	v.workers = n
+
+	return nil
+}
+
+// verifyJob is an entry queued for a worker, which fills in its result.
+type verifyJob struct {
+	entry  *Entry
+	result *LineResult
+}
+
+// Verify checks every line of a Bundle, and returns one result per
+// non-blank line in Bundle order. Lines that are rejected are reported in
+// their result and do not fail the call, but a Bundle that cannot be read
+// does. If ctx is done before every line is verified, Verify stops early
+// and returns the context's error.
+func (v *Verifier) Verify(ctx context.Context, r *Reader) ([]*LineResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan verifyJob)
+	var wg sync.WaitGroup
+	for i := 0; i < v.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				// results are discarded once ctx is done
+				if ctx.Err() != nil {
+					continue
+				}
+				v.verifyEntry(ctx, j.entry, j.result)
+			}
+		}()
+	}
+
+	results, err := v.dispatch(ctx, r, jobs)
+	close(jobs)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a deadline may be reached while the last lines are verified
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// dispatch reads the Bundle and queues its entries, in order, along with
+// the results the workers fill in.
+func (v *Verifier) dispatch(ctx context.Context, r *Reader, jobs chan<- verifyJob) ([]*LineResult, error) {
+	results := []*LineResult{}
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := &LineResult{Line: e.Line}
+		select {
+		case jobs <- verifyJob{entry: e, result: result}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		results = append(results, result)
+	}
+}
+
+// verifyEntry applies the Envelope and Statement layers of
+// docs/validation.md to a single entry.
+func (v *Verifier) verifyEntry(ctx context.Context, e *Entry, result *LineResult) {
+	switch {
+	case e.Err != nil:
+		result.Err = e.Err
+		return
+	case e.Envelope == nil:
+		result.Statement = e.Statement
+		result.Err = ErrUnsignedStatement
+		return
+	}
+
+	names, err := e.Envelope.AttesterNames(ctx, v.attesters...)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	result.Attesters = names
+
+	s, err := e.Envelope.Statement()
+	if err != nil {
+		result.Err = fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+		return
+	}
+	result.Statement = s
+}
+
+// Verify checks every line of a Bundle against the given attesters, with
+// one worker per CPU.
+func Verify(ctx context.Context, r *Reader, attesters ...dsse.Attester) ([]*LineResult, error) {
+	v, err := NewVerifier(attesters...)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Verify(ctx, r)
+}
@@ -0,0 +1,192 @@
+/*
+Tests for concurrent Bundle verification.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowVerifier delays every signature check until its context is done or
+// the delay has passed.
+type slowVerifier struct {
+	signature.Verifier
+	delay time.Duration
+}
+
+func (v *slowVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	select {
+	case <-time.After(v.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return v.Verifier.Verify(ctx, data, sig)
+}
+
+// writeLargeTestBundle writes n envelopes about distinct subjects.
+func writeLargeTestBundle(t testing.TB, n int, signer signature.Signer) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		env := createTestEnvelope(t, createTestStatement(t, fmt.Sprintf("artifact-%d", i), testProvenanceType), signer)
+		if err := w.WriteEnvelope(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &buf
+}
+
+func TestVerify(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	unknown := testutil.NewSigner(t)
+
+	invalid := &dsse.Envelope{
+		PayloadType: dsse.PayloadType,
+		Payload:     []byte(`{"_type":"https://in-toto.io/Statement/v1","subject":[],"predicateType":"x"}`),
+	}
+	assert.NoError(t, invalid.Sign(context.Background(), signer))
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "foo", testProvenanceType), signer)))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "foo", testProvenanceType), unknown)))
+	assert.NoError(t, w.WriteStatement(createTestStatement(t, "foo", testProvenanceType)))
+	buf.WriteString("not an attestation\n\n")
+	assert.NoError(t, w.WriteEnvelope(invalid))
+	assert.NoError(t, w.WriteEnvelope(createTestEnvelope(t, createTestStatement(t, "bar", testVSAV1Type), unknown, signer)))
+	bundle := buf.Bytes()
+
+	tests := map[string]int{
+		"one worker":    1,
+		"many workers":  8,
+		"more than CPU": runtime.GOMAXPROCS(0) * 4,
+	}
+
+	for name, workers := range tests {
+		v, err := NewVerifier(dsse.Attester{Name: "builder", Verifier: signer})
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		assert.NoError(t, v.SetWorkers(workers), fmt.Sprintf("unexpected error in test '%s'", name))
+
+		results, err := v.Verify(context.Background(), NewReader(bytes.NewReader(bundle)))
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		if !assert.Len(t, results, 6, fmt.Sprintf("unexpected results in test '%s'", name)) {
+			continue
+		}
+
+		for i, line := range []int{1, 2, 3, 4, 6, 7} {
+			assert.Equal(t, line, results[i].Line, fmt.Sprintf("results out of order in test '%s'", name))
+		}
+
+		assert.True(t, results[0].OK(), fmt.Sprintf("expected line 1 to pass in test '%s'", name))
+		assert.Equal(t, []string{"builder"}, results[0].Attesters)
+		assert.Equal(t, "foo", results[0].Statement.GetSubject()[0].GetName())
+		assert.ErrorIs(t, results[1].Err, dsse.ErrNoRecognizedAttester, fmt.Sprintf("expected error in test '%s'", name))
+		assert.ErrorIs(t, results[2].Err, ErrUnsignedStatement, fmt.Sprintf("expected error in test '%s'", name))
+		assert.NotNil(t, results[2].Statement)
+		assert.ErrorIs(t, results[3].Err, ErrUnrecognizedLine, fmt.Sprintf("expected error in test '%s'", name))
+		assert.ErrorIs(t, results[4].Err, ErrInvalidStatement, fmt.Sprintf("expected error in test '%s'", name))
+		assert.Equal(t, []string{"builder"}, results[4].Attesters)
+		assert.True(t, results[5].OK(), fmt.Sprintf("expected line 7 to pass in test '%s'", name))
+	}
+}
+
+func TestVerifyLargeBundle(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	n := 2000
+	if testing.Short() {
+		n = 200
+	}
+	bundle := writeLargeTestBundle(t, n, signer)
+
+	results, err := Verify(context.Background(), NewReader(bundle), dsse.Attester{Name: "builder", Verifier: signer})
+	assert.NoError(t, err)
+	assert.Len(t, results, n)
+	for i, r := range results {
+		assert.NoError(t, r.Err)
+		assert.Equal(t, fmt.Sprintf("artifact-%d", i), r.Statement.GetSubject()[0].GetName())
+	}
+}
+
+func TestVerifyCancellation(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	bundle := writeLargeTestBundle(t, 50, signer).Bytes()
+	slow := dsse.Attester{Name: "builder", Verifier: &slowVerifier{Verifier: signer, delay: time.Second}}
+
+	v, err := NewVerifier(slow)
+	assert.NoError(t, err)
+	assert.NoError(t, v.SetWorkers(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = v.Verify(ctx, NewReader(bytes.NewReader(bundle)))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = v.Verify(ctx, NewReader(bytes.NewReader(bundle)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestVerifierErrors(t *testing.T) {
+	_, err := NewVerifier()
+	assert.ErrorIs(t, err, dsse.ErrAttesterRequired)
+
+	_, err = Verify(context.Background(), NewReader(&bytes.Buffer{}), dsse.Attester{Name: "builder"})
+	assert.ErrorIs(t, err, dsse.ErrVerifierRequired)
+
+	signer := testutil.NewSigner(t)
+	v, err := NewVerifier(dsse.Attester{Name: "builder", Verifier: signer})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, v.SetWorkers(0), ErrInvalidWorkers)
+
+	ioErr := errors.New("disk on fire")
+	line := marshalTestLine(t, createTestEnvelope(t, createTestStatement(t, "foo", testProvenanceType), signer))
+	_, err = v.Verify(context.Background(), NewReader(&failingReader{r: strings.NewReader(line + "\n" + line), err: ioErr}))
+	assert.ErrorIs(t, err, ioErr)
+}
+
+func BenchmarkVerify(b *testing.B) {
+	signer := testutil.NewSigner(b)
+	attester := dsse.Attester{Name: "builder", Verifier: signer}
+	bundle := writeLargeTestBundle(b, 1000, signer).Bytes()
+
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			v, err := NewVerifier(attester)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := v.SetWorkers(workers); err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(int64(len(bundle)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := v.Verify(context.Background(), NewReader(bytes.NewReader(bundle))); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Bundle writer.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Writer writes a Bundle one line at a time. Each line is written with a
+// single call to the underlying writer, so that appending to a Bundle file
+// never leaves a partial line behind a successful write.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer that appends lines to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteEnvelope validates an envelope and writes it as a line.
+func (w *Writer) WriteEnvelope(e *dsse.Envelope) error {
+	if err := e.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return w.writeLine(data)
+}
+
+// WriteStatement validates an unsigned Statement and writes it as a line.
+// Bundles should hold envelopes: unsigned Statements are not authenticated.
+func (w *Writer) WriteStatement(s *ita1.Statement) error {
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidStatement, err)
+	}
+
+	data, err := protojson.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return w.writeLine(data)
+}
+
+// WriteEntry writes an entry read from another Bundle. Entries are written
+// from their raw line, compacted, so that copying a Bundle preserves its
+// signatures and any unrecognized JSON lines.
+func (w *Writer) WriteEntry(e *Entry) error {
+	switch {
+	case e.Raw != nil:
+		return w.writeLine(e.Raw)
+	case e.Envelope != nil:
+		return w.WriteEnvelope(e.Envelope)
+	case e.Statement != nil:
+		return w.WriteStatement(e.Statement)
+	default:
+		return ErrUnrecognizedLine
+	}
+}
+
+// writeLine compacts a JSON value, so that it fits on a single line, and
+// writes it followed by a newline.
+func (w *Writer) writeLine(data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnrecognizedLine, err)
+	}
+	buf.WriteByte('\n')
+
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
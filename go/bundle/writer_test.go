@@ -0,0 +1,97 @@
+/*
+Tests for the Bundle writer.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingWriter records the number of writes.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestWriterLines(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	env := createTestEnvelope(t, createTestStatement(t, "foo", "https://example.com/pred"), signer)
+
+	w := &countingWriter{}
+	bw := NewWriter(w)
+	assert.NoError(t, bw.WriteEnvelope(env))
+	assert.NoError(t, bw.WriteStatement(createTestStatement(t, "bar", "https://example.com/pred")))
+	assert.Equal(t, 2, w.writes, "each line must be a single write")
+
+	lines := strings.Split(w.String(), "\n")
+	assert.Len(t, lines, 3)
+	assert.Empty(t, lines[2], "missing final newline")
+	for _, line := range lines[:2] {
+		assert.NotContains(t, line, " ", "line is not compact")
+	}
+}
+
+func TestWriterInvalid(t *testing.T) {
+	tests := map[string]struct {
+		write       func(w *Writer) error
+		expectedErr error
+	}{
+		"unsigned envelope": {
+			write: func(w *Writer) error {
+				return w.WriteEnvelope(&dsse.Envelope{PayloadType: dsse.PayloadType, Payload: []byte("{}")})
+			},
+			expectedErr: ErrInvalidEnvelope,
+		},
+		"invalid statement": {
+			write: func(w *Writer) error {
+				return w.WriteStatement(&ita1.Statement{Type: ita1.StatementTypeUri})
+			},
+			expectedErr: ErrInvalidStatement,
+		},
+		"empty entry": {
+			write: func(w *Writer) error {
+				return w.WriteEntry(&Entry{Line: 1})
+			},
+			expectedErr: ErrUnrecognizedLine,
+		},
+		"raw entry that is not json": {
+			write: func(w *Writer) error {
+				return w.WriteEntry(&Entry{Line: 1, Raw: []byte("foo")})
+			},
+			expectedErr: ErrUnrecognizedLine,
+		},
+	}
+
+	for name, test := range tests {
+		var buf bytes.Buffer
+		err := test.write(NewWriter(&buf))
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		assert.Zero(t, buf.Len(), fmt.Sprintf("partial write in test '%s'", name))
+	}
+}
+
+func TestWriteEntryPreservesLines(t *testing.T) {
+	input := "{\"payloadType\": \"unknown/type\"}\n{\"foo\": [1, 2]}\n"
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range readAll(t, NewReader(strings.NewReader(input))) {
+		assert.NoError(t, w.WriteEntry(e))
+	}
+
+	assert.Equal(t, "{\"payloadType\":\"unknown/type\"}\n{\"foo\":[1,2]}\n", buf.String())
+}
@@ -0,0 +1,158 @@
+/*
+COSE signature algorithm identifiers for the key types supported by the
+signature package.
+*/
+
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+// Algorithm is a COSE algorithm identifier from the IANA "COSE Algorithms"
+// registry.
+type Algorithm int64
+
+const (
+	AlgorithmES256 Algorithm = -7
+	AlgorithmEdDSA Algorithm = -8
+	AlgorithmES384 Algorithm = -35
+	AlgorithmPS256 Algorithm = -37
+	AlgorithmPS384 Algorithm = -38
+	AlgorithmPS512 Algorithm = -39
+	AlgorithmRS256 Algorithm = -257
+	AlgorithmRS384 Algorithm = -258
+	AlgorithmRS512 Algorithm = -259
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported COSE algorithm")
+
+var algorithmNames = map[Algorithm]string{
+	AlgorithmES256: "ES256",
+	AlgorithmEdDSA: "EdDSA",
+	AlgorithmES384: "ES384",
+	AlgorithmPS256: "PS256",
+	AlgorithmPS384: "PS384",
+	AlgorithmPS512: "PS512",
+	AlgorithmRS256: "RS256",
+	AlgorithmRS384: "RS384",
+	AlgorithmRS512: "RS512",
+}
+
+func (a Algorithm) String() string {
+	if name, ok := algorithmNames[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Algorithm(%d)", int64(a))
+}
+
+// hasher is implemented by the ECDSA and RSA signers and verifiers.
+type hasher interface {
+	Hash() crypto.Hash
+}
+
+// AlgorithmFor returns the COSE algorithm produced by a signer, or accepted
+// by a verifier, from the signature package.
+//
+// RSA keys are only supported through the RSA signers and verifiers, since
+// the public key alone does not determine the padding scheme.
+func AlgorithmFor(key interface{ Public() crypto.PublicKey }) (Algorithm, error) {
+	switch k := key.(type) {
+	case *signature.RSAPSSSignerVerifier:
+		return rsaAlgorithm(k.Hash(), AlgorithmPS256, AlgorithmPS384, AlgorithmPS512)
+	case *signature.RSAPKCS1v15SignerVerifier:
+		return rsaAlgorithm(k.Hash(), AlgorithmRS256, AlgorithmRS384, AlgorithmRS512)
+	}
+
+	switch pub := key.Public().(type) {
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	case *ecdsa.PublicKey:
+		alg, h, err := ecdsaAlgorithm(pub)
+		if err != nil {
+			return 0, err
+		}
+
+		// COSE fixes the hash function for each curve
+		if k, ok := key.(hasher); ok && k.Hash() != h {
+			return 0, fmt.Errorf("%w: %s with %s", ErrUnsupportedAlgorithm, pub.Curve.Params().Name, k.Hash())
+		}
+
+		return alg, nil
+	default:
+		return 0, fmt.Errorf("%w: key type %T", ErrUnsupportedAlgorithm, pub)
+	}
+}
+
+func rsaAlgorithm(h crypto.Hash, sha256, sha384, sha512 Algorithm) (Algorithm, error) {
+	switch h {
+	case crypto.SHA256:
+		return sha256, nil
+	case crypto.SHA384:
+		return sha384, nil
+	case crypto.SHA512:
+		return sha512, nil
+	default:
+		return 0, fmt.Errorf("%w: RSA with %s", ErrUnsupportedAlgorithm, h)
+	}
+}
+
+func ecdsaAlgorithm(pub *ecdsa.PublicKey) (Algorithm, crypto.Hash, error) {
+	switch pub.Curve {
+	case elliptic.P256():
+		return AlgorithmES256, crypto.SHA256, nil
+	case elliptic.P384():
+		return AlgorithmES384, crypto.SHA384, nil
+	default:
+		return 0, 0, fmt.Errorf("%w: ECDSA curve %s", ErrUnsupportedAlgorithm, pub.Curve.Params().Name)
+	}
+}
+
+// ecdsaSignature is the ASN.1 structure of a DER-encoded ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ecdsaDERToRaw converts a DER-encoded ECDSA signature, as produced by the
+// signature package, to the fixed-length r || s encoding used by COSE.
+func ecdsaDERToRaw(pub *ecdsa.PublicKey, der []byte) ([]byte, error) {
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed ECDSA signature", signature.ErrInvalidSignature)
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || len(sig.R.Bytes()) > size || len(sig.S.Bytes()) > size {
+		return nil, fmt.Errorf("%w: malformed ECDSA signature", signature.ErrInvalidSignature)
+	}
+
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+
+	return raw, nil
+}
+
+// ecdsaRawToDER converts a COSE r || s ECDSA signature to DER.
+func ecdsaRawToDER(pub *ecdsa.PublicKey, raw []byte) ([]byte, error) {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(raw) != 2*size {
+		return nil, fmt.Errorf("%w: ECDSA signature is %d bytes, expected %d", signature.ErrInvalidSignature, len(raw), 2*size)
+	}
+
+	return asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(raw[:size]),
+		S: new(big.Int).SetBytes(raw[size:]),
+	})
+}
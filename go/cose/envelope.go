@@ -0,0 +1,343 @@
+/*
+Wrapper APIs for COSE_Sign envelopes (RFC 9052) carrying in-toto
+Statements, as permitted by ITE-5 and spec/v1/envelope.md.
+*/
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TagSign is the CBOR tag of a COSE_Sign structure.
+const TagSign = 98
+
+// sigContext is the context string of a COSE_Sign Sig_structure.
+const sigContext = "Signature"
+
+var (
+	ErrContentTypeRequired = errors.New("envelope content type required")
+	ErrPayloadRequired     = errors.New("envelope payload required")
+	ErrSignaturesRequired  = errors.New("envelope signatures required")
+	ErrSigRequired         = errors.New("signature required")
+	ErrAlgorithmRequired   = errors.New("signature algorithm required")
+	ErrUnsupportedTag      = errors.New("CBOR tag is not COSE_Sign")
+	ErrCriticalHeader      = errors.New("critical headers are not supported")
+)
+
+var encMode, decMode = func() (cbor.EncMode, cbor.DecMode) {
+	opts := cbor.CoreDetEncOptions()
+	// protected headers are bstrs even when empty
+	opts.NilContainers = cbor.NilContainerAsEmpty
+	em, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	dm, err := cbor.DecOptions{
+		DupMapKey:   cbor.DupMapKeyEnforcedAPF,
+		IndefLength: cbor.IndefLengthForbidden,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return em, dm
+}()
+
+// Envelope is a COSE_Sign structure with an attached payload.
+//
+// The encoded protected headers of the body and of each signature are kept
+// exactly as received, since signatures cover those bytes. ContentType must
+// therefore not be changed after an envelope is parsed or signed.
+type Envelope struct {
+	// ContentType is the media type of the payload, carried in the body's
+	// protected header. It is required there, as Statement relies on it.
+	ContentType string
+	Payload     []byte
+	Signatures  []Signature
+
+	protected []byte
+}
+
+// Signature is a single COSE_Signature.
+type Signature struct {
+	// Algorithm is carried in the signature's protected header.
+	Algorithm Algorithm
+	// KeyID is the kid header, carried in the unprotected header.
+	KeyID string
+	Sig   []byte
+
+	protected []byte
+}
+
+// header is the subset of a COSE header map understood by this package.
+// Other labels are ignored.
+type header struct {
+	Algorithm   *int64 `cbor:"1,keyasint,omitempty"`
+	Critical    []any  `cbor:"2,keyasint,omitempty"`
+	ContentType string `cbor:"3,keyasint,omitempty"`
+	KeyID       []byte `cbor:"4,keyasint,omitempty"`
+}
+
+// coseSign and coseSignature are the wire representations of Envelope and
+// Signature.
+type coseSign struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected header
+	Payload     []byte
+	Signatures  []coseSignature
+}
+
+type coseSignature struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected header
+	Signature   []byte
+}
+
+// NewEnvelope serializes a Statement into the payload of a new, unsigned
+// envelope. The content type is the same media type DSSE would use as the
+// payloadType.
+func NewEnvelope(s *ita1.Statement) (*Envelope, error) {
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	payload, err := protojson.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+
+	return &Envelope{
+		ContentType: dsse.PayloadTypeFor(s.GetPredicateType()),
+		Payload:     payload,
+	}, nil
+}
+
+// Parse decodes a CBOR-encoded COSE_Sign structure, either tagged or
+// untagged, and checks its required fields.
+func Parse(data []byte) (*Envelope, error) {
+	e := &Envelope{}
+	if err := e.UnmarshalCBOR(data); err != nil {
+		return nil, err
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Validate checks that all of the fields required to verify the envelope
+// are set.
+func (e *Envelope) Validate() error {
+	if e.ContentType == "" {
+		return ErrContentTypeRequired
+	}
+
+	if len(e.Payload) == 0 {
+		return ErrPayloadRequired
+	}
+
+	if len(e.Signatures) == 0 {
+		return ErrSignaturesRequired
+	}
+
+	for i, sig := range e.Signatures {
+		if sig.Algorithm == 0 {
+			return fmt.Errorf("signatures[%d]: %w", i, ErrAlgorithmRequired)
+		}
+
+		if len(sig.Sig) == 0 {
+			return fmt.Errorf("signatures[%d]: %w", i, ErrSigRequired)
+		}
+	}
+
+	return nil
+}
+
+// Statement decodes the envelope's payload into an in-toto Statement and
+// validates it.
+//
+// This does not verify any signatures: callers MUST verify the envelope
+// before trusting the returned Statement.
+func (e *Envelope) Statement() (*ita1.Statement, error) {
+	if !dsse.IsInTotoPayloadType(e.ContentType) {
+		return nil, fmt.Errorf("%w: %q", dsse.ErrNotInTotoPayloadType, e.ContentType)
+	}
+
+	s := &ita1.Statement{}
+	// consumers must ignore unrecognized fields, per the spec's parsing rules
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(e.Payload, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	return s, nil
+}
+
+// bodyProtected returns the encoded protected header of the body.
+func (e *Envelope) bodyProtected() ([]byte, error) {
+	if e.protected != nil {
+		return e.protected, nil
+	}
+
+	return encMode.Marshal(header{ContentType: e.ContentType})
+}
+
+// sigStructure returns the Sig_structure signed by a COSE_Signature with the
+// given encoded protected header.
+func (e *Envelope) sigStructure(signProtected []byte) ([]byte, error) {
+	bodyProtected, err := e.bodyProtected()
+	if err != nil {
+		return nil, err
+	}
+
+	return encMode.Marshal([]any{sigContext, bodyProtected, signProtected, []byte{}, e.Payload})
+}
+
+func (e Envelope) MarshalCBOR() ([]byte, error) {
+	bodyProtected, err := e.bodyProtected()
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := make([]coseSignature, 0, len(e.Signatures))
+	for i, sig := range e.Signatures {
+		protected, err := sig.signProtected()
+		if err != nil {
+			return nil, fmt.Errorf("signatures[%d]: %w", i, err)
+		}
+
+		h, err := decodeProtected(protected)
+		if err != nil {
+			return nil, fmt.Errorf("signatures[%d]: protected header: %w", i, err)
+		}
+
+		// keep parsed headers that were not protected where they were found
+		unprotected := header{}
+		if h.Algorithm == nil {
+			alg := int64(sig.Algorithm)
+			unprotected.Algorithm = &alg
+		}
+		if len(h.KeyID) == 0 {
+			unprotected.KeyID = []byte(sig.KeyID)
+		}
+
+		sigs = append(sigs, coseSignature{
+			Protected:   protected,
+			Unprotected: unprotected,
+			Signature:   sig.Sig,
+		})
+	}
+
+	return encMode.Marshal(cbor.Tag{
+		Number: TagSign,
+		Content: coseSign{
+			Protected:  bodyProtected,
+			Payload:    e.Payload,
+			Signatures: sigs,
+		},
+	})
+}
+
+func (e *Envelope) UnmarshalCBOR(data []byte) error {
+	var tag cbor.RawTag
+	if err := decMode.Unmarshal(data, &tag); err == nil {
+		if tag.Number != TagSign {
+			return fmt.Errorf("%w: %d", ErrUnsupportedTag, tag.Number)
+		}
+		data = tag.Content
+	}
+
+	var raw coseSign
+	if err := decMode.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	body, err := decodeProtected(raw.Protected)
+	if err != nil {
+		return fmt.Errorf("protected header: %w", err)
+	}
+
+	sigs := make([]Signature, 0, len(raw.Signatures))
+	for i, s := range raw.Signatures {
+		protected, err := decodeProtected(s.Protected)
+		if err != nil {
+			return fmt.Errorf("signatures[%d]: protected header: %w", i, err)
+		}
+
+		sig := Signature{KeyID: string(s.Unprotected.KeyID), Sig: s.Signature, protected: s.Protected}
+		if sig.protected == nil {
+			sig.protected = []byte{}
+		}
+		// alg should be protected, but may appear in either header
+		if protected.Algorithm != nil {
+			sig.Algorithm = Algorithm(*protected.Algorithm)
+		} else if s.Unprotected.Algorithm != nil {
+			sig.Algorithm = Algorithm(*s.Unprotected.Algorithm)
+		}
+		if len(protected.KeyID) > 0 {
+			sig.KeyID = string(protected.KeyID)
+		}
+
+		sigs = append(sigs, sig)
+	}
+
+	if raw.Protected == nil {
+		raw.Protected = []byte{}
+	}
+
+	// the content type is only trusted when signed, so one in the
+	// unprotected header is ignored (RFC 9052, section 3.1)
+	*e = Envelope{
+		ContentType: body.ContentType,
+		Payload:     raw.Payload,
+		Signatures:  sigs,
+		protected:   raw.Protected,
+	}
+
+	return nil
+}
+
+// signProtected returns the encoded protected header of the signature.
+func (s *Signature) signProtected() ([]byte, error) {
+	if s.protected != nil {
+		return s.protected, nil
+	}
+
+	alg := int64(s.Algorithm)
+	return encMode.Marshal(header{Algorithm: &alg})
+}
+
+// decodeProtected decodes a protected header, which is either empty or the
+// CBOR encoding of a header map.
+func decodeProtected(data []byte) (*header, error) {
+	h := &header{}
+	if len(data) == 0 {
+		return h, nil
+	}
+
+	if err := decMode.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+
+	// unknown critical headers must cause the message to be rejected
+	if len(h.Critical) > 0 {
+		return nil, fmt.Errorf("%w: %v", ErrCriticalHeader, h.Critical)
+	}
+
+	return h, nil
+}
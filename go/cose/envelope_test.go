@@ -0,0 +1,180 @@
+/*
+Tests for COSE_Sign envelope encoding and decoding.
+*/
+
+package cose
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func createTestStatement(t *testing.T) *ita1.Statement {
+	t.Helper()
+
+	pred, err := structpb.NewStruct(map[string]interface{}{"keyObj": "theValue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ita1.Statement{
+		Type: ita1.StatementTypeUri,
+		Subject: []*ita1.ResourceDescriptor{{
+			Name:   "theSub",
+			Digest: map[string]string{"sha256": "a1234567b1234567c1234567d1234567e1234567f1234567a1234567b1234567"},
+		}},
+		PredicateType: "https://slsa.dev/provenance/v1",
+		Predicate:     pred,
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	want := createTestStatement(t)
+
+	env, err := NewEnvelope(want)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.in-toto.provenance+json", env.ContentType)
+
+	signer := testutil.NewSigner(t)
+	assert.NoError(t, env.Sign(context.Background(), signer))
+
+	data, err := cbor.Marshal(env)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xd8, TagSign}, data[:2], "COSE_Sign tag missing")
+
+	parsed, err := Parse(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, env.ContentType, parsed.ContentType)
+	assert.Equal(t, env.Payload, parsed.Payload)
+	if assert.Len(t, parsed.Signatures, 1) {
+		keyID, _ := signer.KeyID()
+		assert.Equal(t, keyID, parsed.Signatures[0].KeyID)
+		assert.Equal(t, AlgorithmEdDSA, parsed.Signatures[0].Algorithm)
+	}
+
+	got, err := parsed.Statement()
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(want, got), "protos do not match")
+
+	// re-encoding a parsed envelope is lossless
+	again, err := cbor.Marshal(parsed)
+	assert.NoError(t, err)
+	assert.Equal(t, data, again)
+}
+
+func TestParseUntagged(t *testing.T) {
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+	assert.NoError(t, env.Sign(context.Background(), testutil.NewSigner(t)))
+
+	data, err := cbor.Marshal(env)
+	assert.NoError(t, err)
+
+	parsed, err := Parse(data[2:])
+	assert.NoError(t, err)
+	assert.Equal(t, env.Payload, parsed.Payload)
+}
+
+func TestParseBadEnvelopes(t *testing.T) {
+	alg := int64(AlgorithmEdDSA)
+	protected := func(h header) []byte {
+		b, err := encMode.Marshal(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	encode := func(v any) []byte {
+		b, err := encMode.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	body := protected(header{ContentType: dsse.PayloadType})
+	sig := coseSignature{Protected: protected(header{Algorithm: &alg}), Signature: []byte("sig")}
+
+	tests := map[string]struct {
+		input []byte
+		err   error
+	}{
+		"wrong tag": {
+			input: encode(cbor.Tag{Number: 18, Content: coseSign{Protected: body, Payload: []byte("{}"), Signatures: []coseSignature{sig}}}),
+			err:   ErrUnsupportedTag,
+		},
+		"detached payload": {
+			input: encode(coseSign{Protected: body, Signatures: []coseSignature{sig}}),
+			err:   ErrPayloadRequired,
+		},
+		"no content type": {
+			input: encode(coseSign{Payload: []byte("{}"), Signatures: []coseSignature{sig}}),
+			err:   ErrContentTypeRequired,
+		},
+		"unprotected content type": {
+			input: encode(coseSign{Unprotected: header{ContentType: dsse.PayloadType}, Payload: []byte("{}"), Signatures: []coseSignature{sig}}),
+			err:   ErrContentTypeRequired,
+		},
+		"no signatures": {
+			input: encode(coseSign{Protected: body, Payload: []byte("{}")}),
+			err:   ErrSignaturesRequired,
+		},
+		"no algorithm": {
+			input: encode(coseSign{Protected: body, Payload: []byte("{}"), Signatures: []coseSignature{{Signature: []byte("sig")}}}),
+			err:   ErrAlgorithmRequired,
+		},
+		"critical header": {
+			input: encode(coseSign{Protected: protected(header{ContentType: dsse.PayloadType, Critical: []any{99}}), Payload: []byte("{}"), Signatures: []coseSignature{sig}}),
+			err:   ErrCriticalHeader,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := Parse(test.input)
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("parsed bad envelope in test '%s'", name))
+	}
+
+	_, err := Parse([]byte("not cbor"))
+	assert.Error(t, err)
+}
+
+func TestUnprotectedAlgorithm(t *testing.T) {
+	alg := int64(AlgorithmEdDSA)
+	data, err := encMode.Marshal(coseSign{
+		Protected: func() []byte {
+			b, _ := encMode.Marshal(header{ContentType: dsse.PayloadType})
+			return b
+		}(),
+		Payload:    []byte("{}"),
+		Signatures: []coseSignature{{Unprotected: header{Algorithm: &alg}, Signature: []byte("sig")}},
+	})
+	assert.NoError(t, err)
+
+	env, err := Parse(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, AlgorithmEdDSA, env.Signatures[0].Algorithm)
+
+	// the algorithm stays unprotected when re-encoded
+	again, err := cbor.Marshal(env)
+	assert.NoError(t, err)
+	assert.Equal(t, data, again[2:])
+}
+
+func TestStatementNotInToto(t *testing.T) {
+	env := &Envelope{ContentType: "application/json", Payload: []byte("{}")}
+	_, err := env.Statement()
+	assert.ErrorIs(t, err, dsse.ErrNotInTotoPayloadType)
+}
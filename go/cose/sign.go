@@ -0,0 +1,158 @@
+/*
+Signing and verification APIs for COSE_Sign envelopes.
+*/
+
+package cose
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+var (
+	ErrSignerRequired   = errors.New("at least one signer required")
+	ErrVerifierRequired = errors.New("at least one verifier required")
+	ErrNoValidSignature = errors.New("no signature matches a recognized key")
+)
+
+// Sign signs the envelope with each signer and appends the resulting
+// COSE_Signatures, leaving the payload and any existing signatures
+// untouched. The algorithm of each signature is derived from its signer
+// with AlgorithmFor.
+func (e *Envelope) Sign(ctx context.Context, signers ...signature.Signer) error {
+	if len(signers) == 0 {
+		return ErrSignerRequired
+	}
+
+	if e.ContentType == "" {
+		return ErrContentTypeRequired
+	}
+
+	if len(e.Payload) == 0 {
+		return ErrPayloadRequired
+	}
+
+	bodyProtected, err := e.bodyProtected()
+	if err != nil {
+		return err
+	}
+	// pin the body header, so that later signatures cover the same bytes
+	e.protected = bodyProtected
+
+	sigs := make([]Signature, 0, len(signers))
+	for i, s := range signers {
+		sig, err := e.sign(ctx, s)
+		if err != nil {
+			return fmt.Errorf("signers[%d]: %w", i, err)
+		}
+
+		sigs = append(sigs, *sig)
+	}
+
+	e.Signatures = append(e.Signatures, sigs...)
+
+	return nil
+}
+
+func (e *Envelope) sign(ctx context.Context, s signature.Signer) (*Signature, error) {
+	alg, err := AlgorithmFor(s)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, err := s.KeyID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keyid: %w", err)
+	}
+
+	sig := &Signature{Algorithm: alg, KeyID: keyID}
+	if sig.protected, err = sig.signProtected(); err != nil {
+		return nil, err
+	}
+
+	tbs, err := e.sigStructure(sig.protected)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig.Sig, err = s.Sign(ctx, tbs); err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	if pub, ok := s.Public().(*ecdsa.PublicKey); ok {
+		if sig.Sig, err = ecdsaDERToRaw(pub, sig.Sig); err != nil {
+			return nil, err
+		}
+	}
+
+	return sig, nil
+}
+
+// Verify checks the envelope's signatures against the given verifiers and
+// returns the verifiers that matched at least one signature. A signature
+// is only checked by verifiers for its algorithm. Verify fails with
+// ErrNoValidSignature if no verifier matched.
+func (e *Envelope) Verify(ctx context.Context, verifiers ...signature.Verifier) ([]signature.Verifier, error) {
+	if len(verifiers) == 0 {
+		return nil, ErrVerifierRequired
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	matched := make([]signature.Verifier, 0, len(verifiers))
+	for i, v := range verifiers {
+		alg, err := AlgorithmFor(v)
+		if err != nil {
+			return nil, fmt.Errorf("verifiers[%d]: %w", i, err)
+		}
+
+		for _, sig := range e.Signatures {
+			if sig.Algorithm != alg {
+				continue
+			}
+
+			err := e.verify(ctx, v, &sig)
+			if err == nil {
+				matched = append(matched, v)
+				break
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, ErrNoValidSignature
+	}
+
+	return matched, nil
+}
+
+func (e *Envelope) verify(ctx context.Context, v signature.Verifier, sig *Signature) error {
+	protected, err := sig.signProtected()
+	if err != nil {
+		return err
+	}
+
+	tbs, err := e.sigStructure(protected)
+	if err != nil {
+		return err
+	}
+
+	s := sig.Sig
+	if pub, ok := v.Public().(*ecdsa.PublicKey); ok {
+		if s, err = ecdsaRawToDER(pub, s); err != nil {
+			return err
+		}
+	}
+
+	return v.Verify(ctx, tbs, s)
+}
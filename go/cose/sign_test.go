@@ -0,0 +1,155 @@
+/*
+Tests for signing and verifying COSE_Sign envelopes.
+*/
+
+package cose
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestSigners(t *testing.T) map[Algorithm]signature.SignerVerifier {
+	t.Helper()
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svs := map[Algorithm]signature.SignerVerifier{AlgorithmEdDSA: testutil.NewSigner(t)}
+	if svs[AlgorithmES256], err = signature.NewECDSASignerVerifier(p256); err != nil {
+		t.Fatal(err)
+	}
+	if svs[AlgorithmES384], err = signature.NewECDSASignerVerifier(p384); err != nil {
+		t.Fatal(err)
+	}
+	if svs[AlgorithmPS256], err = signature.NewRSAPSSSignerVerifier(rsaKey); err != nil {
+		t.Fatal(err)
+	}
+	if svs[AlgorithmRS512], err = signature.NewRSAPKCS1v15SignerVerifier(rsaKey, signature.WithHash(crypto.SHA512)); err != nil {
+		t.Fatal(err)
+	}
+
+	return svs
+}
+
+func TestSignVerify(t *testing.T) {
+	ctx := context.Background()
+
+	for alg, sv := range createTestSigners(t) {
+		env, err := NewEnvelope(createTestStatement(t))
+		assert.NoError(t, err)
+		assert.NoError(t, env.Sign(ctx, sv), fmt.Sprintf("error signing with %s", alg))
+		assert.Equal(t, alg, env.Signatures[0].Algorithm)
+
+		data, err := cbor.Marshal(env)
+		assert.NoError(t, err)
+		parsed, err := Parse(data)
+		assert.NoError(t, err)
+
+		matched, err := parsed.Verify(ctx, sv)
+		assert.NoError(t, err, fmt.Sprintf("error verifying with %s", alg))
+		assert.Len(t, matched, 1)
+
+		parsed.Payload = append([]byte{}, parsed.Payload...)
+		parsed.Payload[0] ^= 0xff
+		_, err = parsed.Verify(ctx, sv)
+		assert.ErrorIs(t, err, ErrNoValidSignature, fmt.Sprintf("verified tampered payload with %s", alg))
+	}
+}
+
+func TestSignMultiple(t *testing.T) {
+	ctx := context.Background()
+	svs := createTestSigners(t)
+
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+	assert.NoError(t, env.Sign(ctx, svs[AlgorithmEdDSA]))
+	assert.NoError(t, env.Sign(ctx, svs[AlgorithmES256]))
+	assert.Len(t, env.Signatures, 2)
+
+	matched, err := env.Verify(ctx, svs[AlgorithmES256], svs[AlgorithmEdDSA], svs[AlgorithmES384])
+	assert.NoError(t, err)
+	assert.Equal(t, []signature.Verifier{svs[AlgorithmES256], svs[AlgorithmEdDSA]}, matched)
+}
+
+func TestECDSASignatureEncoding(t *testing.T) {
+	sv := createTestSigners(t)[AlgorithmES256]
+
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+	assert.NoError(t, env.Sign(context.Background(), sv))
+
+	// COSE uses fixed-length r || s, not DER
+	sig := env.Signatures[0]
+	if !assert.Len(t, sig.Sig, 64) {
+		return
+	}
+
+	tbs, err := env.sigStructure(sig.protected)
+	assert.NoError(t, err)
+	digest := crypto.SHA256.New()
+	digest.Write(tbs)
+
+	r := new(big.Int).SetBytes(sig.Sig[:32])
+	s := new(big.Int).SetBytes(sig.Sig[32:])
+	assert.True(t, ecdsa.Verify(sv.Public().(*ecdsa.PublicKey), digest.Sum(nil), r, s))
+}
+
+func TestAlgorithmFor(t *testing.T) {
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched, err := signature.NewECDSASignerVerifier(p256, signature.WithHash(crypto.SHA512))
+	assert.NoError(t, err)
+
+	_, err = AlgorithmFor(mismatched)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	_, err = AlgorithmFor(p224)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	alg, err := AlgorithmFor(p256)
+	assert.NoError(t, err)
+	assert.Equal(t, "ES256", alg.String())
+	assert.Equal(t, "Algorithm(-65535)", Algorithm(-65535).String())
+}
+
+func TestSignVerifyErrors(t *testing.T) {
+	ctx := context.Background()
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, env.Sign(ctx), ErrSignerRequired)
+	_, err = env.Verify(ctx)
+	assert.ErrorIs(t, err, ErrVerifierRequired)
+	_, err = env.Verify(ctx, testutil.NewSigner(t))
+	assert.ErrorIs(t, err, ErrSignaturesRequired)
+	assert.ErrorIs(t, (&Envelope{ContentType: "a"}).Sign(ctx, testutil.NewSigner(t)), ErrPayloadRequired)
+}
@@ -0,0 +1,385 @@
+/*
+Discovery of the attestations about an artifact, in Bundle and envelope
+files named following spec/v1/bundle.md and spec/v1/envelope.md.
+*/
+
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/in-toto/attestation/go/bundle"
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/in-toto/attestation/go/validation"
+)
+
+var ErrArtifactIsDir = errors.New("artifact is a directory")
+
+// FileKind is the kind of a candidate attestation file.
+type FileKind string
+
+const (
+	KindBundle   FileKind = "bundle"
+	KindEnvelope FileKind = "envelope"
+)
+
+// File is a candidate attestation file.
+type File struct {
+	// Path is the file's path within the searched file system.
+	Path string
+	Kind FileKind
+}
+
+// Attestation is an attestation about the artifact.
+type Attestation struct {
+	// Path is the file the attestation was found in.
+	Path string
+	// Line is the attestation's line number in a Bundle file, or 0 for an
+	// envelope file.
+	Line            int
+	Envelope        *dsse.Envelope
+	Statement       *ita1.Statement
+	MatchedSubjects []*ita1.ResourceDescriptor
+	// Attesters are the names of the recognized attesters that signed the
+	// envelope. It is empty if no attesters were given with WithAttesters.
+	Attesters []string
+}
+
+// Skipped is a candidate file or Bundle line that holds no attestation
+// about the artifact.
+type Skipped struct {
+	Path string
+	Line int
+	Err  error
+}
+
+// Result is the outcome of discovery.
+type Result struct {
+	// Attestations are the attestations about the artifact, ordered by
+	// file and line.
+	Attestations []*Attestation
+	Skipped      []Skipped
+}
+
+type options struct {
+	digestAlgorithms []ita1.HashAlgorithm
+	attesters        []dsse.Attester
+}
+
+// Option configures discovery.
+type Option func(*options)
+
+// WithDigestAlgorithms sets the algorithms used to match subjects against
+// the artifact, which default to sha256.
+func WithDigestAlgorithms(algs ...ita1.HashAlgorithm) Option {
+	return func(o *options) {
+		o.digestAlgorithms = append(o.digestAlgorithms, algs...)
+	}
+}
+
+// WithAttesters restricts discovery to envelopes signed by at least one of
+// the recognized attesters. Without it, attestations are returned
+// unverified and callers must verify them before trusting them.
+func WithAttesters(attesters ...dsse.Attester) Option {
+	return func(o *options) {
+		o.attesters = append(o.attesters, attesters...)
+	}
+}
+
+// Candidates returns the candidate attestation files for the artifact at
+// the given path: the Bundle <filename>.intoto.jsonl and the envelope files
+// in the artifact's directory, sorted by path. Only existing files are
+// returned.
+func Candidates(fsys fs.FS, artifact string) ([]File, error) {
+	dir, name := path.Split(artifact)
+	dir = path.Clean(dir)
+
+	files := []File{}
+	bundlePath := path.Join(dir, name+bundle.FileSuffix)
+	if info, err := fs.Stat(fsys, bundlePath); err == nil && !info.IsDir() {
+		files = append(files, File{Path: bundlePath, Kind: KindBundle})
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	envelopes, err := dsse.FindFiles(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range envelopes {
+		files = append(files, File{Path: f.Name, Kind: KindEnvelope})
+	}
+	sortFiles(files)
+
+	return files, nil
+}
+
+// CandidatesInTree returns every Bundle and envelope file under dir,
+// sorted by path.
+func CandidatesInTree(fsys fs.FS, dir string) ([]File, error) {
+	files := []File{}
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(d.Name(), bundle.FileSuffix) {
+			files = append(files, File{Path: p, Kind: KindBundle})
+		} else if _, err := dsse.ParseFileName(d.Name()); err == nil {
+			files = append(files, File{Path: p, Kind: KindEnvelope})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortFiles(files)
+
+	return files, nil
+}
+
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+}
+
+// Discover returns the attestations about the artifact at the given path
+// found next to it, in the files returned by Candidates.
+func Discover(ctx context.Context, fsys fs.FS, artifact string, opts ...Option) (*Result, error) {
+	files, err := Candidates(fsys, artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(ctx, fsys, artifact, files, opts...)
+}
+
+// DiscoverInTree returns the attestations about the artifact at the given
+// path found anywhere under dir, in the files returned by
+// CandidatesInTree.
+func DiscoverInTree(ctx context.Context, fsys fs.FS, artifact, dir string, opts ...Option) (*Result, error) {
+	files, err := CandidatesInTree(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(ctx, fsys, artifact, files, opts...)
+}
+
+// Load digests the artifact at the given path and returns the attestations
+// about it in the given files. Files and lines that cannot be read as
+// attestations about the artifact are reported in Result.Skipped.
+func Load(ctx context.Context, fsys fs.FS, artifact string, files []File, opts ...Option) (*Result, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(o.digestAlgorithms) == 0 {
+		o.digestAlgorithms = []ita1.HashAlgorithm{ita1.AlgorithmSHA256}
+	}
+
+	for _, a := range o.attesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := openArtifact(fsys, artifact)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l := &loader{ctx: ctx, opts: o}
+	for _, file := range files {
+		if err := l.load(fsys, file); err != nil {
+			return nil, err
+		}
+	}
+
+	// the sizes of some digests are only known once the Statements are read
+	var subjects []*ita1.ResourceDescriptor
+	for _, c := range l.candidates {
+		if c.statement != nil {
+			subjects = append(subjects, c.statement.GetSubject()...)
+		}
+	}
+
+	digests, err := validation.ComputeDigests(f, o.digestAlgorithms, subjects...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, c := range l.candidates {
+		if err := l.match(result, c, digests); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func openArtifact(fsys fs.FS, artifact string) (fs.File, error) {
+	f, err := fsys.Open(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrArtifactIsDir, artifact)
+	}
+
+	return f, nil
+}
+
+// candidate is an envelope found in a candidate file, with its Statement,
+// or the reason it was skipped.
+type candidate struct {
+	path      string
+	line      int
+	envelope  *dsse.Envelope
+	statement *ita1.Statement
+	err       error
+}
+
+// loader collects the candidate attestations about an artifact from
+// candidate files.
+type loader struct {
+	ctx        context.Context
+	opts       *options
+	candidates []*candidate
+}
+
+func (l *loader) skip(p string, line int, err error) {
+	l.candidates = append(l.candidates, &candidate{path: p, line: line, err: err})
+}
+
+// load reads a candidate file. It only fails if the context is done: other
+// errors are reported as skipped files.
+func (l *loader) load(fsys fs.FS, file File) error {
+	if file.Kind == KindEnvelope {
+		data, err := fs.ReadFile(fsys, file.Path)
+		if err != nil {
+			l.skip(file.Path, 0, err)
+			return nil
+		}
+
+		env, err := dsse.Parse(data)
+		if err != nil {
+			l.skip(file.Path, 0, err)
+			return nil
+		}
+
+		return l.add(file.Path, 0, env)
+	}
+
+	f, err := fsys.Open(file.Path)
+	if err != nil {
+		l.skip(file.Path, 0, err)
+		return nil
+	}
+	defer f.Close()
+
+	r := bundle.NewReader(f)
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			l.skip(file.Path, 0, err)
+			return nil
+		}
+
+		switch {
+		case e.Err != nil:
+			l.skip(file.Path, e.Line, e.Err)
+		case e.Envelope == nil:
+			l.skip(file.Path, e.Line, bundle.ErrUnsignedStatement)
+		default:
+			if err := l.add(file.Path, e.Line, e.Envelope); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// add records an envelope and its Statement as a candidate attestation.
+func (l *loader) add(p string, line int, env *dsse.Envelope) error {
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+
+	s, err := env.Statement()
+	if err != nil {
+		l.skip(p, line, err)
+		return nil
+	}
+
+	l.candidates = append(l.candidates, &candidate{path: p, line: line, envelope: env, statement: s})
+
+	return nil
+}
+
+// match records a candidate in the result, as an attestation if it is about
+// the artifact and skipped otherwise.
+func (l *loader) match(result *Result, c *candidate, digests validation.Digests) error {
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+
+	skip := func(err error) {
+		result.Skipped = append(result.Skipped, Skipped{Path: c.path, Line: c.line, Err: err})
+	}
+
+	if c.err != nil {
+		skip(c.err)
+		return nil
+	}
+
+	matched := validation.MatchSubjects(c.statement.GetSubject(), digests)
+	if len(matched) == 0 {
+		skip(validation.ErrNoMatchedSubjects)
+		return nil
+	}
+
+	a := &Attestation{Path: c.path, Line: c.line, Envelope: c.envelope, Statement: c.statement, MatchedSubjects: matched}
+	if len(l.opts.attesters) > 0 {
+		names, err := c.envelope.AttesterNames(l.ctx, l.opts.attesters...)
+		if errors.Is(err, dsse.ErrNoRecognizedAttester) {
+			skip(err)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		a.Attesters = names
+	}
+
+	result.Attestations = append(result.Attestations, a)
+
+	return nil
+}
@@ -0,0 +1,311 @@
+/*
+Tests for attestation discovery.
+*/
+
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/in-toto/attestation/go/bundle"
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/in-toto/attestation/go/validation"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// createTestEnvelope returns an envelope about an artifact with the given
+// contents, with a single subject digested with the given algorithm.
+func createTestEnvelope(t *testing.T, contents string, alg ita1.HashAlgorithm, signer signature.Signer) *dsse.Envelope {
+	t.Helper()
+
+	var digest string
+	switch alg {
+	case ita1.AlgorithmSHA256:
+		sum := sha256.Sum256([]byte(contents))
+		digest = hex.EncodeToString(sum[:])
+	case ita1.AlgorithmSHA512:
+		sum := sha512.Sum512([]byte(contents))
+		digest = hex.EncodeToString(sum[:])
+	default:
+		t.Fatalf("unsupported algorithm %s", alg)
+	}
+
+	pred, err := structpb.NewStruct(map[string]interface{}{"contents": contents})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.NewEnvelope(&ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       []*ita1.ResourceDescriptor{{Name: "artifact", Digest: map[string]string{string(alg): digest}}},
+		PredicateType: "https://example.com/pred/v1",
+		Predicate:     pred,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.Sign(context.Background(), signer); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func marshalEnvelope(t *testing.T, env *dsse.Envelope) *fstest.MapFile {
+	t.Helper()
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fstest.MapFile{Data: data}
+}
+
+func marshalBundle(t *testing.T, envelopes ...*dsse.Envelope) *fstest.MapFile {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := bundle.NewWriter(&buf)
+	for _, env := range envelopes {
+		if err := w.WriteEnvelope(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteString("not an attestation\n")
+
+	return &fstest.MapFile{Data: buf.Bytes()}
+}
+
+// createTestFS lays out a release directory, with attestations next to the
+// artifacts and in an attestations/ directory.
+func createTestFS(t *testing.T, builder, other signature.Signer) fstest.MapFS {
+	t.Helper()
+
+	return fstest.MapFS{
+		"dist/foo.tar.gz": {Data: []byte("foo")},
+		"dist/bar.tar.gz": {Data: []byte("bar")},
+		"dist/foo.tar.gz.intoto.jsonl": marshalBundle(t,
+			createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, builder),
+			createTestEnvelope(t, "bar", ita1.AlgorithmSHA256, builder),
+		),
+		"dist/bar.tar.gz.intoto.jsonl":   marshalBundle(t, createTestEnvelope(t, "bar", ita1.AlgorithmSHA256, builder)),
+		"dist/build.0123abcd.json":       marshalEnvelope(t, createTestEnvelope(t, "foo", ita1.AlgorithmSHA512, builder)),
+		"dist/test.json":                 marshalEnvelope(t, createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, other)),
+		"dist/package.json":              {Data: []byte(`{"name": "foo"}`)},
+		"dist/README.md":                 {Data: []byte("readme")},
+		"attestations/scan.intoto.jsonl": marshalBundle(t, createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, other)),
+		"attestations/old/review.json":   marshalEnvelope(t, createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, builder)),
+	}
+}
+
+func attestationLocations(result *Result) []string {
+	out := []string{}
+	for _, a := range result.Attestations {
+		out = append(out, fmt.Sprintf("%s:%d", a.Path, a.Line))
+	}
+
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	fsys := createTestFS(t, testutil.NewSigner(t), testutil.NewSigner(t))
+
+	files, err := Candidates(fsys, "dist/foo.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, []File{
+		{Path: "dist/build.0123abcd.json", Kind: KindEnvelope},
+		{Path: "dist/foo.tar.gz.intoto.jsonl", Kind: KindBundle},
+		{Path: "dist/package.json", Kind: KindEnvelope},
+		{Path: "dist/test.json", Kind: KindEnvelope},
+	}, files)
+
+	files, err = CandidatesInTree(fsys, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, []File{
+		{Path: "attestations/old/review.json", Kind: KindEnvelope},
+		{Path: "attestations/scan.intoto.jsonl", Kind: KindBundle},
+		{Path: "dist/bar.tar.gz.intoto.jsonl", Kind: KindBundle},
+		{Path: "dist/build.0123abcd.json", Kind: KindEnvelope},
+		{Path: "dist/foo.tar.gz.intoto.jsonl", Kind: KindBundle},
+		{Path: "dist/package.json", Kind: KindEnvelope},
+		{Path: "dist/test.json", Kind: KindEnvelope},
+	}, files)
+}
+
+func TestCandidatesAtRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"foo":              {Data: []byte("foo")},
+		"foo.intoto.jsonl": {Data: []byte("\n")},
+	}
+
+	files, err := Candidates(fsys, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []File{{Path: "foo.intoto.jsonl", Kind: KindBundle}}, files)
+}
+
+func TestDiscover(t *testing.T) {
+	ctx := context.Background()
+	builder, other := testutil.NewSigner(t), testutil.NewSigner(t)
+	fsys := createTestFS(t, builder, other)
+	builderAttester := dsse.Attester{Name: "builder", Verifier: builder}
+
+	tests := map[string]struct {
+		discover          func() (*Result, error)
+		expectedLocations []string
+	}{
+		"next to artifact": {
+			discover: func() (*Result, error) {
+				return Discover(ctx, fsys, "dist/foo.tar.gz")
+			},
+			expectedLocations: []string{"dist/foo.tar.gz.intoto.jsonl:1", "dist/test.json:0"},
+		},
+		"next to artifact with sha512": {
+			discover: func() (*Result, error) {
+				return Discover(ctx, fsys, "dist/foo.tar.gz", WithDigestAlgorithms(ita1.AlgorithmSHA256, ita1.AlgorithmSHA512))
+			},
+			expectedLocations: []string{"dist/build.0123abcd.json:0", "dist/foo.tar.gz.intoto.jsonl:1", "dist/test.json:0"},
+		},
+		"next to artifact by attester": {
+			discover: func() (*Result, error) {
+				return Discover(ctx, fsys, "dist/foo.tar.gz", WithAttesters(builderAttester))
+			},
+			expectedLocations: []string{"dist/foo.tar.gz.intoto.jsonl:1"},
+		},
+		"other artifact": {
+			discover: func() (*Result, error) {
+				return Discover(ctx, fsys, "dist/bar.tar.gz")
+			},
+			expectedLocations: []string{"dist/bar.tar.gz.intoto.jsonl:1"},
+		},
+		"in tree": {
+			discover: func() (*Result, error) {
+				return DiscoverInTree(ctx, fsys, "dist/foo.tar.gz", ".")
+			},
+			expectedLocations: []string{
+				"attestations/old/review.json:0",
+				"attestations/scan.intoto.jsonl:1",
+				"dist/foo.tar.gz.intoto.jsonl:1",
+				"dist/test.json:0",
+			},
+		},
+		"in subtree": {
+			discover: func() (*Result, error) {
+				return DiscoverInTree(ctx, fsys, "dist/foo.tar.gz", "attestations")
+			},
+			expectedLocations: []string{"attestations/old/review.json:0", "attestations/scan.intoto.jsonl:1"},
+		},
+		"artifact without attestations": {
+			discover: func() (*Result, error) {
+				return Discover(ctx, fsys, "dist/README.md")
+			},
+			expectedLocations: []string{},
+		},
+	}
+
+	for name, test := range tests {
+		result, err := test.discover()
+		if assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name)) {
+			assert.Equal(t, test.expectedLocations, attestationLocations(result), fmt.Sprintf("wrong attestations in test '%s'", name))
+		}
+	}
+}
+
+func TestDiscoverResultDetails(t *testing.T) {
+	builder := testutil.NewSigner(t)
+	fsys := createTestFS(t, builder, testutil.NewSigner(t))
+
+	result, err := Discover(context.Background(), fsys, "dist/foo.tar.gz", WithAttesters(dsse.Attester{Name: "builder", Verifier: builder}))
+	assert.NoError(t, err)
+
+	a := result.Attestations[0]
+	assert.Equal(t, []string{"builder"}, a.Attesters)
+	assert.Equal(t, "artifact", a.MatchedSubjects[0].GetName())
+	assert.Equal(t, "foo", a.Statement.GetPredicate().GetFields()["contents"].GetStringValue())
+
+	skipped := map[string]error{}
+	for _, s := range result.Skipped {
+		skipped[fmt.Sprintf("%s:%d", s.Path, s.Line)] = s.Err
+	}
+	assert.ErrorIs(t, skipped["dist/foo.tar.gz.intoto.jsonl:2"], validation.ErrNoMatchedSubjects)
+	assert.ErrorIs(t, skipped["dist/foo.tar.gz.intoto.jsonl:3"], bundle.ErrUnrecognizedLine)
+	assert.ErrorIs(t, skipped["dist/build.0123abcd.json:0"], validation.ErrNoMatchedSubjects)
+	assert.ErrorIs(t, skipped["dist/package.json:0"], dsse.ErrPayloadTypeRequired)
+	assert.ErrorIs(t, skipped["dist/test.json:0"], dsse.ErrNoRecognizedAttester)
+}
+
+func TestDiscoverVariableLengthDigests(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	sum := blake2b.Sum256([]byte("foo"))
+
+	s, err := createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, signer).Statement()
+	assert.NoError(t, err)
+	s.Subject[0].Digest = map[string]string{string(ita1.AlgorithmBLAKE2b): hex.EncodeToString(sum[:])}
+	env, err := dsse.NewEnvelope(s)
+	assert.NoError(t, err)
+	assert.NoError(t, env.Sign(context.Background(), signer))
+
+	fsys := fstest.MapFS{
+		"foo.tar.gz":              {Data: []byte("foo")},
+		"foo.tar.gz.intoto.jsonl": marshalBundle(t, env),
+	}
+
+	result, err := Discover(context.Background(), fsys, "foo.tar.gz", WithDigestAlgorithms(ita1.AlgorithmBLAKE2b))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo.tar.gz.intoto.jsonl:1"}, attestationLocations(result))
+}
+
+func TestDiscoverSkipsUnsignedStatement(t *testing.T) {
+	s, err := createTestEnvelope(t, "foo", ita1.AlgorithmSHA256, testutil.NewSigner(t)).Statement()
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, bundle.NewWriter(&buf).WriteStatement(s))
+	fsys := fstest.MapFS{
+		"foo.tar.gz":              {Data: []byte("foo")},
+		"foo.tar.gz.intoto.jsonl": {Data: buf.Bytes()},
+	}
+
+	result, err := Discover(context.Background(), fsys, "foo.tar.gz")
+	assert.NoError(t, err)
+	assert.Empty(t, result.Attestations)
+	if assert.Len(t, result.Skipped, 1) {
+		assert.ErrorIs(t, result.Skipped[0].Err, bundle.ErrUnsignedStatement)
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	ctx := context.Background()
+	fsys := createTestFS(t, testutil.NewSigner(t), testutil.NewSigner(t))
+
+	_, err := Discover(ctx, fsys, "dist/missing.tar.gz")
+	assert.Error(t, err)
+
+	_, err = Discover(ctx, fsys, "dist")
+	assert.ErrorIs(t, err, ErrArtifactIsDir)
+
+	_, err = Discover(ctx, fsys, "dist/foo.tar.gz", WithDigestAlgorithms(ita1.AlgorithmGitBlob))
+	assert.ErrorIs(t, err, validation.ErrUnsupportedDigestAlgorithm)
+
+	_, err = Discover(ctx, fsys, "dist/foo.tar.gz", WithAttesters(dsse.Attester{Name: "nobody"}))
+	assert.ErrorIs(t, err, dsse.ErrVerifierRequired)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Discover(canceled, fsys, "dist/foo.tar.gz")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,163 @@
+/*
+APIs for matching DSSE envelope signatures against recognized attesters.
+*/
+
+package dsse
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+var (
+	ErrAttesterRequired     = errors.New("at least one attester required")
+	ErrAttesterNameRequired = errors.New("attester name required")
+	ErrNoRecognizedAttester = errors.New("no signature matches a recognized attester")
+)
+
+// Attester is a named public key trusted by a consumer, i.e. one entry of
+// the recognizedAttesters input in docs/validation.md.
+type Attester struct {
+	Name     string
+	Verifier signature.Verifier
+}
+
+func (a Attester) Validate() error {
+	if a.Name == "" {
+		return ErrAttesterNameRequired
+	}
+
+	if a.Verifier == nil {
+		return fmt.Errorf("attester %q: %w", a.Name, ErrVerifierRequired)
+	}
+
+	return nil
+}
+
+// AttesterNames implements the Envelope layer of docs/validation.md: it
+// returns the names of the attesters whose keys produced at least one of
+// the envelope's signatures, in the order the attesters were given, and
+// fails with ErrNoRecognizedAttester if there are none.
+//
+// A signature's keyid is used as a hint to pick the keys to try, as
+// described for VerifyThreshold.
+func (e *Envelope) AttesterNames(ctx context.Context, attesters ...Attester) ([]string, error) {
+	matches, err := e.matchSignatures(ctx, attesters)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(attesters))
+	names := []string{}
+	for i, a := range attesters {
+		if matches.attesterMatched(i) && !seen[a.Name] {
+			seen[a.Name] = true
+			names = append(names, a.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, ErrNoRecognizedAttester
+	}
+
+	return names, nil
+}
+
+// signatureMatches records, for each signature of an envelope, the indexes
+// of the attesters that verified it and whether its keyid hint selected any
+// attester.
+type signatureMatches struct {
+	verifiedBy [][]int
+	hinted     []bool
+}
+
+func (m *signatureMatches) attesterMatched(attester int) bool {
+	for _, verifiedBy := range m.verifiedBy {
+		for _, i := range verifiedBy {
+			if i == attester {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchSignatures verifies every signature of the envelope against the
+// attesters' keys.
+//
+// If a signature's keyid matches the KEYID of one or more attesters, only
+// their keys are tried. KEYIDs are unauthenticated hints, so if the keyid is
+// missing, or matches no attester, every key is tried instead.
+func (e *Envelope) matchSignatures(ctx context.Context, attesters []Attester) (*signatureMatches, error) {
+	if len(attesters) == 0 {
+		return nil, ErrAttesterRequired
+	}
+
+	keyIDs := make([]string, len(attesters))
+	for i, a := range attesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+
+		keyID, err := a.Verifier.KeyID()
+		if err != nil {
+			return nil, fmt.Errorf("attester %q: failed to get keyid: %w", a.Name, err)
+		}
+		keyIDs[i] = keyID
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	pae := e.PAE()
+	matches := &signatureMatches{
+		verifiedBy: make([][]int, len(e.Signatures)),
+		hinted:     make([]bool, len(e.Signatures)),
+	}
+	for s, sig := range e.Signatures {
+		candidates := []int{}
+		if sig.KeyID != "" {
+			for i, keyID := range keyIDs {
+				if keyID == sig.KeyID {
+					candidates = append(candidates, i)
+				}
+			}
+		}
+
+		matches.hinted[s] = len(candidates) > 0
+		if !matches.hinted[s] {
+			for i := range attesters {
+				candidates = append(candidates, i)
+			}
+		}
+
+		for _, i := range candidates {
+			if err := attesters[i].Verifier.Verify(ctx, pae, sig.Sig); err == nil {
+				matches.verifiedBy[s] = append(matches.verifiedBy[s], i)
+			}
+
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+// sameKey indicates if two verifiers hold the same public key.
+func sameKey(a, b signature.Verifier) bool {
+	if pub, ok := a.Public().(interface{ Equal(crypto.PublicKey) bool }); ok {
+		return pub.Equal(b.Public())
+	}
+
+	aID, aErr := a.KeyID()
+	bID, bErr := b.KeyID()
+	return aErr == nil && bErr == nil && aID != "" && aID == bID
+}
@@ -0,0 +1,50 @@
+/*
+Tests for matching DSSE envelope signatures against attesters.
+*/
+
+package dsse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttesterNames(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewECDSASigner(t)
+	carol := testutil.NewSigner(t)
+
+	env := createTestSignedEnvelope(t, bob, alice)
+
+	got, err := env.AttesterNames(ctx,
+		Attester{Name: "alice", Verifier: alice},
+		Attester{Name: "carol", Verifier: carol},
+		Attester{Name: "bob", Verifier: bob},
+		// a second key registered under an existing name is not double counted
+		Attester{Name: "alice", Verifier: bob},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, got)
+
+	_, err = env.AttesterNames(ctx, Attester{Name: "carol", Verifier: carol})
+	assert.ErrorIs(t, err, ErrNoRecognizedAttester)
+}
+
+func TestAttesterNamesErrors(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	env := createTestSignedEnvelope(t, alice)
+
+	_, err := env.AttesterNames(ctx)
+	assert.ErrorIs(t, err, ErrAttesterRequired)
+
+	_, err = env.AttesterNames(ctx, Attester{Verifier: alice})
+	assert.ErrorIs(t, err, ErrAttesterNameRequired)
+
+	_, err = env.AttesterNames(ctx, Attester{Name: "alice"})
+	assert.ErrorIs(t, err, ErrVerifierRequired)
+}
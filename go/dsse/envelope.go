@@ -0,0 +1,263 @@
+/*
+Wrapper APIs for the DSSE v1.0 Envelope layer of in-toto attestations.
+*/
+
+package dsse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// PayloadType is the generic media type of an in-toto Statement payload.
+const PayloadType = "application/vnd.in-toto+json"
+
+const payloadTypePrefix = "application/vnd.in-toto."
+const payloadTypeSuffix = "+json"
+
+// paePrefix is the version string that starts every DSSE v1 PAE.
+const paePrefix = "DSSEv1"
+
+var (
+	ErrPayloadTypeRequired  = errors.New("envelope payloadType required")
+	ErrPayloadRequired      = errors.New("envelope payload required")
+	ErrSignaturesRequired   = errors.New("envelope signatures required")
+	ErrSigRequired          = errors.New("signature sig required")
+	ErrInvalidBase64        = errors.New("value is not valid base64")
+	ErrNotInTotoPayloadType = errors.New("payloadType is not an in-toto Statement media type")
+)
+
+// Envelope is a DSSE v1.0 envelope, as described in spec/v1/envelope.md.
+//
+// Payload holds the raw (decoded) payload bytes. They are kept exactly as
+// received so that signatures can be verified over them without
+// re-serializing the Statement.
+type Envelope struct {
+	PayloadType string
+	Payload     []byte
+	Signatures  []Signature
+}
+
+// Signature is a single signature in a DSSE envelope. Sig holds the raw
+// (decoded) signature bytes.
+type Signature struct {
+	KeyID string
+	Sig   []byte
+}
+
+// jsonEnvelope and jsonSignature are the wire representations of Envelope
+// and Signature, with the payload and signatures base64-encoded.
+type jsonEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []jsonSignature `json:"signatures"`
+}
+
+type jsonSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// PAE computes the DSSE v1 pre-authentication encoding of a payload, which
+// is the message that is actually signed:
+//
+//	"DSSEv1" SP LEN(type) SP type SP LEN(body) SP body
+func PAE(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(paePrefix)
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(len(payloadType)))
+	buf.WriteByte(' ')
+	buf.WriteString(payloadType)
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(len(payload)))
+	buf.WriteByte(' ')
+	buf.Write(payload)
+
+	return buf.Bytes()
+}
+
+// IsInTotoPayloadType indicates if a payloadType denotes an in-toto
+// Statement, i.e. it is either application/vnd.in-toto+json or
+// application/vnd.in-toto.<predicate>+json.
+func IsInTotoPayloadType(payloadType string) bool {
+	if payloadType == PayloadType {
+		return true
+	}
+
+	if !strings.HasPrefix(payloadType, payloadTypePrefix) || !strings.HasSuffix(payloadType, payloadTypeSuffix) {
+		return false
+	}
+
+	predicate := strings.TrimSuffix(strings.TrimPrefix(payloadType, payloadTypePrefix), payloadTypeSuffix)
+	return predicate != "" && !strings.ContainsAny(predicate, "+/; ")
+}
+
+// NewEnvelope serializes a Statement into the payload of a new, unsigned
+// envelope. The payloadType is predicate-specific if the Statement's
+// predicateType belongs to a vetted predicate, and generic otherwise.
+func NewEnvelope(s *ita1.Statement) (*Envelope, error) {
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	payload, err := protojson.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+
+	return &Envelope{
+		PayloadType: PayloadTypeFor(s.GetPredicateType()),
+		Payload:     payload,
+	}, nil
+}
+
+// Parse decodes a JSON-encoded DSSE envelope and checks its required fields.
+func Parse(data []byte) (*Envelope, error) {
+	e := &Envelope{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Validate checks that all of the fields required by the DSSE spec are set.
+func (e *Envelope) Validate() error {
+	if e.PayloadType == "" {
+		return ErrPayloadTypeRequired
+	}
+
+	if len(e.Payload) == 0 {
+		return ErrPayloadRequired
+	}
+
+	if len(e.Signatures) == 0 {
+		return ErrSignaturesRequired
+	}
+
+	for i, sig := range e.Signatures {
+		if len(sig.Sig) == 0 {
+			return fmt.Errorf("signatures[%d]: %w", i, ErrSigRequired)
+		}
+	}
+
+	return nil
+}
+
+// PAE returns the pre-authentication encoding of the envelope's payload.
+func (e *Envelope) PAE() []byte {
+	return PAE(e.PayloadType, e.Payload)
+}
+
+// Statement decodes the envelope's payload into an in-toto Statement and
+// validates it.
+//
+// This does not verify any signatures: callers MUST verify the envelope
+// before trusting the returned Statement.
+func (e *Envelope) Statement() (*ita1.Statement, error) {
+	return e.StatementWithOptions()
+}
+
+// StatementWithOptions decodes the envelope's payload as Statement does,
+// and applies the given options to the Statement's validation.
+func (e *Envelope) StatementWithOptions(opts ...ita1.ValidateOption) (*ita1.Statement, error) {
+	if !IsInTotoPayloadType(e.PayloadType) {
+		return nil, fmt.Errorf("%w: %q", ErrNotInTotoPayloadType, e.PayloadType)
+	}
+
+	s := &ita1.Statement{}
+	// consumers must ignore unrecognized fields, per the spec's parsing rules
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(e.Payload, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	if err := s.ValidateWithOptions(opts...); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+
+	return s, nil
+}
+
+func (e Envelope) MarshalJSON() ([]byte, error) {
+	sigs := make([]jsonSignature, 0, len(e.Signatures))
+	for _, sig := range e.Signatures {
+		sigs = append(sigs, jsonSignature{
+			KeyID: sig.KeyID,
+			Sig:   base64.StdEncoding.EncodeToString(sig.Sig),
+		})
+	}
+
+	return json.Marshal(jsonEnvelope{
+		PayloadType: e.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(e.Payload),
+		Signatures:  sigs,
+	})
+}
+
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	var raw jsonEnvelope
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	payload, err := decodeBase64(raw.Payload)
+	if err != nil {
+		return fmt.Errorf("payload: %w", err)
+	}
+
+	sigs := make([]Signature, 0, len(raw.Signatures))
+	for i, s := range raw.Signatures {
+		sig, err := decodeBase64(s.Sig)
+		if err != nil {
+			return fmt.Errorf("signatures[%d].sig: %w", i, err)
+		}
+		sigs = append(sigs, Signature{KeyID: s.KeyID, Sig: sig})
+	}
+
+	*e = Envelope{
+		PayloadType: raw.PayloadType,
+		Payload:     payload,
+		Signatures:  sigs,
+	}
+
+	return nil
+}
+
+// decodeBase64 decodes a base64 string in either the standard or the
+// URL-safe alphabet, as permitted by DSSE.
+//
+// Decoding is strict: padding must be correct when present, trailing bits
+// must be zero, and line breaks (which the encoding/base64 decoders
+// otherwise skip silently) are rejected, so that every encoded value has
+// exactly one accepted spelling per alphabet.
+func decodeBase64(s string) ([]byte, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, ErrInvalidBase64
+	}
+
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding.Strict(),
+		base64.URLEncoding.Strict(),
+		base64.RawStdEncoding.Strict(),
+		base64.RawURLEncoding.Strict(),
+	} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+
+	return nil, ErrInvalidBase64
+}
@@ -0,0 +1,206 @@
+/*
+Tests for DSSE envelopes.
+*/
+
+package dsse
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const testStatement = `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"theSub","digest":{"sha256":"a1234567b1234567c1234567d1234567e1234567f1234567a1234567b1234567"}}],"predicateType":"thePredicate","predicate":{"keyObj":{"subKey":"subVal"}}}`
+
+func createTestStatement(t *testing.T) *ita1.Statement {
+	t.Helper()
+
+	pred, err := structpb.NewStruct(map[string]interface{}{
+		"keyObj": map[string]interface{}{
+			"subKey": "subVal"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ita1.Statement{
+		Type: ita1.StatementTypeUri,
+		Subject: []*ita1.ResourceDescriptor{{
+			Name:   "theSub",
+			Digest: map[string]string{"sha256": "a1234567b1234567c1234567d1234567e1234567f1234567a1234567b1234567"},
+		}},
+		PredicateType: "thePredicate",
+		Predicate:     pred,
+	}
+}
+
+func TestPAE(t *testing.T) {
+	// test vector from the DSSE v1.0 protocol spec
+	got := PAE("http://example.com/HelloWorld", []byte("hello world"))
+	assert.Equal(t, "DSSEv1 29 http://example.com/HelloWorld 11 hello world", string(got))
+
+	got = PAE("", nil)
+	assert.Equal(t, "DSSEv1 0  0 ", string(got))
+}
+
+func TestIsInTotoPayloadType(t *testing.T) {
+	tests := map[string]bool{
+		"application/vnd.in-toto+json":             true,
+		"application/vnd.in-toto.provenance+json":  true,
+		"application/vnd.in-toto.test-result+json": true,
+		"application/vnd.in-toto.+json":            false,
+		"application/vnd.in-toto+cbor":             false,
+		"application/vnd.in-toto.vsa+dsse":         false,
+		"application/vnd.novulz+cbor":              false,
+		"":                                         false,
+	}
+
+	for payloadType, want := range tests {
+		assert.Equal(t, want, IsInTotoPayloadType(payloadType), fmt.Sprintf("unexpected result for '%s'", payloadType))
+	}
+}
+
+func TestJsonRoundTripEnvelope(t *testing.T) {
+	var wantEnv = `{"payloadType":"application/vnd.in-toto+json","payload":"aGVsbG8gd29ybGQ=","signatures":[{"keyid":"theKey","sig":"AQID"},{"sig":"BAUG"}]}`
+
+	got, err := Parse([]byte(wantEnv))
+	assert.NoError(t, err, "error during JSON unmarshalling")
+
+	want := &Envelope{
+		PayloadType: PayloadType,
+		Payload:     []byte("hello world"),
+		Signatures: []Signature{
+			{KeyID: "theKey", Sig: []byte{1, 2, 3}},
+			{Sig: []byte{4, 5, 6}},
+		},
+	}
+	assert.Equal(t, want, got, "envelopes do not match")
+
+	out, err := json.Marshal(got)
+	assert.NoError(t, err, "error during JSON marshalling")
+	assert.JSONEq(t, wantEnv, string(out))
+}
+
+func TestEnvelopeBase64Alphabets(t *testing.T) {
+	// 0xfb 0xff encodes to "+/8=" in the standard alphabet and "-_8=" in the
+	// URL-safe one; DSSE allows both, with or without padding
+	for _, sig := range []string{"+/8=", "-_8=", "+/8", "-_8"} {
+		env := fmt.Sprintf(`{"payloadType":"a","payload":"aGk=","signatures":[{"sig":"%s"}]}`, sig)
+		got, err := Parse([]byte(env))
+		assert.NoError(t, err, fmt.Sprintf("error decoding sig '%s'", sig))
+		assert.Equal(t, []byte{0xfb, 0xff}, got.Signatures[0].Sig)
+	}
+}
+
+func TestBadEnvelope(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		err   error
+	}{
+		"missing payloadType": {
+			input: `{"payload":"aGk=","signatures":[{"sig":"AQID"}]}`,
+			err:   ErrPayloadTypeRequired,
+		},
+		"missing payload": {
+			input: `{"payloadType":"application/vnd.in-toto+json","signatures":[{"sig":"AQID"}]}`,
+			err:   ErrPayloadRequired,
+		},
+		"missing signatures": {
+			input: `{"payloadType":"application/vnd.in-toto+json","payload":"aGk=","signatures":[]}`,
+			err:   ErrSignaturesRequired,
+		},
+		"empty sig": {
+			input: `{"payloadType":"application/vnd.in-toto+json","payload":"aGk=","signatures":[{"keyid":"k"}]}`,
+			err:   ErrSigRequired,
+		},
+		"payload not base64": {
+			input: `{"payloadType":"application/vnd.in-toto+json","payload":"not base64!","signatures":[{"sig":"AQID"}]}`,
+			err:   ErrInvalidBase64,
+		},
+		"payload with line break": {
+			input: `{"payloadType":"application/vnd.in-toto+json","payload":"aG\nk=","signatures":[{"sig":"AQID"}]}`,
+			err:   ErrInvalidBase64,
+		},
+		"sig with non-zero trailing bits": {
+			input: `{"payloadType":"application/vnd.in-toto+json","payload":"aGk=","signatures":[{"sig":"AQJ="}]}`,
+			err:   ErrInvalidBase64,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := Parse([]byte(test.input))
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("parsed malformed envelope in test '%s'", name))
+	}
+}
+
+func TestEnvelopeStatement(t *testing.T) {
+	env := &Envelope{
+		PayloadType: "application/vnd.in-toto.provenance+json",
+		Payload:     []byte(testStatement),
+	}
+
+	got, err := env.Statement()
+	assert.NoError(t, err, "error decoding statement")
+	assert.True(t, proto.Equal(createTestStatement(t), got), "protos do not match")
+}
+
+func TestEnvelopeStatementWithOptions(t *testing.T) {
+	s := createTestStatement(t)
+	s.Subject[0].Digest = map[string]string{"sha1": "a1234567b1234567c1234567d1234567e1234567"}
+	env, err := NewEnvelope(s)
+	assert.NoError(t, err)
+
+	_, err = env.Statement()
+	assert.NoError(t, err)
+
+	_, err = env.StatementWithOptions(ita1.RejectWeakDigests())
+	assert.ErrorIs(t, err, ita1.ErrWeakDigestSet)
+}
+
+func TestNewEnvelope(t *testing.T) {
+	want := createTestStatement(t)
+
+	env, err := NewEnvelope(want)
+	assert.NoError(t, err, "error creating envelope")
+	assert.Equal(t, PayloadType, env.PayloadType)
+
+	got, err := env.Statement()
+	assert.NoError(t, err, "error decoding statement")
+	assert.True(t, proto.Equal(want, got), "protos do not match")
+
+	_, err = NewEnvelope(&ita1.Statement{Type: ita1.StatementTypeUri})
+	assert.ErrorIs(t, err, ita1.ErrSubjectRequired)
+}
+
+func TestBadEnvelopeStatement(t *testing.T) {
+	tests := map[string]struct {
+		payloadType string
+		payload     string
+		err         error
+	}{
+		"non in-toto payloadType": {
+			payloadType: "application/vnd.novulz+cbor",
+			payload:     testStatement,
+			err:         ErrNotInTotoPayloadType,
+		},
+		"invalid statement": {
+			payloadType: PayloadType,
+			payload:     `{"_type":"https://in-toto.io/Statement/v1","subject":[],"predicateType":"thePredicate","predicate":{}}`,
+			err:         ita1.ErrSubjectRequired,
+		},
+	}
+
+	for name, test := range tests {
+		env := &Envelope{PayloadType: test.payloadType, Payload: []byte(test.payload)}
+		_, err := env.Statement()
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("decoded bad statement in test '%s'", name))
+	}
+
+	env := &Envelope{PayloadType: PayloadType, Payload: []byte("not json")}
+	_, err := env.Statement()
+	assert.Error(t, err, "decoded non-JSON payload")
+}
@@ -0,0 +1,155 @@
+/*
+Helpers for the envelope file naming convention in spec/v1/envelope.md.
+*/
+
+package dsse
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileSuffix is the suffix of a file holding a single envelope.
+const FileSuffix = ".json"
+
+// KeyIDPrefixLength is the number of KEYID characters included in envelope
+// file names.
+const KeyIDPrefixLength = 8
+
+// bundleSuffix is the suffix of a bundle file, which must not be mistaken
+// for a single envelope.
+const bundleSuffix = ".intoto.jsonl"
+
+var (
+	ErrStepNameRequired = errors.New("step name required")
+	ErrInvalidStepName  = errors.New("step name must not contain path separators")
+	ErrKeyIDTooShort    = errors.New("keyid is shorter than the file name prefix")
+	ErrNotEnvelopeFile  = errors.New("not an envelope file name")
+)
+
+// EnvelopeFile describes an envelope file named according to the
+// <step-name>[.<keyid[0:8]>].json convention.
+type EnvelopeFile struct {
+	// Name is the file name, or its path within the file system searched
+	// by FindFiles.
+	Name string
+	// Step is the step or envelope name.
+	Step string
+	// KeyIDPrefix is the truncated KEYID of the signing functionary, if the
+	// file name includes one.
+	KeyIDPrefix string
+}
+
+// MatchesKeyID indicates if the file name's KEYID prefix matches a full
+// KEYID. A file name without a KEYID matches no KEYID.
+func (f EnvelopeFile) MatchesKeyID(keyID string) bool {
+	return f.KeyIDPrefix != "" && strings.HasPrefix(strings.ToLower(keyID), f.KeyIDPrefix)
+}
+
+// FileName returns the file name for an envelope about step, signed by the
+// functionary with the given KEYID: <step>.<keyid[0:8]>.json, or
+// <step>.json if keyID is empty.
+func FileName(step, keyID string) (string, error) {
+	if step == "" {
+		return "", ErrStepNameRequired
+	}
+
+	if strings.ContainsAny(step, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidStepName, step)
+	}
+
+	if keyID == "" {
+		return step + FileSuffix, nil
+	}
+
+	if len(keyID) < KeyIDPrefixLength {
+		return "", fmt.Errorf("%w: %q", ErrKeyIDTooShort, keyID)
+	}
+
+	return step + "." + strings.ToLower(keyID[:KeyIDPrefixLength]) + FileSuffix, nil
+}
+
+// ParseFileName parses an envelope file name.
+//
+// Step names may themselves contain dots, so the component before ".json"
+// is only taken to be a KEYID prefix if it consists of exactly eight hex
+// digits.
+func ParseFileName(name string) (*EnvelopeFile, error) {
+	if !strings.HasSuffix(name, FileSuffix) || strings.HasSuffix(name, bundleSuffix) {
+		return nil, fmt.Errorf("%w: %q", ErrNotEnvelopeFile, name)
+	}
+
+	base := strings.TrimSuffix(name, FileSuffix)
+	f := &EnvelopeFile{Name: name, Step: base}
+
+	if i := strings.LastIndexByte(base, '.'); i > 0 && isKeyIDPrefix(base[i+1:]) {
+		f.Step = base[:i]
+		f.KeyIDPrefix = base[i+1:]
+	}
+
+	if f.Step == "" {
+		return nil, fmt.Errorf("%w: %q", ErrNotEnvelopeFile, name)
+	}
+
+	return f, nil
+}
+
+func isKeyIDPrefix(s string) bool {
+	if len(s) != KeyIDPrefixLength {
+		return false
+	}
+
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FindFiles lists the envelope files in dir, sorted by name. Files that do
+// not follow the naming convention are skipped.
+func FindFiles(fsys fs.FS, dir string) ([]EnvelopeFile, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []EnvelopeFile{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		f, err := ParseFileName(entry.Name())
+		if err != nil {
+			continue
+		}
+		f.Name = path.Join(dir, f.Name)
+		files = append(files, *f)
+	}
+
+	return files, nil
+}
+
+// GroupByStep groups envelope files by step name. Within each step, files
+// are sorted by KEYID prefix.
+func GroupByStep(files []EnvelopeFile) map[string][]EnvelopeFile {
+	groups := map[string][]EnvelopeFile{}
+	for _, f := range files {
+		groups[f.Step] = append(groups[f.Step], f)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].KeyIDPrefix < group[j].KeyIDPrefix
+		})
+	}
+
+	return groups
+}
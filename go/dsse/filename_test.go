@@ -0,0 +1,119 @@
+/*
+Tests for the envelope file naming helpers.
+*/
+
+package dsse
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyID = "776A00E29F3559E0141B3B096F696ABC6CFB0C657AB40F441132B345B08453F5"
+
+func TestFileName(t *testing.T) {
+	got, err := FileName("build", testKeyID)
+	assert.NoError(t, err)
+	assert.Equal(t, "build.776a00e2.json", got)
+
+	got, err = FileName("build", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "build.json", got)
+
+	_, err = FileName("", testKeyID)
+	assert.ErrorIs(t, err, ErrStepNameRequired)
+
+	_, err = FileName("../build", testKeyID)
+	assert.ErrorIs(t, err, ErrInvalidStepName)
+
+	_, err = FileName("build", "abc")
+	assert.ErrorIs(t, err, ErrKeyIDTooShort)
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := map[string]struct {
+		name string
+		want *EnvelopeFile
+		err  error
+	}{
+		"with keyid": {
+			name: "build.776a00e2.json",
+			want: &EnvelopeFile{Name: "build.776a00e2.json", Step: "build", KeyIDPrefix: "776a00e2"},
+		},
+		"without keyid": {
+			name: "build.json",
+			want: &EnvelopeFile{Name: "build.json", Step: "build"},
+		},
+		"dotted step name": {
+			name: "release.v1.2.json",
+			want: &EnvelopeFile{Name: "release.v1.2.json", Step: "release.v1.2"},
+		},
+		"dotted step name with keyid": {
+			name: "release.v1.2.776a00e2.json",
+			want: &EnvelopeFile{Name: "release.v1.2.776a00e2.json", Step: "release.v1.2", KeyIDPrefix: "776a00e2"},
+		},
+		"keyid only": {
+			name: "776a00e2.json",
+			want: &EnvelopeFile{Name: "776a00e2.json", Step: "776a00e2"},
+		},
+		"bundle": {
+			name: "foo.tar.gz.intoto.jsonl",
+			err:  ErrNotEnvelopeFile,
+		},
+		"wrong suffix": {
+			name: "build.776a00e2.link",
+			err:  ErrNotEnvelopeFile,
+		},
+		"empty step": {
+			name: ".json",
+			err:  ErrNotEnvelopeFile,
+		},
+	}
+
+	for name, test := range tests {
+		got, err := ParseFileName(test.name)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, fmt.Sprintf("parsed bad file name in test '%s'", name))
+			continue
+		}
+
+		assert.NoError(t, err, fmt.Sprintf("error parsing file name in test '%s'", name))
+		assert.Equal(t, test.want, got, fmt.Sprintf("wrong result in test '%s'", name))
+	}
+}
+
+func TestFileNameRoundTrip(t *testing.T) {
+	name, err := FileName("package", testKeyID)
+	assert.NoError(t, err)
+
+	got, err := ParseFileName(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "package", got.Step)
+	assert.True(t, got.MatchesKeyID(testKeyID))
+	assert.False(t, got.MatchesKeyID("0000000000"))
+}
+
+func TestFindAndGroupFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"att/build.bbbbbbbb.json":        {},
+		"att/build.aaaaaaaa.json":        {},
+		"att/test.json":                  {},
+		"att/foo.intoto.jsonl":           {},
+		"att/README.md":                  {},
+		"att/nested/build.cccccccc.json": {},
+	}
+
+	files, err := FindFiles(fsys, "att")
+	assert.NoError(t, err)
+
+	groups := GroupByStep(files)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, []EnvelopeFile{
+		{Name: "att/build.aaaaaaaa.json", Step: "build", KeyIDPrefix: "aaaaaaaa"},
+		{Name: "att/build.bbbbbbbb.json", Step: "build", KeyIDPrefix: "bbbbbbbb"},
+	}, groups["build"])
+	assert.Equal(t, []EnvelopeFile{{Name: "att/test.json", Step: "test"}}, groups["test"])
+}
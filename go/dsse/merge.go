@@ -0,0 +1,93 @@
+/*
+APIs for collaboratively signing a single envelope: adding signatures made
+elsewhere and merging the signatures of copies of the same envelope.
+*/
+
+package dsse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEnvelopeRequired    = errors.New("envelope required")
+	ErrPayloadMismatch     = errors.New("envelopes have different payloads")
+	ErrPayloadTypeMismatch = errors.New("envelopes have different payloadTypes")
+)
+
+// hasSignature indicates if the envelope already holds a signature that
+// sig duplicates: one with the same keyid and signature bytes. Signatures
+// that only share a keyid are all kept, as an unverified signature must not
+// mask a valid one.
+func (e *Envelope) hasSignature(sig Signature) bool {
+	for _, s := range e.Signatures {
+		if s.KeyID == sig.KeyID && bytes.Equal(s.Sig, sig.Sig) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddSignature appends a signature over the envelope's PAE that was made
+// elsewhere, e.g. by a functionary holding their own key. The payload is
+// left untouched. A signature duplicating an existing one is ignored.
+//
+// The signature is not verified: consumers must verify the envelope.
+func (e *Envelope) AddSignature(sig Signature) error {
+	if len(sig.Sig) == 0 {
+		return ErrSigRequired
+	}
+
+	if !e.hasSignature(sig) {
+		e.Signatures = append(e.Signatures, sig)
+	}
+
+	return nil
+}
+
+// Merge adds the signatures of other copies of the same envelope, which
+// must have exactly the same payloadType and payload bytes. Duplicate
+// signatures are ignored, and the envelope is left unchanged if any copy
+// does not match.
+//
+// A signature is only a duplicate if both its keyid and its bytes match an
+// existing one. Schemes such as ECDSA and RSA-PSS are randomized, so
+// signatures made separately with the same key are all kept; Sign does not
+// add another one for a signer that already has a valid signature.
+//
+// The merged signatures are not verified: consumers must verify the
+// envelope.
+func (e *Envelope) Merge(others ...*Envelope) error {
+	for i, other := range others {
+		if other == nil {
+			return fmt.Errorf("others[%d]: %w", i, ErrEnvelopeRequired)
+		}
+
+		if other.PayloadType != e.PayloadType {
+			return fmt.Errorf("others[%d]: %w: %q != %q", i, ErrPayloadTypeMismatch, other.PayloadType, e.PayloadType)
+		}
+
+		if !bytes.Equal(other.Payload, e.Payload) {
+			return fmt.Errorf("others[%d]: %w", i, ErrPayloadMismatch)
+		}
+
+		for j, sig := range other.Signatures {
+			if len(sig.Sig) == 0 {
+				return fmt.Errorf("others[%d].signatures[%d]: %w", i, j, ErrSigRequired)
+			}
+		}
+	}
+
+	for _, other := range others {
+		for _, sig := range other.Signatures {
+			if !e.hasSignature(sig) {
+				e.Signatures = append(e.Signatures, sig)
+			}
+		}
+	}
+
+	return nil
+}
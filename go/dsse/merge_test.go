@@ -0,0 +1,171 @@
+/*
+Tests for collaborative signing of a single envelope.
+*/
+
+package dsse
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+// passAround simulates handing an envelope to another functionary, who
+// receives its JSON encoding.
+func passAround(t *testing.T, env *Envelope) *Envelope {
+	t.Helper()
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &Envelope{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestCollaborativeSigning(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewECDSASigner(t)
+	carol := testutil.NewSigner(t)
+
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+	payload := env.Payload
+
+	// alice signs and passes the envelope on to bob and carol, who sign
+	// their copies independently
+	assert.NoError(t, env.Sign(ctx, alice))
+	bobCopy := passAround(t, env)
+	carolCopy := passAround(t, env)
+	assert.NoError(t, bobCopy.Sign(ctx, bob))
+	assert.NoError(t, carolCopy.Sign(ctx, carol))
+
+	assert.NoError(t, env.Merge(bobCopy, carolCopy))
+	assert.Equal(t, payload, env.Payload, "payload changed")
+	assert.Len(t, env.Signatures, 3, "alice's signature was not de-duplicated")
+
+	matched, err := env.Verify(ctx, alice, bob, carol)
+	assert.NoError(t, err)
+	assert.Equal(t, []signature.Verifier{alice, bob, carol}, matched)
+}
+
+func TestAddSignature(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	env := createTestSignedEnvelope(t, alice)
+
+	// bob signs the PAE with his own tooling
+	bob := testutil.NewSigner(t)
+	sig, err := bob.Sign(ctx, env.PAE())
+	assert.NoError(t, err)
+	keyID, _ := bob.KeyID()
+
+	assert.NoError(t, env.AddSignature(Signature{KeyID: keyID, Sig: sig}))
+	assert.NoError(t, env.AddSignature(Signature{KeyID: keyID, Sig: sig}))
+	assert.Len(t, env.Signatures, 2)
+
+	// only identical signatures are duplicates
+	assert.NoError(t, env.AddSignature(Signature{KeyID: keyID, Sig: []byte("again")}))
+	assert.NoError(t, env.AddSignature(Signature{Sig: sig}))
+	assert.NoError(t, env.AddSignature(Signature{Sig: sig}))
+	assert.Len(t, env.Signatures, 4)
+
+	assert.ErrorIs(t, env.AddSignature(Signature{KeyID: "theKey"}), ErrSigRequired)
+
+	_, err = env.Verify(ctx, bob)
+	assert.NoError(t, err)
+}
+
+// randomized signatures by the same key are not duplicates
+func TestMergeKeepsRandomizedSignatures(t *testing.T) {
+	ctx := context.Background()
+	signer := testutil.NewECDSASigner(t)
+
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+	first := passAround(t, env)
+	second := passAround(t, env)
+	assert.NoError(t, first.Sign(ctx, signer))
+	assert.NoError(t, second.Sign(ctx, signer))
+	assert.Equal(t, first.Signatures[0].KeyID, second.Signatures[0].KeyID)
+	assert.NotEqual(t, first.Signatures[0].Sig, second.Signatures[0].Sig)
+
+	assert.NoError(t, first.Merge(second, second))
+	assert.Len(t, first.Signatures, 2)
+
+	matched, err := first.Verify(ctx, signer)
+	assert.NoError(t, err)
+	assert.Len(t, matched, 1)
+}
+
+func TestSignSkipsExistingSignature(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	env := createTestSignedEnvelope(t, signer, signer)
+	assert.Len(t, env.Signatures, 1)
+
+	assert.NoError(t, env.Sign(context.Background(), signer))
+	assert.Len(t, env.Signatures, 1)
+}
+
+// a bogus signature with a functionary's keyid must not mask their real one
+func TestForgedSignatureDoesNotMask(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewSigner(t)
+	keyID, _ := bob.KeyID()
+	forged := Signature{KeyID: keyID, Sig: []byte("\x00")}
+
+	real := createTestSignedEnvelope(t, alice, bob)
+	realSig := real.Signatures[1]
+
+	env := createTestSignedEnvelope(t, alice)
+	assert.NoError(t, env.AddSignature(forged))
+	assert.NoError(t, env.AddSignature(realSig))
+	assert.Equal(t, []Signature{env.Signatures[0], forged, realSig}, env.Signatures)
+
+	env = createTestSignedEnvelope(t, alice)
+	assert.NoError(t, env.AddSignature(forged))
+	assert.NoError(t, env.Merge(real))
+	assert.Contains(t, env.Signatures, realSig)
+	_, err := env.Verify(ctx, bob)
+	assert.NoError(t, err)
+
+	env = createTestSignedEnvelope(t, alice)
+	assert.NoError(t, env.AddSignature(forged))
+	assert.NoError(t, env.Sign(ctx, bob))
+	assert.Len(t, env.Signatures, 3)
+	_, err = env.Verify(ctx, bob)
+	assert.NoError(t, err)
+}
+
+func TestMergeMismatch(t *testing.T) {
+	env := createTestSignedEnvelope(t, testutil.NewSigner(t))
+
+	otherType := passAround(t, env)
+	otherType.PayloadType = "application/vnd.in-toto.provenance+json"
+
+	otherPayload := passAround(t, env)
+	otherPayload.Payload = append(otherPayload.Payload, ' ')
+
+	unsigned := passAround(t, env)
+	unsigned.Signatures = []Signature{{KeyID: "theKey"}}
+
+	good := createTestSignedEnvelope(t, testutil.NewSigner(t))
+	good.Payload = env.Payload
+
+	assert.ErrorIs(t, env.Merge(good, otherType), ErrPayloadTypeMismatch)
+	assert.ErrorIs(t, env.Merge(good, otherPayload), ErrPayloadMismatch)
+	assert.ErrorIs(t, env.Merge(good, unsigned), ErrSigRequired)
+	assert.ErrorIs(t, env.Merge(good, nil), ErrEnvelopeRequired)
+	assert.Len(t, env.Signatures, 1, "failed merge modified the envelope")
+}
@@ -0,0 +1,114 @@
+/*
+Predicate-specific media types for in-toto envelopes, as described in
+spec/v1/envelope.md.
+*/
+
+package dsse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/in-toto/attestation/go/predicates"
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+// storageMediaTypeSuffix is the suffix of the media type denoting an
+// individual attestation in arbitrary storage systems.
+const storageMediaTypeSuffix = "+dsse"
+
+// PayloadTypeFor returns the payloadType for an envelope carrying a
+// Statement with the given predicate type:
+// application/vnd.in-toto.<predicate>+json for vetted predicates, or the
+// generic PayloadType otherwise.
+func PayloadTypeFor(predicateType string) string {
+	spec, ok := predicates.SpecForPredicateType(predicateType)
+	if !ok {
+		return PayloadType
+	}
+
+	return payloadTypePrefix + spec.Name + payloadTypeSuffix
+}
+
+// StorageMediaType returns the media type denoting an individual
+// attestation with the given predicate type in storage systems:
+// application/vnd.in-toto.<predicate>+dsse. It returns false if the
+// predicate is not a vetted predicate.
+func StorageMediaType(predicateType string) (string, bool) {
+	spec, ok := predicates.SpecForPredicateType(predicateType)
+	if !ok {
+		return "", false
+	}
+
+	return payloadTypePrefix + spec.Name + storageMediaTypeSuffix, true
+}
+
+// PredicateName returns the <predicate> component of a predicate-specific
+// in-toto payloadType, or an empty string for the generic payloadType and
+// for media types that are not in-toto Statement media types.
+func PredicateName(payloadType string) string {
+	if payloadType == PayloadType || !IsInTotoPayloadType(payloadType) {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(payloadType, payloadTypePrefix), payloadTypeSuffix)
+}
+
+// PayloadTypeMismatch reports an envelope whose predicate-specific
+// payloadType disagrees with the predicateType of its Statement.
+//
+// The spec does not allow consumers to rely on the payloadType as an
+// indicator of the predicate type, so a mismatch is informational: the
+// Statement's predicateType remains authoritative.
+type PayloadTypeMismatch struct {
+	// PayloadType is the envelope's payloadType.
+	PayloadType string
+	// PredicateName is the predicate named by PayloadType.
+	PredicateName string
+	// PredicateType is the Statement's predicateType.
+	PredicateType string
+	// ExpectedPayloadType is the payloadType matching PredicateType.
+	ExpectedPayloadType string
+}
+
+func (m *PayloadTypeMismatch) Error() string {
+	return fmt.Sprintf("payloadType %q does not match predicateType %q (expected %q)", m.PayloadType, m.PredicateType, m.ExpectedPayloadType)
+}
+
+// CheckPayloadType compares a payloadType with the predicateType of the
+// Statement it carries, returning nil if they are consistent.
+//
+// The generic payloadType is consistent with any predicate type. A
+// predicate-specific payloadType naming a predicate that is not vetted is
+// only reported if the predicateType belongs to a vetted predicate, since
+// its spec file name cannot otherwise be known.
+func CheckPayloadType(payloadType string, s *ita1.Statement) *PayloadTypeMismatch {
+	name := PredicateName(payloadType)
+	if name == "" {
+		return nil
+	}
+
+	predicateType := s.GetPredicateType()
+	spec, known := predicates.SpecForPredicateType(predicateType)
+	if known && spec.Name == name {
+		return nil
+	}
+
+	if _, vetted := predicates.SpecByName(name); !known && !vetted {
+		return nil
+	}
+
+	return &PayloadTypeMismatch{
+		PayloadType:         payloadType,
+		PredicateName:       name,
+		PredicateType:       predicateType,
+		ExpectedPayloadType: PayloadTypeFor(predicateType),
+	}
+}
+
+// PayloadTypeMismatch compares the envelope's payloadType with the
+// predicateType of a Statement decoded from its payload, returning nil if
+// they are consistent.
+func (e *Envelope) PayloadTypeMismatch(s *ita1.Statement) *PayloadTypeMismatch {
+	return CheckPayloadType(e.PayloadType, s)
+}
@@ -0,0 +1,109 @@
+/*
+Tests for predicate-specific payloadType handling.
+*/
+
+package dsse
+
+import (
+	"fmt"
+	"testing"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadTypeFor(t *testing.T) {
+	tests := map[string]string{
+		"https://slsa.dev/provenance/v1":                  "application/vnd.in-toto.provenance+json",
+		"https://slsa.dev/verification_summary/v1":        "application/vnd.in-toto.vsa+json",
+		"https://in-toto.io/attestation/test-result/v0.1": "application/vnd.in-toto.test-result+json",
+		"https://example.com/thePredicate/v1":             PayloadType,
+	}
+
+	for predicateType, want := range tests {
+		assert.Equal(t, want, PayloadTypeFor(predicateType), fmt.Sprintf("wrong payloadType for '%s'", predicateType))
+		assert.True(t, IsInTotoPayloadType(want))
+	}
+}
+
+func TestStorageMediaType(t *testing.T) {
+	got, ok := StorageMediaType("https://spdx.dev/Document/v2.3")
+	assert.True(t, ok)
+	assert.Equal(t, "application/vnd.in-toto.spdx+dsse", got)
+
+	_, ok = StorageMediaType("https://example.com/thePredicate/v1")
+	assert.False(t, ok)
+}
+
+func TestPredicateName(t *testing.T) {
+	assert.Equal(t, "provenance", PredicateName("application/vnd.in-toto.provenance+json"))
+	assert.Equal(t, "", PredicateName(PayloadType))
+	assert.Equal(t, "", PredicateName("application/json"))
+}
+
+func TestNewEnvelopePredicatePayloadType(t *testing.T) {
+	st := createTestStatement(t)
+	st.PredicateType = "https://slsa.dev/provenance/v1"
+
+	env, err := NewEnvelope(st)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.in-toto.provenance+json", env.PayloadType)
+	assert.Nil(t, env.PayloadTypeMismatch(st))
+}
+
+func TestPayloadTypeMismatch(t *testing.T) {
+	tests := map[string]struct {
+		payloadType   string
+		predicateType string
+		mismatch      *PayloadTypeMismatch
+	}{
+		"generic": {
+			payloadType:   PayloadType,
+			predicateType: "https://slsa.dev/provenance/v1",
+		},
+		"matching": {
+			payloadType:   "application/vnd.in-toto.provenance+json",
+			predicateType: "https://in-toto.io/Provenance/v0.1",
+		},
+		"custom predicate": {
+			payloadType:   "application/vnd.in-toto.thePredicate+json",
+			predicateType: "https://example.com/thePredicate/v1",
+		},
+		"vetted payloadType, other predicate": {
+			payloadType:   "application/vnd.in-toto.provenance+json",
+			predicateType: "https://slsa.dev/verification_summary/v1",
+			mismatch: &PayloadTypeMismatch{
+				PayloadType:         "application/vnd.in-toto.provenance+json",
+				PredicateName:       "provenance",
+				PredicateType:       "https://slsa.dev/verification_summary/v1",
+				ExpectedPayloadType: "application/vnd.in-toto.vsa+json",
+			},
+		},
+		"vetted payloadType, custom predicate": {
+			payloadType:   "application/vnd.in-toto.provenance+json",
+			predicateType: "https://example.com/thePredicate/v1",
+			mismatch: &PayloadTypeMismatch{
+				PayloadType:         "application/vnd.in-toto.provenance+json",
+				PredicateName:       "provenance",
+				PredicateType:       "https://example.com/thePredicate/v1",
+				ExpectedPayloadType: PayloadType,
+			},
+		},
+		"custom payloadType, vetted predicate": {
+			payloadType:   "application/vnd.in-toto.thePredicate+json",
+			predicateType: "https://slsa.dev/provenance/v1",
+			mismatch: &PayloadTypeMismatch{
+				PayloadType:         "application/vnd.in-toto.thePredicate+json",
+				PredicateName:       "thePredicate",
+				PredicateType:       "https://slsa.dev/provenance/v1",
+				ExpectedPayloadType: "application/vnd.in-toto.provenance+json",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		env := &Envelope{PayloadType: test.payloadType}
+		got := env.PayloadTypeMismatch(&ita1.Statement{PredicateType: test.predicateType})
+		assert.Equal(t, test.mismatch, got, fmt.Sprintf("unexpected mismatch report in test '%s'", name))
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Signing and verification APIs for DSSE envelopes.
+*/
+
+package dsse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+var (
+	ErrSignerRequired   = errors.New("at least one signer required")
+	ErrVerifierRequired = errors.New("at least one verifier required")
+	ErrNoValidSignature = errors.New("no signature matches a recognized key")
+)
+
+// Sign signs the envelope's PAE with each signer and appends the resulting
+// signatures, leaving the payload and any existing signatures untouched.
+// Signers that can verify, and one of whose signatures the envelope already
+// holds, are skipped.
+func (e *Envelope) Sign(ctx context.Context, signers ...signature.Signer) error {
+	if len(signers) == 0 {
+		return ErrSignerRequired
+	}
+
+	if e.PayloadType == "" {
+		return ErrPayloadTypeRequired
+	}
+
+	if len(e.Payload) == 0 {
+		return ErrPayloadRequired
+	}
+
+	pae := e.PAE()
+	sigs := make([]Signature, 0, len(signers))
+	for i, s := range signers {
+		keyID, err := s.KeyID()
+		if err != nil {
+			return fmt.Errorf("signers[%d]: failed to get keyid: %w", i, err)
+		}
+
+		if v, ok := s.(signature.Verifier); ok && e.hasValidSignature(ctx, pae, keyID, v) {
+			continue
+		}
+
+		sig, err := s.Sign(ctx, pae)
+		if err != nil {
+			return fmt.Errorf("signers[%d]: failed to sign: %w", i, err)
+		}
+
+		sigs = append(sigs, Signature{KeyID: keyID, Sig: sig})
+	}
+
+	for _, sig := range sigs {
+		if !e.hasSignature(sig) {
+			e.Signatures = append(e.Signatures, sig)
+		}
+	}
+
+	return nil
+}
+
+// hasValidSignature indicates if the envelope holds a signature with the
+// keyid that the verifier accepts.
+func (e *Envelope) hasValidSignature(ctx context.Context, pae []byte, keyID string, v signature.Verifier) bool {
+	for _, sig := range e.Signatures {
+		if sig.KeyID == keyID && v.Verify(ctx, pae, sig.Sig) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verify checks the envelope's signatures against the given verifiers and
+// returns the verifiers that matched at least one signature. It fails with
+// ErrNoValidSignature if none did.
+func (e *Envelope) Verify(ctx context.Context, verifiers ...signature.Verifier) ([]signature.Verifier, error) {
+	if len(verifiers) == 0 {
+		return nil, ErrVerifierRequired
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	pae := e.PAE()
+	matched := make([]signature.Verifier, 0, len(verifiers))
+	for _, v := range verifiers {
+		for _, sig := range e.Signatures {
+			err := v.Verify(ctx, pae, sig.Sig)
+			if err == nil {
+				matched = append(matched, v)
+				break
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, ErrNoValidSignature
+	}
+
+	return matched, nil
+}
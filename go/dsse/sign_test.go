@@ -0,0 +1,88 @@
+/*
+Tests for signing and verifying DSSE envelopes.
+*/
+
+package dsse
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestSignedEnvelope(t *testing.T, signers ...signature.Signer) *Envelope {
+	t.Helper()
+
+	env, err := NewEnvelope(createTestStatement(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.Sign(context.Background(), signers...); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestSignVerifyEnvelope(t *testing.T) {
+	ctx := context.Background()
+	edSigner := testutil.NewSigner(t)
+	ecSigner := testutil.NewECDSASigner(t)
+	other := testutil.NewSigner(t)
+
+	env := createTestSignedEnvelope(t, edSigner, ecSigner)
+	assert.Len(t, env.Signatures, 2)
+
+	wantID, _ := edSigner.KeyID()
+	assert.Equal(t, wantID, env.Signatures[0].KeyID)
+
+	// round trip through JSON to check that the signatures survive encoding
+	data, err := json.Marshal(env)
+	assert.NoError(t, err)
+	got, err := Parse(data)
+	assert.NoError(t, err)
+
+	matched, err := got.Verify(ctx, other, ecSigner, edSigner)
+	assert.NoError(t, err)
+	assert.Equal(t, []signature.Verifier{ecSigner, edSigner}, matched)
+
+	_, err = got.Verify(ctx, other)
+	assert.ErrorIs(t, err, ErrNoValidSignature)
+}
+
+func TestVerifyTamperedEnvelope(t *testing.T) {
+	ctx := context.Background()
+	signer := testutil.NewSigner(t)
+
+	env := createTestSignedEnvelope(t, signer)
+	env.PayloadType = "application/vnd.in-toto.provenance+json"
+	_, err := env.Verify(ctx, signer)
+	assert.ErrorIs(t, err, ErrNoValidSignature, "verified envelope with tampered payloadType")
+
+	env = createTestSignedEnvelope(t, signer)
+	env.Payload = append(env.Payload, ' ')
+	_, err = env.Verify(ctx, signer)
+	assert.ErrorIs(t, err, ErrNoValidSignature, "verified envelope with tampered payload")
+}
+
+func TestSignVerifyErrors(t *testing.T) {
+	ctx := context.Background()
+
+	env, err := NewEnvelope(createTestStatement(t))
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, env.Sign(ctx), ErrSignerRequired)
+
+	_, err = env.Verify(ctx, testutil.NewSigner(t))
+	assert.ErrorIs(t, err, ErrSignaturesRequired, "verified unsigned envelope")
+
+	_, err = env.Verify(ctx)
+	assert.ErrorIs(t, err, ErrVerifierRequired)
+
+	assert.ErrorIs(t, (&Envelope{PayloadType: PayloadType}).Sign(ctx, testutil.NewSigner(t)), ErrPayloadRequired)
+}
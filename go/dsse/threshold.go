@@ -0,0 +1,93 @@
+/*
+Threshold verification of multi-signature DSSE envelopes.
+*/
+
+package dsse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must be at least 1")
+	ErrThresholdNotMet  = errors.New("signature threshold not met")
+)
+
+// ThresholdResult reports the outcome of verifying an envelope's signatures
+// against a set of attesters.
+type ThresholdResult struct {
+	// Attesters are the names of the distinct attesters counted towards the
+	// threshold, in the order the attesters were given.
+	Attesters []string
+	// Unrecognized are the signatures that no attester's key verified, and
+	// whose keyid did not identify any attester.
+	Unrecognized []Signature
+	// Invalid are the signatures whose keyid identified one or more
+	// attesters, but that none of those attesters' keys verified.
+	Invalid []Signature
+}
+
+// VerifyThreshold checks that at least threshold distinct attesters signed
+// the envelope.
+//
+// Each attester counts at most once, no matter how many signatures it
+// produced, and attesters sharing a public key count as one: the same key
+// listed under two names cannot satisfy a threshold of two. Signatures are
+// matched to keys using keyid hints as described for AttesterNames.
+//
+// The result is returned even if the threshold is not met, in which case
+// the error wraps ErrThresholdNotMet.
+func (e *Envelope) VerifyThreshold(ctx context.Context, threshold int, attesters ...Attester) (*ThresholdResult, error) {
+	if threshold < 1 {
+		return nil, ErrInvalidThreshold
+	}
+
+	matches, err := e.matchSignatures(ctx, attesters)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ThresholdResult{Attesters: []string{}}
+	for s, sig := range e.Signatures {
+		if len(matches.verifiedBy[s]) > 0 {
+			continue
+		}
+
+		if matches.hinted[s] {
+			result.Invalid = append(result.Invalid, sig)
+		} else {
+			result.Unrecognized = append(result.Unrecognized, sig)
+		}
+	}
+
+	seenNames := map[string]bool{}
+	counted := []Attester{}
+	for i, a := range attesters {
+		if !matches.attesterMatched(i) || seenNames[a.Name] {
+			continue
+		}
+
+		duplicateKey := false
+		for _, c := range counted {
+			if sameKey(c.Verifier, a.Verifier) {
+				duplicateKey = true
+				break
+			}
+		}
+		if duplicateKey {
+			continue
+		}
+
+		seenNames[a.Name] = true
+		counted = append(counted, a)
+		result.Attesters = append(result.Attesters, a.Name)
+	}
+
+	if len(result.Attesters) < threshold {
+		return result, fmt.Errorf("%w: got %d distinct attesters, want %d", ErrThresholdNotMet, len(result.Attesters), threshold)
+	}
+
+	return result, nil
+}
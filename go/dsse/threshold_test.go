@@ -0,0 +1,131 @@
+/*
+Tests for threshold verification of DSSE envelopes.
+*/
+
+package dsse
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyThreshold(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewECDSASigner(t)
+	carol := testutil.NewSigner(t)
+	mallory := testutil.NewSigner(t)
+
+	attesters := []Attester{
+		{Name: "alice", Verifier: alice},
+		{Name: "bob", Verifier: bob},
+		{Name: "carol", Verifier: carol},
+	}
+
+	tests := map[string]struct {
+		signers      []signature.Signer
+		threshold    int
+		want         []string
+		unrecognized int
+		err          error
+	}{
+		"two of three": {
+			signers:   []signature.Signer{bob, alice},
+			threshold: 2,
+			want:      []string{"alice", "bob"},
+		},
+		"all three": {
+			signers:   []signature.Signer{alice, bob, carol},
+			threshold: 3,
+			want:      []string{"alice", "bob", "carol"},
+		},
+		"same key twice": {
+			signers:   []signature.Signer{alice, alice},
+			threshold: 2,
+			want:      []string{"alice"},
+			err:       ErrThresholdNotMet,
+		},
+		"unrecognized signer": {
+			signers:      []signature.Signer{alice, mallory},
+			threshold:    2,
+			want:         []string{"alice"},
+			unrecognized: 1,
+			err:          ErrThresholdNotMet,
+		},
+	}
+
+	for name, test := range tests {
+		env := createTestSignedEnvelope(t, test.signers...)
+
+		got, err := env.VerifyThreshold(ctx, test.threshold, attesters...)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		}
+
+		if assert.NotNil(t, got, fmt.Sprintf("no result in test '%s'", name)) {
+			assert.Equal(t, test.want, got.Attesters, fmt.Sprintf("wrong attesters in test '%s'", name))
+			assert.Len(t, got.Unrecognized, test.unrecognized, fmt.Sprintf("wrong unrecognized signatures in test '%s'", name))
+			assert.Empty(t, got.Invalid, fmt.Sprintf("unexpected invalid signatures in test '%s'", name))
+		}
+	}
+}
+
+func TestVerifyThresholdSharedKey(t *testing.T) {
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewSigner(t)
+	env := createTestSignedEnvelope(t, alice, bob)
+
+	// the same key listed under two names must not count twice
+	got, err := env.VerifyThreshold(context.Background(), 2,
+		Attester{Name: "alice", Verifier: alice},
+		Attester{Name: "alias", Verifier: alice},
+	)
+	assert.ErrorIs(t, err, ErrThresholdNotMet)
+	assert.Equal(t, []string{"alice"}, got.Attesters)
+	assert.Len(t, got.Unrecognized, 1)
+}
+
+func TestVerifyThresholdKeyIDHints(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewSigner(t)
+	attesters := []Attester{{Name: "alice", Verifier: alice}, {Name: "bob", Verifier: bob}}
+
+	// missing and unknown hints fall back to trying every key
+	env := createTestSignedEnvelope(t, alice, bob)
+	env.Signatures[0].KeyID = ""
+	env.Signatures[1].KeyID = "unknown"
+
+	got, err := env.VerifyThreshold(ctx, 2, attesters...)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, got.Attesters)
+
+	// a signature whose hint names a key it does not verify under is invalid
+	env = createTestSignedEnvelope(t, alice, bob)
+	env.Signatures[1].KeyID = env.Signatures[0].KeyID
+
+	got, err = env.VerifyThreshold(ctx, 2, attesters...)
+	assert.ErrorIs(t, err, ErrThresholdNotMet)
+	assert.Equal(t, []string{"alice"}, got.Attesters)
+	assert.Equal(t, []Signature{env.Signatures[1]}, got.Invalid)
+	assert.Empty(t, got.Unrecognized)
+}
+
+func TestVerifyThresholdErrors(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	env := createTestSignedEnvelope(t, alice)
+
+	_, err := env.VerifyThreshold(ctx, 0, Attester{Name: "alice", Verifier: alice})
+	assert.ErrorIs(t, err, ErrInvalidThreshold)
+
+	_, err = env.VerifyThreshold(ctx, 1)
+	assert.ErrorIs(t, err, ErrAttesterRequired)
+}
@@ -0,0 +1,239 @@
+/*
+Parsing of raw git commit and tag objects, as hashed by the gitCommit and
+gitTag algorithms of spec/v1/digest_set.md.
+*/
+
+package gitobject
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCommit    = errors.New("invalid git commit object")
+	ErrInvalidTag       = errors.New("invalid git tag object")
+	ErrInvalidSignature = errors.New("invalid git author, committer or tagger line")
+)
+
+// Signature identifies the author, committer or tagger of an object, and
+// when they acted.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// Header is a header of a commit or tag object. Continuation lines of
+// multi-line values, such as gpgsig, are joined with newlines.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Commit is a parsed commit object.
+type Commit struct {
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	// Headers holds the headers other than tree, parent, author and
+	// committer, e.g. encoding, mergetag and gpgsig, in object order.
+	Headers []Header
+	Message string
+}
+
+// Tag is a parsed annotated tag object.
+type Tag struct {
+	Object string
+	Type   string
+	Name   string
+	// Tagger is nil for the few old tags without one.
+	Tagger *Signature
+	// Headers holds the headers other than object, type, tag and tagger.
+	Headers []Header
+	Message string
+}
+
+// parseHeaders splits an object into its headers and message.
+func parseHeaders(data []byte) ([]Header, string, error) {
+	head, message, found := bytes.Cut(data, []byte("\n\n"))
+	if !found {
+		// an object may end right after its headers
+		head = bytes.TrimSuffix(data, []byte("\n"))
+	}
+
+	headers := []Header{}
+	for _, line := range strings.Split(string(head), "\n") {
+		if strings.HasPrefix(line, " ") {
+			if len(headers) == 0 {
+				return nil, "", errors.New("continuation line before any header")
+			}
+			headers[len(headers)-1].Value += "\n" + line[1:]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, " ")
+		if !ok || key == "" {
+			return nil, "", fmt.Errorf("malformed header %q", line)
+		}
+		headers = append(headers, Header{Key: key, Value: value})
+	}
+
+	return headers, string(message), nil
+}
+
+// ParseCommit parses the content of a raw commit object, i.e. without its
+// `commit <size>` header.
+func ParseCommit(data []byte) (*Commit, error) {
+	headers, message, err := parseHeaders(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCommit, err)
+	}
+
+	c := &Commit{Parents: []string{}, Headers: []Header{}, Message: message}
+	var hasAuthor, hasCommitter bool
+	for i, h := range headers {
+		switch {
+		case h.Key == "tree" && i == 0:
+			c.Tree = h.Value
+		case h.Key == "parent" && !hasAuthor:
+			c.Parents = append(c.Parents, h.Value)
+		case h.Key == "author" && !hasAuthor:
+			hasAuthor = true
+			if c.Author, err = ParseSignature(h.Value); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidCommit, err)
+			}
+		case h.Key == "committer" && hasAuthor && !hasCommitter:
+			hasCommitter = true
+			if c.Committer, err = ParseSignature(h.Value); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidCommit, err)
+			}
+		case h.Key == "tree" || h.Key == "parent" || h.Key == "author" || h.Key == "committer":
+			return nil, fmt.Errorf("%w: unexpected %s header", ErrInvalidCommit, h.Key)
+		default:
+			c.Headers = append(c.Headers, h)
+		}
+	}
+
+	if c.Tree == "" || !hasAuthor || !hasCommitter {
+		return nil, fmt.Errorf("%w: tree, author and committer headers required", ErrInvalidCommit)
+	}
+
+	for _, name := range append([]string{c.Tree}, c.Parents...) {
+		if _, err := FormatForDigest(name); err != nil || !isHex(name) {
+			return nil, fmt.Errorf("%w: invalid object name %q", ErrInvalidCommit, name)
+		}
+	}
+
+	return c, nil
+}
+
+// ParseTag parses the content of a raw tag object.
+func ParseTag(data []byte) (*Tag, error) {
+	headers, message, err := parseHeaders(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTag, err)
+	}
+
+	t := &Tag{Headers: []Header{}, Message: message}
+	for i, h := range headers {
+		switch {
+		case h.Key == "object" && i == 0:
+			t.Object = h.Value
+		case h.Key == "type" && i == 1:
+			t.Type = h.Value
+		case h.Key == "tag" && i == 2:
+			t.Name = h.Value
+		case h.Key == "tagger" && i == 3:
+			s, err := ParseSignature(h.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidTag, err)
+			}
+			t.Tagger = &s
+		case h.Key == "object" || h.Key == "type" || h.Key == "tag" || h.Key == "tagger":
+			return nil, fmt.Errorf("%w: unexpected %s header", ErrInvalidTag, h.Key)
+		default:
+			t.Headers = append(t.Headers, h)
+		}
+	}
+
+	if t.Object == "" || t.Type == "" || t.Name == "" {
+		return nil, fmt.Errorf("%w: object, type and tag headers required", ErrInvalidTag)
+	}
+
+	if _, err := FormatForDigest(t.Object); err != nil || !isHex(t.Object) {
+		return nil, fmt.Errorf("%w: invalid object name %q", ErrInvalidTag, t.Object)
+	}
+
+	if err := validType(t.Type); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTag, err)
+	}
+
+	return t, nil
+}
+
+// ParseSignature parses the value of an author, committer or tagger
+// header, e.g. `A U Thor <author@example.com> 1700000000 +0100`.
+func ParseSignature(value string) (Signature, error) {
+	open := strings.LastIndex(value, " <")
+	end := strings.LastIndex(value, "> ")
+	if open < 0 || end < open {
+		return Signature{}, fmt.Errorf("%w: %q", ErrInvalidSignature, value)
+	}
+
+	fields := strings.Fields(value[end+2:])
+	if len(fields) != 2 {
+		return Signature{}, fmt.Errorf("%w: %q", ErrInvalidSignature, value)
+	}
+
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Signature{}, fmt.Errorf("%w: %q", ErrInvalidSignature, value)
+	}
+
+	offset, err := parseZone(fields[1])
+	if err != nil {
+		return Signature{}, fmt.Errorf("%w: %q", ErrInvalidSignature, value)
+	}
+
+	return Signature{
+		Name:  value[:open],
+		Email: value[open+2 : end],
+		When:  time.Unix(seconds, 0).In(time.FixedZone("", offset)),
+	}, nil
+}
+
+// parseZone returns the offset in seconds of a `+hhmm` or `-hhmm` zone.
+func parseZone(zone string) (int, error) {
+	if len(zone) != 5 || (zone[0] != '+' && zone[0] != '-') {
+		return 0, fmt.Errorf("malformed zone %q", zone)
+	}
+
+	hhmm, err := strconv.ParseUint(zone[1:], 10, 16)
+	if err != nil || hhmm%100 >= 60 {
+		return 0, fmt.Errorf("malformed zone %q", zone)
+	}
+
+	offset := int(hhmm/100)*3600 + int(hhmm%100)*60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+
+	return offset, nil
+}
+
+// isHex indicates if s is lowercase hex, as object names are written.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,215 @@
+/*
+Tests for parsing git commit and tag objects.
+*/
+
+package gitobject
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTree   = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	testParent = "5ab2f8a4323abafb10abb68657d9d39f1a775057"
+)
+
+var (
+	testAuthor = Signature{
+		Name:  "A U Thor",
+		Email: "author@example.com",
+		When:  time.Unix(1700000000, 0).In(time.FixedZone("", 3600)),
+	}
+	testCommitter = Signature{
+		Name:  "C O Mitter",
+		Email: "committer@example.com",
+		When:  time.Unix(1700000100, 0).In(time.FixedZone("", -5*3600-1800)),
+	}
+)
+
+func TestParseCommit(t *testing.T) {
+	tests := map[string]struct {
+		data     string
+		expected *Commit
+	}{
+		"root commit": {
+			data: "tree " + testTree + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0100\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 -0530\n" +
+				"\n" +
+				"Initial commit\n",
+			expected: &Commit{
+				Tree:      testTree,
+				Parents:   []string{},
+				Author:    testAuthor,
+				Committer: testCommitter,
+				Headers:   []Header{},
+				Message:   "Initial commit\n",
+			},
+		},
+		"signed merge": {
+			data: "tree " + testTree + "\n" +
+				"parent " + testParent + "\n" +
+				"parent " + testTree + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0100\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 -0530\n" +
+				"encoding ISO-8859-1\n" +
+				"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+				" \n" +
+				" iQEzBAABCAAdFiEE\n" +
+				" -----END PGP SIGNATURE-----\n" +
+				"\n" +
+				"Merge branch 'main'\n\nWith a body.\n",
+			expected: &Commit{
+				Tree:      testTree,
+				Parents:   []string{testParent, testTree},
+				Author:    testAuthor,
+				Committer: testCommitter,
+				Headers: []Header{
+					{Key: "encoding", Value: "ISO-8859-1"},
+					{Key: "gpgsig", Value: "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEE\n-----END PGP SIGNATURE-----"},
+				},
+				Message: "Merge branch 'main'\n\nWith a body.\n",
+			},
+		},
+		"no message": {
+			data: "tree " + testTree + "\n" +
+				"author A U Thor <author@example.com> 1700000000 +0100\n" +
+				"committer C O Mitter <committer@example.com> 1700000100 -0530\n",
+			expected: &Commit{
+				Tree:      testTree,
+				Parents:   []string{},
+				Author:    testAuthor,
+				Committer: testCommitter,
+				Headers:   []Header{},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		c, err := ParseCommit([]byte(test.data))
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		assert.Equal(t, test.expected, c, fmt.Sprintf("unexpected commit in test '%s'", name))
+	}
+}
+
+func TestParseCommitErrors(t *testing.T) {
+	const (
+		tree      = "tree " + testTree + "\n"
+		parent    = "parent " + testParent + "\n"
+		author    = "author A U Thor <author@example.com> 1700000000 +0100\n"
+		committer = "committer C O Mitter <committer@example.com> 1700000100 -0530\n"
+	)
+
+	tests := map[string]string{
+		"empty":                   "",
+		"no tree":                 author + committer,
+		"no author":               tree + committer,
+		"no committer":            tree + author,
+		"tree after parent":       parent + tree + author + committer,
+		"parent after author":     tree + author + parent + committer,
+		"committer before author": tree + committer + author,
+		"two trees":               tree + tree + author + committer,
+		"two committers":          tree + author + committer + committer,
+		"short tree":              "tree abc123\n" + author + committer,
+		"uppercase parent":        tree + "parent 5AB2F8A4323ABAFB10ABB68657D9D39F1A775057\n" + author + committer,
+		"malformed author":        tree + "author A U Thor 1700000000 +0100\n" + committer,
+		"leading continuation":    " gpgsig\n" + tree + author + committer,
+		"header without value":    tree + author + committer + "encoding\n",
+	}
+
+	for name, data := range tests {
+		_, err := ParseCommit([]byte(data))
+		assert.ErrorIs(t, err, ErrInvalidCommit, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	data := "object " + testParent + "\n" +
+		"type commit\n" +
+		"tag v1.0.0\n" +
+		"tagger A U Thor <author@example.com> 1700000000 +0100\n" +
+		"\n" +
+		"Release v1.0.0\n"
+
+	tag, err := ParseTag([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, &Tag{
+		Object:  testParent,
+		Type:    TypeCommit,
+		Name:    "v1.0.0",
+		Tagger:  &testAuthor,
+		Headers: []Header{},
+		Message: "Release v1.0.0\n",
+	}, tag)
+
+	// the oldest tags have no tagger
+	tag, err = ParseTag([]byte("object " + testTree + "\ntype tree\ntag v0\n\nTree\n"))
+	assert.NoError(t, err)
+	assert.Nil(t, tag.Tagger)
+	assert.Equal(t, TypeTree, tag.Type)
+}
+
+func TestParseTagErrors(t *testing.T) {
+	const (
+		object = "object " + testParent + "\n"
+		typ    = "type commit\n"
+		tag    = "tag v1.0.0\n"
+	)
+
+	tests := map[string]string{
+		"empty":            "",
+		"no object":        typ + tag,
+		"no type":          object + tag,
+		"no name":          object + typ,
+		"type first":       typ + object + tag,
+		"unknown type":     object + "type note\n" + tag,
+		"invalid object":   "object v1\n" + typ + tag,
+		"malformed tagger": object + typ + tag + "tagger A U Thor <author@example.com>\n",
+		"two tags":         object + typ + tag + tag,
+	}
+
+	for name, data := range tests {
+		_, err := ParseTag([]byte(data))
+		assert.ErrorIs(t, err, ErrInvalidTag, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected Signature
+	}{
+		"utc": {
+			value:    "A U Thor <author@example.com> 0 +0000",
+			expected: Signature{Name: "A U Thor", Email: "author@example.com", When: time.Unix(0, 0).UTC()},
+		},
+		"angle brackets in name": {
+			value:    "<A> U Thor <author@example.com> 1700000000 +0100",
+			expected: Signature{Name: "<A> U Thor", Email: "author@example.com", When: testAuthor.When},
+		},
+		"empty name and email": {
+			value:    " <> 1700000100 -0530",
+			expected: Signature{When: testCommitter.When},
+		},
+	}
+
+	for name, test := range tests {
+		s, err := ParseSignature(test.value)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		assert.Equal(t, test.expected.Name, s.Name, fmt.Sprintf("unexpected name in test '%s'", name))
+		assert.Equal(t, test.expected.Email, s.Email, fmt.Sprintf("unexpected email in test '%s'", name))
+		assert.True(t, test.expected.When.Equal(s.When), fmt.Sprintf("unexpected time in test '%s'", name))
+		_, wantOffset := test.expected.When.Zone()
+		_, gotOffset := s.When.Zone()
+		assert.Equal(t, wantOffset, gotOffset, fmt.Sprintf("unexpected zone in test '%s'", name))
+	}
+
+	for _, value := range []string{"", "A U Thor", "A U Thor <author@example.com>", "A U Thor <author@example.com> now +0100", "A U Thor <author@example.com> 0 CET"} {
+		_, err := ParseSignature(value)
+		assert.ErrorIs(t, err, ErrInvalidSignature, fmt.Sprintf("expected error for %q", value))
+	}
+}
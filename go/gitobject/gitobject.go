@@ -0,0 +1,263 @@
+/*
+Computation of git object names, for the gitBlob and gitTree algorithms of
+spec/v1/digest_set.md, for files and directory trees inside or outside git.
+*/
+
+package gitobject
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Format is the hash function of a git object format.
+type Format string
+
+const (
+	FormatSHA1   Format = "sha1"
+	FormatSHA256 Format = "sha256"
+)
+
+// Object types.
+const (
+	TypeBlob   = "blob"
+	TypeTree   = "tree"
+	TypeCommit = "commit"
+	TypeTag    = "tag"
+)
+
+// Tree entry modes, as written in tree objects.
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeDir        = "40000"
+)
+
+var (
+	ErrUnsupportedFormat   = errors.New("unsupported git object format")
+	ErrUnsupportedType     = errors.New("unsupported git object type")
+	ErrSizeMismatch        = errors.New("object content does not match its size")
+	ErrUnsupportedFileType = errors.New("file type cannot be stored in git")
+)
+
+// newHash returns a hash function of the object format.
+func (f Format) newHash() (hash.Hash, error) {
+	switch f {
+	case FormatSHA1:
+		return sha1.New(), nil
+	case FormatSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, f)
+	}
+}
+
+func validType(objType string) error {
+	switch objType {
+	case TypeBlob, TypeTree, TypeCommit, TypeTag:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedType, objType)
+	}
+}
+
+// Hash returns the lowercase hex name of the object with the given type and
+// content, i.e. the hash of `<type> SP <size> NUL <content>`.
+func Hash(f Format, objType string, content []byte) (string, error) {
+	return HashReader(f, objType, bytes.NewReader(content), int64(len(content)))
+}
+
+// HashReader returns the name of the object with the given type whose
+// content of the given size is read from r. It fails with ErrSizeMismatch
+// if r holds more or fewer bytes.
+func HashReader(f Format, objType string, r io.Reader, size int64) (string, error) {
+	sum, err := hashReader(f, objType, r, size)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+func hashReader(f Format, objType string, r io.Reader, size int64) ([]byte, error) {
+	h, err := f.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validType(objType); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(h, "%s %d\x00", objType, size)
+	n, err := io.Copy(h, io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+
+	if n != size {
+		return nil, fmt.Errorf("%w: read %d bytes, want %d", ErrSizeMismatch, n, size)
+	}
+
+	// r must end where the content does
+	if m, _ := r.Read(make([]byte, 1)); m > 0 {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrSizeMismatch, size)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// HashFile returns the gitBlob of a file, as `git hash-object` does. The
+// blob of a symbolic link holds the path it points to, rather than the
+// content of its target.
+func HashFile(f Format, name string) (string, error) {
+	sum, _, err := hashFile(f, name)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// hashFile returns the blob name and the tree entry mode of a file.
+func hashFile(f Format, name string) ([]byte, string, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(name)
+		if err != nil {
+			return nil, "", err
+		}
+
+		target = filepath.ToSlash(target)
+		sum, err := hashReader(f, TypeBlob, strings.NewReader(target), int64(len(target)))
+		return sum, ModeSymlink, err
+	case info.Mode().IsRegular():
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+
+		mode := ModeFile
+		// git only records the owner's executable bit
+		if info.Mode().Perm()&0o100 != 0 {
+			mode = ModeExecutable
+		}
+
+		sum, err := hashReader(f, TypeBlob, file, info.Size())
+		return sum, mode, err
+	default:
+		return nil, "", fmt.Errorf("%w: %s is a %s", ErrUnsupportedFileType, name, info.Mode().Type())
+	}
+}
+
+// treeEntry is an entry of a tree object.
+type treeEntry struct {
+	mode string
+	name string
+	sum  []byte
+}
+
+// sortKey returns the name tree entries are sorted by: git compares the
+// names of subtrees as if they ended with a slash.
+func (e treeEntry) sortKey() string {
+	if e.mode == ModeDir {
+		return e.name + "/"
+	}
+
+	return e.name
+}
+
+// HashDir returns the gitTree of a directory, as `git add` followed by
+// `git write-tree` computes it in a fresh repository: directories holding
+// no files are left out, as git cannot track them, and so are `.git`
+// directories. Other special files, such as sockets, are rejected.
+func HashDir(f Format, dir string) (string, error) {
+	sum, _, err := hashDir(f, dir)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// hashDir returns the name of a directory's tree, and the number of its
+// entries.
+func hashDir(f Format, dir string) ([]byte, int, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]treeEntry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		if d.Name() == ".git" {
+			continue
+		}
+		name := filepath.Join(dir, d.Name())
+
+		if d.IsDir() {
+			sum, n, err := hashDir(f, name)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n > 0 {
+				entries = append(entries, treeEntry{mode: ModeDir, name: d.Name(), sum: sum})
+			}
+			continue
+		}
+
+		sum, mode, err := hashFile(f, name)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, treeEntry{mode: mode, name: d.Name(), sum: sum})
+	}
+
+	// byte-wise comparison is the C locale's collation
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].sortKey() < entries[j].sortKey()
+	})
+
+	var content bytes.Buffer
+	for _, e := range entries {
+		content.WriteString(e.mode)
+		content.WriteByte(' ')
+		content.WriteString(e.name)
+		content.WriteByte(0)
+		content.Write(e.sum)
+	}
+
+	sum, err := hashReader(f, TypeTree, &content, int64(content.Len()))
+	return sum, len(entries), err
+}
+
+// FormatForDigest returns the object format of a hex-encoded object name,
+// telling SHA-1 and SHA-256 names apart by their length.
+func FormatForDigest(digest string) (Format, error) {
+	switch len(digest) {
+	case hex.EncodedLen(sha1.Size):
+		return FormatSHA1, nil
+	case hex.EncodedLen(sha256.Size):
+		return FormatSHA256, nil
+	default:
+		return "", fmt.Errorf("%w: name of length %d", ErrUnsupportedFormat, len(digest))
+	}
+}
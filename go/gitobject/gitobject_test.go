@@ -0,0 +1,169 @@
+/*
+Tests for git object names.
+*/
+
+package gitobject
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFormats = []Format{FormatSHA1, FormatSHA256}
+
+// testObject is a line of a tree listing generated by git.
+type testObject struct {
+	mode    string
+	objType string
+	name    string
+	path    string
+}
+
+func readTestObjects(t *testing.T, f Format) []testObject {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", fmt.Sprintf("tree.%s.txt", f)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	objects := []testObject{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		meta, path, ok := strings.Cut(scanner.Text(), "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 {
+			t.Fatalf("malformed listing line %q", scanner.Text())
+		}
+		objects = append(objects, testObject{mode: fields[0], objType: fields[1], name: fields[2], path: path})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return objects
+}
+
+func TestHash(t *testing.T) {
+	// the example of spec/v1/digest_set.md
+	name, err := Hash(FormatSHA1, TypeBlob, []byte("Hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "5ab2f8a4323abafb10abb68657d9d39f1a775057", name)
+
+	// the empty tree
+	name, err = Hash(FormatSHA1, TypeTree, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", name)
+
+	name, err = Hash(FormatSHA256, TypeTree, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321", name)
+}
+
+func TestHashErrors(t *testing.T) {
+	tests := map[string]struct {
+		format      Format
+		objType     string
+		content     string
+		size        int64
+		expectedErr error
+	}{
+		"unknown format": {
+			format:      "md5",
+			objType:     TypeBlob,
+			expectedErr: ErrUnsupportedFormat,
+		},
+		"unknown type": {
+			format:      FormatSHA1,
+			objType:     "note",
+			expectedErr: ErrUnsupportedType,
+		},
+		"short content": {
+			format:      FormatSHA256,
+			objType:     TypeBlob,
+			content:     "Hello",
+			size:        6,
+			expectedErr: ErrSizeMismatch,
+		},
+		"long content": {
+			format:      FormatSHA256,
+			objType:     TypeBlob,
+			content:     "Hello",
+			size:        4,
+			expectedErr: ErrSizeMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := HashReader(test.format, test.objType, strings.NewReader(test.content), test.size)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestHashFileAndDir(t *testing.T) {
+	for _, f := range testFormats {
+		objects := readTestObjects(t, f)
+		assert.NotEmpty(t, objects)
+
+		for _, o := range objects {
+			path := filepath.Join("testdata", "tree", filepath.FromSlash(o.path))
+
+			var name string
+			var err error
+			if o.objType == TypeTree {
+				name, err = HashDir(f, path)
+			} else {
+				name, err = HashFile(f, path)
+			}
+			assert.NoError(t, err, fmt.Sprintf("unexpected error for %s in format %s", o.path, f))
+			assert.Equal(t, o.name, name, fmt.Sprintf("unexpected %s name for %s in format %s", o.objType, o.path, f))
+		}
+	}
+}
+
+func TestHashDirSkipsUntrackedEntries(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("Hello"), 0o644))
+
+	want, err := HashDir(FormatSHA1, dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "empty", "emptier"), 0o755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+
+	got, err := HashDir(FormatSHA1, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestHashFileErrors(t *testing.T) {
+	_, err := HashFile(FormatSHA1, filepath.Join("testdata", "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = HashFile(FormatSHA1, filepath.Join("testdata", "tree"))
+	assert.ErrorIs(t, err, ErrUnsupportedFileType)
+
+	_, err = HashDir(FormatSHA1, filepath.Join("testdata", "tree", "hello.txt"))
+	assert.Error(t, err)
+}
+
+func TestFormatForDigest(t *testing.T) {
+	f, err := FormatForDigest("5ab2f8a4323abafb10abb68657d9d39f1a775057")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatSHA1, f)
+
+	f, err = FormatForDigest("6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatSHA256, f)
+
+	_, err = FormatForDigest("abc123")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,763 @@
+/*
+Read-only access to the loose and packed objects and the refs of a local git
+repository, without running git.
+*/
+
+package gitobject
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotRepository     = errors.New("not a git repository")
+	ErrInvalidObjectName = errors.New("invalid git object name")
+	ErrObjectNotFound    = errors.New("git object not found")
+	ErrCorruptObject     = errors.New("corrupt git object")
+	ErrUnexpectedType    = errors.New("git object has an unexpected type")
+	ErrRefNotFound       = errors.New("git ref not found")
+)
+
+// maxDeltaDepth bounds the chains of deltas followed to read a packed
+// object. git itself packs chains of at most 4095 deltas.
+const maxDeltaDepth = 4096
+
+// maxSymrefDepth bounds the chains of symbolic refs, as git does.
+const maxSymrefDepth = 5
+
+// pack object types
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypes = map[int]string{
+	packCommit: TypeCommit,
+	packTree:   TypeTree,
+	packBlob:   TypeBlob,
+	packTag:    TypeTag,
+}
+
+// Store reads objects from the object store of a local repository. Every
+// object read is checked against its name. A Store is safe for concurrent
+// use, but does not notice packs added after its first read of a packed
+// object.
+//
+// Alternate object stores, listed in objects/info/alternates by clones made
+// with --shared or --reference, are not read: objects only found there are
+// reported with ErrObjectNotFound.
+type Store struct {
+	gitDir    string
+	commonDir string
+	format    Format
+
+	once     sync.Once
+	packs    []*packIndex
+	packsErr error
+}
+
+// OpenStore opens the repository at path, which is either a working tree
+// or a bare repository.
+func OpenStore(path string) (*Store, error) {
+	gitDir, err := findGitDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	commonDir := gitDir
+	// linked worktrees share the objects and refs of the main repository
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	format, err := readObjectFormat(filepath.Join(commonDir, "config"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{gitDir: gitDir, commonDir: commonDir, format: format}, nil
+}
+
+// findGitDir returns the git directory of a working tree or bare
+// repository.
+func findGitDir(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err == nil && info.IsDir():
+		return dotGit, nil
+	case err == nil:
+		// worktrees and submodules point to their git directory
+		data, err := os.ReadFile(dotGit)
+		if err != nil {
+			return "", err
+		}
+
+		dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+		if !ok {
+			return "", fmt.Errorf("%w: malformed %s", ErrNotRepository, dotGit)
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(path, dir)
+		}
+		return dir, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	}
+
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotRepository, path)
+	}
+
+	return path, nil
+}
+
+// readObjectFormat reads the extensions.objectFormat setting of a
+// repository's config, which defaults to sha1.
+func readObjectFormat(config string) (Format, error) {
+	f, err := os.Open(config)
+	if errors.Is(err, os.ErrNotExist) {
+		return FormatSHA1, nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "extensions" || !strings.EqualFold(strings.TrimSpace(key), "objectformat") {
+			continue
+		}
+
+		format := Format(strings.ToLower(strings.TrimSpace(value)))
+		if _, err := format.newHash(); err != nil {
+			return "", err
+		}
+		return format, nil
+	}
+
+	return FormatSHA1, scanner.Err()
+}
+
+// Format returns the object format of the repository.
+func (s *Store) Format() Format {
+	return s.format
+}
+
+// rawName decodes an object name of the repository's format.
+func (s *Store) rawName(name string) ([]byte, error) {
+	f, err := FormatForDigest(name)
+	if err != nil || f != s.format || !isHex(name) {
+		return nil, fmt.Errorf("%w: %q in a %s repository", ErrInvalidObjectName, name, s.format)
+	}
+
+	return hex.DecodeString(name)
+}
+
+// Read returns the type and content of the named object, after checking
+// that they hash to its name.
+func (s *Store) Read(name string) (string, []byte, error) {
+	raw, err := s.rawName(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	objType, content, err := s.read(raw, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	got, err := Hash(s.format, objType, content)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if got != name {
+		return "", nil, fmt.Errorf("%w: %s hashes to %s", ErrCorruptObject, name, got)
+	}
+
+	return objType, content, nil
+}
+
+func (s *Store) read(raw []byte, depth int) (string, []byte, error) {
+	objType, content, err := s.readLoose(raw)
+	if !errors.Is(err, ErrObjectNotFound) {
+		return objType, content, err
+	}
+
+	s.once.Do(func() {
+		s.packs, s.packsErr = loadPackIndexes(filepath.Join(s.commonDir, "objects", "pack"), s.format)
+	})
+	if s.packsErr != nil {
+		return "", nil, s.packsErr
+	}
+
+	for _, p := range s.packs {
+		if offset, ok := p.find(raw); ok {
+			return s.readPacked(p, offset, depth)
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: %x", ErrObjectNotFound, raw)
+}
+
+func (s *Store) readLoose(raw []byte) (string, []byte, error) {
+	name := hex.EncodeToString(raw)
+	f, err := os.Open(filepath.Join(s.commonDir, "objects", name[:2], name[2:]))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	} else if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %v", ErrCorruptObject, name, err)
+	}
+	defer zr.Close()
+
+	br := bufio.NewReader(zr)
+	header, err := br.ReadString(0)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: missing header", ErrCorruptObject, name)
+	}
+
+	objType, sizeStr, ok := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if !ok || err != nil || size < 0 {
+		return "", nil, fmt.Errorf("%w: %s: malformed header %q", ErrCorruptObject, name, header)
+	}
+
+	content, err := readExactly(br, size)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %v", ErrCorruptObject, name, err)
+	}
+
+	return objType, content, nil
+}
+
+// readExactly reads size bytes, and fails if r holds more or fewer.
+func readExactly(r io.Reader, size int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, size+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) != size {
+		return nil, fmt.Errorf("%w: got %d bytes, want %d", ErrSizeMismatch, len(content), size)
+	}
+
+	return content, nil
+}
+
+// readPacked reads the object at an offset of a pack, resolving deltas.
+func (s *Store) readPacked(p *packIndex, offset int64, depth int) (string, []byte, error) {
+	if depth > maxDeltaDepth {
+		return "", nil, fmt.Errorf("%w: delta chain too long in %s", ErrCorruptObject, p.pack)
+	}
+
+	typ, data, base, err := s.readPackEntry(p, offset, depth)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if base == nil {
+		return packTypes[typ], data, nil
+	}
+
+	baseType, baseContent, err := base()
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := applyDelta(baseContent, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptObject, p.pack, offset, err)
+	}
+
+	return baseType, content, nil
+}
+
+// readPackEntry reads the type and decompressed data of the object at the
+// offset in a pack and, for deltas, returns a function reading the base.
+// The pack is closed before the base is read, so that long delta chains do
+// not hold a file open for every link.
+func (s *Store) readPackEntry(p *packIndex, offset int64, depth int) (int, []byte, func() (string, []byte, error), error) {
+	f, err := os.Open(p.pack)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer f.Close()
+
+	typ, size, headerLen, err := readPackHeader(f, offset)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptObject, p.pack, offset, err)
+	}
+
+	var base func() (string, []byte, error)
+	switch typ {
+	case packOfsDelta, packRefDelta:
+		// the base reference precedes the compressed delta
+		var buf [binary.MaxVarintLen64 + 64]byte
+		n, err := f.ReadAt(buf[:], offset+headerLen)
+		if n == 0 {
+			return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptObject, p.pack, offset, err)
+		}
+
+		if typ == packOfsDelta {
+			rel, m := readOffset(buf[:n])
+			if m == 0 || rel <= 0 || rel > offset {
+				return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: invalid base offset", ErrCorruptObject, p.pack, offset)
+			}
+			headerLen += int64(m)
+			base = func() (string, []byte, error) { return s.readPacked(p, offset-rel, depth+1) }
+		} else {
+			hashSize := len(p.names) / max(p.count, 1)
+			if n < hashSize {
+				return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: truncated base name", ErrCorruptObject, p.pack, offset)
+			}
+			baseName := bytes.Clone(buf[:hashSize])
+			headerLen += int64(hashSize)
+			base = func() (string, []byte, error) { return s.read(baseName, depth+1) }
+		}
+	case packCommit, packTree, packBlob, packTag:
+	default:
+		return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: unknown type %d", ErrCorruptObject, p.pack, offset, typ)
+	}
+
+	zr, err := zlib.NewReader(io.NewSectionReader(f, offset+headerLen, 1<<62))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptObject, p.pack, offset, err)
+	}
+	defer zr.Close()
+
+	data, err := readExactly(zr, size)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptObject, p.pack, offset, err)
+	}
+
+	return typ, data, base, nil
+}
+
+// readPackHeader reads the type and size of a packed object, and returns
+// the length of the header.
+func readPackHeader(f io.ReaderAt, offset int64) (int, int64, int64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n, err := f.ReadAt(buf[:], offset)
+	if n == 0 {
+		return 0, 0, 0, err
+	}
+
+	b := buf[0]
+	typ := int(b>>4) & 7
+	size := int64(b & 0x0f)
+	shift := 4
+	i := 1
+	for b&0x80 != 0 {
+		if i >= n || shift > 56 {
+			return 0, 0, 0, errors.New("malformed object header")
+		}
+		b = buf[i]
+		size |= int64(b&0x7f) << shift
+		shift += 7
+		i++
+	}
+
+	return typ, size, int64(i), nil
+}
+
+// readOffset decodes the base offset of an OFS_DELTA object, relative to
+// the delta, and returns the number of bytes read, or 0 if malformed.
+func readOffset(buf []byte) (int64, int) {
+	var offset int64
+	for i, b := range buf {
+		if i > 0 {
+			offset++
+		}
+		offset = offset<<7 | int64(b&0x7f)
+		if b&0x80 == 0 {
+			return offset, i + 1
+		}
+		if i >= 8 {
+			break
+		}
+	}
+
+	return 0, 0
+}
+
+// readSize decodes a size at the start of a delta.
+func readSize(delta []byte) (int, []byte, bool) {
+	size, shift := 0, 0
+	for i, b := range delta {
+		size |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, delta[i+1:], true
+		}
+		if shift > 56 {
+			break
+		}
+	}
+
+	return 0, nil, false
+}
+
+// applyDelta reconstructs an object from its base and a git delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, ok := readSize(delta)
+	if !ok || baseSize != len(base) {
+		return nil, errors.New("delta does not match its base")
+	}
+
+	size, delta, ok := readSize(delta)
+	if !ok {
+		return nil, errors.New("malformed delta size")
+	}
+
+	out := make([]byte, 0, min(size, 64<<20))
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			var args [7]int
+			for i := range args {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errors.New("truncated delta copy instruction")
+				}
+				args[i] = int(delta[0])
+				delta = delta[1:]
+			}
+
+			offset := args[0] | args[1]<<8 | args[2]<<16 | args[3]<<24
+			n := args[4] | args[5]<<8 | args[6]<<16
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > len(base) {
+				return nil, errors.New("delta copies beyond its base")
+			}
+			out = append(out, base[offset:offset+n]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta insert instruction")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("reserved delta instruction")
+		}
+
+		if len(out) > size {
+			return nil, errors.New("delta result exceeds its size")
+		}
+	}
+
+	if len(out) != size {
+		return nil, errors.New("delta result does not match its size")
+	}
+
+	return out, nil
+}
+
+// packIndex is a version 2 pack index.
+type packIndex struct {
+	pack    string
+	count   int
+	fanout  [256]uint32
+	names   []byte
+	offsets []byte
+	large   []byte
+}
+
+var packIndexMagic = []byte{0xff, 't', 'O', 'c'}
+
+// loadPackIndexes reads the indexes of the packs in a directory.
+func loadPackIndexes(dir string, format Format) ([]*packIndex, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "pack-*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	h, err := format.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	packs := make([]*packIndex, 0, len(matches))
+	for _, idx := range matches {
+		p, err := readPackIndex(idx, h.Size())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptObject, idx, err)
+		}
+		packs = append(packs, p)
+	}
+
+	return packs, nil
+}
+
+func readPackIndex(idx string, hashSize int) (*packIndex, error) {
+	data, err := os.ReadFile(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], packIndexMagic) || binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return nil, errors.New("unsupported pack index version")
+	}
+
+	p := &packIndex{pack: strings.TrimSuffix(idx, ".idx") + ".pack"}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+		if i > 0 && p.fanout[i] < p.fanout[i-1] {
+			return nil, errors.New("malformed fanout table")
+		}
+	}
+	p.count = int(p.fanout[255])
+
+	rest := data[8+256*4:]
+	// names, CRC32s and offsets
+	if len(rest) < p.count*(hashSize+4+4) {
+		return nil, errors.New("truncated pack index")
+	}
+	p.names = rest[:p.count*hashSize]
+	rest = rest[p.count*(hashSize+4):]
+	p.offsets = rest[:p.count*4]
+	p.large = rest[p.count*4:]
+
+	return p, nil
+}
+
+// find returns the offset of an object in the pack.
+func (p *packIndex) find(raw []byte) (int64, bool) {
+	hashSize := len(raw)
+	lo := 0
+	if raw[0] > 0 {
+		lo = int(p.fanout[raw[0]-1])
+	}
+	hi := int(p.fanout[raw[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.names[(lo+i)*hashSize:(lo+i+1)*hashSize], raw) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.names[i*hashSize:(i+1)*hashSize], raw) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+
+	// offsets of 2 GiB and more are stored in the large offset table
+	j := int(offset &^ 0x80000000)
+	if len(p.large) < (j+1)*8 {
+		return 0, false
+	}
+
+	return int64(binary.BigEndian.Uint64(p.large[j*8:])), true
+}
+
+// Commit reads and parses the named commit.
+func (s *Store) Commit(name string) (*Commit, error) {
+	objType, content, err := s.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if objType != TypeCommit {
+		return nil, fmt.Errorf("%w: %s is a %s, not a commit", ErrUnexpectedType, name, objType)
+	}
+
+	return ParseCommit(content)
+}
+
+// Tag reads and parses the named annotated tag.
+func (s *Store) Tag(name string) (*Tag, error) {
+	objType, content, err := s.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if objType != TypeTag {
+		return nil, fmt.Errorf("%w: %s is a %s, not a tag", ErrUnexpectedType, name, objType)
+	}
+
+	return ParseTag(content)
+}
+
+// ResolveRef returns the name of the object a ref points to, following
+// symbolic refs such as HEAD. Like git, it looks ref up as given, then
+// under refs/, refs/tags/, refs/heads/ and refs/remotes/.
+func (s *Store) ResolveRef(ref string) (string, error) {
+	for _, candidate := range []string{ref, "refs/" + ref, "refs/tags/" + ref, "refs/heads/" + ref, "refs/remotes/" + ref, "refs/remotes/" + ref + "/HEAD"} {
+		name, err := s.resolve(candidate, 0)
+		if errors.Is(err, ErrRefNotFound) {
+			continue
+		}
+		return name, err
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+}
+
+func (s *Store) resolve(ref string, depth int) (string, error) {
+	if depth > maxSymrefDepth {
+		return "", fmt.Errorf("%w: too many levels of symbolic refs at %s", ErrRefNotFound, ref)
+	}
+
+	value, err := s.readRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if target, ok := strings.CutPrefix(value, "ref: "); ok {
+		return s.resolve(target, depth+1)
+	}
+
+	if _, err := s.rawName(value); err != nil {
+		return "", fmt.Errorf("%w: %s holds %q", ErrInvalidObjectName, ref, value)
+	}
+
+	return value, nil
+}
+
+// readRef reads a loose ref, or else a packed ref.
+func (s *Store) readRef(ref string) (string, error) {
+	if !isValidRefPath(ref) {
+		return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+	}
+
+	// HEAD and other pseudorefs are private to each worktree
+	dir := s.commonDir
+	if !strings.HasPrefix(ref, "refs/") {
+		dir = s.gitDir
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !errors.Is(err, os.ErrNotExist) && !isDirError(err) {
+		return "", err
+	}
+
+	f, err := os.Open(filepath.Join(s.commonDir, "packed-refs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+
+		name, packedRef, ok := strings.Cut(line, " ")
+		if ok && packedRef == ref {
+			return name, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+}
+
+// isValidRefPath rejects refs that would escape the git directory.
+func isValidRefPath(ref string) bool {
+	if ref == "" || strings.HasPrefix(ref, "/") || strings.Contains(ref, "\\") {
+		return false
+	}
+
+	for _, part := range strings.Split(ref, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isDirError indicates if reading a file failed because it is a directory,
+// e.g. refs/heads when resolving "heads".
+func isDirError(err error) bool {
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		return false
+	}
+
+	info, statErr := os.Stat(pathErr.Path)
+	return statErr == nil && info.IsDir()
+}
+
+// ResolveCommit resolves a ref, as ResolveRef does, and peels annotated
+// tags until it reaches a commit, whose name it returns. The name is a
+// gitCommit digest of the commit the ref points to.
+func (s *Store) ResolveCommit(ref string) (string, error) {
+	name, err := s.ResolveRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		objType, content, err := s.Read(name)
+		if err != nil {
+			return "", err
+		}
+
+		switch objType {
+		case TypeCommit:
+			return name, nil
+		case TypeTag:
+			tag, err := ParseTag(content)
+			if err != nil {
+				return "", err
+			}
+			name = tag.Object
+		default:
+			return "", fmt.Errorf("%w: %s points to a %s", ErrUnexpectedType, ref, objType)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s: too many levels of tags", ErrUnexpectedType, ref)
+}
@@ -0,0 +1,340 @@
+/*
+Tests for reading objects and refs from local repositories.
+*/
+
+package gitobject
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readTestListing reads the fields of a listing generated by git.
+func readTestListing(t *testing.T, name string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := [][]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			t.Fatalf("malformed listing line %q", scanner.Text())
+		}
+		lines = append(lines, fields)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return lines
+}
+
+func openTestStore(t *testing.T, f Format) *Store {
+	t.Helper()
+
+	s, err := OpenStore(filepath.Join("testdata", fmt.Sprintf("repo.%s.git", f)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestStoreRead(t *testing.T) {
+	for _, f := range testFormats {
+		s := openTestStore(t, f)
+		assert.Equal(t, f, s.Format())
+
+		objects := readTestListing(t, fmt.Sprintf("objects.%s.txt", f))
+		assert.NotEmpty(t, objects)
+
+		for _, o := range objects {
+			name, objType := o[0], o[1]
+			size, err := strconv.Atoi(o[2])
+			assert.NoError(t, err)
+
+			gotType, content, err := s.Read(name)
+			assert.NoError(t, err, fmt.Sprintf("unexpected error for %s in format %s", name, f))
+			assert.Equal(t, objType, gotType, fmt.Sprintf("unexpected type for %s in format %s", name, f))
+			assert.Len(t, content, size, fmt.Sprintf("unexpected size for %s in format %s", name, f))
+
+			switch objType {
+			case TypeCommit:
+				c, err := s.Commit(name)
+				assert.NoError(t, err, fmt.Sprintf("unexpected error for commit %s in format %s", name, f))
+				assert.Equal(t, "A U Thor", c.Author.Name)
+			case TypeTag:
+				tag, err := s.Tag(name)
+				assert.NoError(t, err, fmt.Sprintf("unexpected error for tag %s in format %s", name, f))
+				assert.Equal(t, "v1", tag.Name)
+			}
+		}
+	}
+}
+
+func TestStoreResolve(t *testing.T) {
+	for _, f := range testFormats {
+		s := openTestStore(t, f)
+
+		for _, ref := range readTestListing(t, fmt.Sprintf("refs.%s.txt", f)) {
+			name, err := s.ResolveRef(ref[0])
+			assert.NoError(t, err, fmt.Sprintf("unexpected error for %s in format %s", ref[0], f))
+			assert.Equal(t, ref[1], name, fmt.Sprintf("unexpected name for %s in format %s", ref[0], f))
+
+			commit, err := s.ResolveCommit(ref[0])
+			assert.NoError(t, err, fmt.Sprintf("unexpected error for %s in format %s", ref[0], f))
+			assert.Equal(t, ref[2], commit, fmt.Sprintf("unexpected commit for %s in format %s", ref[0], f))
+		}
+	}
+}
+
+func TestStoreSignedCommit(t *testing.T) {
+	for _, f := range testFormats {
+		s := openTestStore(t, f)
+
+		main, err := s.ResolveRef("main")
+		assert.NoError(t, err)
+		mainCommit, err := s.Commit(main)
+		assert.NoError(t, err)
+
+		// the signed commit is loose, and its tree is packed
+		signed, err := s.ResolveRef("signed")
+		assert.NoError(t, err)
+		c, err := s.Commit(signed)
+		assert.NoError(t, err)
+
+		assert.Equal(t, mainCommit.Tree, c.Tree)
+		assert.Equal(t, []string{main}, c.Parents)
+		assert.Equal(t, "C O Mitter", c.Committer.Name)
+		assert.Equal(t, int64(1700000200), c.Committer.When.Unix())
+		_, offset := c.Committer.When.Zone()
+		assert.Equal(t, 5*3600+1800, offset)
+		assert.Equal(t, []Header{{Key: "gpgsig", Value: "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n\n-----END SSH SIGNATURE-----"}}, c.Headers)
+		assert.Equal(t, "Signed change\n\nWith a body.\n", c.Message)
+
+		tree, content, err := s.Read(c.Tree)
+		assert.NoError(t, err)
+		assert.Equal(t, TypeTree, tree)
+		assert.True(t, bytes.HasPrefix(content, []byte(ModeFile+" numbers.txt\x00")))
+	}
+}
+
+func TestOpenStoreWorktree(t *testing.T) {
+	repo, err := filepath.Abs(filepath.Join("testdata", "repo.sha1.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := readTestListing(t, "refs.sha1.txt")
+
+	// a working tree whose .git file points to the repository
+	work := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(work, ".git"), []byte("gitdir: "+repo+"\n"), 0o644))
+
+	s, err := OpenStore(work)
+	assert.NoError(t, err)
+	name, err := s.ResolveRef("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, refs[0][1], name)
+
+	// a linked worktree, with its own HEAD and a relative commondir
+	gitDir := filepath.Join(t.TempDir(), "worktrees", "signed")
+	assert.NoError(t, os.MkdirAll(gitDir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/signed\n"), 0o644))
+	rel, err := filepath.Rel(gitDir, repo)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(gitDir, "commondir"), []byte(rel+"\n"), 0o644))
+	work = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(work, ".git"), []byte("gitdir: "+gitDir+"\n"), 0o644))
+
+	s, err = OpenStore(work)
+	assert.NoError(t, err)
+	name, err = s.ResolveCommit("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, refs[2][1], name)
+}
+
+func TestOpenStoreErrors(t *testing.T) {
+	_, err := OpenStore(t.TempDir())
+	assert.ErrorIs(t, err, ErrNotRepository)
+
+	work := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(work, ".git"), []byte("not a gitdir line\n"), 0o644))
+	_, err = OpenStore(work)
+	assert.ErrorIs(t, err, ErrNotRepository)
+
+	repo := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "config"), []byte("[extensions]\n\tobjectFormat = md5\n"), 0o644))
+	_, err = OpenStore(repo)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestStoreErrors(t *testing.T) {
+	s := openTestStore(t, FormatSHA1)
+	objects := readTestListing(t, "objects.sha1.txt")
+
+	var blob, tree string
+	for _, o := range objects {
+		switch o[1] {
+		case TypeBlob:
+			blob = o[0]
+		case TypeTree:
+			tree = o[0]
+		}
+	}
+
+	tests := map[string]struct {
+		read        func() error
+		expectedErr error
+	}{
+		"sha256 name": {
+			read: func() error {
+				_, _, err := s.Read("6ef19b41225c5369f1c104d45d8d85efa9b057b53b14b4b9b939dd74decc5321")
+				return err
+			},
+			expectedErr: ErrInvalidObjectName,
+		},
+		"uppercase name": {
+			read: func() error {
+				_, _, err := s.Read(strings.ToUpper(blob))
+				return err
+			},
+			expectedErr: ErrInvalidObjectName,
+		},
+		"missing object": {
+			read: func() error {
+				_, _, err := s.Read("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+				return err
+			},
+			expectedErr: ErrObjectNotFound,
+		},
+		"blob as commit": {
+			read: func() error {
+				_, err := s.Commit(blob)
+				return err
+			},
+			expectedErr: ErrUnexpectedType,
+		},
+		"tree as tag": {
+			read: func() error {
+				_, err := s.Tag(tree)
+				return err
+			},
+			expectedErr: ErrUnexpectedType,
+		},
+		"missing ref": {
+			read: func() error {
+				_, err := s.ResolveRef("missing")
+				return err
+			},
+			expectedErr: ErrRefNotFound,
+		},
+		"ref outside the repository": {
+			read: func() error {
+				_, err := s.ResolveRef("../repo.sha1.git/HEAD")
+				return err
+			},
+			expectedErr: ErrRefNotFound,
+		},
+		"ref that is not an object name": {
+			read: func() error {
+				_, err := s.ResolveRef("config")
+				return err
+			},
+			expectedErr: ErrInvalidObjectName,
+		},
+	}
+
+	for name, test := range tests {
+		assert.ErrorIs(t, test.read(), test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestStoreCorruptObject(t *testing.T) {
+	repo := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "HEAD"), []byte("ref: refs/heads/main\n"), 0o644))
+
+	// the empty tree, stored under the name of the blob "Hello"
+	name := "5ab2f8a4323abafb10abb68657d9d39f1a775057"
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err := zw.Write([]byte("tree 0\x00"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "objects", name[:2]), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "objects", name[:2], name[2:]), buf.Bytes(), 0o444))
+
+	s, err := OpenStore(repo)
+	assert.NoError(t, err)
+	_, _, err = s.Read(name)
+	assert.ErrorIs(t, err, ErrCorruptObject)
+
+	// a truncated object
+	buf.Reset()
+	zw = zlib.NewWriter(&buf)
+	_, err = zw.Write([]byte("blob 6\x00Hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	assert.NoError(t, os.Chmod(filepath.Join(repo, "objects", name[:2], name[2:]), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(repo, "objects", name[:2], name[2:]), buf.Bytes(), 0o444))
+
+	_, _, err = s.Read(name)
+	assert.ErrorIs(t, err, ErrCorruptObject)
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("Hello, world")
+
+	tests := map[string]struct {
+		delta    []byte
+		expected string
+	}{
+		"copy and insert": {
+			// copy 5 bytes at offset 0, insert ", git"
+			delta:    []byte{12, 10, 0x91, 0, 5, 5, ',', ' ', 'g', 'i', 't'},
+			expected: "Hello, git",
+		},
+		"copy with offset": {
+			// copy 5 bytes at offset 7
+			delta:    []byte{12, 5, 0x91, 7, 5},
+			expected: "world",
+		},
+	}
+
+	for name, test := range tests {
+		out, err := applyDelta(base, test.delta)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		assert.Equal(t, test.expected, string(out), fmt.Sprintf("unexpected result in test '%s'", name))
+	}
+
+	invalid := map[string][]byte{
+		"empty":              {},
+		"wrong base size":    {11, 5, 0x91, 0, 5},
+		"copy beyond base":   {12, 5, 0x91, 10, 5},
+		"truncated copy":     {12, 5, 0x91, 0},
+		"truncated insert":   {12, 5, 5, 'H'},
+		"reserved":           {12, 0, 0},
+		"short result":       {12, 6, 0x91, 0, 5},
+		"result beyond size": {12, 4, 0x91, 0, 5},
+	}
+
+	for name, delta := range invalid {
+		_, err := applyDelta(base, delta)
+		assert.Error(t, err, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Package cjson implements the OLPC canonical JSON encoding used by in-toto
+and TUF metadata for KEYIDs and signatures.
+
+See http://wiki.laptop.org/go/Canonical_JSON.
+*/
+
+package cjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedValue = errors.New("value cannot be canonically encoded")
+
+// Encode returns the canonical JSON encoding of v.
+//
+// v is first marshalled with encoding/json, so struct tags are honored. The
+// result must not contain floating point numbers, which canonical JSON does
+// not allow.
+func Encode(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, generic); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err != nil {
+			return fmt.Errorf("%w: non-integer number %s", ErrUnsupportedValue, v)
+		}
+		buf.WriteString(v.String())
+	case string:
+		encodeString(buf, v)
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeString(buf, k)
+			buf.WriteByte(':')
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedValue, reflect.TypeOf(v))
+	}
+
+	return nil
+}
+
+// encodeString writes s as a canonical JSON string: only the quote and
+// backslash characters are escaped, everything else is written verbatim.
+func encodeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s))
+	buf.WriteByte('"')
+}
@@ -0,0 +1,46 @@
+/*
+Tests for canonical JSON encoding.
+*/
+
+package cjson
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	tests := map[string]struct {
+		input any
+		want  string
+	}{
+		"sorted keys": {
+			input: map[string]any{"b": 1, "a": []any{true, nil, "x"}, "c": map[string]any{"z": "", "y": -2}},
+			want:  `{"a":[true,null,"x"],"b":1,"c":{"y":-2,"z":""}}`,
+		},
+		"struct tags": {
+			input: struct {
+				Name  string `json:"name"`
+				Empty string `json:"empty,omitempty"`
+			}{Name: "theName"},
+			want: `{"name":"theName"}`,
+		},
+		"minimal string escaping": {
+			input: "quote\" backslash\\ newline\n tab\t <html> é",
+			want:  "\"quote\\\" backslash\\\\ newline\n tab\t <html> é\"",
+		},
+	}
+
+	for name, test := range tests {
+		got, err := Encode(test.input)
+		assert.NoError(t, err, fmt.Sprintf("error encoding in test '%s'", name))
+		assert.Equal(t, test.want, string(got), fmt.Sprintf("wrong encoding in test '%s'", name))
+	}
+}
+
+func TestEncodeFloat(t *testing.T) {
+	_, err := Encode(map[string]any{"f": 1.5})
+	assert.ErrorIs(t, err, ErrUnsupportedValue)
+}
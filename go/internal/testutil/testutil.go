@@ -0,0 +1,50 @@
+/*
+Package testutil provides fixtures shared by the tests of several packages.
+*/
+
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+// NewSigner returns a SignerVerifier for a new ed25519 key.
+func NewSigner(t testing.TB) signature.SignerVerifier {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sv, err := signature.NewSignerVerifier(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sv
+}
+
+// NewECDSASigner returns a SignerVerifier for a new ECDSA P-256 key, whose
+// signatures are randomized.
+func NewECDSASigner(t testing.TB) signature.SignerVerifier {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sv, err := signature.NewSignerVerifier(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sv
+}
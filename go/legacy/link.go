@@ -0,0 +1,161 @@
+/*
+Conversion of legacy in-toto link metadata to Statements with a Link
+predicate, as described in spec/predicates/link.md.
+*/
+
+package legacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TypeLink is the _type of legacy link metadata.
+const TypeLink = "link"
+
+var ErrProductsRequired = errors.New("link must record at least one product to become a Statement subject")
+
+// Link is the signed metadata of a legacy link file.
+type Link struct {
+	Type        string                       `json:"_type"`
+	Name        string                       `json:"name"`
+	Command     []string                     `json:"command"`
+	Materials   map[string]map[string]string `json:"materials"`
+	Products    map[string]map[string]string `json:"products"`
+	Byproducts  map[string]any               `json:"byproducts"`
+	Environment map[string]any               `json:"environment"`
+}
+
+// ReadLinkResult is the outcome of reading a legacy link file.
+type ReadLinkResult struct {
+	// Statement is the link converted to a Statement with a Link
+	// predicate.
+	Statement *ita1.Statement
+	// KeyIDs are the keyids of the attesters whose signatures were
+	// verified.
+	KeyIDs []string
+}
+
+// Link decodes the metablock's signed metadata as a link.
+func (m *Metablock) Link() (*Link, error) {
+	l := &Link{}
+	if err := json.Unmarshal(m.Signed, l); err != nil {
+		return nil, err
+	}
+
+	if l.Type != TypeLink {
+		return nil, fmt.Errorf("%w: got %q, want %q", ErrUnexpectedType, l.Type, TypeLink)
+	}
+
+	return l, nil
+}
+
+// ReadLink parses a legacy link file, verifies its signatures and converts
+// it to a Statement.
+func ReadLink(ctx context.Context, data []byte, verifiers ...signature.Verifier) (*ReadLinkResult, error) {
+	m, err := ParseMetablock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keyIDs, err := m.Verify(ctx, verifiers...)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := m.Link()
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := l.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadLinkResult{Statement: statement, KeyIDs: keyIDs}, nil
+}
+
+// Predicate returns the link as a Link v0.3 predicate. Materials are sorted
+// by name.
+func (l *Link) Predicate() (*linkv0.Link, error) {
+	byproducts, err := structpb.NewStruct(l.Byproducts)
+	if err != nil {
+		return nil, fmt.Errorf("byproducts: %w", err)
+	}
+
+	environment, err := structpb.NewStruct(l.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("environment: %w", err)
+	}
+
+	return &linkv0.Link{
+		Name:        l.Name,
+		Command:     l.Command,
+		Materials:   resourceDescriptors(l.Materials),
+		Byproducts:  byproducts,
+		Environment: environment,
+	}, nil
+}
+
+// Statement converts the link to a Statement: its products become the
+// subject, sorted by name, and the rest of the link becomes a Link v0.3
+// predicate.
+func (l *Link) Statement() (*ita1.Statement, error) {
+	if len(l.Products) == 0 {
+		return nil, ErrProductsRequired
+	}
+
+	pred, err := l.Predicate()
+	if err != nil {
+		return nil, err
+	}
+
+	predJSON, err := protojson.Marshal(pred)
+	if err != nil {
+		return nil, err
+	}
+
+	predStruct := &structpb.Struct{}
+	if err := protojson.Unmarshal(predJSON, predStruct); err != nil {
+		return nil, err
+	}
+
+	s := &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       resourceDescriptors(l.Products),
+		PredicateType: linkv0.PredicateTypeUri + linkv0.PredicateVersion,
+		Predicate:     predStruct,
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// resourceDescriptors converts a legacy artifact map, from names to
+// digest sets, to ResourceDescriptors sorted by name.
+func resourceDescriptors(artifacts map[string]map[string]string) []*ita1.ResourceDescriptor {
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rds := make([]*ita1.ResourceDescriptor, 0, len(names))
+	for _, name := range names {
+		rds = append(rds, &ita1.ResourceDescriptor{Name: name, Digest: artifacts[name]})
+	}
+
+	return rds
+}
@@ -0,0 +1,101 @@
+/*
+Tests for converting legacy in-toto links to Statements.
+*/
+
+package legacy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/testutil"
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadLink(t *testing.T) {
+	result, err := ReadLink(context.Background(), readTestData(t, "package.d3ffd108.link"), loadTestVerifier(t, "bob.pub"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{bobKeyID}, result.KeyIDs)
+
+	s := result.Statement
+	assert.Equal(t, ita1.StatementTypeUri, s.GetType())
+	assert.Equal(t, "https://in-toto.io/attestation/link/v0.3", s.GetPredicateType())
+	assert.True(t, proto.Equal(&ita1.ResourceDescriptor{
+		Name:   "foo.tar.gz",
+		Digest: map[string]string{"sha256": "52947cb78b91ad01fe81cd6aef42d1f6817e92b9e6936c1e5aabb7c98514f355"},
+	}, s.GetSubject()[0]))
+	assert.Len(t, s.GetSubject(), 1)
+
+	predJSON, err := protojson.Marshal(s.GetPredicate())
+	assert.NoError(t, err)
+	pred := &linkv0.Link{}
+	assert.NoError(t, protojson.Unmarshal(predJSON, pred))
+
+	assert.Equal(t, "package", pred.GetName())
+	assert.Equal(t, []string{"tar", "zcvf", "foo.tar.gz", "foo.py"}, pred.GetCommand())
+	assert.Len(t, pred.GetMaterials(), 1)
+	assert.Equal(t, "foo.py", pred.GetMaterials()[0].GetName())
+	assert.Equal(t, "74dc3727c6e89308b39e4dfedf787e37841198b1fa165a27c013544a60502549", pred.GetMaterials()[0].GetDigest()["sha256"])
+	assert.Equal(t, "a foo.py\n", pred.GetByproducts().GetFields()["stderr"].GetStringValue())
+	assert.Equal(t, float64(0), pred.GetByproducts().GetFields()["return-value"].GetNumberValue())
+	assert.Empty(t, pred.GetEnvironment().GetFields())
+}
+
+func TestReadLinkErrors(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+
+	tests := map[string]struct {
+		input       []byte
+		expectedErr error
+	}{
+		"wrong key": {
+			input:       readTestData(t, "write-code.b7d643de.link"),
+			expectedErr: ErrNoValidSignature,
+		},
+		"not a link": {
+			input:       signTestMetablock(t, map[string]any{"_type": "layout", "steps": []any{}}, alice),
+			expectedErr: ErrUnexpectedType,
+		},
+		"no products": {
+			input:       signTestMetablock(t, map[string]any{"_type": "link", "name": "test", "products": map[string]any{}}, alice),
+			expectedErr: ErrProductsRequired,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := ReadLink(ctx, test.input, alice)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+func TestLinkStatementSortsArtifacts(t *testing.T) {
+	l := &Link{
+		Type: TypeLink,
+		Name: "build",
+		Materials: map[string]map[string]string{
+			"src/b.go": {"sha256": strings.Repeat("b", 64)},
+			"src/a.go": {"sha256": strings.Repeat("a", 64)},
+		},
+		Products: map[string]map[string]string{
+			"out/z": {"sha256": strings.Repeat("f", 64)},
+			"out/y": {"sha256": strings.Repeat("e", 64)},
+		},
+	}
+
+	s, err := l.Statement()
+	assert.NoError(t, err)
+	assert.Equal(t, "out/y", s.GetSubject()[0].GetName())
+	assert.Equal(t, "out/z", s.GetSubject()[1].GetName())
+
+	pred, err := l.Predicate()
+	assert.NoError(t, err)
+	assert.Equal(t, "src/a.go", pred.GetMaterials()[0].GetName())
+	assert.Equal(t, "src/b.go", pred.GetMaterials()[1].GetName())
+}
@@ -0,0 +1,166 @@
+/*
+Reader for the metadata format of in-toto v0.9 and earlier, in which a
+"signed" object is signed over its canonical JSON encoding.
+*/
+
+package legacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/in-toto/attestation/go/internal/cjson"
+	"github.com/in-toto/attestation/go/signature"
+)
+
+var (
+	ErrSignedRequired     = errors.New("signed metadata required")
+	ErrSignatureRequired  = errors.New("at least one signature required")
+	ErrKeyIDRequired      = errors.New("signature keyid required")
+	ErrInvalidSignature   = errors.New("signature must be hex-encoded")
+	ErrVerifierRequired   = errors.New("at least one verifier required")
+	ErrNoValidSignature   = errors.New("no signature matches a recognized key")
+	ErrUnexpectedType     = errors.New("unexpected metadata _type")
+	ErrTrailingData       = errors.New("unexpected data after metadata")
+	ErrDuplicateSignature = errors.New("duplicate signature keyid")
+)
+
+// Metablock is a legacy in-toto metadata file:
+//
+//	{"signed": {"_type": ..., ...}, "signatures": [{"keyid": ..., "sig": ...}]}
+type Metablock struct {
+	// Signed is the signed metadata as it appears in the file.
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []Signature     `json:"signatures"`
+}
+
+// Signature is a signature over the canonical JSON encoding of a
+// Metablock's signed metadata.
+type Signature struct {
+	// KeyID is the in-toto KEYID of the signing key.
+	KeyID string `json:"keyid"`
+	// Sig is the hex-encoded signature.
+	Sig string `json:"sig"`
+}
+
+// ParseMetablock parses and validates a legacy metadata file.
+func ParseMetablock(data []byte) (*Metablock, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	m := &Metablock{}
+	if err := dec.Decode(m); err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, ErrTrailingData
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Validate checks that the metablock has signed metadata and well-formed
+// signatures.
+func (m *Metablock) Validate() error {
+	if len(m.Signed) == 0 || bytes.Equal(m.Signed, []byte("null")) {
+		return ErrSignedRequired
+	}
+
+	if len(m.Signatures) == 0 {
+		return ErrSignatureRequired
+	}
+
+	seen := make(map[string]bool, len(m.Signatures))
+	for i, s := range m.Signatures {
+		if s.KeyID == "" {
+			return fmt.Errorf("signatures[%d]: %w", i, ErrKeyIDRequired)
+		}
+
+		if seen[s.KeyID] {
+			return fmt.Errorf("signatures[%d]: %w: %s", i, ErrDuplicateSignature, s.KeyID)
+		}
+		seen[s.KeyID] = true
+
+		if _, err := hex.DecodeString(s.Sig); err != nil || s.Sig == "" {
+			return fmt.Errorf("signatures[%d]: %w", i, ErrInvalidSignature)
+		}
+	}
+
+	return nil
+}
+
+// Type returns the _type of the signed metadata, e.g. "link" or "layout".
+func (m *Metablock) Type() (string, error) {
+	var header struct {
+		Type string `json:"_type"`
+	}
+	if err := json.Unmarshal(m.Signed, &header); err != nil {
+		return "", err
+	}
+
+	return header.Type, nil
+}
+
+// Verify checks the metablock's signatures against the given verifiers and
+// returns the keyids of the verifiers that produced at least one of them,
+// in the order the verifiers were given. As in in-toto v0.9, a signature
+// is only checked with the verifier whose KEYID matches its keyid. It
+// fails with ErrNoValidSignature if no signature is valid.
+func (m *Metablock) Verify(ctx context.Context, verifiers ...signature.Verifier) ([]string, error) {
+	if len(verifiers) == 0 {
+		return nil, ErrVerifierRequired
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	canonical, err := cjson.Encode(m.Signed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize signed metadata: %w", err)
+	}
+
+	keyIDs := make([]string, 0, len(verifiers))
+	seen := make(map[string]bool, len(verifiers))
+	for i, v := range verifiers {
+		keyID, err := v.KeyID()
+		if err != nil {
+			return nil, fmt.Errorf("verifiers[%d]: failed to get keyid: %w", i, err)
+		}
+
+		if seen[keyID] {
+			continue
+		}
+
+		for _, s := range m.Signatures {
+			if s.KeyID != keyID {
+				continue
+			}
+
+			// Validate already checked the encoding
+			sig, _ := hex.DecodeString(s.Sig)
+			if err := v.Verify(ctx, canonical, sig); err == nil {
+				keyIDs = append(keyIDs, keyID)
+				seen[keyID] = true
+				break
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		}
+	}
+
+	if len(keyIDs) == 0 {
+		return nil, ErrNoValidSignature
+	}
+
+	return keyIDs, nil
+}
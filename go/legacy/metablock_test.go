@@ -0,0 +1,246 @@
+/*
+Tests for legacy in-toto metadata parsing and signature verification.
+*/
+
+package legacy
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/in-toto/attestation/go/internal/cjson"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	danKeyID = "b7d643dec0a051096ee5d87221b5d91a33daa658699d30903e1cefb90c418401"
+	bobKeyID = "d3ffd1086938b3698618adf088bf14b13db4c8ae19e4e78d73da49ee88492710"
+)
+
+func readTestData(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func loadTestVerifier(t *testing.T, name string) signature.Verifier {
+	t.Helper()
+
+	v, err := signature.LoadVerifierPEM(readTestData(t, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+// signTestMetablock signs the given metadata the way in-toto v0.9 did.
+func signTestMetablock(t *testing.T, signed any, signers ...signature.Signer) []byte {
+	t.Helper()
+
+	canonical, err := cjson.Encode(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := map[string]any{"signed": signed, "signatures": []Signature{}}
+	for _, s := range signers {
+		keyID, err := s.KeyID()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := s.Sign(context.Background(), canonical)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m["signatures"] = append(m["signatures"].([]Signature), Signature{KeyID: keyID, Sig: hex.EncodeToString(sig)})
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestParseMetablock(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		expectedErr error
+	}{
+		"valid": {
+			input: `{"signed": {"_type": "link"}, "signatures": [{"keyid": "abc", "sig": "00ff"}]}`,
+		},
+		"missing signed": {
+			input:       `{"signatures": [{"keyid": "abc", "sig": "00ff"}]}`,
+			expectedErr: ErrSignedRequired,
+		},
+		"null signed": {
+			input:       `{"signed": null, "signatures": [{"keyid": "abc", "sig": "00ff"}]}`,
+			expectedErr: ErrSignedRequired,
+		},
+		"no signatures": {
+			input:       `{"signed": {"_type": "link"}, "signatures": []}`,
+			expectedErr: ErrSignatureRequired,
+		},
+		"missing keyid": {
+			input:       `{"signed": {"_type": "link"}, "signatures": [{"sig": "00ff"}]}`,
+			expectedErr: ErrKeyIDRequired,
+		},
+		"duplicate keyid": {
+			input:       `{"signed": {"_type": "link"}, "signatures": [{"keyid": "abc", "sig": "00ff"}, {"keyid": "abc", "sig": "ff00"}]}`,
+			expectedErr: ErrDuplicateSignature,
+		},
+		"non-hex signature": {
+			input:       `{"signed": {"_type": "link"}, "signatures": [{"keyid": "abc", "sig": "AP8="}]}`,
+			expectedErr: ErrInvalidSignature,
+		},
+		"empty signature": {
+			input:       `{"signed": {"_type": "link"}, "signatures": [{"keyid": "abc", "sig": ""}]}`,
+			expectedErr: ErrInvalidSignature,
+		},
+		"trailing data": {
+			input:       `{"signed": {"_type": "link"}, "signatures": [{"keyid": "abc", "sig": "00ff"}]} {}`,
+			expectedErr: ErrTrailingData,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := ParseMetablock([]byte(test.input))
+		if test.expectedErr == nil {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+}
+
+func TestMetablockType(t *testing.T) {
+	m, err := ParseMetablock(readTestData(t, "write-code.b7d643de.link"))
+	assert.NoError(t, err)
+
+	typ, err := m.Type()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeLink, typ)
+}
+
+func TestVerifyTestData(t *testing.T) {
+	ctx := context.Background()
+	dan := loadTestVerifier(t, "dan.pub")
+	bob := loadTestVerifier(t, "bob.pub")
+
+	tests := map[string]struct {
+		file           string
+		verifiers      []signature.Verifier
+		expectedKeyIDs []string
+		expectedErr    error
+	}{
+		"write-code signed by dan": {
+			file:           "write-code.b7d643de.link",
+			verifiers:      []signature.Verifier{bob, dan},
+			expectedKeyIDs: []string{danKeyID},
+		},
+		"package signed by bob": {
+			file:           "package.d3ffd108.link",
+			verifiers:      []signature.Verifier{dan, bob},
+			expectedKeyIDs: []string{bobKeyID},
+		},
+		"wrong key": {
+			file:        "write-code.b7d643de.link",
+			verifiers:   []signature.Verifier{bob},
+			expectedErr: ErrNoValidSignature,
+		},
+		"no verifiers": {
+			file:        "write-code.b7d643de.link",
+			expectedErr: ErrVerifierRequired,
+		},
+	}
+
+	for name, test := range tests {
+		m, err := ParseMetablock(readTestData(t, test.file))
+		assert.NoError(t, err, fmt.Sprintf("unexpected parse error in test '%s'", name))
+
+		keyIDs, err := m.Verify(ctx, test.verifiers...)
+		if test.expectedErr == nil {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+			assert.Equal(t, test.expectedKeyIDs, keyIDs, fmt.Sprintf("wrong keyids in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+}
+
+func TestVerifyIgnoresFormatting(t *testing.T) {
+	// the signature covers the canonical encoding, not the file's layout
+	m, err := ParseMetablock(readTestData(t, "write-code.b7d643de.link"))
+	assert.NoError(t, err)
+
+	compact, err := json.Marshal(m)
+	assert.NoError(t, err)
+
+	m, err = ParseMetablock(compact)
+	assert.NoError(t, err)
+
+	_, err = m.Verify(context.Background(), loadTestVerifier(t, "dan.pub"))
+	assert.NoError(t, err)
+}
+
+func TestVerifyTampered(t *testing.T) {
+	m, err := ParseMetablock(readTestData(t, "write-code.b7d643de.link"))
+	assert.NoError(t, err)
+
+	m.Signed = json.RawMessage(`{"_type": "link", "name": "write-code", "materials": {}, "products": {}, "byproducts": {}, "command": [], "environment": {}}`)
+	_, err = m.Verify(context.Background(), loadTestVerifier(t, "dan.pub"))
+	assert.ErrorIs(t, err, ErrNoValidSignature)
+}
+
+func TestVerifyMultipleSigners(t *testing.T) {
+	ctx := context.Background()
+	alice := testutil.NewSigner(t)
+	carol := testutil.NewSigner(t)
+	mallory := testutil.NewSigner(t)
+
+	data := signTestMetablock(t, map[string]any{"_type": "link", "name": "build"}, alice, carol)
+	m, err := ParseMetablock(data)
+	assert.NoError(t, err)
+
+	aliceKeyID, err := alice.KeyID()
+	assert.NoError(t, err)
+	carolKeyID, err := carol.KeyID()
+	assert.NoError(t, err)
+
+	keyIDs, err := m.Verify(ctx, carol, mallory, alice, carol)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{carolKeyID, aliceKeyID}, keyIDs)
+}
+
+func TestVerifyRejectsMisattributedSignature(t *testing.T) {
+	// a valid signature under another signer's keyid is not accepted
+	alice := testutil.NewSigner(t)
+	carol := testutil.NewSigner(t)
+
+	m, err := ParseMetablock(signTestMetablock(t, map[string]any{"_type": "link"}, alice))
+	assert.NoError(t, err)
+
+	carolKeyID, err := carol.KeyID()
+	assert.NoError(t, err)
+	m.Signatures[0].KeyID = carolKeyID
+
+	_, err = m.Verify(context.Background(), alice)
+	assert.ErrorIs(t, err, ErrNoValidSignature)
+}
@@ -0,0 +1,539 @@
+/*
+Client for pushing attestations to and pulling them from OCI registries,
+with the referrers API of the OCI distribution spec.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/in-toto/attestation/go/dsse"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// DefaultMaxBlobSize is the default limit on the size of a manifest or
+// attestation read from a registry.
+const DefaultMaxBlobSize = 64 << 20
+
+// maxReferrersPages limits the pages of referrers a Client follows, so that
+// a misbehaving registry cannot paginate forever.
+const maxReferrersPages = 1000
+
+// Headers and query parameters of the distribution spec.
+const (
+	headerSubject     = "OCI-Subject"
+	paramArtifactType = "artifactType"
+)
+
+// repositoryPattern is the repository name grammar of the distribution
+// spec.
+var repositoryPattern = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// linkPattern extracts the target of a Link header with rel="next".
+var linkPattern = regexp.MustCompile(`^\s*<([^>]+)>\s*;\s*rel="?next"?`)
+
+var (
+	ErrInvalidRepository = errors.New("invalid repository name")
+	ErrUnexpectedStatus  = errors.New("unexpected registry response")
+	ErrBlobTooLarge      = errors.New("registry content exceeds the maximum blob size")
+)
+
+// Client pushes attestations to and pulls them from a repository of an
+// OCI registry. Authentication is left to the http.Client given with
+// WithHTTPClient.
+type Client struct {
+	registry    *url.URL
+	repository  string
+	httpClient  *http.Client
+	maxBlobSize int64
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the HTTP client used to reach the registry, which
+// defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithMaxBlobSize limits the size of the manifests and attestations read
+// from the registry.
+func WithMaxBlobSize(n int64) ClientOption {
+	return func(cl *Client) {
+		cl.maxBlobSize = n
+	}
+}
+
+// NewClient returns a Client for a repository of the registry at the given
+// base URL, e.g. https://registry.example.com.
+func NewClient(registry, repository string, opts ...ClientOption) (*Client, error) {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported registry URL scheme %q", u.Scheme)
+	}
+
+	if !repositoryPattern.MatchString(repository) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRepository, repository)
+	}
+
+	c := &Client{registry: u, repository: repository, httpClient: http.DefaultClient, maxBlobSize: DefaultMaxBlobSize}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+func (c *Client) endpoint(kind, reference string) *url.URL {
+	return c.registry.JoinPath("v2", c.repository, kind, reference)
+}
+
+// do sends a request and checks that the response has one of the
+// expected status codes. The caller must close the response body.
+func (c *Client) do(ctx context.Context, method string, u *url.URL, body []byte, header http.Header, expected ...int) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+
+	return resp, fmt.Errorf("%w: %s %s: %s", ErrUnexpectedStatus, method, u.Path, resp.Status)
+}
+
+// read returns a response body, bounded by the maximum blob size.
+func (c *Client) read(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBlobSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > c.maxBlobSize {
+		return nil, ErrBlobTooLarge
+	}
+
+	return data, nil
+}
+
+// Resolve returns the descriptor of the manifest with the given digest.
+func (c *Client) Resolve(ctx context.Context, d digest.Digest) (ocispec.Descriptor, error) {
+	if err := d.Validate(); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	header := http.Header{"Accept": {ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex}}
+	resp, err := c.do(ctx, http.MethodHead, c.endpoint("manifests", d.String()), nil, header, http.StatusOK)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s", ErrSubjectNotFound, d)
+	} else if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	resp.Body.Close()
+
+	desc := ocispec.Descriptor{MediaType: resp.Header.Get("Content-Type"), Digest: d, Size: resp.ContentLength}
+	if err := ValidateDescriptor(desc); err != nil || desc.Size <= 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s: incomplete manifest headers", ErrUnexpectedStatus, d)
+	}
+
+	return desc, nil
+}
+
+// fetch reads a manifest or blob and checks it against its descriptor.
+func (c *Client) fetch(ctx context.Context, kind string, d ocispec.Descriptor) ([]byte, error) {
+	if d.Size > c.maxBlobSize {
+		return nil, fmt.Errorf("%w: %s", ErrBlobTooLarge, d.Digest)
+	}
+
+	header := http.Header{"Accept": {d.MediaType}}
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint(kind, d.Digest.String()), nil, header, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.read(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifyContent(d, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// pushBlob uploads a blob, unless the registry already has it.
+func (c *Client) pushBlob(ctx context.Context, blob []byte) error {
+	d := digest.FromBytes(blob)
+	resp, err := c.do(ctx, http.MethodHead, c.endpoint("blobs", d.String()), nil, nil, http.StatusOK)
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	} else if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	resp, err = c.do(ctx, http.MethodPost, c.endpoint("blobs", "uploads/"), nil, nil, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("%w: upload location required", ErrUnexpectedStatus)
+	}
+	query := location.Query()
+	query.Set("digest", d.String())
+	location.RawQuery = query.Encode()
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.do(ctx, http.MethodPut, location, blob, header, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// pushManifest uploads a manifest under a digest or tag, and returns the
+// response headers.
+func (c *Client) pushManifest(ctx context.Context, reference, mediaType string, manifest []byte) (http.Header, error) {
+	header := http.Header{"Content-Type": {mediaType}}
+	resp, err := c.do(ctx, http.MethodPut, c.endpoint("manifests", reference), manifest, header, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return resp.Header, nil
+}
+
+// Push uploads an artifact. If the registry does not process the
+// artifact's subject, the artifact is also listed in the subject's
+// referrers tag, following the fallback of the distribution spec.
+func (c *Client) Push(ctx context.Context, a *Artifact) error {
+	for _, blob := range [][]byte{ocispec.DescriptorEmptyJSON.Data, a.Blob} {
+		if err := c.pushBlob(ctx, blob); err != nil {
+			return err
+		}
+	}
+
+	header, err := c.pushManifest(ctx, a.Descriptor.Digest.String(), a.Descriptor.MediaType, a.Manifest)
+	if err != nil {
+		return err
+	}
+
+	if header.Get(headerSubject) != "" {
+		return nil
+	}
+
+	var m ocispec.Manifest
+	if err := json.Unmarshal(a.Manifest, &m); err != nil {
+		return err
+	}
+
+	return c.addToReferrersTag(ctx, m.Subject.Digest, a.Descriptor)
+}
+
+// referrersTag returns the tag listing the referrers of a subject in
+// registries without the referrers API: <alg>-<ref>, truncated to the
+// lengths allowed by the distribution spec.
+func referrersTag(subject digest.Digest) string {
+	alg, ref := subject.Algorithm().String(), subject.Encoded()
+	if len(alg) > 32 {
+		alg = alg[:32]
+	}
+	if len(ref) > 64 {
+		ref = ref[:64]
+	}
+
+	return alg + "-" + ref
+}
+
+func (c *Client) addToReferrersTag(ctx context.Context, subject digest.Digest, referrer ocispec.Descriptor) error {
+	index, err := c.referrersTagIndex(ctx, subject)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range index.Manifests {
+		if d.Digest == referrer.Digest {
+			return nil
+		}
+	}
+	index.Manifests = append(index.Manifests, referrer)
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.pushManifest(ctx, referrersTag(subject), ocispec.MediaTypeImageIndex, data)
+	return err
+}
+
+// referrersTagIndex returns the index in the referrers tag of a subject,
+// or an empty index if there is none.
+func (c *Client) referrersTagIndex(ctx context.Context, subject digest.Digest) (*ocispec.Index, error) {
+	index := &ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{},
+	}
+
+	header := http.Header{"Accept": {ocispec.MediaTypeImageIndex}}
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint("manifests", referrersTag(subject)), nil, header, http.StatusOK)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, err := c.read(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("%w: invalid referrers index: %v", ErrUnexpectedStatus, err)
+	}
+
+	return index, nil
+}
+
+// PushEnvelope attaches a signed envelope to the subject manifest with the
+// given digest, and returns the descriptor of the referrer.
+func (c *Client) PushEnvelope(ctx context.Context, subject digest.Digest, env *dsse.Envelope) (ocispec.Descriptor, error) {
+	d, err := c.Resolve(ctx, subject)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	a, err := NewEnvelopeArtifact(d, env)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return a.Descriptor, c.Push(ctx, a)
+}
+
+// PushBundle attaches a Bundle to the subject manifest with the given
+// digest, and returns the descriptor of the referrer.
+func (c *Client) PushBundle(ctx context.Context, subject digest.Digest, data []byte) (ocispec.Descriptor, error) {
+	d, err := c.Resolve(ctx, subject)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	a, err := NewBundleArtifact(d, data)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return a.Descriptor, c.Push(ctx, a)
+}
+
+// Referrers returns the descriptors of the manifests that refer to the
+// subject manifest with the given digest. A non-empty artifactType
+// restricts them to artifacts of that type, whether or not the registry
+// applies the filter. Registries without the referrers API are queried
+// through the referrers tag. At most 1000 pages of referrers are followed.
+func (c *Client) Referrers(ctx context.Context, subject digest.Digest, artifactType string) ([]ocispec.Descriptor, error) {
+	if err := subject.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	u := c.endpoint("referrers", subject.String())
+	if artifactType != "" {
+		u.RawQuery = url.Values{paramArtifactType: {artifactType}}.Encode()
+	}
+
+	referrers := []ocispec.Descriptor{}
+	visited := map[string]bool{}
+	for u != nil {
+		if visited[u.String()] {
+			return nil, fmt.Errorf("%w: referrers pages loop back to %s", ErrUnexpectedStatus, u)
+		}
+		if len(visited) == maxReferrersPages {
+			return nil, fmt.Errorf("%w: more than %d pages of referrers", ErrUnexpectedStatus, maxReferrersPages)
+		}
+		visited[u.String()] = true
+
+		header := http.Header{"Accept": {ocispec.MediaTypeImageIndex}}
+		resp, err := c.do(ctx, http.MethodGet, u, nil, header, http.StatusOK)
+		if resp != nil && resp.StatusCode == http.StatusNotFound && len(referrers) == 0 {
+			index, err := c.referrersTagIndex(ctx, subject)
+			if err != nil {
+				return nil, err
+			}
+			return filterReferrers(index.Manifests, artifactType), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		next, err := nextPage(resp)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		data, err := c.read(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("%w: invalid referrers index: %v", ErrUnexpectedStatus, err)
+		}
+		referrers = append(referrers, filterReferrers(index.Manifests, artifactType)...)
+		u = next
+	}
+
+	return referrers, nil
+}
+
+// nextPage returns the URL of the next page of a paginated response, or
+// nil on the last page.
+func nextPage(resp *http.Response) (*url.URL, error) {
+	for _, link := range resp.Header.Values("Link") {
+		if m := linkPattern.FindStringSubmatch(link); m != nil {
+			return resp.Request.URL.Parse(m[1])
+		}
+	}
+
+	return nil, nil
+}
+
+func filterReferrers(descriptors []ocispec.Descriptor, artifactType string) []ocispec.Descriptor {
+	out := []ocispec.Descriptor{}
+	for _, d := range descriptors {
+		if artifactType == "" || d.ArtifactType == artifactType {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// Pull returns the attestations about the subject manifest with the given
+// digest. A non-empty artifactType restricts them to artifacts of that
+// type. Referrers that are not attestation artifacts, and envelopes whose
+// Statement has no subject with the subject's digest, are ignored. The
+// envelopes are not verified: callers MUST verify them before trusting
+// them.
+func (c *Client) Pull(ctx context.Context, subject digest.Digest, artifactType string) ([]*Attestation, error) {
+	referrers, err := c.Referrers(ctx, subject, artifactType)
+	if err != nil {
+		return nil, err
+	}
+
+	attestations := []*Attestation{}
+	for _, r := range referrers {
+		if !IsAttestationMediaType(r.ArtifactType) {
+			continue
+		}
+
+		data, err := c.fetch(ctx, "manifests", r)
+		if err != nil {
+			return nil, err
+		}
+
+		_, layer, err := ParseArtifactManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("referrer %s: %w", r.Digest, err)
+		}
+
+		blob, err := c.fetch(ctx, "blobs", layer)
+		if err != nil {
+			return nil, err
+		}
+
+		envelopes, err := ReadAttestations(layer, blob)
+		if err != nil {
+			return nil, fmt.Errorf("referrer %s: %w", r.Digest, err)
+		}
+
+		attestations = append(attestations, attestationsAbout(subject, r, envelopes)...)
+	}
+
+	return attestations, nil
+}
+
+// Export attaches a signed envelope to each of its Statement's subjects
+// whose digest is a manifest in the repository, and returns the
+// descriptors of the referrers. Subjects that are not in the repository
+// are ignored, but at least one must be.
+func (c *Client) Export(ctx context.Context, env *dsse.Envelope) ([]ocispec.Descriptor, error) {
+	if env == nil {
+		return nil, dsse.ErrEnvelopeRequired
+	}
+
+	s, err := env.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	referrers := []ocispec.Descriptor{}
+	for _, rd := range s.GetSubject() {
+		subject, err := SubjectDigest(rd)
+		if err != nil {
+			continue
+		}
+
+		r, err := c.PushEnvelope(ctx, subject, env)
+		if errors.Is(err, ErrSubjectNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, r)
+	}
+
+	if len(referrers) == 0 {
+		return nil, ErrSubjectNotFound
+	}
+
+	return referrers, nil
+}
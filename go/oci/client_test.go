@@ -0,0 +1,438 @@
+/*
+Tests for the registry client, against an in-process registry stand-in.
+*/
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/in-toto/attestation/go/bundle"
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRepository = "example/fooly"
+
+type testManifest struct {
+	mediaType string
+	data      []byte
+}
+
+// testRegistry is an in-process stand-in for an OCI registry serving a
+// single repository. Without referrersAPI it behaves like a registry that
+// predates the referrers API, which clients must support through the
+// referrers tag.
+type testRegistry struct {
+	referrersAPI bool
+	// applyFilters makes the referrers API filter by artifactType.
+	applyFilters bool
+	// pageSize paginates the referrers API if positive.
+	pageSize int
+
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string]testManifest
+	// order lists the manifest digests in the order they were pushed.
+	order   []digest.Digest
+	uploads int
+}
+
+func newTestRegistry(t *testing.T, referrersAPI bool) (*testRegistry, *Client) {
+	t.Helper()
+
+	r := &testRegistry{
+		referrersAPI: referrersAPI,
+		blobs:        map[digest.Digest][]byte{},
+		manifests:    map[string]testManifest{},
+	}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	c, err := NewClient(server.URL, testRepository, WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, c
+}
+
+// putImage stores an image manifest, as if pushed by a container tool.
+func (r *testRegistry) putImage(d ocispec.Descriptor, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.manifests[d.Digest.String()] = testManifest{mediaType: d.MediaType, data: data}
+	r.order = append(r.order, d.Digest)
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path, ok := strings.CutPrefix(req.URL.Path, "/v2/"+testRepository+"/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	kind, reference, _ := strings.Cut(path, "/")
+	switch {
+	case kind == "blobs" && reference == "uploads/" && req.Method == http.MethodPost:
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d?state=opaque", testRepository, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case kind == "blobs" && strings.HasPrefix(reference, "uploads/") && req.Method == http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		d := digest.Digest(req.URL.Query().Get("digest"))
+		if req.URL.Query().Get("state") != "opaque" || digest.FromBytes(data) != d {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[d] = data
+		w.WriteHeader(http.StatusCreated)
+	case kind == "blobs":
+		data, ok := r.blobs[digest.Digest(reference)]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		r.serve(w, req, "application/octet-stream", data)
+	case kind == "manifests" && req.Method == http.MethodPut:
+		r.putManifest(w, req, reference)
+	case kind == "manifests":
+		m, ok := r.manifests[reference]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		r.serve(w, req, m.mediaType, m.data)
+	case kind == "referrers" && r.referrersAPI:
+		r.serveReferrers(w, req, digest.Digest(reference))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request, mediaType string, data []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+	if req.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (r *testRegistry) putManifest(w http.ResponseWriter, req *http.Request, reference string) {
+	data, _ := io.ReadAll(req.Body)
+	d := digest.FromBytes(data)
+	if strings.Contains(reference, ":") && digest.Digest(reference) != d {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var m ocispec.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, l := range append([]ocispec.Descriptor{m.Config}, m.Layers...) {
+		if _, ok := r.blobs[l.Digest]; !ok && l.Digest != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	stored := testManifest{mediaType: req.Header.Get("Content-Type"), data: data}
+	if _, ok := r.manifests[d.String()]; !ok {
+		r.order = append(r.order, d)
+	}
+	r.manifests[d.String()] = stored
+	r.manifests[reference] = stored
+
+	if r.referrersAPI && m.Subject != nil {
+		w.Header().Set(headerSubject, m.Subject.Digest.String())
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (r *testRegistry) serveReferrers(w http.ResponseWriter, req *http.Request, subject digest.Digest) {
+	artifactType := req.URL.Query().Get(paramArtifactType)
+	referrers := []ocispec.Descriptor{}
+	for _, d := range r.order {
+		stored := r.manifests[d.String()]
+		var m ocispec.Manifest
+		if err := json.Unmarshal(stored.data, &m); err != nil || m.Subject == nil || m.Subject.Digest != subject {
+			continue
+		}
+
+		if r.applyFilters && artifactType != "" && m.ArtifactType != artifactType {
+			continue
+		}
+
+		referrers = append(referrers, ocispec.Descriptor{
+			MediaType:    stored.mediaType,
+			Digest:       d,
+			Size:         int64(len(stored.data)),
+			ArtifactType: m.ArtifactType,
+			Annotations:  m.Annotations,
+		})
+	}
+
+	if r.applyFilters && artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", paramArtifactType)
+	}
+
+	if r.pageSize > 0 {
+		page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		start := min(page*r.pageSize, len(referrers))
+		end := min(start+r.pageSize, len(referrers))
+		if end < len(referrers) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, req.URL.Path, page+1))
+		}
+		referrers = referrers[start:end]
+	}
+
+	data, _ := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: referrers,
+	})
+	w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+	w.Write(data)
+}
+
+func TestClientPushAndPull(t *testing.T) {
+	ctx := context.Background()
+	provMediaType := EnvelopeMediaType(testProvenanceType)
+
+	tests := map[string]struct {
+		referrersAPI bool
+		applyFilters bool
+		pageSize     int
+	}{
+		"referrers API": {
+			referrersAPI: true,
+			applyFilters: true,
+		},
+		"referrers API without filtering": {
+			referrersAPI: true,
+			pageSize:     1,
+		},
+		"referrers tag": {},
+	}
+
+	for name, test := range tests {
+		registry, c := newTestRegistry(t, test.referrersAPI)
+		registry.applyFilters = test.applyFilters
+		registry.pageSize = test.pageSize
+
+		image, data := createTestManifest(t, "fooly")
+		registry.putImage(image, data)
+		other, data := createTestManifest(t, "barly")
+		registry.putImage(other, data)
+
+		signer := testutil.NewSigner(t)
+		prov := createTestEnvelope(t, testProvenanceType, signer, image)
+		custom := createTestEnvelope(t, testCustomType, signer, image)
+
+		provRef, err := c.PushEnvelope(ctx, image.Digest, prov)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		_, err = c.PushBundle(ctx, image.Digest, writeTestBundle(t, custom))
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		_, err = c.PushEnvelope(ctx, other.Digest, createTestEnvelope(t, testProvenanceType, signer, other))
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+
+		// an attestation about another image, attached to this one
+		misattached, err := NewEnvelopeArtifact(image, createTestEnvelope(t, testCustomType, signer, other))
+		assert.NoError(t, err)
+		assert.NoError(t, c.Push(ctx, misattached), fmt.Sprintf("unexpected error in test '%s'", name))
+
+		referrers, err := c.Referrers(ctx, image.Digest, "")
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		assert.Len(t, referrers, 3, fmt.Sprintf("wrong referrers in test '%s'", name))
+
+		referrers, err = c.Referrers(ctx, image.Digest, provMediaType)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		if assert.Len(t, referrers, 1, fmt.Sprintf("wrong filtered referrers in test '%s'", name)) {
+			assert.Equal(t, provRef.Digest, referrers[0].Digest, fmt.Sprintf("wrong referrer in test '%s'", name))
+			assert.Equal(t, testProvenanceType, referrers[0].Annotations[AnnotationPredicateType], fmt.Sprintf("wrong annotations in test '%s'", name))
+		}
+
+		attestations, err := c.Pull(ctx, image.Digest, "")
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		if assert.Len(t, attestations, 2, fmt.Sprintf("wrong attestations in test '%s'", name)) {
+			assert.Equal(t, prov, attestations[0].Envelope, fmt.Sprintf("wrong envelope in test '%s'", name))
+			assert.Equal(t, custom, attestations[1].Envelope, fmt.Sprintf("wrong envelope in test '%s'", name))
+			assert.Equal(t, bundle.MediaType, attestations[1].Referrer.ArtifactType, fmt.Sprintf("wrong referrer in test '%s'", name))
+		}
+
+		attestations, err = c.Pull(ctx, image.Digest, provMediaType)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		if assert.Len(t, attestations, 1, fmt.Sprintf("wrong filtered attestations in test '%s'", name)) {
+			assert.Equal(t, prov, attestations[0].Envelope, fmt.Sprintf("wrong envelope in test '%s'", name))
+		}
+	}
+}
+
+func TestClientPushIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	registry, c := newTestRegistry(t, false)
+	image, data := createTestManifest(t, "fooly")
+	registry.putImage(image, data)
+	prov := createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image)
+
+	_, err := c.PushEnvelope(ctx, image.Digest, prov)
+	assert.NoError(t, err)
+	_, err = c.PushEnvelope(ctx, image.Digest, prov)
+	assert.NoError(t, err)
+
+	// the empty config and the envelope are uploaded once
+	assert.Equal(t, 2, registry.uploads)
+
+	var index ocispec.Index
+	assert.NoError(t, json.Unmarshal(registry.manifests[referrersTag(image.Digest)].data, &index))
+	assert.Len(t, index.Manifests, 1)
+}
+
+func TestClientExport(t *testing.T) {
+	ctx := context.Background()
+	registry, c := newTestRegistry(t, true)
+	image, data := createTestManifest(t, "fooly")
+	registry.putImage(image, data)
+	elsewhere, _ := createTestManifest(t, "elsewhere")
+	signer := testutil.NewSigner(t)
+
+	env := createTestEnvelope(t, testProvenanceType, signer, elsewhere, image)
+	referrers, err := c.Export(ctx, env)
+	assert.NoError(t, err)
+	assert.Len(t, referrers, 1)
+
+	attestations, err := c.Pull(ctx, image.Digest, "")
+	assert.NoError(t, err)
+	if assert.Len(t, attestations, 1) {
+		assert.Equal(t, env, attestations[0].Envelope)
+	}
+
+	_, err = c.Export(ctx, createTestEnvelope(t, testProvenanceType, signer, elsewhere))
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+
+	_, err = c.Export(ctx, nil)
+	assert.ErrorIs(t, err, dsse.ErrEnvelopeRequired)
+}
+
+func TestClientPullDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	registry, c := newTestRegistry(t, true)
+	image, data := createTestManifest(t, "fooly")
+	registry.putImage(image, data)
+
+	a, err := NewEnvelopeArtifact(image, createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Push(ctx, a))
+
+	registry.blobs[a.Layer.Digest] = append(registry.blobs[a.Layer.Digest][1:], ' ')
+	_, err = c.Pull(ctx, image.Digest, "")
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+
+	small, err := NewClient(c.registry.String(), testRepository, WithHTTPClient(c.httpClient), WithMaxBlobSize(16))
+	assert.NoError(t, err)
+	_, err = small.Pull(ctx, image.Digest, "")
+	assert.ErrorIs(t, err, ErrBlobTooLarge)
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	_, c := newTestRegistry(t, true)
+	image, _ := createTestManifest(t, "fooly")
+
+	_, err := c.Resolve(ctx, image.Digest)
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+
+	_, err = c.PushEnvelope(ctx, image.Digest, createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image))
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+
+	_, err = c.Referrers(ctx, "sha256:1234", "")
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	// an unknown repository has no referrers
+	other, err := NewClient(c.registry.String(), "example/barly", WithHTTPClient(c.httpClient))
+	assert.NoError(t, err)
+	referrers, err := other.Referrers(ctx, image.Digest, "")
+	assert.NoError(t, err)
+	assert.Empty(t, referrers)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	broken, err := NewClient(failing.URL, testRepository)
+	assert.NoError(t, err)
+	_, err = broken.Referrers(ctx, image.Digest, "")
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+	_, err = broken.Resolve(ctx, image.Digest)
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+
+	for _, repository := range []string{"", "Example/fooly", "example//fooly", "example/fooly/"} {
+		_, err = NewClient(c.registry.String(), repository)
+		assert.ErrorIs(t, err, ErrInvalidRepository, fmt.Sprintf("repository %q", repository))
+	}
+
+	_, err = NewClient("oci://registry.example.com", testRepository)
+	assert.Error(t, err)
+}
+
+func TestReferrersPaginationLimits(t *testing.T) {
+	image, _ := createTestManifest(t, "fooly")
+
+	tests := map[string]func(*url.URL) string{
+		"self-referencing page": func(u *url.URL) string {
+			return u.RequestURI()
+		},
+		"endless pages": func(u *url.URL) string {
+			page, _ := strconv.Atoi(u.Query().Get("page"))
+			return fmt.Sprintf("%s?page=%d", u.Path, page+1)
+		},
+	}
+
+	for name, next := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			data, _ := json.Marshal(ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}, MediaType: ocispec.MediaTypeImageIndex})
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next(req.URL)))
+			w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+			w.Write(data)
+		}))
+
+		c, err := NewClient(server.URL, testRepository, WithHTTPClient(server.Client()))
+		assert.NoError(t, err)
+		_, err = c.Referrers(context.Background(), image.Digest, "")
+		assert.ErrorIs(t, err, ErrUnexpectedStatus, fmt.Sprintf("pagination did not stop in test '%s'", name))
+		assert.LessOrEqual(t, requests, maxReferrersPages, fmt.Sprintf("too many requests in test '%s'", name))
+		server.Close()
+	}
+}
+
+func TestReferrersTag(t *testing.T) {
+	d := digest.FromString("fooly")
+	assert.Equal(t, "sha256-"+d.Encoded(), referrersTag(d))
+
+	long := digest.NewDigestFromEncoded(digest.SHA512, strings.Repeat("ab", 64))
+	assert.Equal(t, "sha512-"+strings.Repeat("ab", 32), referrersTag(long))
+}
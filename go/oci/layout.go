@@ -0,0 +1,405 @@
+/*
+Export and import of attestations to and from OCI image layout directories,
+for moving them alongside container images without a registry.
+*/
+
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/in-toto/attestation/go/dsse"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var (
+	ErrInvalidLayout   = errors.New("invalid OCI image layout")
+	ErrSubjectNotFound = errors.New("subject manifest not found")
+)
+
+// Layout is an OCI image layout directory. Referrers are listed in its
+// index.json, where tools following the image spec's referrers guidance
+// find them. A Layout is not safe for concurrent use, by this or any other
+// process.
+type Layout struct {
+	dir string
+}
+
+// CreateLayout opens the image layout in dir, initializing an empty one if
+// dir does not exist or is empty.
+func CreateLayout(dir string) (*Layout, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(entries) > 0 {
+		return OpenLayout(dir)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ocispec.ImageBlobsDir), 0o755); err != nil {
+		return nil, err
+	}
+
+	l := &Layout{dir: dir}
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFile(filepath.Join(dir, ocispec.ImageLayoutFile), layout); err != nil {
+		return nil, err
+	}
+
+	if err := l.writeIndex(&ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{},
+	}); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// OpenLayout opens an existing image layout.
+func OpenLayout(dir string) (*Layout, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ocispec.ImageLayoutFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	var layout ocispec.ImageLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	if layout.Version != ocispec.ImageLayoutVersion {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalidLayout, layout.Version)
+	}
+
+	l := &Layout{dir: dir}
+	if _, err := l.Index(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Index returns the layout's index.json.
+func (l *Layout) Index() (*ocispec.Index, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, ocispec.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	index := &ocispec.Index{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	return index, nil
+}
+
+func (l *Layout) writeIndex(index *ocispec.Index) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(l.dir, ocispec.ImageIndexFile), data)
+}
+
+func (l *Layout) blobPath(d digest.Digest) string {
+	return filepath.Join(l.dir, ocispec.ImageBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+// ReadBlob returns the blob with the given descriptor, after checking its
+// size and digest.
+func (l *Layout) ReadBlob(d ocispec.Descriptor) ([]byte, error) {
+	if err := d.Digest.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	data, err := os.ReadFile(l.blobPath(d.Digest))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := VerifyContent(d, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// WriteBlob stores content and returns its sha256 digest.
+func (l *Layout) WriteBlob(content []byte) (digest.Digest, error) {
+	d := digest.FromBytes(content)
+	p := l.blobPath(d)
+	if _, err := os.Stat(p); err == nil {
+		return d, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+
+	return d, writeFile(p, content)
+}
+
+// Resolve returns the descriptor of the manifest with the given digest. It
+// is looked up in index.json first, and otherwise among the layout's
+// blobs, e.g. for the platform manifests of a multi-platform image.
+func (l *Layout) Resolve(d digest.Digest) (ocispec.Descriptor, error) {
+	if err := d.Validate(); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	index, err := l.Index()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	for _, m := range index.Manifests {
+		if m.Digest == d {
+			return m, nil
+		}
+	}
+
+	data, err := os.ReadFile(l.blobPath(d))
+	if errors.Is(err, os.ErrNotExist) {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s", ErrSubjectNotFound, d)
+	} else if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	var header struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.MediaType == "" {
+		return ocispec.Descriptor{}, fmt.Errorf("%w: %s is not a manifest", ErrSubjectNotFound, d)
+	}
+
+	return ocispec.Descriptor{MediaType: header.MediaType, Digest: d, Size: int64(len(data))}, nil
+}
+
+// Attach stores an artifact and lists it in index.json. Attaching the
+// same artifact again has no effect.
+func (l *Layout) Attach(a *Artifact) error {
+	for _, blob := range [][]byte{ocispec.DescriptorEmptyJSON.Data, a.Blob, a.Manifest} {
+		if _, err := l.WriteBlob(blob); err != nil {
+			return err
+		}
+	}
+
+	index, err := l.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range index.Manifests {
+		if m.Digest == a.Descriptor.Digest {
+			return nil
+		}
+	}
+	index.Manifests = append(index.Manifests, a.Descriptor)
+
+	return l.writeIndex(index)
+}
+
+// AttachEnvelope attaches a signed envelope to the subject manifest with
+// the given digest, and returns the descriptor of the referrer.
+func (l *Layout) AttachEnvelope(subject digest.Digest, env *dsse.Envelope) (ocispec.Descriptor, error) {
+	d, err := l.Resolve(subject)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	a, err := NewEnvelopeArtifact(d, env)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return a.Descriptor, l.Attach(a)
+}
+
+// AttachBundle attaches a Bundle to the subject manifest with the given
+// digest, and returns the descriptor of the referrer.
+func (l *Layout) AttachBundle(subject digest.Digest, data []byte) (ocispec.Descriptor, error) {
+	d, err := l.Resolve(subject)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	a, err := NewBundleArtifact(d, data)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	return a.Descriptor, l.Attach(a)
+}
+
+// Export attaches a signed envelope to each of its Statement's subjects
+// whose digest is a manifest in the layout, and returns the descriptors of
+// the referrers. Subjects that are not in the layout are ignored, but at
+// least one must be.
+func (l *Layout) Export(env *dsse.Envelope) ([]ocispec.Descriptor, error) {
+	if env == nil {
+		return nil, dsse.ErrEnvelopeRequired
+	}
+
+	s, err := env.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	referrers := []ocispec.Descriptor{}
+	for _, rd := range s.GetSubject() {
+		subject, err := SubjectDigest(rd)
+		if err != nil {
+			continue
+		}
+
+		r, err := l.AttachEnvelope(subject, env)
+		if errors.Is(err, ErrSubjectNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, r)
+	}
+
+	if len(referrers) == 0 {
+		return nil, ErrSubjectNotFound
+	}
+
+	return referrers, nil
+}
+
+// Referrers returns the descriptors of the manifests in index.json that
+// refer to the subject manifest with the given digest, in index order. A
+// non-empty artifactType restricts them to artifacts of that type.
+func (l *Layout) Referrers(subject digest.Digest, artifactType string) ([]ocispec.Descriptor, error) {
+	index, err := l.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	referrers := []ocispec.Descriptor{}
+	for _, d := range index.Manifests {
+		if d.MediaType != ocispec.MediaTypeImageManifest {
+			continue
+		}
+
+		// entries written by other tools may not carry an artifactType
+		if artifactType != "" && d.ArtifactType != "" && d.ArtifactType != artifactType {
+			continue
+		}
+
+		data, err := l.ReadBlob(d)
+		if err != nil {
+			return nil, err
+		}
+
+		var m ocispec.Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+
+		if m.Subject == nil || m.Subject.Digest != subject {
+			continue
+		}
+
+		r := ocispec.Descriptor{
+			MediaType:    d.MediaType,
+			Digest:       d.Digest,
+			Size:         d.Size,
+			ArtifactType: m.ArtifactType,
+			Annotations:  m.Annotations,
+		}
+		if r.ArtifactType == "" {
+			r.ArtifactType = m.Config.MediaType
+		}
+
+		if artifactType == "" || r.ArtifactType == artifactType {
+			referrers = append(referrers, r)
+		}
+	}
+
+	return referrers, nil
+}
+
+// Import returns the attestations attached to the subject manifest with
+// the given digest. Referrers that are not attestation artifacts, and
+// envelopes whose Statement has no subject with the subject's digest, are
+// ignored. The envelopes are not verified: callers MUST verify them before
+// trusting them.
+func (l *Layout) Import(subject digest.Digest) ([]*Attestation, error) {
+	referrers, err := l.Referrers(subject, "")
+	if err != nil {
+		return nil, err
+	}
+
+	attestations := []*Attestation{}
+	for _, r := range referrers {
+		if !IsAttestationMediaType(r.ArtifactType) {
+			continue
+		}
+
+		data, err := l.ReadBlob(r)
+		if err != nil {
+			return nil, err
+		}
+
+		_, layer, err := ParseArtifactManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("referrer %s: %w", r.Digest, err)
+		}
+
+		blob, err := l.ReadBlob(layer)
+		if err != nil {
+			return nil, err
+		}
+
+		envelopes, err := ReadAttestations(layer, blob)
+		if err != nil {
+			return nil, fmt.Errorf("referrer %s: %w", r.Digest, err)
+		}
+
+		attestations = append(attestations, attestationsAbout(subject, r, envelopes)...)
+	}
+
+	return attestations, nil
+}
+
+// writeFile replaces a file atomically, so that an interrupted write does
+// not leave a truncated blob or index behind.
+func writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-"+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
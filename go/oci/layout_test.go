@@ -0,0 +1,250 @@
+/*
+Tests for exporting and importing attestations in OCI image layouts.
+*/
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestLayout returns a layout holding a tagged image, and the
+// descriptor of a second image manifest that is only stored as a blob.
+func createTestLayout(t *testing.T) (*Layout, ocispec.Descriptor, ocispec.Descriptor) {
+	t.Helper()
+
+	l, err := CreateLayout(filepath.Join(t.TempDir(), "layout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image, data := createTestManifest(t, "fooly")
+	if _, err := l.WriteBlob(data); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := l.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Manifests = append(index.Manifests, image)
+	if err := l.writeIndex(index); err != nil {
+		t.Fatal(err)
+	}
+
+	untagged, data := createTestManifest(t, "barly")
+	if _, err := l.WriteBlob(data); err != nil {
+		t.Fatal(err)
+	}
+	untagged.Annotations = nil
+
+	return l, image, untagged
+}
+
+func TestAttachAndImport(t *testing.T) {
+	l, image, untagged := createTestLayout(t)
+	signer := testutil.NewSigner(t)
+	prov := createTestEnvelope(t, testProvenanceType, signer, image)
+	custom := createTestEnvelope(t, testCustomType, signer, image)
+	other := createTestEnvelope(t, testProvenanceType, signer, untagged)
+
+	provRef, err := l.AttachEnvelope(image.Digest, prov)
+	assert.NoError(t, err)
+	bundleRef, err := l.AttachBundle(image.Digest, writeTestBundle(t, custom, prov))
+	assert.NoError(t, err)
+	_, err = l.AttachEnvelope(untagged.Digest, other)
+	assert.NoError(t, err)
+
+	// the layout can be reopened from disk
+	l, err = OpenLayout(l.dir)
+	assert.NoError(t, err)
+
+	attestations, err := l.Import(image.Digest)
+	assert.NoError(t, err)
+	if assert.Len(t, attestations, 3) {
+		assert.Equal(t, provRef.Digest, attestations[0].Referrer.Digest)
+		assert.Equal(t, prov, attestations[0].Envelope)
+		assert.Equal(t, bundleRef.Digest, attestations[1].Referrer.Digest)
+		assert.Equal(t, custom, attestations[1].Envelope)
+		assert.Equal(t, prov, attestations[2].Envelope)
+	}
+
+	attestations, err = l.Import(untagged.Digest)
+	assert.NoError(t, err)
+	if assert.Len(t, attestations, 1) {
+		assert.Equal(t, other, attestations[0].Envelope)
+	}
+
+	matched, err := attestations[0].Envelope.Verify(context.Background(), signer)
+	assert.NoError(t, err)
+	assert.Len(t, matched, 1)
+}
+
+func TestImportFiltersBySubject(t *testing.T) {
+	l, image, untagged := createTestLayout(t)
+	signer := testutil.NewSigner(t)
+	prov := createTestEnvelope(t, testProvenanceType, signer, image)
+	other := createTestEnvelope(t, testProvenanceType, signer, untagged)
+
+	// referrers can claim any subject, whatever their envelope is about
+	_, err := l.AttachEnvelope(image.Digest, other)
+	assert.NoError(t, err)
+	_, err = l.AttachBundle(image.Digest, writeTestBundle(t, other, prov))
+	assert.NoError(t, err)
+
+	attestations, err := l.Import(image.Digest)
+	assert.NoError(t, err)
+	if assert.Len(t, attestations, 1) {
+		assert.Equal(t, prov, attestations[0].Envelope)
+	}
+}
+
+func TestAttachIsIdempotent(t *testing.T) {
+	l, image, _ := createTestLayout(t)
+	prov := createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image)
+
+	first, err := l.AttachEnvelope(image.Digest, prov)
+	assert.NoError(t, err)
+	second, err := l.AttachEnvelope(image.Digest, prov)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	index, err := l.Index()
+	assert.NoError(t, err)
+	assert.Len(t, index.Manifests, 2)
+}
+
+func TestReferrers(t *testing.T) {
+	l, image, _ := createTestLayout(t)
+	signer := testutil.NewSigner(t)
+	_, err := l.AttachEnvelope(image.Digest, createTestEnvelope(t, testProvenanceType, signer, image))
+	assert.NoError(t, err)
+	_, err = l.AttachEnvelope(image.Digest, createTestEnvelope(t, testCustomType, signer, image))
+	assert.NoError(t, err)
+
+	referrers, err := l.Referrers(image.Digest, "")
+	assert.NoError(t, err)
+	assert.Len(t, referrers, 2)
+
+	referrers, err = l.Referrers(image.Digest, "application/vnd.in-toto.provenance+dsse")
+	assert.NoError(t, err)
+	if assert.Len(t, referrers, 1) {
+		assert.Equal(t, testProvenanceType, referrers[0].Annotations[AnnotationPredicateType])
+	}
+
+	referrers, err = l.Referrers(image.Digest, "application/vnd.example.sbom")
+	assert.NoError(t, err)
+	assert.Empty(t, referrers)
+
+	// images are not referrers of themselves
+	referrers, err = l.Referrers(digest.FromString("missing"), "")
+	assert.NoError(t, err)
+	assert.Empty(t, referrers)
+}
+
+func TestExport(t *testing.T) {
+	l, image, untagged := createTestLayout(t)
+	elsewhere, _ := createTestManifest(t, "elsewhere")
+	signer := testutil.NewSigner(t)
+
+	env := createTestEnvelope(t, testProvenanceType, signer, image, elsewhere, untagged)
+	referrers, err := l.Export(env)
+	assert.NoError(t, err)
+	assert.Len(t, referrers, 2)
+
+	for _, subject := range []digest.Digest{image.Digest, untagged.Digest} {
+		attestations, err := l.Import(subject)
+		assert.NoError(t, err)
+		if assert.Len(t, attestations, 1) {
+			assert.Equal(t, env, attestations[0].Envelope)
+		}
+	}
+
+	_, err = l.Export(createTestEnvelope(t, testProvenanceType, signer, elsewhere))
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+
+	_, err = l.Export(nil)
+	assert.ErrorIs(t, err, dsse.ErrEnvelopeRequired)
+}
+
+func TestImportDetectsTampering(t *testing.T) {
+	l, image, _ := createTestLayout(t)
+	prov := createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image)
+
+	a, err := NewEnvelopeArtifact(image, prov)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Attach(a))
+
+	assert.NoError(t, os.WriteFile(l.blobPath(a.Layer.Digest), append(a.Blob, ' '), 0o644))
+	_, err = l.Import(image.Digest)
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+}
+
+func TestResolve(t *testing.T) {
+	l, image, untagged := createTestLayout(t)
+
+	d, err := l.Resolve(image.Digest)
+	assert.NoError(t, err)
+	assert.Equal(t, image, d)
+
+	d, err = l.Resolve(untagged.Digest)
+	assert.NoError(t, err)
+	assert.Equal(t, untagged, d)
+
+	_, err = l.Resolve(digest.FromString("missing"))
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+
+	// blobs that are not manifests cannot be subjects
+	_, err = l.Resolve(ocispec.DescriptorEmptyJSON.Digest)
+	assert.Error(t, err)
+
+	_, err = l.AttachEnvelope(digest.FromString("missing"), createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), image))
+	assert.ErrorIs(t, err, ErrSubjectNotFound)
+}
+
+func TestOpenLayoutErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := OpenLayout(dir)
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+
+	// an existing directory that is not a layout is not initialized
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o644))
+	_, err = CreateLayout(dir)
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+
+	layout, err := json.Marshal(ocispec.ImageLayout{Version: "2.0.0"})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ocispec.ImageLayoutFile), layout, 0o644))
+	_, err = OpenLayout(dir)
+	assert.ErrorIs(t, err, ErrInvalidLayout)
+}
+
+func TestCreateLayout(t *testing.T) {
+	dir := t.TempDir()
+	l, err := CreateLayout(dir)
+	assert.NoError(t, err)
+
+	for _, name := range []string{ocispec.ImageLayoutFile, ocispec.ImageIndexFile, ocispec.ImageBlobsDir} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+	}
+
+	index, err := l.Index()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, index.SchemaVersion)
+	assert.Empty(t, index.Manifests)
+
+	// creating an existing layout opens it
+	_, err = CreateLayout(dir)
+	assert.NoError(t, err)
+}
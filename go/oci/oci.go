@@ -0,0 +1,343 @@
+/*
+Packaging of in-toto attestations as OCI artifacts that refer to a subject
+image manifest, following the referrers model of the OCI image spec.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/in-toto/attestation/go/bundle"
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// GenericEnvelopeMediaType denotes an individual attestation whose
+// predicate is not a vetted predicate, by analogy with the generic
+// payloadType application/vnd.in-toto+json.
+const GenericEnvelopeMediaType = "application/vnd.in-toto+dsse"
+
+// AnnotationPredicateType is the manifest annotation recording the
+// predicateType of the Statement in an envelope artifact. Like the media
+// type, it is informational only: consumers must rely on the Statement.
+const AnnotationPredicateType = "in-toto.io/predicate-type"
+
+const envelopeMediaTypePrefix = "application/vnd.in-toto."
+const envelopeMediaTypeSuffix = "+dsse"
+
+var (
+	ErrInvalidDescriptor  = errors.New("invalid OCI descriptor")
+	ErrUnsupportedDigest  = errors.New("resource descriptor has no sha256 or sha512 digest")
+	ErrDigestMismatch     = errors.New("content does not match its descriptor")
+	ErrNotAttestation     = errors.New("artifact is not an in-toto attestation")
+	ErrNoAttestations     = errors.New("bundle holds no envelopes")
+	ErrUnexpectedManifest = errors.New("manifest is not an attestation artifact")
+)
+
+// Artifact is an attestation packaged as an OCI artifact: an image
+// manifest with an empty config, whose single layer is an envelope or a
+// Bundle, and whose subject is the manifest the attestation is about.
+type Artifact struct {
+	// Descriptor describes the artifact's manifest, with its artifactType
+	// and annotations, as it is listed among the subject's referrers.
+	Descriptor ocispec.Descriptor
+	// Manifest is the serialized manifest.
+	Manifest []byte
+	// Layer describes Blob.
+	Layer ocispec.Descriptor
+	// Blob is the envelope or Bundle.
+	Blob []byte
+}
+
+// Attestation is an envelope read from a referrer artifact.
+type Attestation struct {
+	// Referrer describes the artifact's manifest.
+	Referrer ocispec.Descriptor
+	Envelope *dsse.Envelope
+}
+
+// EnvelopeMediaType returns the media type of an individual attestation
+// with the given predicate type: application/vnd.in-toto.<predicate>+dsse
+// for vetted predicates, and GenericEnvelopeMediaType otherwise.
+func EnvelopeMediaType(predicateType string) string {
+	if mt, ok := dsse.StorageMediaType(predicateType); ok {
+		return mt
+	}
+
+	return GenericEnvelopeMediaType
+}
+
+// IsEnvelopeMediaType reports whether a media type denotes an individual
+// attestation.
+func IsEnvelopeMediaType(mediaType string) bool {
+	if mediaType == GenericEnvelopeMediaType {
+		return true
+	}
+
+	if !strings.HasPrefix(mediaType, envelopeMediaTypePrefix) || !strings.HasSuffix(mediaType, envelopeMediaTypeSuffix) {
+		return false
+	}
+
+	predicate := strings.TrimSuffix(strings.TrimPrefix(mediaType, envelopeMediaTypePrefix), envelopeMediaTypeSuffix)
+	return predicate != "" && !strings.ContainsAny(predicate, "+/; ")
+}
+
+// IsAttestationMediaType reports whether a media type denotes an
+// individual attestation or a Bundle.
+func IsAttestationMediaType(mediaType string) bool {
+	return mediaType == bundle.MediaType || IsEnvelopeMediaType(mediaType)
+}
+
+// NewEnvelopeArtifact packages a signed envelope as an artifact referring
+// to the subject manifest. Its artifactType is derived from the
+// Statement's predicateType.
+func NewEnvelopeArtifact(subject ocispec.Descriptor, env *dsse.Envelope) (*Artifact, error) {
+	if env == nil {
+		return nil, dsse.ErrEnvelopeRequired
+	}
+
+	if err := env.Validate(); err != nil {
+		return nil, err
+	}
+
+	s, err := env.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{AnnotationPredicateType: s.GetPredicateType()}
+
+	return newArtifact(subject, EnvelopeMediaType(s.GetPredicateType()), blob, annotations)
+}
+
+// NewBundleArtifact packages a Bundle as an artifact referring to the
+// subject manifest. The Bundle is stored as is, but must hold at least
+// one envelope.
+func NewBundleArtifact(subject ocispec.Descriptor, data []byte) (*Artifact, error) {
+	envelopes, err := readBundle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(envelopes) == 0 {
+		return nil, ErrNoAttestations
+	}
+
+	return newArtifact(subject, bundle.MediaType, data, nil)
+}
+
+func newArtifact(subject ocispec.Descriptor, artifactType string, blob []byte, annotations map[string]string) (*Artifact, error) {
+	if err := ValidateDescriptor(subject); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	layer := ocispec.Descriptor{
+		MediaType: artifactType,
+		Digest:    digest.FromBytes(blob),
+		Size:      int64(len(blob)),
+	}
+
+	m := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{layer},
+		Subject:      &ocispec.Descriptor{MediaType: subject.MediaType, Digest: subject.Digest, Size: subject.Size},
+		Annotations:  annotations,
+	}
+	m.SchemaVersion = 2
+
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		Descriptor: ocispec.Descriptor{
+			MediaType:    ocispec.MediaTypeImageManifest,
+			Digest:       digest.FromBytes(manifest),
+			Size:         int64(len(manifest)),
+			ArtifactType: artifactType,
+			Annotations:  annotations,
+		},
+		Manifest: manifest,
+		Layer:    layer,
+		Blob:     blob,
+	}, nil
+}
+
+// ParseArtifactManifest decodes the manifest of an attestation artifact
+// and returns it with the descriptor of its attestation layer.
+func ParseArtifactManifest(data []byte) (*ocispec.Manifest, ocispec.Descriptor, error) {
+	m := &ocispec.Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, ocispec.Descriptor{}, fmt.Errorf("%w: %v", ErrUnexpectedManifest, err)
+	}
+
+	if m.MediaType != "" && m.MediaType != ocispec.MediaTypeImageManifest {
+		return nil, ocispec.Descriptor{}, fmt.Errorf("%w: media type %q", ErrUnexpectedManifest, m.MediaType)
+	}
+
+	for _, l := range m.Layers {
+		if IsAttestationMediaType(l.MediaType) {
+			return m, l, nil
+		}
+	}
+
+	return nil, ocispec.Descriptor{}, ErrNotAttestation
+}
+
+// ReadAttestations decodes the envelopes in an attestation layer. The
+// lines of a Bundle that are not envelopes are ignored, as the spec
+// requires of consumers.
+func ReadAttestations(layer ocispec.Descriptor, blob []byte) ([]*dsse.Envelope, error) {
+	if err := VerifyContent(layer, blob); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case layer.MediaType == bundle.MediaType:
+		return readBundle(blob)
+	case IsEnvelopeMediaType(layer.MediaType):
+		env, err := dsse.Parse(blob)
+		if err != nil {
+			return nil, err
+		}
+		return []*dsse.Envelope{env}, nil
+	default:
+		return nil, fmt.Errorf("%w: media type %q", ErrNotAttestation, layer.MediaType)
+	}
+}
+
+func readBundle(data []byte) ([]*dsse.Envelope, error) {
+	envelopes := []*dsse.Envelope{}
+	r := bundle.NewReader(bytes.NewReader(data))
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return envelopes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Envelope != nil {
+			envelopes = append(envelopes, e.Envelope)
+		}
+	}
+}
+
+// ValidateDescriptor checks that a descriptor has a media type, a valid
+// digest and a size.
+func ValidateDescriptor(d ocispec.Descriptor) error {
+	if d.MediaType == "" {
+		return fmt.Errorf("%w: media type required", ErrInvalidDescriptor)
+	}
+
+	if err := d.Digest.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	if d.Size < 0 {
+		return fmt.Errorf("%w: negative size", ErrInvalidDescriptor)
+	}
+
+	return nil
+}
+
+// VerifyContent checks that content has the size and digest of its
+// descriptor.
+func VerifyContent(d ocispec.Descriptor, content []byte) error {
+	if int64(len(content)) != d.Size {
+		return fmt.Errorf("%w: %s has size %d, expected %d", ErrDigestMismatch, d.Digest, len(content), d.Size)
+	}
+
+	if err := d.Digest.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	if d.Digest.Algorithm().FromBytes(content) != d.Digest {
+		return fmt.Errorf("%w: %s", ErrDigestMismatch, d.Digest)
+	}
+
+	return nil
+}
+
+// SubjectDigest returns the OCI digest of a subject ResourceDescriptor,
+// preferring sha256 over sha512, the algorithms registered by the OCI
+// image spec.
+func SubjectDigest(rd *ita1.ResourceDescriptor) (digest.Digest, error) {
+	for _, alg := range []digest.Algorithm{digest.SHA256, digest.SHA512} {
+		value, ok := rd.GetDigest()[string(alg)]
+		if !ok {
+			continue
+		}
+
+		d := digest.NewDigestFromEncoded(alg, strings.ToLower(value))
+		if err := d.Validate(); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+		}
+
+		return d, nil
+	}
+
+	return "", ErrUnsupportedDigest
+}
+
+// ResourceDescriptor returns the ResourceDescriptor of the content
+// described by an OCI descriptor, for use as a Statement subject. The name
+// is taken from the org.opencontainers.image.ref.name annotation, if any.
+func ResourceDescriptor(d ocispec.Descriptor) (*ita1.ResourceDescriptor, error) {
+	if err := ValidateDescriptor(d); err != nil {
+		return nil, err
+	}
+
+	return &ita1.ResourceDescriptor{
+		Name:      d.Annotations[ocispec.AnnotationRefName],
+		Digest:    map[string]string{d.Digest.Algorithm().String(): d.Digest.Encoded()},
+		MediaType: d.MediaType,
+	}, nil
+}
+
+// MatchesSubject reports whether a subject ResourceDescriptor has the
+// digest of an OCI descriptor.
+func MatchesSubject(rd *ita1.ResourceDescriptor, d ocispec.Descriptor) bool {
+	value, ok := rd.GetDigest()[d.Digest.Algorithm().String()]
+	return ok && strings.EqualFold(value, d.Digest.Encoded())
+}
+
+// attestationsAbout returns the envelopes of a referrer whose Statement
+// has a subject with the given digest. A referrer's subject is chosen by
+// whoever pushed it, so it is not evidence of what the attestation is
+// about.
+func attestationsAbout(subject digest.Digest, referrer ocispec.Descriptor, envelopes []*dsse.Envelope) []*Attestation {
+	attestations := []*Attestation{}
+	for _, env := range envelopes {
+		s, err := env.Statement()
+		if err != nil {
+			continue
+		}
+
+		for _, rd := range s.GetSubject() {
+			if MatchesSubject(rd, ocispec.Descriptor{Digest: subject}) {
+				attestations = append(attestations, &Attestation{Referrer: referrer, Envelope: env})
+				break
+			}
+		}
+	}
+
+	return attestations
+}
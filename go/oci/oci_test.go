@@ -0,0 +1,299 @@
+/*
+Tests for packaging attestations as OCI artifacts, and helpers shared by the
+OCI tests.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/bundle"
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	testProvenanceType = "https://slsa.dev/provenance/v1"
+	testCustomType     = "https://example.com/pred/v1"
+)
+
+// createTestEnvelope returns a signed envelope about the given image
+// manifests.
+func createTestEnvelope(t *testing.T, predicateType string, signer signature.Signer, subjects ...ocispec.Descriptor) *dsse.Envelope {
+	t.Helper()
+
+	pred, err := structpb.NewStruct(map[string]interface{}{"keyObj": "subVal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		PredicateType: predicateType,
+		Predicate:     pred,
+	}
+	for _, d := range subjects {
+		rd, err := ResourceDescriptor(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Subject = append(s.Subject, rd)
+	}
+
+	env, err := dsse.NewEnvelope(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.Sign(context.Background(), signer); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+// createTestManifest returns an image manifest and its descriptor.
+func createTestManifest(t *testing.T, name string) (ocispec.Descriptor, []byte) {
+	t.Helper()
+
+	layer := []byte(name)
+	m := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{{
+			MediaType: ocispec.MediaTypeImageLayer,
+			Digest:    digest.FromBytes(layer),
+			Size:      int64(len(layer)),
+		}},
+	}
+	m.SchemaVersion = 2
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ocispec.Descriptor{
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Digest:      digest.FromBytes(data),
+		Size:        int64(len(data)),
+		Annotations: map[string]string{ocispec.AnnotationRefName: name},
+	}, data
+}
+
+func writeTestBundle(t *testing.T, envelopes ...*dsse.Envelope) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := bundle.NewWriter(&buf)
+	for _, env := range envelopes {
+		if err := w.WriteEnvelope(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.WriteString("not an attestation\n")
+
+	return buf.Bytes()
+}
+
+func TestEnvelopeMediaType(t *testing.T) {
+	tests := map[string]struct {
+		predicateType     string
+		expectedMediaType string
+	}{
+		"vetted predicate": {
+			predicateType:     testProvenanceType,
+			expectedMediaType: "application/vnd.in-toto.provenance+dsse",
+		},
+		"older version of vetted predicate": {
+			predicateType:     "https://slsa.dev/provenance/v0.2",
+			expectedMediaType: "application/vnd.in-toto.provenance+dsse",
+		},
+		"custom predicate": {
+			predicateType:     testCustomType,
+			expectedMediaType: GenericEnvelopeMediaType,
+		},
+	}
+
+	for name, test := range tests {
+		mt := EnvelopeMediaType(test.predicateType)
+		assert.Equal(t, test.expectedMediaType, mt, fmt.Sprintf("wrong media type in test '%s'", name))
+		assert.True(t, IsAttestationMediaType(mt), fmt.Sprintf("media type not recognized in test '%s'", name))
+	}
+}
+
+func TestIsAttestationMediaType(t *testing.T) {
+	tests := map[string]struct {
+		mediaType string
+		expected  bool
+	}{
+		"bundle":           {mediaType: bundle.MediaType, expected: true},
+		"envelope":         {mediaType: "application/vnd.in-toto.vsa+dsse", expected: true},
+		"generic envelope": {mediaType: GenericEnvelopeMediaType, expected: true},
+		"payload type":     {mediaType: dsse.PayloadType},
+		"empty predicate":  {mediaType: "application/vnd.in-toto.+dsse"},
+		"nested suffix":    {mediaType: "application/vnd.in-toto.vsa+json+dsse"},
+		"image manifest":   {mediaType: ocispec.MediaTypeImageManifest},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.expected, IsAttestationMediaType(test.mediaType), fmt.Sprintf("wrong result in test '%s'", name))
+	}
+}
+
+func TestNewEnvelopeArtifact(t *testing.T) {
+	subject, _ := createTestManifest(t, "fooly")
+	env := createTestEnvelope(t, testProvenanceType, testutil.NewSigner(t), subject)
+
+	a, err := NewEnvelopeArtifact(subject, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.in-toto.provenance+dsse", a.Descriptor.ArtifactType)
+	assert.Equal(t, testProvenanceType, a.Descriptor.Annotations[AnnotationPredicateType])
+	assert.NoError(t, VerifyContent(a.Descriptor, a.Manifest))
+
+	m, layer, err := ParseArtifactManifest(a.Manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Layer, layer)
+	assert.Equal(t, ocispec.DescriptorEmptyJSON.Digest, m.Config.Digest)
+	assert.Equal(t, subject.Digest, m.Subject.Digest)
+	assert.Equal(t, subject.Size, m.Subject.Size)
+	assert.Nil(t, m.Subject.Annotations)
+
+	envelopes, err := ReadAttestations(layer, a.Blob)
+	assert.NoError(t, err)
+	assert.Equal(t, []*dsse.Envelope{env}, envelopes)
+
+	// packaging is deterministic, so that re-exports are de-duplicated
+	again, err := NewEnvelopeArtifact(subject, env)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Descriptor.Digest, again.Descriptor.Digest)
+}
+
+func TestNewArtifactErrors(t *testing.T) {
+	subject, _ := createTestManifest(t, "fooly")
+	signer := testutil.NewSigner(t)
+	env := createTestEnvelope(t, testProvenanceType, signer, subject)
+	unsigned := *env
+	unsigned.Signatures = nil
+
+	_, err := NewEnvelopeArtifact(subject, nil)
+	assert.ErrorIs(t, err, dsse.ErrEnvelopeRequired)
+
+	_, err = NewEnvelopeArtifact(subject, &unsigned)
+	assert.ErrorIs(t, err, dsse.ErrSignaturesRequired)
+
+	_, err = NewEnvelopeArtifact(ocispec.Descriptor{Digest: subject.Digest, Size: subject.Size}, env)
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	_, err = NewEnvelopeArtifact(ocispec.Descriptor{MediaType: subject.MediaType, Digest: "sha256:1234", Size: subject.Size}, env)
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	_, err = NewBundleArtifact(subject, []byte("not an attestation\n"))
+	assert.ErrorIs(t, err, ErrNoAttestations)
+}
+
+func TestReadAttestations(t *testing.T) {
+	subject, _ := createTestManifest(t, "fooly")
+	signer := testutil.NewSigner(t)
+	prov := createTestEnvelope(t, testProvenanceType, signer, subject)
+	custom := createTestEnvelope(t, testCustomType, signer, subject)
+
+	a, err := NewBundleArtifact(subject, writeTestBundle(t, prov, custom))
+	assert.NoError(t, err)
+	assert.Equal(t, bundle.MediaType, a.Descriptor.ArtifactType)
+
+	envelopes, err := ReadAttestations(a.Layer, a.Blob)
+	assert.NoError(t, err)
+	assert.Equal(t, []*dsse.Envelope{prov, custom}, envelopes)
+
+	tampered := bytes.Replace(a.Blob, []byte("not an"), []byte("not my"), 1)
+	_, err = ReadAttestations(a.Layer, tampered)
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+
+	_, err = ReadAttestations(a.Layer, a.Blob[1:])
+	assert.ErrorIs(t, err, ErrDigestMismatch)
+
+	other := a.Layer
+	other.MediaType = ocispec.MediaTypeImageLayer
+	_, err = ReadAttestations(other, a.Blob)
+	assert.ErrorIs(t, err, ErrNotAttestation)
+}
+
+func TestSubjectDigest(t *testing.T) {
+	sha256Hex := strings.Repeat("ab", 32)
+	sha512Hex := strings.Repeat("cd", 64)
+
+	tests := map[string]struct {
+		digest         map[string]string
+		expectedDigest digest.Digest
+		expectedErr    error
+	}{
+		"sha256": {
+			digest:         map[string]string{"sha256": sha256Hex},
+			expectedDigest: digest.Digest("sha256:" + sha256Hex),
+		},
+		"sha256 preferred": {
+			digest:         map[string]string{"sha512": sha512Hex, "sha256": sha256Hex},
+			expectedDigest: digest.Digest("sha256:" + sha256Hex),
+		},
+		"sha512": {
+			digest:         map[string]string{"sha512": sha512Hex, "sha1": strings.Repeat("ef", 20)},
+			expectedDigest: digest.Digest("sha512:" + sha512Hex),
+		},
+		"uppercase hex": {
+			digest:         map[string]string{"sha256": strings.ToUpper(sha256Hex)},
+			expectedDigest: digest.Digest("sha256:" + sha256Hex),
+		},
+		"unsupported algorithm": {
+			digest:      map[string]string{"gitCommit": strings.Repeat("ef", 20)},
+			expectedErr: ErrUnsupportedDigest,
+		},
+		"truncated digest": {
+			digest:      map[string]string{"sha256": "abcd"},
+			expectedErr: ErrInvalidDescriptor,
+		},
+	}
+
+	for name, test := range tests {
+		d, err := SubjectDigest(&ita1.ResourceDescriptor{Digest: test.digest})
+		if test.expectedErr != nil {
+			assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		} else {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+			assert.Equal(t, test.expectedDigest, d, fmt.Sprintf("wrong digest in test '%s'", name))
+		}
+	}
+}
+
+func TestResourceDescriptor(t *testing.T) {
+	d, _ := createTestManifest(t, "fooly")
+
+	rd, err := ResourceDescriptor(d)
+	assert.NoError(t, err)
+	assert.NoError(t, rd.Validate())
+	assert.Equal(t, "fooly", rd.GetName())
+	assert.Equal(t, ocispec.MediaTypeImageManifest, rd.GetMediaType())
+	assert.True(t, MatchesSubject(rd, d))
+
+	got, err := SubjectDigest(rd)
+	assert.NoError(t, err)
+	assert.Equal(t, d.Digest, got)
+
+	other, _ := createTestManifest(t, "barly")
+	assert.False(t, MatchesSubject(rd, other))
+
+	_, err = ResourceDescriptor(ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest})
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+}
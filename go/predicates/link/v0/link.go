@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for in-toto Link v0.3 predicate protos.
+*/
+
+package v0
+
+const PredicateTypeUri = "https://in-toto.io/attestation/link/"
+const PredicateVersion = "v0.3"
@@ -0,0 +1,237 @@
+/*
+Registry of the vetted predicate specifications in spec/predicates, mapping
+each spec's file name to its predicate type URIs.
+*/
+
+package predicates
+
+import (
+	"strconv"
+	"strings"
+
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	provenancev01 "github.com/in-toto/attestation/go/predicates/provenance/v01"
+	provenancev02 "github.com/in-toto/attestation/go/predicates/provenance/v02"
+	provenancev1 "github.com/in-toto/attestation/go/predicates/provenance/v1"
+	referencev0 "github.com/in-toto/attestation/go/predicates/reference/v0"
+	releasev0 "github.com/in-toto/attestation/go/predicates/release/v0"
+	releasev02 "github.com/in-toto/attestation/go/predicates/release/v02"
+	scaiv0 "github.com/in-toto/attestation/go/predicates/scai/v0"
+	svrv01 "github.com/in-toto/attestation/go/predicates/svr/v01"
+	testresultv0 "github.com/in-toto/attestation/go/predicates/test_result/v0"
+	vsav0 "github.com/in-toto/attestation/go/predicates/vsa/v0"
+	vsav1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	vulnsv01 "github.com/in-toto/attestation/go/predicates/vulns/v01"
+	vulnsv02 "github.com/in-toto/attestation/go/predicates/vulns/v02"
+)
+
+// Spec describes a vetted predicate specification.
+type Spec struct {
+	// Name is the predicate specification file name without its extension,
+	// as used for <predicate> in application/vnd.in-toto.<predicate>+json.
+	Name string
+	// TypeUri is the versionless predicate type URI. Versioned predicate
+	// types are TypeUri followed by "/" and the version.
+	TypeUri string
+	// DeprecatedTypeUris are versionless type URIs the predicate was
+	// previously published under.
+	DeprecatedTypeUris []string
+	// KnownTypes are the versioned predicate types with generated Go
+	// bindings in this module.
+	KnownTypes []string
+}
+
+var specs = []Spec{
+	{
+		Name:    "cyclonedx",
+		TypeUri: "https://cyclonedx.org/bom",
+	},
+	{
+		Name:               "link",
+		TypeUri:            versionless(linkv0.PredicateTypeUri),
+		DeprecatedTypeUris: []string{"https://in-toto.io/Link"},
+		KnownTypes:         []string{linkv0.PredicateTypeUri + linkv0.PredicateVersion},
+	},
+	{
+		Name:               "provenance",
+		TypeUri:            versionless(provenancev1.PredicateTypeUri),
+		DeprecatedTypeUris: []string{"https://in-toto.io/Provenance"},
+		KnownTypes: []string{
+			provenancev01.PredicateTypeUri + provenancev01.PredicateVersion,
+			provenancev02.PredicateTypeUri + provenancev02.PredicateVersion,
+			provenancev1.PredicateTypeUri + provenancev1.PredicateVersion,
+		},
+	},
+	{
+		Name:       "reference",
+		TypeUri:    versionless(referencev0.PredicateTypeUri),
+		KnownTypes: []string{referencev0.PredicateTypeUri + referencev0.PredicateVersion},
+	},
+	{
+		Name:    "release",
+		TypeUri: versionless(releasev02.PredicateTypeUri),
+		KnownTypes: []string{
+			releasev0.PredicateTypeUri + releasev0.PredicateVersion,
+			releasev02.PredicateTypeUri + releasev02.PredicateVersion,
+		},
+	},
+	{
+		Name:    "runtime-trace",
+		TypeUri: "https://in-toto.io/attestation/runtime-trace",
+	},
+	{
+		Name:       "scai",
+		TypeUri:    versionless(scaiv0.PredicateTypeUri),
+		KnownTypes: []string{scaiv0.PredicateTypeUri + scaiv0.PredicateVersion},
+	},
+	{
+		Name:    "spdx",
+		TypeUri: "https://spdx.dev/Document",
+	},
+	{
+		Name:       "svr",
+		TypeUri:    versionless(svrv01.PredicateTypeUri),
+		KnownTypes: []string{svrv01.PredicateTypeUri + svrv01.PredicateVersion},
+	},
+	{
+		Name:       "test-result",
+		TypeUri:    versionless(testresultv0.PredicateTypeUri),
+		KnownTypes: []string{testresultv0.PredicateTypeUri + testresultv0.PredicateVersion},
+	},
+	{
+		Name:    "vsa",
+		TypeUri: versionless(vsav1.PredicateTypeUri),
+		KnownTypes: []string{
+			vsav0.PredicateTypeUri + vsav0.PredicateVersion,
+			vsav1.PredicateTypeUri + vsav1.PredicateVersion,
+		},
+	},
+	{
+		Name:    "vuln",
+		TypeUri: versionless(vulnsv02.PredicateTypeUri),
+		KnownTypes: []string{
+			vulnsv01.PredicateTypeUri + vulnsv01.PredicateVersion,
+			vulnsv02.PredicateTypeUri + vulnsv02.PredicateVersion,
+		},
+	},
+}
+
+func versionless(typeUri string) string {
+	return strings.TrimSuffix(typeUri, "/")
+}
+
+// Specs returns all vetted predicate specifications, sorted by name.
+func Specs() []Spec {
+	out := make([]Spec, len(specs))
+	copy(out, specs)
+
+	return out
+}
+
+// SpecByName returns the predicate specification with the given file name.
+func SpecByName(name string) (Spec, bool) {
+	for _, s := range specs {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Spec{}, false
+}
+
+// SpecForPredicateType returns the predicate specification a predicate type
+// URI belongs to, matching any version of the predicate, including
+// deprecated type URIs.
+func SpecForPredicateType(predicateType string) (Spec, bool) {
+	for _, s := range specs {
+		if s.Matches(predicateType) {
+			return s, true
+		}
+	}
+
+	return Spec{}, false
+}
+
+// Matches indicates if a predicate type URI is the versionless type URI of
+// the spec, or any version of it.
+func (s Spec) Matches(predicateType string) bool {
+	if matchesTypeUri(s.TypeUri, predicateType) {
+		return true
+	}
+
+	for _, uri := range s.DeprecatedTypeUris {
+		if matchesTypeUri(uri, predicateType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsKnownType indicates if a predicate type URI is a version of the
+// predicate with generated Go bindings.
+func (s Spec) IsKnownType(predicateType string) bool {
+	for _, t := range s.KnownTypes {
+		if t == predicateType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesTypeUri(typeUri, predicateType string) bool {
+	return predicateType == typeUri || strings.HasPrefix(predicateType, typeUri+"/")
+}
+
+// MatchesType indicates if a predicate type satisfies a query, taking
+// versioning into account as described in spec/versioning.md:
+//
+//   - a versionless query, such as https://slsa.dev/provenance, matches any
+//     version of the predicate,
+//   - a versioned query, such as https://slsa.dev/provenance/v1, matches any
+//     version with the same major version, such as .../v1 or .../v1.1. 0.X
+//     versions are major versions, so .../v0.2 does not match .../v0.3.
+//
+// Versions are the last path segment of the type URI, of the form v<N> or
+// v<N>.<M>[.<P>]. Type URIs that do not follow this convention only match
+// themselves and their versions.
+func MatchesType(query, predicateType string) bool {
+	if query == "" {
+		return false
+	}
+
+	base, major, ok := splitVersion(query)
+	if !ok {
+		return matchesTypeUri(query, predicateType)
+	}
+
+	typeBase, typeMajor, ok := splitVersion(predicateType)
+	return ok && typeBase == base && typeMajor == major
+}
+
+// splitVersion splits a versioned type URI into its versionless type URI and
+// its major version.
+func splitVersion(typeUri string) (string, string, bool) {
+	i := strings.LastIndex(typeUri, "/")
+	if i < 0 || !strings.HasPrefix(typeUri[i+1:], "v") {
+		return "", "", false
+	}
+
+	parts := strings.Split(typeUri[i+2:], ".")
+	nums := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || strings.HasPrefix(p, "+") {
+			return "", "", false
+		}
+		nums = append(nums, n)
+	}
+
+	major := strconv.Itoa(nums[0])
+	if nums[0] == 0 && len(nums) > 1 {
+		major += "." + strconv.Itoa(nums[1])
+	}
+
+	return typeUri[:i], major, true
+}
@@ -0,0 +1,99 @@
+/*
+Tests for the predicate specification registry.
+*/
+
+package predicates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecForPredicateType(t *testing.T) {
+	tests := map[string]string{
+		"https://slsa.dev/provenance/v1":                    "provenance",
+		"https://slsa.dev/provenance/v0.2":                  "provenance",
+		"https://in-toto.io/Provenance/v0.1":                "provenance",
+		"https://in-toto.io/Link/v1":                        "link",
+		"https://in-toto.io/attestation/link/v0.3":          "link",
+		"https://slsa.dev/verification_summary/v1":          "vsa",
+		"https://in-toto.io/attestation/test-result/v0.1":   "test-result",
+		"https://in-toto.io/attestation/vulns/v0.2":         "vuln",
+		"https://in-toto.io/attestation/runtime-trace/v0.1": "runtime-trace",
+		"https://spdx.dev/Document/v2.3":                    "spdx",
+		"https://cyclonedx.org/bom":                         "cyclonedx",
+	}
+
+	for predicateType, want := range tests {
+		got, ok := SpecForPredicateType(predicateType)
+		if assert.True(t, ok, fmt.Sprintf("no spec for '%s'", predicateType)) {
+			assert.Equal(t, want, got.Name, fmt.Sprintf("wrong spec for '%s'", predicateType))
+		}
+	}
+
+	for _, predicateType := range []string{
+		"https://example.com/thePredicate/v1",
+		"https://slsa.dev/provenancev1",
+		"https://in-toto.io/attestation/vulnsv0.2",
+		"",
+	} {
+		_, ok := SpecForPredicateType(predicateType)
+		assert.False(t, ok, fmt.Sprintf("matched unknown predicate type '%s'", predicateType))
+	}
+}
+
+func TestKnownTypes(t *testing.T) {
+	spec, ok := SpecByName("provenance")
+	assert.True(t, ok)
+	assert.True(t, spec.IsKnownType("https://slsa.dev/provenance/v1"))
+	assert.False(t, spec.IsKnownType("https://slsa.dev/provenance/v2"))
+
+	for _, s := range Specs() {
+		for _, typ := range s.KnownTypes {
+			assert.True(t, s.Matches(typ), fmt.Sprintf("spec '%s' does not match its known type '%s'", s.Name, typ))
+		}
+	}
+
+	_, ok = SpecByName("notAPredicate")
+	assert.False(t, ok)
+}
+
+func TestSpecNamesMatchSpecFiles(t *testing.T) {
+	for _, s := range Specs() {
+		_, err := os.Stat(filepath.Join("..", "..", "spec", "predicates", s.Name+".md"))
+		assert.NoError(t, err, fmt.Sprintf("no spec file for predicate '%s'", s.Name))
+	}
+}
+
+func TestMatchesType(t *testing.T) {
+	tests := map[string]struct {
+		query         string
+		predicateType string
+		expected      bool
+	}{
+		"same version":        {"https://slsa.dev/provenance/v1", "https://slsa.dev/provenance/v1", true},
+		"minor version":       {"https://slsa.dev/provenance/v1", "https://slsa.dev/provenance/v1.1", true},
+		"minor version query": {"https://slsa.dev/provenance/v1.1", "https://slsa.dev/provenance/v1", true},
+		"other major version": {"https://slsa.dev/provenance/v1", "https://slsa.dev/provenance/v2", false},
+		"0.x major versions":  {"https://slsa.dev/provenance/v0.2", "https://slsa.dev/provenance/v0.3", false},
+		"0.x patch version":   {"https://in-toto.io/attestation/link/v0.3", "https://in-toto.io/attestation/link/v0.3.1", true},
+		"leading zeros":       {"https://slsa.dev/provenance/v01", "https://slsa.dev/provenance/v1.0", true},
+		"versionless query":   {"https://slsa.dev/provenance", "https://slsa.dev/provenance/v0.2", true},
+		"versionless exact":   {"https://cyclonedx.org/bom", "https://cyclonedx.org/bom", true},
+		"versionless prefix":  {"https://slsa.dev/provenance", "https://slsa.dev/provenancev1", false},
+		"other type":          {"https://slsa.dev/provenance/v1", "https://slsa.dev/verification_summary/v1", false},
+		"unversioned type":    {"https://slsa.dev/provenance/v1", "https://slsa.dev/provenance", false},
+		"non-numeric version": {"https://example.com/pred/vNext", "https://example.com/pred/vNext", true},
+		"non-numeric other":   {"https://example.com/pred/vNext", "https://example.com/pred/vNext2", false},
+		"empty query":         {"", "https://slsa.dev/provenance/v1", false},
+	}
+
+	for name, test := range tests {
+		got := MatchesType(test.query, test.predicateType)
+		assert.Equal(t, test.expected, got, fmt.Sprintf("wrong match in test '%s'", name))
+	}
+}
@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for SLSA Provenance v0.1 protos.
+*/
+
+package v01
+
+const PredicateTypeUri = "https://slsa.dev/provenance/"
+const PredicateVersion = "v0.1"
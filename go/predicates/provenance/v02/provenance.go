@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for SLSA Provenance v0.2 protos.
+*/
+
+package v02
+
+const PredicateTypeUri = "https://slsa.dev/provenance/"
+const PredicateVersion = "v0.2"
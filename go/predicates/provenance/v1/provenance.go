@@ -11,6 +11,9 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
+const PredicateTypeUri = "https://slsa.dev/provenance/"
+const PredicateVersion = "v1"
+
 // all of the following errors apply to SLSA Build L1 and above
 var (
 	ErrBuilderRequired         = errors.New("runDetails.builder required")
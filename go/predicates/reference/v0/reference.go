@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for in-toto Reference v0.1 predicate protos.
+*/
+
+package v0
+
+const PredicateTypeUri = "https://in-toto.io/attestation/reference/"
+const PredicateVersion = "v0.1"
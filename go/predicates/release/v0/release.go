@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for in-toto Release v0.1 predicate protos.
+*/
+
+package v0
+
+const PredicateTypeUri = "https://in-toto.io/attestation/release/"
+const PredicateVersion = "v0.1"
@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for Simple Verification Result v0.1 protos.
+*/
+
+package v01
+
+const PredicateTypeUri = "https://in-toto.io/attestation/svr/"
+const PredicateVersion = "v0.1"
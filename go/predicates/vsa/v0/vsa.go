@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for SLSA Verification Summary v0.2 protos.
+*/
+
+package v0
+
+const PredicateTypeUri = "https://slsa.dev/verification_summary/"
+const PredicateVersion = "v0.2"
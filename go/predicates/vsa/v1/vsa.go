@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for SLSA Verification Summary v1 protos.
+*/
+
+package v1
+
+const PredicateTypeUri = "https://slsa.dev/verification_summary/"
+const PredicateVersion = "v1"
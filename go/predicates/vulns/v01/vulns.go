@@ -0,0 +1,8 @@
+/*
+Wrapper APIs for in-toto Vulnerabilities v0.1 predicate protos.
+*/
+
+package v01
+
+const PredicateTypeUri = "https://in-toto.io/attestation/vulns/"
+const PredicateVersion = "v0.1"
@@ -0,0 +1,112 @@
+/*
+Signer and Verifier implementation for ECDSA keys on the NIST P-256 and
+P-384 curves.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+)
+
+// ECDSASignerVerifier signs and verifies ASN.1 DER-encoded ECDSA signatures.
+type ECDSASignerVerifier struct {
+	keyID string
+	hash  crypto.Hash
+	priv  *ecdsa.PrivateKey
+	pub   *ecdsa.PublicKey
+}
+
+// curveHash returns the hash function conventionally paired with a curve,
+// e.g. ecdsa-sha2-nistp256.
+func curveHash(curve elliptic.Curve) (crypto.Hash, error) {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256, nil
+	case elliptic.P384():
+		return crypto.SHA384, nil
+	default:
+		return 0, fmt.Errorf("%w: ECDSA curve %s", ErrUnsupportedKey, curve.Params().Name)
+	}
+}
+
+// NewECDSASignerVerifier creates a SignerVerifier from a P-256 or P-384
+// private key. Messages are hashed with SHA-256 or SHA-384 respectively,
+// unless overridden with WithHash.
+func NewECDSASignerVerifier(priv *ecdsa.PrivateKey, opts ...Option) (*ECDSASignerVerifier, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("%w: nil ECDSA private key", ErrUnsupportedKey)
+	}
+
+	sv, err := NewECDSAVerifier(&priv.PublicKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sv.priv = priv
+
+	return sv, nil
+}
+
+// NewECDSAVerifier creates a verify-only ECDSASignerVerifier from a P-256
+// or P-384 public key.
+func NewECDSAVerifier(pub *ecdsa.PublicKey, opts ...Option) (*ECDSASignerVerifier, error) {
+	if pub == nil {
+		return nil, fmt.Errorf("%w: nil ECDSA public key", ErrUnsupportedKey)
+	}
+
+	h, err := curveHash(pub.Curve)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := applyOptions(pub, h, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ECDSASignerVerifier{keyID: o.keyID, hash: o.hash, pub: pub}, nil
+}
+
+func (sv *ECDSASignerVerifier) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	if sv.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return ecdsa.SignASN1(rand.Reader, sv.priv, d)
+}
+
+func (sv *ECDSASignerVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return err
+	}
+
+	if !ecdsa.VerifyASN1(sv.pub, d, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (sv *ECDSASignerVerifier) KeyID() (string, error) {
+	return sv.keyID, nil
+}
+
+func (sv *ECDSASignerVerifier) Public() crypto.PublicKey {
+	return sv.pub
+}
+
+// Hash returns the hash function used to digest messages.
+func (sv *ECDSASignerVerifier) Hash() crypto.Hash {
+	return sv.hash
+}
@@ -0,0 +1,82 @@
+/*
+Signer and Verifier implementation for ed25519 keys.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+)
+
+// ED25519SignerVerifier signs and verifies pure ed25519 signatures.
+type ED25519SignerVerifier struct {
+	keyID string
+	priv  ed25519.PrivateKey
+	pub   ed25519.PublicKey
+}
+
+// NewED25519SignerVerifier creates a SignerVerifier from an ed25519 private
+// key.
+func NewED25519SignerVerifier(priv ed25519.PrivateKey, opts ...Option) (*ED25519SignerVerifier, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: bad ed25519 private key length %d", ErrUnsupportedKey, len(priv))
+	}
+
+	sv, err := NewED25519Verifier(priv.Public().(ed25519.PublicKey), opts...)
+	if err != nil {
+		return nil, err
+	}
+	sv.priv = priv
+
+	return sv, nil
+}
+
+// NewED25519Verifier creates a verify-only ED25519SignerVerifier from an
+// ed25519 public key.
+func NewED25519Verifier(pub ed25519.PublicKey, opts ...Option) (*ED25519SignerVerifier, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: bad ed25519 public key length %d", ErrUnsupportedKey, len(pub))
+	}
+
+	o, err := applyOptions(pub, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ED25519SignerVerifier{keyID: o.keyID, pub: pub}, nil
+}
+
+func (sv *ED25519SignerVerifier) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	if sv.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ed25519.Sign(sv.priv, data), nil
+}
+
+func (sv *ED25519SignerVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(sv.pub, data, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (sv *ED25519SignerVerifier) KeyID() (string, error) {
+	return sv.keyID, nil
+}
+
+func (sv *ED25519SignerVerifier) Public() crypto.PublicKey {
+	return sv.pub
+}
@@ -0,0 +1,303 @@
+/*
+Loader for JSON Web Keys (RFC 7517, RFC 7518 and RFC 8037).
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrInvalidJWK = errors.New("invalid JSON Web Key")
+
+// JWK is a JSON Web Key holding an ed25519, ECDSA or RSA key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+
+	// public parameters
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// private parameters
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	Dp string `json:"dp,omitempty"`
+	Dq string `json:"dq,omitempty"`
+	Qi string `json:"qi,omitempty"`
+}
+
+// ParseJWK decodes a JSON Web Key.
+func ParseJWK(data []byte) (*JWK, error) {
+	jwk := &JWK{}
+	if err := json.Unmarshal(data, jwk); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJWK, err)
+	}
+
+	return jwk, nil
+}
+
+// IsPrivate indicates if the JWK holds private key material.
+func (jwk *JWK) IsPrivate() bool {
+	return jwk.D != ""
+}
+
+// PublicKey returns the public key held in the JWK.
+func (jwk *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: OKP curve %q", ErrUnsupportedKey, jwk.Crv)
+		}
+		x, err := jwkBytes("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad ed25519 public key length %d", ErrInvalidJWK, len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("%w: EC curve %q", ErrUnsupportedKey, jwk.Crv)
+		}
+		x, err := jwkInt("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := jwkInt("y", jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJWK, err)
+		}
+		return pub, nil
+	case "RSA":
+		n, err := jwkInt("n", jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := jwkInt("e", jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: bad RSA exponent", ErrInvalidJWK)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	default:
+		return nil, fmt.Errorf("%w: kty %q", ErrUnsupportedKey, jwk.Kty)
+	}
+}
+
+// PrivateKey returns the private key held in the JWK.
+func (jwk *JWK) PrivateKey() (crypto.PrivateKey, error) {
+	if !jwk.IsPrivate() {
+		return nil, ErrNoPrivateKey
+	}
+
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		seed, err := jwkBytes("d", jwk.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: bad ed25519 private key length %d", ErrInvalidJWK, len(seed))
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		if !pub.Equal(priv.Public()) {
+			return nil, fmt.Errorf("%w: private key does not match public key", ErrInvalidJWK)
+		}
+		return priv, nil
+	case *ecdsa.PublicKey:
+		d, err := jwkInt("d", jwk.D)
+		if err != nil {
+			return nil, err
+		}
+		priv := &ecdsa.PrivateKey{PublicKey: *pub, D: d}
+		ecdhPriv, err := priv.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJWK, err)
+		}
+		ecdhPub, err := pub.ECDH()
+		if err != nil || !ecdhPriv.PublicKey().Equal(ecdhPub) {
+			return nil, fmt.Errorf("%w: private key does not match public key", ErrInvalidJWK)
+		}
+		return priv, nil
+	case *rsa.PublicKey:
+		priv := &rsa.PrivateKey{PublicKey: *pub}
+		if priv.D, err = jwkInt("d", jwk.D); err != nil {
+			return nil, err
+		}
+		p, err := jwkInt("p", jwk.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := jwkInt("q", jwk.Q)
+		if err != nil {
+			return nil, err
+		}
+		priv.Primes = []*big.Int{p, q}
+		if err := priv.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJWK, err)
+		}
+		priv.Precompute()
+		return priv, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+}
+
+// options returns the Options implied by the JWK's kid and alg parameters.
+// The alg must be one for the JWK's kty and crv, lest, e.g., ES384 sign
+// with a P-256 key and SHA-256.
+func (jwk *JWK) options() ([]Option, error) {
+	var opts []Option
+	if jwk.Kid != "" {
+		opts = append(opts, WithKeyID(jwk.Kid))
+	}
+
+	if jwk.Alg == "" {
+		return opts, nil
+	}
+
+	var kty, crv string
+	var hash crypto.Hash
+	switch jwk.Alg {
+	case "EdDSA":
+		kty, crv = "OKP", "Ed25519"
+	case "ES256":
+		kty, crv = "EC", "P-256"
+	case "ES384":
+		kty, crv = "EC", "P-384"
+	case "RS256", "PS256":
+		kty = "RSA"
+	case "RS384", "PS384":
+		kty, hash = "RSA", crypto.SHA384
+	case "RS512", "PS512":
+		kty, hash = "RSA", crypto.SHA512
+	default:
+		return nil, fmt.Errorf("%w: alg %q", ErrUnsupportedKey, jwk.Alg)
+	}
+
+	if jwk.Kty != kty || (crv != "" && jwk.Crv != crv) {
+		return nil, fmt.Errorf("%w: alg %q cannot be used with kty %q and crv %q", ErrInvalidJWK, jwk.Alg, jwk.Kty, jwk.Crv)
+	}
+
+	if hash != 0 {
+		opts = append(opts, WithHash(hash))
+	}
+
+	return opts, nil
+}
+
+// isPKCS1v15 indicates if the JWK's alg selects RSASSA-PKCS1-v1_5.
+func (jwk *JWK) isPKCS1v15() bool {
+	return jwk.Alg == "RS256" || jwk.Alg == "RS384" || jwk.Alg == "RS512"
+}
+
+// LoadSignerJWK creates a SignerVerifier from a private JSON Web Key. The
+// key's kid, if any, is used as its KEYID, and its alg selects the RSA
+// signature scheme and hash function.
+func LoadSignerJWK(data []byte, opts ...Option) (SignerVerifier, error) {
+	jwk, err := ParseJWK(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwk.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	jwkOpts, err := jwk.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(jwkOpts, opts...)
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok && jwk.isPKCS1v15() {
+		return NewRSAPKCS1v15SignerVerifier(rsaKey, opts...)
+	}
+
+	return NewSignerVerifier(key, opts...)
+}
+
+// LoadVerifierJWK creates a Verifier from a public (or private) JSON Web
+// Key. The key's kid, if any, is used as its KEYID, and its alg selects the
+// RSA signature scheme and hash function.
+func LoadVerifierJWK(data []byte, opts ...Option) (Verifier, error) {
+	jwk, err := ParseJWK(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	jwkOpts, err := jwk.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(jwkOpts, opts...)
+
+	if rsaKey, ok := key.(*rsa.PublicKey); ok && jwk.isPKCS1v15() {
+		return NewRSAPKCS1v15Verifier(rsaKey, opts...)
+	}
+
+	return NewVerifier(key, opts...)
+}
+
+// jwkBytes decodes a base64url-encoded (unpadded) JWK parameter.
+func jwkBytes(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing %q", ErrInvalidJWK, name)
+	}
+
+	b, err := base64.RawURLEncoding.Strict().DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad %q: %v", ErrInvalidJWK, name, err)
+	}
+
+	return b, nil
+}
+
+func jwkInt(name, value string) (*big.Int, error) {
+	b, err := jwkBytes(name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,141 @@
+/*
+KEYID derivation compatible with in-toto and TUF key metadata.
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/in-toto/attestation/go/internal/cjson"
+)
+
+// Key types and signing schemes, as named in in-toto and TUF key metadata.
+const (
+	KeyTypeED25519 = "ed25519"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeRSA     = "rsa"
+
+	SchemeED25519        = "ed25519"
+	SchemeECDSAP256      = "ecdsa-sha2-nistp256"
+	SchemeECDSAP384      = "ecdsa-sha2-nistp384"
+	SchemeRSAPSSSHA256   = "rsassa-pss-sha256"
+	SchemeRSAPKCS1SHA256 = "rsa-pkcs1v15-sha256"
+)
+
+// keyIDHashAlgorithms is part of the hashed key metadata for compatibility
+// with securesystemslib and in-toto-golang, even though only SHA-256 is used.
+var keyIDHashAlgorithms = []string{"sha256", "sha512"}
+
+// KeyMetadata is the public part of a key in in-toto and TUF metadata.
+type KeyMetadata struct {
+	KeyIDHashAlgorithms []string          `json:"keyid_hash_algorithms"`
+	KeyType             string            `json:"keytype"`
+	KeyVal              map[string]string `json:"keyval"`
+	Scheme              string            `json:"scheme"`
+}
+
+// NewKeyMetadata describes a public key for use with the given scheme.
+// ed25519 keys are hex-encoded and all other keys are PEM-encoded, following
+// securesystemslib.
+func NewKeyMetadata(pub crypto.PublicKey, scheme string) (*KeyMetadata, error) {
+	var keyType, public string
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		keyType = KeyTypeED25519
+		public = hex.EncodeToString(k)
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		if _, ok := k.(*ecdsa.PublicKey); ok {
+			keyType = KeyTypeECDSA
+		} else {
+			keyType = KeyTypeRSA
+		}
+		pemBytes, err := MarshalPEMPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		public = strings.TrimSpace(string(pemBytes))
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+
+	return &KeyMetadata{
+		KeyIDHashAlgorithms: keyIDHashAlgorithms,
+		KeyType:             keyType,
+		KeyVal:              map[string]string{"public": public},
+		Scheme:              scheme,
+	}, nil
+}
+
+// KeyID returns the KEYID of the key: the lowercase hex SHA-256 of its
+// canonical JSON encoding.
+func (m *KeyMetadata) KeyID() (string, error) {
+	data, err := cjson.Encode(m)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DefaultScheme returns the scheme used by default for a public key:
+// ed25519, ECDSA with the curve's conventional hash, or RSASSA-PSS with
+// SHA-256.
+func DefaultScheme(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return SchemeED25519, nil
+	case *ecdsa.PublicKey:
+		return ecdsaScheme(k)
+	case *rsa.PublicKey:
+		return SchemeRSAPSSSHA256, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+}
+
+// KeyID computes the in-toto/TUF KEYID of a public key under its default
+// scheme.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	scheme, err := DefaultScheme(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return KeyIDForScheme(pub, scheme)
+}
+
+// KeyIDForScheme computes the in-toto/TUF KEYID of a public key used with
+// the given scheme. The scheme is part of the hashed metadata, so the same
+// key has different KEYIDs under different schemes.
+func KeyIDForScheme(pub crypto.PublicKey, scheme string) (string, error) {
+	m, err := NewKeyMetadata(pub, scheme)
+	if err != nil {
+		return "", err
+	}
+
+	return m.KeyID()
+}
+
+func ecdsaScheme(pub *ecdsa.PublicKey) (string, error) {
+	h, err := curveHash(pub.Curve)
+	if err != nil {
+		return "", err
+	}
+
+	// securesystemslib names ECDSA schemes by curve, assuming the
+	// conventional hash
+	if h == crypto.SHA256 {
+		return SchemeECDSAP256, nil
+	}
+
+	return SchemeECDSAP384, nil
+}
@@ -0,0 +1,111 @@
+/*
+Tests for in-toto/TUF KEYID derivation.
+*/
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestKey(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestKeyIDED25519(t *testing.T) {
+	// RFC 8032 test vector 1 public key
+	pub, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	const want = "01e8764eedab63b3593451765fc337b2cc6d2c66923845da255f448c19e2afc5"
+
+	got, err := KeyID(ed25519.PublicKey(pub))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	sv, err := NewED25519Verifier(ed25519.PublicKey(pub))
+	assert.NoError(t, err)
+	keyID, _ := sv.KeyID()
+	assert.Equal(t, want, keyID, "verifier does not use the in-toto KEYID by default")
+}
+
+func TestKeyIDPEMKeys(t *testing.T) {
+	tests := map[string]struct {
+		key    string
+		scheme string
+		keyID  string
+	}{
+		"bob": {
+			key:    "bob.pub",
+			scheme: SchemeRSAPSSSHA256,
+			keyID:  "d3ffd1086938b3698618adf088bf14b13db4c8ae19e4e78d73da49ee88492710",
+		},
+		"dan": {
+			key:    "dan.pub",
+			scheme: SchemeRSAPSSSHA256,
+			keyID:  "b7d643dec0a051096ee5d87221b5d91a33daa658699d30903e1cefb90c418401",
+		},
+		"bob pkcs1v15": {
+			key:    "bob.pub",
+			scheme: SchemeRSAPKCS1SHA256,
+			keyID:  "91560424b4fd83ae98356cc9345deded18eab2562d4fce3330fbbcf4b262a27f",
+		},
+		"ecdsa": {
+			key:    "ecdsa.pub",
+			scheme: SchemeECDSAP256,
+			keyID:  "5f7bd3bc5a3858ac9dbacceee2d903fe99f8f4636db4c5d2cb5147075ba494ae",
+		},
+	}
+
+	for name, test := range tests {
+		pub, err := ParsePEMPublicKey(readTestKey(t, test.key))
+		if !assert.NoError(t, err, fmt.Sprintf("error loading key in test '%s'", name)) {
+			continue
+		}
+
+		got, err := KeyIDForScheme(pub, test.scheme)
+		assert.NoError(t, err)
+		assert.Equal(t, test.keyID, got, fmt.Sprintf("wrong keyid in test '%s'", name))
+	}
+}
+
+func TestKeyIDDefault(t *testing.T) {
+	const bobKeyID = "d3ffd1086938b3698618adf088bf14b13db4c8ae19e4e78d73da49ee88492710"
+
+	pub, err := ParsePEMPublicKey(readTestKey(t, "bob.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := KeyID(pub)
+	assert.NoError(t, err)
+	assert.Equal(t, bobKeyID, def)
+
+	// every verifier of a key has its default KEYID, whatever its scheme
+	pss, err := NewRSAPSSVerifier(pub.(*rsa.PublicKey))
+	assert.NoError(t, err)
+	pkcs1, err := NewRSAPKCS1v15Verifier(pub.(*rsa.PublicKey))
+	assert.NoError(t, err)
+	for _, v := range []Verifier{pss, pkcs1} {
+		keyID, _ := v.KeyID()
+		assert.Equal(t, bobKeyID, keyID)
+	}
+}
+
+func TestKeyIDUnsupportedKey(t *testing.T) {
+	_, err := KeyID("theKey")
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
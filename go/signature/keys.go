@@ -0,0 +1,145 @@
+/*
+Loaders for PEM, PKCS #8 and PKIX encoded keys.
+*/
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoPEMBlock          = errors.New("no PEM block found")
+	ErrUnsupportedPEMBlock = errors.New("unsupported PEM block type")
+)
+
+// ParsePKCS8PrivateKey parses a DER-encoded PKCS #8 private key and checks
+// that it is of a supported type.
+func ParsePKCS8PrivateKey(der []byte) (crypto.PrivateKey, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkPrivateKey(key)
+}
+
+// ParsePKIXPublicKey parses a DER-encoded PKIX (SubjectPublicKeyInfo)
+// public key and checks that it is of a supported type.
+func ParsePKIXPublicKey(der []byte) (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkPublicKey(key)
+}
+
+// ParsePEMPrivateKey parses the first PEM block in data as a private key.
+//
+// Supported block types are "PRIVATE KEY" (PKCS #8), "RSA PRIVATE KEY"
+// (PKCS #1) and "EC PRIVATE KEY" (SEC 1).
+func ParsePEMPrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return checkPrivateKey(key)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPEMBlock, block.Type)
+	}
+}
+
+// ParsePEMPublicKey parses the first PEM block in data as a public key.
+//
+// Supported block types are "PUBLIC KEY" (PKIX), "RSA PUBLIC KEY"
+// (PKCS #1) and "CERTIFICATE", in which case the certificate's public key
+// is returned without any validation of the certificate itself.
+func ParsePEMPublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEMBlock
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return checkPublicKey(cert.PublicKey)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPEMBlock, block.Type)
+	}
+}
+
+// LoadSignerPEM creates a SignerVerifier from a PEM-encoded private key.
+func LoadSignerPEM(data []byte, opts ...Option) (SignerVerifier, error) {
+	key, err := ParsePEMPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSignerVerifier(key, opts...)
+}
+
+// LoadVerifierPEM creates a Verifier from a PEM-encoded public key or
+// certificate.
+func LoadVerifierPEM(data []byte, opts ...Option) (Verifier, error) {
+	key, err := ParsePEMPublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewVerifier(key, opts...)
+}
+
+// MarshalPEMPublicKey encodes a public key as a PEM "PUBLIC KEY" block.
+func MarshalPEMPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedKey, err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func checkPrivateKey(key crypto.PrivateKey) (crypto.PrivateKey, error) {
+	switch key.(type) {
+	case ed25519.PrivateKey, *ecdsa.PrivateKey, *rsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+func checkPublicKey(key crypto.PublicKey) (crypto.PublicKey, error) {
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
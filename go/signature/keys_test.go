@@ -0,0 +1,319 @@
+/*
+Tests for the PEM, PKCS #8, PKIX and JWK key loaders.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePEM(t *testing.T, blockType string, der []byte) []byte {
+	t.Helper()
+
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func encodePKCS8(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func encodePKIX(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func TestLoadPEMKeys(t *testing.T) {
+	ecKey := testECDSAKey(t, elliptic.P256())
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		priv []byte
+		pub  []byte
+	}{
+		"ed25519 pkcs8": {
+			priv: encodePEM(t, "PRIVATE KEY", encodePKCS8(t, testED25519Key(t))),
+		},
+		"ecdsa sec1": {
+			priv: encodePEM(t, "EC PRIVATE KEY", sec1),
+			pub:  encodePEM(t, "PUBLIC KEY", encodePKIX(t, &ecKey.PublicKey)),
+		},
+		"rsa pkcs1": {
+			priv: encodePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey(t))),
+			pub:  encodePEM(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&testRSAKey(t).PublicKey)),
+		},
+	}
+
+	ctx := context.Background()
+	for name, test := range tests {
+		signer, err := LoadSignerPEM(test.priv)
+		if !assert.NoError(t, err, fmt.Sprintf("error loading private key in test '%s'", name)) {
+			continue
+		}
+
+		pub := test.pub
+		if pub == nil {
+			pub, err = MarshalPEMPublicKey(signer.Public())
+			assert.NoError(t, err)
+		}
+
+		verifier, err := LoadVerifierPEM(pub)
+		if !assert.NoError(t, err, fmt.Sprintf("error loading public key in test '%s'", name)) {
+			continue
+		}
+
+		sig, err := signer.Sign(ctx, []byte("msg"))
+		assert.NoError(t, err)
+		assert.NoError(t, verifier.Verify(ctx, []byte("msg"), sig), fmt.Sprintf("loaded keys do not match in test '%s'", name))
+	}
+}
+
+func TestLoadPEMCertificate(t *testing.T) {
+	key := testECDSAKey(t, elliptic.P256())
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "theSigner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParsePEMPublicKey(encodePEM(t, "CERTIFICATE", der))
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(got), "certificate key does not match")
+}
+
+func TestBadPEMKeys(t *testing.T) {
+	_, err := ParsePEMPrivateKey([]byte("not pem"))
+	assert.ErrorIs(t, err, ErrNoPEMBlock)
+
+	_, err = ParsePEMPublicKey(encodePEM(t, "OPENSSH PRIVATE KEY", []byte("key")))
+	assert.ErrorIs(t, err, ErrUnsupportedPEMBlock)
+
+	_, err = ParsePEMPrivateKey(encodePEM(t, "PRIVATE KEY", []byte("garbage")))
+	assert.Error(t, err)
+}
+
+func TestParseDERKeys(t *testing.T) {
+	key := testECDSAKey(t, elliptic.P384())
+
+	priv, err := ParsePKCS8PrivateKey(encodePKCS8(t, key))
+	assert.NoError(t, err)
+	assert.True(t, key.Equal(priv))
+
+	pub, err := ParsePKIXPublicKey(encodePKIX(t, &key.PublicKey))
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(pub))
+}
+
+func TestLoadJWKRFC8037(t *testing.T) {
+	// test vector from RFC 8037, appendix A
+	const jwk = `{"kty":"OKP","crv":"Ed25519","d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+	const signingInput = "eyJhbGciOiJFZERTQSJ9.RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc"
+	const wantSig = "hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+
+	ctx := context.Background()
+
+	signer, err := LoadSignerJWK([]byte(jwk))
+	if !assert.NoError(t, err, "error loading private JWK") {
+		return
+	}
+
+	sig, err := signer.Sign(ctx, []byte(signingInput))
+	assert.NoError(t, err)
+	assert.Equal(t, wantSig, base64.RawURLEncoding.EncodeToString(sig))
+
+	verifier, err := LoadVerifierJWK([]byte(jwk))
+	assert.NoError(t, err, "error loading public JWK")
+	assert.NoError(t, verifier.Verify(ctx, []byte(signingInput), sig))
+}
+
+func jwkB64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestLoadJWK(t *testing.T) {
+	ecKey := testECDSAKey(t, elliptic.P384())
+	rsaKey := testRSAKey(t)
+
+	tests := map[string]struct {
+		jwk string
+	}{
+		"ecdsa p384": {
+			jwk: fmt.Sprintf(`{"kty":"EC","crv":"P-384","kid":"theKey","x":"%s","y":"%s","d":"%s"}`,
+				jwkB64(ecKey.X.FillBytes(make([]byte, 48))), jwkB64(ecKey.Y.FillBytes(make([]byte, 48))), jwkB64(ecKey.D.FillBytes(make([]byte, 48)))),
+		},
+		"rsa pss": {
+			jwk: fmt.Sprintf(`{"kty":"RSA","kid":"theKey","alg":"PS256","n":"%s","e":"AQAB","d":"%s","p":"%s","q":"%s"}`,
+				jwkB64(rsaKey.N.Bytes()), jwkB64(rsaKey.D.Bytes()), jwkB64(rsaKey.Primes[0].Bytes()), jwkB64(rsaKey.Primes[1].Bytes())),
+		},
+		"rsa pkcs1v15": {
+			jwk: fmt.Sprintf(`{"kty":"RSA","kid":"theKey","alg":"RS256","n":"%s","e":"AQAB","d":"%s","p":"%s","q":"%s"}`,
+				jwkB64(rsaKey.N.Bytes()), jwkB64(rsaKey.D.Bytes()), jwkB64(rsaKey.Primes[0].Bytes()), jwkB64(rsaKey.Primes[1].Bytes())),
+		},
+	}
+
+	ctx := context.Background()
+	for name, test := range tests {
+		signer, err := LoadSignerJWK([]byte(test.jwk))
+		if !assert.NoError(t, err, fmt.Sprintf("error loading private JWK in test '%s'", name)) {
+			continue
+		}
+
+		verifier, err := LoadVerifierJWK([]byte(test.jwk))
+		if !assert.NoError(t, err, fmt.Sprintf("error loading public JWK in test '%s'", name)) {
+			continue
+		}
+
+		keyID, _ := verifier.KeyID()
+		assert.Equal(t, "theKey", keyID, fmt.Sprintf("kid not used as keyid in test '%s'", name))
+		assert.IsType(t, signer, verifier, fmt.Sprintf("signer and verifier schemes differ in test '%s'", name))
+
+		sig, err := signer.Sign(ctx, []byte("msg"))
+		assert.NoError(t, err)
+		assert.NoError(t, verifier.Verify(ctx, []byte("msg"), sig), fmt.Sprintf("loaded keys do not match in test '%s'", name))
+	}
+}
+
+func TestBadJWK(t *testing.T) {
+	other := testECDSAKey(t, elliptic.P256())
+	key := testECDSAKey(t, elliptic.P256())
+
+	tests := map[string]struct {
+		jwk string
+		err error
+	}{
+		"not json": {
+			jwk: `{`,
+			err: ErrInvalidJWK,
+		},
+		"unsupported kty": {
+			jwk: `{"kty":"oct","k":"AQAB"}`,
+			err: ErrUnsupportedKey,
+		},
+		"unsupported curve": {
+			jwk: `{"kty":"OKP","crv":"X25519","x":"AQAB"}`,
+			err: ErrUnsupportedKey,
+		},
+		"point not on curve": {
+			jwk: `{"kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}`,
+			err: ErrInvalidJWK,
+		},
+		"padded base64": {
+			jwk: `{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo="}`,
+			err: ErrInvalidJWK,
+		},
+		"mismatched private key": {
+			jwk: fmt.Sprintf(`{"kty":"EC","crv":"P-256","x":"%s","y":"%s","d":"%s"}`,
+				jwkB64(key.X.FillBytes(make([]byte, 32))), jwkB64(key.Y.FillBytes(make([]byte, 32))), jwkB64(other.D.FillBytes(make([]byte, 32)))),
+			err: ErrInvalidJWK,
+		},
+	}
+
+	for name, test := range tests {
+		jwk, err := ParseJWK([]byte(test.jwk))
+		if err == nil {
+			if jwk.IsPrivate() {
+				_, err = jwk.PrivateKey()
+			} else {
+				_, err = jwk.PublicKey()
+			}
+		}
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("loaded bad JWK in test '%s'", name))
+	}
+
+	_, err := LoadSignerJWK([]byte(`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`))
+	assert.ErrorIs(t, err, ErrNoPrivateKey)
+}
+
+func TestJWKAlg(t *testing.T) {
+	p256 := testECDSAKey(t, elliptic.P256())
+	p384 := testECDSAKey(t, elliptic.P384())
+	rsaKey := testRSAKey(t)
+
+	keys := map[string]string{
+		"ed25519": `"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"`,
+		"p256": fmt.Sprintf(`"kty":"EC","crv":"P-256","x":"%s","y":"%s"`,
+			jwkB64(p256.X.FillBytes(make([]byte, 32))), jwkB64(p256.Y.FillBytes(make([]byte, 32)))),
+		"p384": fmt.Sprintf(`"kty":"EC","crv":"P-384","x":"%s","y":"%s"`,
+			jwkB64(p384.X.FillBytes(make([]byte, 48))), jwkB64(p384.Y.FillBytes(make([]byte, 48)))),
+		"rsa": fmt.Sprintf(`"kty":"RSA","n":"%s","e":"AQAB"`, jwkB64(rsaKey.N.Bytes())),
+	}
+
+	tests := map[string]struct {
+		key string
+		alg string
+		err error
+	}{
+		"EdDSA on ed25519": {key: "ed25519", alg: "EdDSA"},
+		"ES256 on P-256":   {key: "p256", alg: "ES256"},
+		"ES384 on P-384":   {key: "p384", alg: "ES384"},
+		"RS256 on rsa":     {key: "rsa", alg: "RS256"},
+		"PS512 on rsa":     {key: "rsa", alg: "PS512"},
+		"ES384 on P-256":   {key: "p256", alg: "ES384", err: ErrInvalidJWK},
+		"ES256 on P-384":   {key: "p384", alg: "ES256", err: ErrInvalidJWK},
+		"RS256 on P-256":   {key: "p256", alg: "RS256", err: ErrInvalidJWK},
+		"PS256 on ed25519": {key: "ed25519", alg: "PS256", err: ErrInvalidJWK},
+		"EdDSA on rsa":     {key: "rsa", alg: "EdDSA", err: ErrInvalidJWK},
+		"ES256 on rsa":     {key: "rsa", alg: "ES256", err: ErrInvalidJWK},
+		"ES512 on P-384":   {key: "p384", alg: "ES512", err: ErrUnsupportedKey},
+	}
+
+	for name, test := range tests {
+		_, err := LoadVerifierJWK([]byte(fmt.Sprintf(`{%s,"alg":"%s"}`, keys[test.key], test.alg)))
+		if test.err == nil {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, err, test.err, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+}
+
+// ensure ed25519 keys loaded from JWK match those from PKCS #8
+func TestJWKMatchesPKCS8(t *testing.T) {
+	key := testED25519Key(t)
+	jwk := fmt.Sprintf(`{"kty":"OKP","crv":"Ed25519","x":"%s","d":"%s"}`, jwkB64(key.Public().(ed25519.PublicKey)), jwkB64(key.Seed()))
+
+	fromJWK, err := LoadSignerJWK([]byte(jwk))
+	assert.NoError(t, err)
+
+	fromPEM, err := LoadSignerPEM(encodePEM(t, "PRIVATE KEY", encodePKCS8(t, key)))
+	assert.NoError(t, err)
+
+	wantID, _ := fromPEM.KeyID()
+	gotID, _ := fromJWK.KeyID()
+	assert.Equal(t, wantID, gotID)
+}
@@ -0,0 +1,193 @@
+/*
+Signer and Verifier implementations for RSA keys, using either RSASSA-PSS or
+RSASSA-PKCS1-v1_5 signatures.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing or
+// verification.
+const minRSAKeyBits = 2048
+
+// RSAPSSSignerVerifier signs and verifies RSASSA-PSS signatures with a salt
+// length equal to the hash length.
+type RSAPSSSignerVerifier struct {
+	keyID string
+	hash  crypto.Hash
+	priv  *rsa.PrivateKey
+	pub   *rsa.PublicKey
+}
+
+// RSAPKCS1v15SignerVerifier signs and verifies RSASSA-PKCS1-v1_5 signatures.
+type RSAPKCS1v15SignerVerifier struct {
+	keyID string
+	hash  crypto.Hash
+	priv  *rsa.PrivateKey
+	pub   *rsa.PublicKey
+}
+
+func checkRSAPublicKey(pub *rsa.PublicKey) error {
+	if pub == nil {
+		return fmt.Errorf("%w: nil RSA public key", ErrUnsupportedKey)
+	}
+
+	if pub.N.BitLen() < minRSAKeyBits {
+		return fmt.Errorf("%w: RSA key size %d is below the %d bit minimum", ErrUnsupportedKey, pub.N.BitLen(), minRSAKeyBits)
+	}
+
+	return nil
+}
+
+// NewRSAPSSSignerVerifier creates an RSASSA-PSS SignerVerifier from an RSA
+// private key. Messages are hashed with SHA-256 unless overridden with
+// WithHash.
+func NewRSAPSSSignerVerifier(priv *rsa.PrivateKey, opts ...Option) (*RSAPSSSignerVerifier, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("%w: nil RSA private key", ErrUnsupportedKey)
+	}
+
+	sv, err := NewRSAPSSVerifier(&priv.PublicKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sv.priv = priv
+
+	return sv, nil
+}
+
+// NewRSAPSSVerifier creates a verify-only RSAPSSSignerVerifier from an RSA
+// public key.
+func NewRSAPSSVerifier(pub *rsa.PublicKey, opts ...Option) (*RSAPSSSignerVerifier, error) {
+	if err := checkRSAPublicKey(pub); err != nil {
+		return nil, err
+	}
+
+	o, err := applyOptions(pub, crypto.SHA256, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RSAPSSSignerVerifier{keyID: o.keyID, hash: o.hash, pub: pub}, nil
+}
+
+func (sv *RSAPSSSignerVerifier) pssOptions() *rsa.PSSOptions {
+	return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: sv.hash}
+}
+
+func (sv *RSAPSSSignerVerifier) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	if sv.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsa.SignPSS(rand.Reader, sv.priv, sv.hash, d, sv.pssOptions())
+}
+
+func (sv *RSAPSSSignerVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return err
+	}
+
+	if err := rsa.VerifyPSS(sv.pub, sv.hash, d, sig, sv.pssOptions()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+func (sv *RSAPSSSignerVerifier) KeyID() (string, error) {
+	return sv.keyID, nil
+}
+
+func (sv *RSAPSSSignerVerifier) Public() crypto.PublicKey {
+	return sv.pub
+}
+
+// Hash returns the hash function used to digest messages.
+func (sv *RSAPSSSignerVerifier) Hash() crypto.Hash {
+	return sv.hash
+}
+
+// NewRSAPKCS1v15SignerVerifier creates an RSASSA-PKCS1-v1_5 SignerVerifier
+// from an RSA private key. Messages are hashed with SHA-256 unless
+// overridden with WithHash.
+func NewRSAPKCS1v15SignerVerifier(priv *rsa.PrivateKey, opts ...Option) (*RSAPKCS1v15SignerVerifier, error) {
+	if priv == nil {
+		return nil, fmt.Errorf("%w: nil RSA private key", ErrUnsupportedKey)
+	}
+
+	sv, err := NewRSAPKCS1v15Verifier(&priv.PublicKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sv.priv = priv
+
+	return sv, nil
+}
+
+// NewRSAPKCS1v15Verifier creates a verify-only RSAPKCS1v15SignerVerifier
+// from an RSA public key.
+func NewRSAPKCS1v15Verifier(pub *rsa.PublicKey, opts ...Option) (*RSAPKCS1v15SignerVerifier, error) {
+	if err := checkRSAPublicKey(pub); err != nil {
+		return nil, err
+	}
+
+	o, err := applyOptions(pub, crypto.SHA256, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RSAPKCS1v15SignerVerifier{keyID: o.keyID, hash: o.hash, pub: pub}, nil
+}
+
+func (sv *RSAPKCS1v15SignerVerifier) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	if sv.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsa.SignPKCS1v15(rand.Reader, sv.priv, sv.hash, d)
+}
+
+func (sv *RSAPKCS1v15SignerVerifier) Verify(ctx context.Context, data, sig []byte) error {
+	d, err := digest(ctx, sv.hash, data)
+	if err != nil {
+		return err
+	}
+
+	if err := rsa.VerifyPKCS1v15(sv.pub, sv.hash, d, sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+func (sv *RSAPKCS1v15SignerVerifier) KeyID() (string, error) {
+	return sv.keyID, nil
+}
+
+func (sv *RSAPKCS1v15SignerVerifier) Public() crypto.PublicKey {
+	return sv.pub
+}
+
+// Hash returns the hash function used to digest messages.
+func (sv *RSAPKCS1v15SignerVerifier) Hash() crypto.Hash {
+	return sv.hash
+}
@@ -0,0 +1,146 @@
+/*
+Signer and Verifier APIs for authenticating in-toto attestation envelopes.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnsupportedKey      = errors.New("unsupported key type")
+	ErrInvalidSignature    = errors.New("signature verification failed")
+	ErrNoPrivateKey        = errors.New("no private key available for signing")
+	ErrUnsupportedHashFunc = errors.New("unsupported hash function")
+)
+
+// Signer produces signatures over arbitrary messages, e.g. the PAE of a DSSE
+// envelope.
+type Signer interface {
+	// Sign returns a signature over data.
+	Sign(ctx context.Context, data []byte) ([]byte, error)
+	// KeyID returns the identifier of the signing key, used as the keyid
+	// hint in envelopes. It may be empty.
+	KeyID() (string, error)
+	// Public returns the public key matching the signing key.
+	Public() crypto.PublicKey
+}
+
+// Verifier checks signatures over arbitrary messages.
+type Verifier interface {
+	// Verify returns nil if sig is a valid signature over data, and an
+	// error wrapping ErrInvalidSignature otherwise.
+	Verify(ctx context.Context, data, sig []byte) error
+	// KeyID returns the identifier of the verification key. It may be empty.
+	KeyID() (string, error)
+	// Public returns the verification key.
+	Public() crypto.PublicKey
+}
+
+// SignerVerifier is implemented by keys that can both sign and verify.
+type SignerVerifier interface {
+	Signer
+	Verifier
+}
+
+// Option customizes a SignerVerifier created by this package.
+type Option func(*options)
+
+type options struct {
+	keyID    string
+	keyIDSet bool
+	hash     crypto.Hash
+}
+
+// WithKeyID overrides the default KEYID of a key, which is its in-toto/TUF
+// KEYID under the default scheme for the key, whichever scheme is in use,
+// so that all of a key's signers and verifiers share it.
+func WithKeyID(keyID string) Option {
+	return func(o *options) {
+		o.keyID = keyID
+		o.keyIDSet = true
+	}
+}
+
+// WithHash sets the hash function used to digest messages for RSA and
+// ECDSA keys. It is ignored by ed25519 keys, which hash internally.
+func WithHash(h crypto.Hash) Option {
+	return func(o *options) {
+		o.hash = h
+	}
+}
+
+func applyOptions(pub crypto.PublicKey, defaultHash crypto.Hash, opts []Option) (*options, error) {
+	o := &options{hash: defaultHash}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.hash != 0 && !o.hash.Available() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHashFunc, o.hash)
+	}
+
+	if !o.keyIDSet {
+		keyID, err := KeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		o.keyID = keyID
+	}
+
+	return o, nil
+}
+
+// NewSignerVerifier creates a SignerVerifier for an ed25519, ECDSA or RSA
+// private key. RSA keys sign with RSASSA-PSS; use NewRSAPKCS1v15SignerVerifier
+// for PKCS #1 v1.5 signatures.
+func NewSignerVerifier(key crypto.PrivateKey, opts ...Option) (SignerVerifier, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return NewED25519SignerVerifier(k, opts...)
+	case *ed25519.PrivateKey:
+		return NewED25519SignerVerifier(*k, opts...)
+	case *ecdsa.PrivateKey:
+		return NewECDSASignerVerifier(k, opts...)
+	case *rsa.PrivateKey:
+		return NewRSAPSSSignerVerifier(k, opts...)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// NewVerifier creates a Verifier for an ed25519, ECDSA or RSA public key.
+// RSA keys verify RSASSA-PSS signatures; use NewRSAPKCS1v15Verifier for
+// PKCS #1 v1.5 signatures.
+func NewVerifier(pub crypto.PublicKey, opts ...Option) (Verifier, error) {
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		return NewED25519Verifier(k, opts...)
+	case *ed25519.PublicKey:
+		return NewED25519Verifier(*k, opts...)
+	case *ecdsa.PublicKey:
+		return NewECDSAVerifier(k, opts...)
+	case *rsa.PublicKey:
+		return NewRSAPSSVerifier(k, opts...)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+}
+
+// digest hashes data with h, honoring ctx cancellation before doing work.
+func digest(ctx context.Context, h crypto.Hash, data []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hasher := h.New()
+	hasher.Write(data)
+	return hasher.Sum(nil), nil
+}
@@ -0,0 +1,187 @@
+/*
+Tests for the Signer and Verifier implementations.
+*/
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	rsaKeyOnce sync.Once
+	rsaKey     *rsa.PrivateKey
+)
+
+// testRSAKey returns an RSA key shared by all tests, since generating one is
+// slow.
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	rsaKeyOnce.Do(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	return rsaKey
+}
+
+func testECDSAKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func testED25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func createTestSignerVerifiers(t *testing.T) map[string]SignerVerifier {
+	t.Helper()
+
+	svs := map[string]SignerVerifier{}
+	var err error
+
+	if svs["ed25519"], err = NewED25519SignerVerifier(testED25519Key(t)); err != nil {
+		t.Fatal(err)
+	}
+	if svs["ecdsa p256"], err = NewECDSASignerVerifier(testECDSAKey(t, elliptic.P256())); err != nil {
+		t.Fatal(err)
+	}
+	if svs["ecdsa p384"], err = NewECDSASignerVerifier(testECDSAKey(t, elliptic.P384())); err != nil {
+		t.Fatal(err)
+	}
+	if svs["rsa pss"], err = NewRSAPSSSignerVerifier(testRSAKey(t)); err != nil {
+		t.Fatal(err)
+	}
+	if svs["rsa pkcs1v15"], err = NewRSAPKCS1v15SignerVerifier(testRSAKey(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	return svs
+}
+
+func TestSignVerify(t *testing.T) {
+	ctx := context.Background()
+	msg := []byte("DSSEv1 28 application/vnd.in-toto+json 2 {}")
+
+	for name, sv := range createTestSignerVerifiers(t) {
+		sig, err := sv.Sign(ctx, msg)
+		assert.NoError(t, err, fmt.Sprintf("error signing in test '%s'", name))
+
+		err = sv.Verify(ctx, msg, sig)
+		assert.NoError(t, err, fmt.Sprintf("error verifying in test '%s'", name))
+
+		err = sv.Verify(ctx, []byte("tampered"), sig)
+		assert.ErrorIs(t, err, ErrInvalidSignature, fmt.Sprintf("verified tampered message in test '%s'", name))
+
+		sig[len(sig)-1] ^= 0xff
+		err = sv.Verify(ctx, msg, sig)
+		assert.ErrorIs(t, err, ErrInvalidSignature, fmt.Sprintf("verified tampered signature in test '%s'", name))
+
+		keyID, err := sv.KeyID()
+		assert.NoError(t, err)
+		assert.Len(t, keyID, 64, fmt.Sprintf("unexpected default keyid in test '%s'", name))
+	}
+}
+
+func TestVerifierCannotSign(t *testing.T) {
+	ctx := context.Background()
+
+	for name, sv := range createTestSignerVerifiers(t) {
+		v, err := NewVerifier(sv.Public())
+		assert.NoError(t, err, fmt.Sprintf("error creating verifier in test '%s'", name))
+
+		signer, ok := v.(Signer)
+		if !assert.True(t, ok, fmt.Sprintf("verifier does not implement Signer in test '%s'", name)) {
+			continue
+		}
+
+		_, err = signer.Sign(ctx, []byte("msg"))
+		assert.ErrorIs(t, err, ErrNoPrivateKey, fmt.Sprintf("signed without private key in test '%s'", name))
+
+		wantID, _ := sv.KeyID()
+		gotID, _ := v.KeyID()
+		assert.Equal(t, wantID, gotID, fmt.Sprintf("keyids do not match in test '%s'", name))
+	}
+}
+
+func TestWithKeyID(t *testing.T) {
+	sv, err := NewED25519SignerVerifier(testED25519Key(t), WithKeyID("theKey"))
+	assert.NoError(t, err)
+
+	keyID, err := sv.KeyID()
+	assert.NoError(t, err)
+	assert.Equal(t, "theKey", keyID)
+}
+
+func TestWithHash(t *testing.T) {
+	sv, err := NewRSAPSSSignerVerifier(testRSAKey(t), WithHash(crypto.SHA512))
+	assert.NoError(t, err)
+	assert.Equal(t, crypto.SHA512, sv.Hash())
+
+	sig, err := sv.Sign(context.Background(), []byte("msg"))
+	assert.NoError(t, err)
+
+	// the default SHA-256 verifier must not accept a SHA-512 signature
+	v, err := NewRSAPSSVerifier(&testRSAKey(t).PublicKey)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, v.Verify(context.Background(), []byte("msg"), sig), ErrInvalidSignature)
+}
+
+func TestUnsupportedKeys(t *testing.T) {
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]crypto.PrivateKey{
+		"ecdsa p224": testECDSAKey(t, elliptic.P224()),
+		"small rsa":  smallRSA,
+		"not a key":  "theKey",
+	}
+
+	for name, key := range tests {
+		_, err := NewSignerVerifier(key)
+		assert.ErrorIs(t, err, ErrUnsupportedKey, fmt.Sprintf("accepted unsupported key in test '%s'", name))
+	}
+
+	_, err = NewED25519Verifier(ed25519.PublicKey("short"))
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+func TestSignCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, sv := range createTestSignerVerifiers(t) {
+		_, err := sv.Sign(ctx, []byte("msg"))
+		assert.ErrorIs(t, err, context.Canceled, fmt.Sprintf("signed with canceled context in test '%s'", name))
+	}
+}
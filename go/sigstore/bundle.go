@@ -0,0 +1,289 @@
+/*
+Wrapper APIs for Sigstore bundles carrying DSSE envelopes, one of the
+alternative envelope schemas listed in spec/v1/envelope.md.
+*/
+
+package sigstore
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+const (
+	// mediaTypePrefix is the media type of v0.1 and v0.2 bundles, which
+	// carry their version as a parameter, e.g. ";version=0.2".
+	mediaTypePrefix = "application/vnd.dev.sigstore.bundle+json;version="
+	// MediaTypeV03 is the media type of v0.3 bundles.
+	MediaTypeV03 = "application/vnd.dev.sigstore.bundle.v0.3+json"
+)
+
+// Bundle versions
+const (
+	Version01 = "0.1"
+	Version02 = "0.2"
+	Version03 = "0.3"
+)
+
+var (
+	ErrUnsupportedMediaType          = errors.New("unsupported Sigstore bundle media type")
+	ErrVerificationMaterialRequired  = errors.New("bundle verification material required")
+	ErrNotDSSE                       = errors.New("bundle does not contain a DSSE envelope")
+	ErrInvalidCertificate            = errors.New("bundle certificate is invalid")
+	ErrTlogEntryFieldRequired        = errors.New("transparency log entry field required")
+	ErrInclusionProofFieldRequired   = errors.New("inclusion proof field required")
+	ErrMultipleVerificationMaterials = errors.New("bundle must contain exactly one of a certificate, a certificate chain or a public key")
+)
+
+// Bundle is a parsed Sigstore bundle.
+type Bundle struct {
+	MediaType string
+	// Version is the bundle format version, e.g. "0.3".
+	Version string
+	// Envelope is the bundle's DSSE envelope, or nil if the bundle holds a
+	// message signature instead.
+	Envelope *dsse.Envelope
+	// Certificates holds the signing certificate followed by any
+	// intermediate certificates included in the bundle. It is empty if the
+	// bundle is signed with a public key.
+	Certificates []*x509.Certificate
+	// PublicKeyHint identifies the public key that signed the bundle, if
+	// it is not signed with a certificate.
+	PublicKeyHint string
+	TlogEntries   []TlogEntry
+}
+
+// TlogEntry is a transparency log entry for the bundle's signature.
+type TlogEntry struct {
+	LogIndex       int64
+	LogID          []byte
+	Kind           string
+	KindVersion    string
+	IntegratedTime int64
+	// SignedEntryTimestamp is the log's signed promise to include the
+	// entry, if present.
+	SignedEntryTimestamp []byte
+	InclusionProof       *InclusionProof
+	// CanonicalizedBody is the entry body as stored in the log.
+	CanonicalizedBody []byte
+}
+
+// InclusionProof is a Merkle tree inclusion proof for a transparency log
+// entry, together with the signed checkpoint it was computed against.
+type InclusionProof struct {
+	// LogIndex is the index of the entry within the log shard the proof
+	// applies to, which may differ from the entry's global LogIndex.
+	LogIndex   int64
+	TreeSize   int64
+	RootHash   []byte
+	Hashes     [][]byte
+	Checkpoint string
+}
+
+// jsonBundle and its nested types are the protobuf JSON representations of
+// the bundle. []byte fields are base64-encoded.
+type jsonBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial *struct {
+		PublicKey *struct {
+			Hint string `json:"hint"`
+		} `json:"publicKey"`
+		X509CertificateChain *struct {
+			Certificates []jsonCertificate `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		Certificate *jsonCertificate `json:"certificate"`
+		TlogEntries []jsonTlogEntry  `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope     *dsse.Envelope  `json:"dsseEnvelope"`
+	MessageSignature json.RawMessage `json:"messageSignature"`
+}
+
+type jsonCertificate struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+type jsonTlogEntry struct {
+	LogIndex jsonInt64 `json:"logIndex"`
+	LogID    *struct {
+		KeyID []byte `json:"keyId"`
+	} `json:"logId"`
+	KindVersion *struct {
+		Kind    string `json:"kind"`
+		Version string `json:"version"`
+	} `json:"kindVersion"`
+	IntegratedTime   jsonInt64 `json:"integratedTime"`
+	InclusionPromise *struct {
+		SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	} `json:"inclusionPromise"`
+	InclusionProof *struct {
+		LogIndex   jsonInt64 `json:"logIndex"`
+		RootHash   []byte    `json:"rootHash"`
+		TreeSize   jsonInt64 `json:"treeSize"`
+		Hashes     [][]byte  `json:"hashes"`
+		Checkpoint *struct {
+			Envelope string `json:"envelope"`
+		} `json:"checkpoint"`
+	} `json:"inclusionProof"`
+	CanonicalizedBody []byte `json:"canonicalizedBody"`
+}
+
+// jsonInt64 is an int64 in protobuf JSON, which is encoded as a string but
+// may also be given as a number.
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s", data)
+	}
+	*i = jsonInt64(v)
+
+	return nil
+}
+
+// bundleVersion returns the version of a bundle media type.
+func bundleVersion(mediaType string) (string, error) {
+	if mediaType == MediaTypeV03 {
+		return Version03, nil
+	}
+
+	switch v := strings.TrimPrefix(mediaType, mediaTypePrefix); {
+	case !strings.HasPrefix(mediaType, mediaTypePrefix):
+	case v == Version01, v == Version02, v == Version03:
+		return v, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedMediaType, mediaType)
+}
+
+// ParseBundle decodes a JSON-encoded Sigstore bundle of version 0.1 to
+// 0.3. This does not verify anything: see Bundle.Verify.
+func ParseBundle(data []byte) (*Bundle, error) {
+	var raw jsonBundle
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version, err := bundleVersion(raw.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := raw.VerificationMaterial
+	if vm == nil {
+		return nil, ErrVerificationMaterialRequired
+	}
+
+	b := &Bundle{MediaType: raw.MediaType, Version: version, Envelope: raw.DSSEEnvelope}
+	if b.Envelope != nil {
+		if err := b.Envelope.Validate(); err != nil {
+			return nil, fmt.Errorf("dsseEnvelope: %w", err)
+		}
+	}
+
+	var certs []jsonCertificate
+	materials := 0
+	if vm.PublicKey != nil {
+		b.PublicKeyHint = vm.PublicKey.Hint
+		materials++
+	}
+	if vm.X509CertificateChain != nil {
+		certs = vm.X509CertificateChain.Certificates
+		materials++
+	}
+	if vm.Certificate != nil {
+		certs = []jsonCertificate{*vm.Certificate}
+		materials++
+	}
+	if materials != 1 {
+		return nil, ErrMultipleVerificationMaterials
+	}
+
+	for i, c := range certs {
+		cert, err := x509.ParseCertificate(c.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: certificates[%d]: %v", ErrInvalidCertificate, i, err)
+		}
+		b.Certificates = append(b.Certificates, cert)
+	}
+	if vm.PublicKey == nil && len(b.Certificates) == 0 {
+		return nil, fmt.Errorf("%w: no certificates", ErrInvalidCertificate)
+	}
+
+	for i, e := range vm.TlogEntries {
+		entry, err := parseTlogEntry(e)
+		if err != nil {
+			return nil, fmt.Errorf("tlogEntries[%d]: %w", i, err)
+		}
+		b.TlogEntries = append(b.TlogEntries, *entry)
+	}
+
+	return b, nil
+}
+
+func parseTlogEntry(e jsonTlogEntry) (*TlogEntry, error) {
+	if e.LogID == nil || len(e.LogID.KeyID) == 0 {
+		return nil, fmt.Errorf("%w: logId", ErrTlogEntryFieldRequired)
+	}
+
+	if e.KindVersion == nil {
+		return nil, fmt.Errorf("%w: kindVersion", ErrTlogEntryFieldRequired)
+	}
+
+	if len(e.CanonicalizedBody) == 0 {
+		return nil, fmt.Errorf("%w: canonicalizedBody", ErrTlogEntryFieldRequired)
+	}
+
+	entry := &TlogEntry{
+		LogIndex:          int64(e.LogIndex),
+		LogID:             e.LogID.KeyID,
+		Kind:              e.KindVersion.Kind,
+		KindVersion:       e.KindVersion.Version,
+		IntegratedTime:    int64(e.IntegratedTime),
+		CanonicalizedBody: e.CanonicalizedBody,
+	}
+
+	if e.InclusionPromise != nil {
+		entry.SignedEntryTimestamp = e.InclusionPromise.SignedEntryTimestamp
+	}
+
+	if p := e.InclusionProof; p != nil {
+		if len(p.RootHash) == 0 {
+			return nil, fmt.Errorf("%w: rootHash", ErrInclusionProofFieldRequired)
+		}
+
+		if p.Checkpoint == nil || p.Checkpoint.Envelope == "" {
+			return nil, fmt.Errorf("%w: checkpoint", ErrInclusionProofFieldRequired)
+		}
+
+		entry.InclusionProof = &InclusionProof{
+			LogIndex:   int64(p.LogIndex),
+			TreeSize:   int64(p.TreeSize),
+			RootHash:   p.RootHash,
+			Hashes:     p.Hashes,
+			Checkpoint: p.Checkpoint.Envelope,
+		}
+	}
+
+	return entry, nil
+}
+
+// Statement extracts the in-toto Statement from the bundle's DSSE envelope.
+//
+// This does not verify any signatures: callers MUST verify the bundle
+// before trusting the returned Statement.
+func (b *Bundle) Statement() (*ita1.Statement, error) {
+	if b.Envelope == nil {
+		return nil, ErrNotDSSE
+	}
+
+	return b.Envelope.Statement()
+}
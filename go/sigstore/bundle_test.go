@@ -0,0 +1,173 @@
+/*
+Tests for Sigstore bundle parsing.
+*/
+
+package sigstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestData(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// editJSON applies edit to the generic JSON representation of data.
+func editJSON(t *testing.T, data []byte, edit func(map[string]any)) []byte {
+	t.Helper()
+
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	edit(m)
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestParseBundle(t *testing.T) {
+	b, err := ParseBundle(readTestData(t, "sigstore.js-2.0.0-provenance.sigstore.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, Version01, b.Version)
+	assert.Len(t, b.Certificates, 1)
+	if assert.Len(t, b.TlogEntries, 1) {
+		e := b.TlogEntries[0]
+		assert.Equal(t, int64(31821305), e.LogIndex)
+		assert.Equal(t, int64(1692374735), e.IntegratedTime)
+		assert.Equal(t, "intoto", e.Kind)
+		assert.Equal(t, "0.0.2", e.KindVersion)
+		if assert.NotNil(t, e.InclusionProof) {
+			assert.Equal(t, int64(27657874), e.InclusionProof.LogIndex)
+			assert.Equal(t, int64(27657875), e.InclusionProof.TreeSize)
+			assert.Len(t, e.InclusionProof.Hashes, 10)
+		}
+	}
+
+	st, err := b.Statement()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://slsa.dev/provenance/v1", st.GetPredicateType())
+	assert.Equal(t, "pkg:npm/sigstore@2.0.0", st.GetSubject()[0].GetName())
+}
+
+func TestParseBundlePublicKey(t *testing.T) {
+	b, err := ParseBundle(readTestData(t, "npm-publish.sigstore.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Empty(t, b.Certificates)
+	assert.NotEmpty(t, b.PublicKeyHint)
+}
+
+func TestBundleVersion(t *testing.T) {
+	tests := map[string]string{
+		"application/vnd.dev.sigstore.bundle+json;version=0.1": Version01,
+		"application/vnd.dev.sigstore.bundle+json;version=0.2": Version02,
+		"application/vnd.dev.sigstore.bundle+json;version=0.3": Version03,
+		"application/vnd.dev.sigstore.bundle.v0.3+json":        Version03,
+	}
+
+	for mediaType, want := range tests {
+		got, err := bundleVersion(mediaType)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, fmt.Sprintf("wrong version for '%s'", mediaType))
+	}
+
+	for _, mediaType := range []string{
+		"application/vnd.dev.sigstore.bundle+json;version=0.4",
+		"application/vnd.dev.sigstore.bundle.v0.4+json",
+		"application/vnd.in-toto+json",
+		"",
+	} {
+		_, err := bundleVersion(mediaType)
+		assert.ErrorIs(t, err, ErrUnsupportedMediaType, fmt.Sprintf("accepted media type '%s'", mediaType))
+	}
+}
+
+func TestParseBadBundles(t *testing.T) {
+	data := readTestData(t, "dsse.sigstore.json")
+	vm := func(m map[string]any) map[string]any {
+		return m["verificationMaterial"].(map[string]any)
+	}
+	tlogEntry := func(m map[string]any) map[string]any {
+		return vm(m)["tlogEntries"].([]any)[0].(map[string]any)
+	}
+
+	tests := map[string]struct {
+		edit func(map[string]any)
+		err  error
+	}{
+		"media type": {
+			edit: func(m map[string]any) { m["mediaType"] = "application/json" },
+			err:  ErrUnsupportedMediaType,
+		},
+		"no verification material": {
+			edit: func(m map[string]any) { delete(m, "verificationMaterial") },
+			err:  ErrVerificationMaterialRequired,
+		},
+		"certificate and public key": {
+			edit: func(m map[string]any) { vm(m)["publicKey"] = map[string]any{"hint": "theKey"} },
+			err:  ErrMultipleVerificationMaterials,
+		},
+		"bad certificate": {
+			edit: func(m map[string]any) {
+				vm(m)["x509CertificateChain"] = map[string]any{"certificates": []any{map[string]any{"rawBytes": "AAAA"}}}
+			},
+			err: ErrInvalidCertificate,
+		},
+		"no log id": {
+			edit: func(m map[string]any) { delete(tlogEntry(m), "logId") },
+			err:  ErrTlogEntryFieldRequired,
+		},
+		"no body": {
+			edit: func(m map[string]any) { delete(tlogEntry(m), "canonicalizedBody") },
+			err:  ErrTlogEntryFieldRequired,
+		},
+		"proof without checkpoint": {
+			edit: func(m map[string]any) {
+				tlogEntry(m)["inclusionProof"] = map[string]any{"logIndex": "1", "treeSize": "2", "rootHash": "AAAA"}
+			},
+			err: ErrInclusionProofFieldRequired,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := ParseBundle(editJSON(t, data, test.edit))
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("parsed bad bundle in test '%s'", name))
+	}
+}
+
+func TestBundleNotDSSE(t *testing.T) {
+	data := editJSON(t, readTestData(t, "dsse.sigstore.json"), func(m map[string]any) {
+		delete(m, "dsseEnvelope")
+		m["messageSignature"] = map[string]any{}
+	})
+
+	b, err := ParseBundle(data)
+	assert.NoError(t, err)
+
+	_, err = b.Statement()
+	assert.ErrorIs(t, err, ErrNotDSSE)
+}
@@ -0,0 +1,105 @@
+/*
+Parsing and verification of transparency log checkpoints, which are signed
+notes committing to a tree size and root hash.
+*/
+
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// noteSignaturePrefix starts each signature line of a signed note.
+const noteSignaturePrefix = "— "
+
+// keyHintLength is the length of the key hint that prefixes each note
+// signature.
+const keyHintLength = 4
+
+var ErrInvalidCheckpoint = errors.New("checkpoint is invalid")
+
+// checkpoint is a parsed signed checkpoint.
+type checkpoint struct {
+	Origin   string
+	TreeSize int64
+	RootHash []byte
+	// text is the signed body of the note.
+	text       []byte
+	signatures []noteSignature
+}
+
+type noteSignature struct {
+	name    string
+	keyHint []byte
+	sig     []byte
+}
+
+// parseCheckpoint parses a signed note holding a checkpoint:
+//
+//	<origin>\n<tree size>\n<base64 root hash>\n[other lines]\n\n
+//	— <name> <base64(key hint || signature)>\n
+func parseCheckpoint(note string) (*checkpoint, error) {
+	i := strings.Index(note, "\n\n")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no signatures", ErrInvalidCheckpoint)
+	}
+
+	text, sigs := note[:i+1], note[i+2:]
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("%w: too few lines", ErrInvalidCheckpoint)
+	}
+
+	size, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("%w: invalid tree size %q", ErrInvalidCheckpoint, lines[1])
+	}
+
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid root hash %q", ErrInvalidCheckpoint, lines[2])
+	}
+
+	c := &checkpoint{Origin: lines[0], TreeSize: size, RootHash: root, text: []byte(text)}
+	for _, line := range strings.Split(strings.TrimSuffix(sigs, "\n"), "\n") {
+		if !strings.HasPrefix(line, noteSignaturePrefix) {
+			return nil, fmt.Errorf("%w: malformed signature line %q", ErrInvalidCheckpoint, line)
+		}
+
+		name, sig, ok := strings.Cut(strings.TrimPrefix(line, noteSignaturePrefix), " ")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed signature line %q", ErrInvalidCheckpoint, line)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(sig)
+		if err != nil || len(raw) <= keyHintLength {
+			return nil, fmt.Errorf("%w: malformed signature for %q", ErrInvalidCheckpoint, name)
+		}
+
+		c.signatures = append(c.signatures, noteSignature{name: name, keyHint: raw[:keyHintLength], sig: raw[keyHintLength:]})
+	}
+
+	return c, nil
+}
+
+// verify checks that the checkpoint is signed by the log. Signatures by
+// other keys, such as witnesses, are ignored.
+func (c *checkpoint) verify(ctx context.Context, log *TransparencyLog) error {
+	for _, s := range c.signatures {
+		if len(log.LogID) < keyHintLength || !bytes.Equal(s.keyHint, log.LogID[:keyHintLength]) {
+			continue
+		}
+
+		if err := log.verifier.Verify(ctx, c.text, s.sig); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no valid signature by log %s", ErrInvalidCheckpoint, log.BaseURL)
+}
@@ -0,0 +1,77 @@
+/*
+Merkle tree inclusion proof verification, as specified in RFC 9162
+section 2.1.3.2.
+*/
+
+package sigstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidInclusionProof = errors.New("inclusion proof is invalid")
+
+// leafHash returns the RFC 9162 hash of a leaf.
+func leafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(leaf)
+
+	return h.Sum(nil)
+}
+
+// nodeHash returns the RFC 9162 hash of an interior node.
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// verifyInclusion checks that the leaf with the given hash is at index in
+// the tree of the given size with the given root hash.
+func verifyInclusion(index, size int64, leaf []byte, proof [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return fmt.Errorf("%w: index %d is outside the tree of size %d", ErrInvalidInclusionProof, index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if len(p) != sha256.Size {
+			return fmt.Errorf("%w: malformed hash", ErrInvalidInclusionProof)
+		}
+
+		if sn == 0 {
+			return fmt.Errorf("%w: proof is too long", ErrInvalidInclusionProof)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("%w: proof is too short", ErrInvalidInclusionProof)
+	}
+
+	if !bytes.Equal(r, root) {
+		return fmt.Errorf("%w: root hash mismatch", ErrInvalidInclusionProof)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Tests for Merkle tree inclusion proof verification.
+*/
+
+package sigstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// merkleRoot computes the RFC 9162 root hash of a list of leaf hashes.
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+
+	k := splitPoint(len(leaves))
+	return nodeHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+// merkleProof computes the RFC 9162 inclusion proof for leaf m.
+func merkleProof(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+
+	k := splitPoint(len(leaves))
+	if m < k {
+		return append(merkleProof(m, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+
+	return append(merkleProof(m-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
+
+// splitPoint returns the largest power of two smaller than n.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+func createTestLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = leafHash([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	return leaves
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := createTestLeaves(size)
+		root := merkleRoot(leaves)
+
+		for i := 0; i < size; i++ {
+			proof := merkleProof(i, leaves)
+			err := verifyInclusion(int64(i), int64(size), leaves[i], proof, root)
+			assert.NoError(t, err, fmt.Sprintf("error verifying leaf %d of %d", i, size))
+
+			if size > 1 {
+				err = verifyInclusion(int64(i), int64(size), leaves[(i+1)%size], proof, root)
+				assert.ErrorIs(t, err, ErrInvalidInclusionProof, fmt.Sprintf("verified wrong leaf for %d of %d", i, size))
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionBadProofs(t *testing.T) {
+	leaves := createTestLeaves(7)
+	root := merkleRoot(leaves)
+	proof := merkleProof(3, leaves)
+
+	tests := map[string]struct {
+		index, size int64
+		proof       [][]byte
+	}{
+		"index out of range": {index: 7, size: 7, proof: proof},
+		"negative index":     {index: -1, size: 7, proof: proof},
+		"wrong index":        {index: 2, size: 7, proof: proof},
+		"wrong size":         {index: 3, size: 9, proof: proof},
+		"too short":          {index: 3, size: 7, proof: proof[:len(proof)-1]},
+		"too long":           {index: 3, size: 7, proof: append(proof, root)},
+		"malformed hash":     {index: 3, size: 7, proof: [][]byte{{0x01}, proof[1], proof[2]}},
+	}
+
+	for name, test := range tests {
+		err := verifyInclusion(test.index, test.size, leaves[3], test.proof, root)
+		assert.ErrorIs(t, err, ErrInvalidInclusionProof, fmt.Sprintf("verified bad proof in test '%s'", name))
+	}
+}
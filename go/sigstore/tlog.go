@@ -0,0 +1,271 @@
+/*
+Offline verification of Rekor transparency log entries: inclusion promises,
+inclusion proofs and the binding between an entry and the bundle.
+*/
+
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/cjson"
+)
+
+var (
+	ErrTlogEntryRequired        = errors.New("at least one transparency log entry required")
+	ErrUnknownLog               = errors.New("transparency log is not trusted")
+	ErrInclusionPromiseRequired = errors.New("inclusion promise required")
+	ErrInclusionProofRequired   = errors.New("inclusion proof required")
+	ErrInvalidInclusionPromise  = errors.New("inclusion promise is invalid")
+	ErrLogNotValid              = errors.New("transparency log key is not valid at the integrated time")
+	ErrUnsupportedEntryKind     = errors.New("unsupported transparency log entry kind")
+	ErrEntryMismatch            = errors.New("transparency log entry does not match the bundle")
+)
+
+// entryContent is the part of a log entry body that binds it to a DSSE
+// envelope.
+type entryContent struct {
+	payloadType string
+	payloadHash string
+	signatures  []entrySignature
+}
+
+type entrySignature struct {
+	sig []byte
+	// verifier is the PEM-encoded certificate or public key.
+	verifier []byte
+}
+
+// intotoV002Body and dsseV001Body are the Rekor entry bodies for DSSE
+// envelopes. Both hash the payload with SHA-256.
+type intotoV002Body struct {
+	Spec struct {
+		Content struct {
+			Envelope struct {
+				PayloadType string `json:"payloadType"`
+				Signatures  []struct {
+					// Sig is the base64-encoded signature, base64-encoded
+					// again.
+					Sig       []byte `json:"sig"`
+					PublicKey []byte `json:"publicKey"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+			PayloadHash entryHash `json:"payloadHash"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+type dsseV001Body struct {
+	Spec struct {
+		PayloadHash entryHash `json:"payloadHash"`
+		Signatures  []struct {
+			Signature string `json:"signature"`
+			Verifier  []byte `json:"verifier"`
+		} `json:"signatures"`
+	} `json:"spec"`
+}
+
+type entryHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// parseEntryContent decodes the parts of a log entry body that are compared
+// with the bundle.
+func parseEntryContent(e *TlogEntry) (*entryContent, error) {
+	c := &entryContent{}
+
+	switch kind := e.Kind + "/" + e.KindVersion; kind {
+	case "intoto/0.0.2":
+		var body intotoV002Body
+		if err := json.Unmarshal(e.CanonicalizedBody, &body); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEntryMismatch, err)
+		}
+
+		content := body.Spec.Content
+		c.payloadType = content.Envelope.PayloadType
+		c.payloadHash = content.PayloadHash.hex()
+		for _, s := range content.Envelope.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(string(s.Sig))
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed signature", ErrEntryMismatch)
+			}
+			c.signatures = append(c.signatures, entrySignature{sig: sig, verifier: s.PublicKey})
+		}
+	case "dsse/0.0.1":
+		var body dsseV001Body
+		if err := json.Unmarshal(e.CanonicalizedBody, &body); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEntryMismatch, err)
+		}
+
+		c.payloadHash = body.Spec.PayloadHash.hex()
+		for _, s := range body.Spec.Signatures {
+			sig, err := base64.StdEncoding.DecodeString(s.Signature)
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed signature", ErrEntryMismatch)
+			}
+			c.signatures = append(c.signatures, entrySignature{sig: sig, verifier: s.Verifier})
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEntryKind, kind)
+	}
+
+	return c, nil
+}
+
+// hex returns the SHA-256 digest value, or an empty string for other
+// algorithms.
+func (h entryHash) hex() string {
+	if h.Algorithm != "sha256" {
+		return ""
+	}
+
+	return strings.ToLower(h.Value)
+}
+
+// matches checks that the entry logs every signature of the envelope, made
+// with the given signing certificate if there is one.
+func (c *entryContent) matches(env *dsse.Envelope, leafDER []byte) error {
+	sum := sha256.Sum256(env.Payload)
+	if c.payloadHash != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("%w: payload hash", ErrEntryMismatch)
+	}
+
+	if c.payloadType != "" && c.payloadType != env.PayloadType {
+		return fmt.Errorf("%w: payloadType", ErrEntryMismatch)
+	}
+
+	for i, sig := range env.Signatures {
+		if !c.logs(sig.Sig, leafDER) {
+			return fmt.Errorf("%w: signatures[%d] is not logged", ErrEntryMismatch, i)
+		}
+	}
+
+	return nil
+}
+
+func (c *entryContent) logs(sig, leafDER []byte) bool {
+	for _, s := range c.signatures {
+		if !bytes.Equal(s.sig, sig) {
+			continue
+		}
+
+		if leafDER == nil {
+			return true
+		}
+
+		block, _ := pem.Decode(s.verifier)
+		if block != nil && block.Type == "CERTIFICATE" && bytes.Equal(block.Bytes, leafDER) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyPromise checks the entry's signed entry timestamp, which is the
+// log's signature over the canonical JSON of the entry's metadata.
+func (e *TlogEntry) verifyPromise(ctx context.Context, log *TransparencyLog) error {
+	payload, err := cjson.Encode(map[string]any{
+		"body":           base64.StdEncoding.EncodeToString(e.CanonicalizedBody),
+		"integratedTime": e.IntegratedTime,
+		"logID":          hex.EncodeToString(e.LogID),
+		"logIndex":       e.LogIndex,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := log.verifier.Verify(ctx, payload, e.SignedEntryTimestamp); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInclusionPromise, err)
+	}
+
+	return nil
+}
+
+// verifyProof checks the entry's inclusion proof and the checkpoint it was
+// computed against.
+func (e *TlogEntry) verifyProof(ctx context.Context, log *TransparencyLog) error {
+	p := e.InclusionProof
+	if err := verifyInclusion(p.LogIndex, p.TreeSize, leafHash(e.CanonicalizedBody), p.Hashes, p.RootHash); err != nil {
+		return err
+	}
+
+	cp, err := parseCheckpoint(p.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := cp.verify(ctx, log); err != nil {
+		return err
+	}
+
+	if cp.TreeSize != p.TreeSize || !bytes.Equal(cp.RootHash, p.RootHash) {
+		return fmt.Errorf("%w: checkpoint does not match the inclusion proof", ErrInvalidCheckpoint)
+	}
+
+	return nil
+}
+
+// verifyTlogEntry verifies a log entry for the bundle's envelope. It
+// returns the entry's integrated time if it is covered by a verified
+// inclusion promise, or the zero time otherwise.
+func (b *Bundle) verifyTlogEntry(ctx context.Context, root *TrustedRoot, e *TlogEntry) (time.Time, error) {
+	log, ok := root.tlog(e.LogID)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrUnknownLog, hex.EncodeToString(e.LogID))
+	}
+
+	// v0.1 bundles rely on promises, later versions on proofs
+	if b.Version == Version01 && len(e.SignedEntryTimestamp) == 0 {
+		return time.Time{}, ErrInclusionPromiseRequired
+	}
+	if b.Version != Version01 && e.InclusionProof == nil {
+		return time.Time{}, ErrInclusionProofRequired
+	}
+
+	content, err := parseEntryContent(e)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var leafDER []byte
+	if len(b.Certificates) > 0 {
+		leafDER = b.Certificates[0].Raw
+	}
+	if err := content.matches(b.Envelope, leafDER); err != nil {
+		return time.Time{}, err
+	}
+
+	if e.InclusionProof != nil {
+		if err := e.verifyProof(ctx, log); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if len(e.SignedEntryTimestamp) == 0 {
+		return time.Time{}, nil
+	}
+
+	if err := e.verifyPromise(ctx, log); err != nil {
+		return time.Time{}, err
+	}
+
+	integrated := time.Unix(e.IntegratedTime, 0)
+	if !log.ValidFor.Contains(integrated) {
+		return time.Time{}, ErrLogNotValid
+	}
+
+	return integrated, nil
+}
@@ -0,0 +1,184 @@
+/*
+Parsing of Sigstore trusted_root.json files, which pin the transparency
+logs and certificate authorities trusted for offline verification.
+*/
+
+package sigstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/in-toto/attestation/go/signature"
+)
+
+// trustedRootMediaTypePrefix is the media type of trusted roots, without
+// its version parameter.
+const trustedRootMediaTypePrefix = "application/vnd.dev.sigstore.trustedroot"
+
+var (
+	ErrUnsupportedTrustedRoot = errors.New("unsupported trusted root media type")
+	ErrInvalidTrustedRoot     = errors.New("trusted root is invalid")
+)
+
+// TrustedRoot is the set of transparency logs and certificate authorities
+// trusted to verify bundles.
+type TrustedRoot struct {
+	Tlogs                  []TransparencyLog
+	CertificateAuthorities []CertificateAuthority
+}
+
+// TransparencyLog is a trusted transparency log instance.
+type TransparencyLog struct {
+	BaseURL string
+	// LogID is the SHA-256 digest of the log's DER-encoded public key.
+	LogID     []byte
+	PublicKey crypto.PublicKey
+	ValidFor  ValidityPeriod
+
+	verifier signature.Verifier
+}
+
+// CertificateAuthority is a trusted certificate authority.
+type CertificateAuthority struct {
+	URI string
+	// Certificates is the authority's chain, starting with the issuing
+	// certificate and ending with the root.
+	Certificates []*x509.Certificate
+	ValidFor     ValidityPeriod
+}
+
+// ValidityPeriod is the period in which a log or authority may be used. A
+// zero End means the period is open-ended.
+type ValidityPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains indicates if t falls within the validity period.
+func (p ValidityPeriod) Contains(t time.Time) bool {
+	if t.Before(p.Start) {
+		return false
+	}
+
+	return p.End.IsZero() || !t.After(p.End)
+}
+
+// jsonTrustedRoot and its nested types are the protobuf JSON
+// representations of the trusted root.
+type jsonTrustedRoot struct {
+	MediaType string `json:"mediaType"`
+	Tlogs     []struct {
+		BaseURL   string `json:"baseUrl"`
+		PublicKey struct {
+			RawBytes   []byte             `json:"rawBytes"`
+			KeyDetails string             `json:"keyDetails"`
+			ValidFor   jsonValidityPeriod `json:"validFor"`
+		} `json:"publicKey"`
+		LogID struct {
+			KeyID []byte `json:"keyId"`
+		} `json:"logId"`
+	} `json:"tlogs"`
+	CertificateAuthorities []struct {
+		URI       string `json:"uri"`
+		CertChain struct {
+			Certificates []jsonCertificate `json:"certificates"`
+		} `json:"certChain"`
+		ValidFor jsonValidityPeriod `json:"validFor"`
+	} `json:"certificateAuthorities"`
+}
+
+type jsonValidityPeriod struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end"`
+}
+
+func (p jsonValidityPeriod) period() ValidityPeriod {
+	v := ValidityPeriod{Start: p.Start}
+	if p.End != nil {
+		v.End = *p.End
+	}
+
+	return v
+}
+
+// ParseTrustedRoot decodes a JSON-encoded trusted root, such as the
+// trusted_root.json distributed through the Sigstore TUF repository.
+func ParseTrustedRoot(data []byte) (*TrustedRoot, error) {
+	var raw jsonTrustedRoot
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(raw.MediaType, trustedRootMediaTypePrefix) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedTrustedRoot, raw.MediaType)
+	}
+
+	root := &TrustedRoot{}
+	for i, t := range raw.Tlogs {
+		pub, err := x509.ParsePKIXPublicKey(t.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tlogs[%d]: %v", ErrInvalidTrustedRoot, i, err)
+		}
+
+		verifier, err := newLogVerifier(pub, t.PublicKey.KeyDetails)
+		if err != nil {
+			return nil, fmt.Errorf("%w: tlogs[%d]: %v", ErrInvalidTrustedRoot, i, err)
+		}
+
+		root.Tlogs = append(root.Tlogs, TransparencyLog{
+			BaseURL:   t.BaseURL,
+			LogID:     t.LogID.KeyID,
+			PublicKey: pub,
+			ValidFor:  t.PublicKey.ValidFor.period(),
+			verifier:  verifier,
+		})
+	}
+
+	for i, ca := range raw.CertificateAuthorities {
+		authority := CertificateAuthority{URI: ca.URI, ValidFor: ca.ValidFor.period()}
+		for j, c := range ca.CertChain.Certificates {
+			cert, err := x509.ParseCertificate(c.RawBytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: certificateAuthorities[%d].certificates[%d]: %v", ErrInvalidTrustedRoot, i, j, err)
+			}
+			authority.Certificates = append(authority.Certificates, cert)
+		}
+
+		if len(authority.Certificates) == 0 {
+			return nil, fmt.Errorf("%w: certificateAuthorities[%d]: no certificates", ErrInvalidTrustedRoot, i)
+		}
+
+		root.CertificateAuthorities = append(root.CertificateAuthorities, authority)
+	}
+
+	return root, nil
+}
+
+// newLogVerifier creates a verifier for a log key. RSA keys default to
+// RSASSA-PSS, so PKCS#1 v1.5 keys have to be told apart by their details.
+func newLogVerifier(pub crypto.PublicKey, keyDetails string) (signature.Verifier, error) {
+	if rsaPub, ok := pub.(*rsa.PublicKey); ok && strings.Contains(keyDetails, "PKCS1V15") {
+		return signature.NewRSAPKCS1v15Verifier(rsaPub)
+	}
+
+	return signature.NewVerifier(pub)
+}
+
+// tlog returns the trusted log with the given log ID.
+func (r *TrustedRoot) tlog(logID []byte) (*TransparencyLog, bool) {
+	for i := range r.Tlogs {
+		if bytes.Equal(r.Tlogs[i].LogID, logID) {
+			return &r.Tlogs[i], true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,177 @@
+/*
+Offline verification of Sigstore bundles against a local trusted root.
+*/
+
+package sigstore
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+var (
+	ErrTrustedRootRequired    = errors.New("trusted root required")
+	ErrVerifierRequired       = errors.New("verifier required for bundles signed with a public key")
+	ErrNoVerifiedTime         = errors.New("no verified signing time")
+	ErrUntrustedCertificate   = errors.New("certificate does not chain to a trusted certificate authority")
+	ErrInvalidBundleSignature = errors.New("bundle signature is invalid")
+)
+
+// Result is the outcome of verifying a bundle.
+type Result struct {
+	// Statement is the verified in-toto Statement.
+	Statement *ita1.Statement
+	// Certificate is the signing certificate, or nil if the bundle is
+	// signed with a public key. Callers are expected to check its identity
+	// against their policy.
+	Certificate *x509.Certificate
+	// Verifier is the verifier that matched the envelope's signature.
+	Verifier signature.Verifier
+	// SigningTime is the time the signature was verified at: the log's
+	// integrated time, or the time given with WithVerificationTime.
+	SigningTime time.Time
+}
+
+type verifyOptions struct {
+	verifiers []signature.Verifier
+	at        time.Time
+}
+
+// VerifyOption configures Bundle.Verify.
+type VerifyOption func(*verifyOptions)
+
+// WithVerifiers sets the verifiers for bundles signed with a public key
+// rather than a certificate.
+func WithVerifiers(verifiers ...signature.Verifier) VerifyOption {
+	return func(o *verifyOptions) {
+		o.verifiers = append(o.verifiers, verifiers...)
+	}
+}
+
+// WithVerificationTime sets the time at which the signing certificate must
+// be valid, instead of the integrated time of the log entries. It is needed
+// for bundles without an inclusion promise.
+func WithVerificationTime(t time.Time) VerifyOption {
+	return func(o *verifyOptions) {
+		o.at = t
+	}
+}
+
+// Verify verifies the bundle without network access and returns its
+// Statement:
+//
+//   - every transparency log entry must come from a trusted log, match the
+//     envelope and signing certificate, and carry a valid inclusion proof
+//     (v0.2 and later) or inclusion promise (v0.1),
+//   - the signing certificate must chain to a trusted certificate authority
+//     at the integrated time of the log entries,
+//   - the envelope must be signed by the certificate's key, or by one of
+//     the verifiers given with WithVerifiers for public key bundles.
+//
+// Verify does not check the certificate's identity, nor RFC 3161 signed
+// timestamps.
+func (b *Bundle) Verify(ctx context.Context, root *TrustedRoot, opts ...VerifyOption) (*Result, error) {
+	if root == nil {
+		return nil, ErrTrustedRootRequired
+	}
+
+	if b.Envelope == nil {
+		return nil, ErrNotDSSE
+	}
+
+	o := &verifyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(b.TlogEntries) == 0 {
+		return nil, ErrTlogEntryRequired
+	}
+
+	at := o.at
+	for i := range b.TlogEntries {
+		integrated, err := b.verifyTlogEntry(ctx, root, &b.TlogEntries[i])
+		if err != nil {
+			return nil, fmt.Errorf("tlogEntries[%d]: %w", i, err)
+		}
+
+		if at.IsZero() {
+			at = integrated
+		}
+	}
+
+	if at.IsZero() {
+		return nil, ErrNoVerifiedTime
+	}
+
+	verifiers := o.verifiers
+	var leaf *x509.Certificate
+	if len(b.Certificates) > 0 {
+		leaf = b.Certificates[0]
+		if err := root.verifyCertificate(leaf, at); err != nil {
+			return nil, err
+		}
+
+		v, err := signature.NewVerifier(leaf.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+		}
+		verifiers = []signature.Verifier{v}
+	} else if len(verifiers) == 0 {
+		return nil, ErrVerifierRequired
+	}
+
+	matched, err := b.Envelope.Verify(ctx, verifiers...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBundleSignature, err)
+	}
+
+	statement, err := b.Envelope.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Statement:   statement,
+		Certificate: leaf,
+		Verifier:    matched[0],
+		SigningTime: at,
+	}, nil
+}
+
+// verifyCertificate checks that a signing certificate chains to one of the
+// certificate authorities that was valid at the given time. Only the
+// trusted root's intermediates are used, not those included in the bundle.
+func (r *TrustedRoot) verifyCertificate(leaf *x509.Certificate, at time.Time) error {
+	for _, ca := range r.CertificateAuthorities {
+		if !ca.ValidFor.Contains(at) {
+			continue
+		}
+
+		roots := x509.NewCertPool()
+		intermediates := x509.NewCertPool()
+		last := len(ca.Certificates) - 1
+		roots.AddCert(ca.Certificates[last])
+		for _, c := range ca.Certificates[:last] {
+			intermediates.AddCert(c)
+		}
+
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   at,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		})
+		if err == nil {
+			return nil
+		}
+	}
+
+	return ErrUntrustedCertificate
+}
@@ -0,0 +1,497 @@
+/*
+Tests for offline Sigstore bundle verification.
+*/
+
+package sigstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/cjson"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// npmPublishKey is the npm registry key that signed npm-publish.sigstore.json.
+const npmPublishKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE1Olb3zMAFFxXKHiIkQO5cJ3Yhl5i6UPp+IhuteBJbuHcA5UogKo0EWtlWwW6KSaKoTNEYL7JlCQiVnkhBktUgg==
+-----END PUBLIC KEY-----
+`
+
+func loadTestTrustedRoot(t *testing.T) *TrustedRoot {
+	t.Helper()
+
+	root, err := ParseTrustedRoot(readTestData(t, "trusted_root.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func loadTestBundle(t *testing.T, data []byte) *Bundle {
+	t.Helper()
+
+	b, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestVerifyPublicGoodBundles(t *testing.T) {
+	root := loadTestTrustedRoot(t)
+
+	tests := map[string]struct {
+		predicateType string
+		signingTime   int64
+	}{
+		"sigstore.js-2.0.0-provenance.sigstore.json": {
+			predicateType: "https://slsa.dev/provenance/v1",
+			signingTime:   1692374735,
+		},
+		"dsse.sigstore.json": {
+			predicateType: "https://slsa.dev/provenance/v0.2",
+			signingTime:   1668034836,
+		},
+	}
+
+	for name, test := range tests {
+		b := loadTestBundle(t, readTestData(t, name))
+
+		got, err := b.Verify(context.Background(), root)
+		if !assert.NoError(t, err, fmt.Sprintf("error verifying '%s'", name)) {
+			continue
+		}
+
+		assert.Equal(t, test.predicateType, got.Statement.GetPredicateType())
+		assert.Equal(t, time.Unix(test.signingTime, 0), got.SigningTime)
+		assert.NotNil(t, got.Certificate)
+	}
+}
+
+func TestVerifyPublicKeyBundle(t *testing.T) {
+	root := loadTestTrustedRoot(t)
+	b := loadTestBundle(t, readTestData(t, "npm-publish.sigstore.json"))
+
+	_, err := b.Verify(context.Background(), root)
+	assert.ErrorIs(t, err, ErrVerifierRequired)
+
+	v, err := signature.LoadVerifierPEM([]byte(npmPublishKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := b.Verify(context.Background(), root, WithVerifiers(v))
+	if assert.NoError(t, err) {
+		assert.Nil(t, got.Certificate)
+		assert.Equal(t, v, got.Verifier)
+		assert.Equal(t, "https://github.com/npm/attestation/tree/main/specs/publish/v0.1", got.Statement.GetPredicateType())
+	}
+
+	other, err := signature.NewVerifier(&createTestKey(t).PublicKey)
+	assert.NoError(t, err)
+	_, err = b.Verify(context.Background(), root, WithVerifiers(other))
+	assert.ErrorIs(t, err, ErrInvalidBundleSignature)
+}
+
+func TestVerifyTamperedBundles(t *testing.T) {
+	root := loadTestTrustedRoot(t)
+	data := readTestData(t, "sigstore.js-2.0.0-provenance.sigstore.json")
+	tlogEntry := func(m map[string]any) map[string]any {
+		return m["verificationMaterial"].(map[string]any)["tlogEntries"].([]any)[0].(map[string]any)
+	}
+	proof := func(m map[string]any) map[string]any {
+		return tlogEntry(m)["inclusionProof"].(map[string]any)
+	}
+
+	tests := map[string]struct {
+		edit func(map[string]any)
+		err  error
+	}{
+		"payload": {
+			edit: func(m map[string]any) {
+				env := m["dsseEnvelope"].(map[string]any)
+				env["payload"] = base64.StdEncoding.EncodeToString([]byte(`{"_type":"https://in-toto.io/Statement/v1"}`))
+			},
+			err: ErrEntryMismatch,
+		},
+		"signature": {
+			edit: func(m map[string]any) {
+				env := m["dsseEnvelope"].(map[string]any)
+				env["signatures"] = []any{map[string]any{"sig": base64.StdEncoding.EncodeToString([]byte("theSig"))}}
+			},
+			err: ErrEntryMismatch,
+		},
+		"integrated time": {
+			edit: func(m map[string]any) { tlogEntry(m)["integratedTime"] = "1692374736" },
+			err:  ErrInvalidInclusionPromise,
+		},
+		"log index": {
+			edit: func(m map[string]any) { tlogEntry(m)["logIndex"] = "31821306" },
+			err:  ErrInvalidInclusionPromise,
+		},
+		"proof hash": {
+			edit: func(m map[string]any) {
+				hashes := proof(m)["hashes"].([]any)
+				hashes[0] = hashes[1]
+			},
+			err: ErrInvalidInclusionProof,
+		},
+		"proof root hash": {
+			edit: func(m map[string]any) {
+				proof(m)["rootHash"] = base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+			},
+			err: ErrInvalidInclusionProof,
+		},
+		"checkpoint": {
+			edit: func(m map[string]any) {
+				cp := proof(m)["checkpoint"].(map[string]any)
+				cp["envelope"] = strings.Replace(cp["envelope"].(string), "Timestamp: 1", "Timestamp: 2", 1)
+			},
+			err: ErrInvalidCheckpoint,
+		},
+		"unknown log": {
+			edit: func(m map[string]any) {
+				tlogEntry(m)["logId"] = map[string]any{"keyId": base64.StdEncoding.EncodeToString([]byte("theLog"))}
+			},
+			err: ErrUnknownLog,
+		},
+		"v0.2 without proof": {
+			edit: func(m map[string]any) {
+				m["mediaType"] = "application/vnd.dev.sigstore.bundle+json;version=0.2"
+				delete(tlogEntry(m), "inclusionProof")
+			},
+			err: ErrInclusionProofRequired,
+		},
+		"v0.1 without promise": {
+			edit: func(m map[string]any) { delete(tlogEntry(m), "inclusionPromise") },
+			err:  ErrInclusionPromiseRequired,
+		},
+		"no tlog entries": {
+			edit: func(m map[string]any) { delete(m["verificationMaterial"].(map[string]any), "tlogEntries") },
+			err:  ErrTlogEntryRequired,
+		},
+	}
+
+	for name, test := range tests {
+		b := loadTestBundle(t, editJSON(t, data, test.edit))
+		_, err := b.Verify(context.Background(), root)
+		assert.ErrorIs(t, err, test.err, fmt.Sprintf("verified tampered bundle in test '%s'", name))
+	}
+}
+
+func TestVerifyUntrustedCertificate(t *testing.T) {
+	root := loadTestTrustedRoot(t)
+	root.CertificateAuthorities = root.CertificateAuthorities[:1]
+
+	// the only remaining authority expired before the bundle was signed
+	b := loadTestBundle(t, readTestData(t, "sigstore.js-2.0.0-provenance.sigstore.json"))
+	_, err := b.Verify(context.Background(), root)
+	assert.ErrorIs(t, err, ErrUntrustedCertificate)
+
+	_, err = b.Verify(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrTrustedRootRequired)
+}
+
+func createTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func createTestCertificate(t *testing.T, tmpl, parent *x509.Certificate, pub *ecdsa.PublicKey, signer *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+
+	// a nil parent creates a self-signed certificate
+	if parent == nil {
+		parent = tmpl
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// testInstance is a self-contained certificate authority and transparency
+// log for creating v0.3 bundles.
+type testInstance struct {
+	caKey   *ecdsa.PrivateKey
+	ca      *x509.Certificate
+	logKey  signature.SignerVerifier
+	logID   []byte
+	logTime time.Time
+}
+
+func createTestInstance(t *testing.T) *testInstance {
+	t.Helper()
+
+	now := time.Now().Truncate(time.Second)
+	caKey := createTestKey(t)
+	ca := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "theCA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, &caKey.PublicKey, caKey)
+
+	logKey, err := signature.NewECDSASignerVerifier(createTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(logKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logID := sha256.Sum256(der)
+
+	return &testInstance{caKey: caKey, ca: ca, logKey: logKey, logID: logID[:], logTime: now}
+}
+
+func (ti *testInstance) trustedRoot(t *testing.T) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(ti.logKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+		"tlogs": []any{map[string]any{
+			"baseUrl": "https://log.example.com",
+			"publicKey": map[string]any{
+				"rawBytes":   der,
+				"keyDetails": "PKIX_ECDSA_P256_SHA_256",
+				"validFor":   map[string]any{"start": ti.logTime.Add(-time.Hour)},
+			},
+			"logId": map[string]any{"keyId": ti.logID},
+		}},
+		"certificateAuthorities": []any{map[string]any{
+			"uri":       "https://ca.example.com",
+			"certChain": map[string]any{"certificates": []any{map[string]any{"rawBytes": ti.ca.Raw}}},
+			"validFor":  map[string]any{"start": ti.logTime.Add(-time.Hour)},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// bundle signs a Statement with a fresh certificate and logs it as a
+// dsse/0.0.1 entry at index 2 of a five entry tree.
+func (ti *testInstance) bundle(t *testing.T, st *ita1.Statement, withPromise bool) []byte {
+	t.Helper()
+	ctx := context.Background()
+
+	key := createTestKey(t)
+	leaf := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    ti.logTime.Add(-time.Minute),
+		NotAfter:     ti.logTime.Add(10 * time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, ti.ca, &key.PublicKey, ti.caKey)
+
+	signer, err := signature.NewECDSASignerVerifier(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.NewEnvelope(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Sign(ctx, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	payloadHash := sha256.Sum256(env.Payload)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"payloadHash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+			"signatures": []any{map[string]any{
+				"signature": base64.StdEncoding.EncodeToString(env.Signatures[0].Sig),
+				"verifier":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}),
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := createTestLeaves(5)
+	leaves[2] = leafHash(body)
+	rootHash := merkleRoot(leaves)
+
+	note := fmt.Sprintf("log.example.com\n5\n%s\n", base64.StdEncoding.EncodeToString(rootHash))
+	noteSig, err := ti.logKey.Sign(ctx, []byte(note))
+	if err != nil {
+		t.Fatal(err)
+	}
+	note += "\n— log.example.com " + base64.StdEncoding.EncodeToString(append(ti.logID[:4:4], noteSig...)) + "\n"
+
+	entry := map[string]any{
+		"logIndex":       "1002",
+		"logId":          map[string]any{"keyId": ti.logID},
+		"kindVersion":    map[string]any{"kind": "dsse", "version": "0.0.1"},
+		"integratedTime": fmt.Sprint(ti.logTime.Unix()),
+		"inclusionProof": map[string]any{
+			"logIndex":   "2",
+			"rootHash":   rootHash,
+			"treeSize":   "5",
+			"hashes":     merkleProof(2, leaves),
+			"checkpoint": map[string]any{"envelope": note},
+		},
+		"canonicalizedBody": body,
+	}
+
+	if withPromise {
+		set, err := cjson.Encode(map[string]any{
+			"body":           base64.StdEncoding.EncodeToString(body),
+			"integratedTime": ti.logTime.Unix(),
+			"logID":          hex.EncodeToString(ti.logID),
+			"logIndex":       1002,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := ti.logKey.Sign(ctx, set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry["inclusionPromise"] = map[string]any{"signedEntryTimestamp": sig}
+	}
+
+	data, err := json.Marshal(map[string]any{
+		"mediaType": MediaTypeV03,
+		"verificationMaterial": map[string]any{
+			"certificate": map[string]any{"rawBytes": leaf.Raw},
+			"tlogEntries": []any{entry},
+		},
+		"dsseEnvelope": env,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func createTestStatement() *ita1.Statement {
+	return &ita1.Statement{
+		Type: ita1.StatementTypeUri,
+		Subject: []*ita1.ResourceDescriptor{{
+			Name:   "theSub",
+			Digest: map[string]string{"sha256": "a1234567b1234567c1234567d1234567e1234567f1234567a1234567b1234567"},
+		}},
+		PredicateType: "https://example.com/thePredicate/v1",
+		Predicate:     &structpb.Struct{Fields: map[string]*structpb.Value{"keyObj": structpb.NewStringValue("theValue")}},
+	}
+}
+
+func TestVerifyV03Bundle(t *testing.T) {
+	ti := createTestInstance(t)
+	root, err := ParseTrustedRoot(ti.trustedRoot(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := loadTestBundle(t, ti.bundle(t, createTestStatement(), true))
+	got, err := b.Verify(context.Background(), root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/thePredicate/v1", got.Statement.GetPredicateType())
+		assert.Equal(t, ti.logTime, got.SigningTime)
+	}
+
+	// another instance's log and authority are not trusted
+	other := createTestInstance(t)
+	otherRoot, err := ParseTrustedRoot(other.trustedRoot(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Verify(context.Background(), otherRoot)
+	assert.ErrorIs(t, err, ErrUnknownLog)
+}
+
+func TestVerifyV03BundleWithoutPromise(t *testing.T) {
+	ti := createTestInstance(t)
+	root, err := ParseTrustedRoot(ti.trustedRoot(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the integrated time is not signed without a promise
+	b := loadTestBundle(t, ti.bundle(t, createTestStatement(), false))
+	_, err = b.Verify(context.Background(), root)
+	assert.ErrorIs(t, err, ErrNoVerifiedTime)
+
+	got, err := b.Verify(context.Background(), root, WithVerificationTime(ti.logTime))
+	if assert.NoError(t, err) {
+		assert.Equal(t, ti.logTime, got.SigningTime)
+	}
+
+	// the certificate is only valid for ten minutes
+	_, err = b.Verify(context.Background(), root, WithVerificationTime(ti.logTime.Add(time.Hour)))
+	assert.ErrorIs(t, err, ErrUntrustedCertificate)
+}
+
+func TestParseTrustedRoot(t *testing.T) {
+	root := loadTestTrustedRoot(t)
+	assert.Len(t, root.Tlogs, 1)
+	assert.Len(t, root.CertificateAuthorities, 2)
+	assert.Equal(t, "https://rekor.sigstore.dev", root.Tlogs[0].BaseURL)
+
+	ca := root.CertificateAuthorities[0]
+	assert.True(t, ca.ValidFor.Contains(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, ca.ValidFor.Contains(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, root.CertificateAuthorities[1].ValidFor.Contains(time.Now()))
+
+	_, err := ParseTrustedRoot([]byte(`{"mediaType": "application/json"}`))
+	assert.ErrorIs(t, err, ErrUnsupportedTrustedRoot)
+
+	_, err = ParseTrustedRoot([]byte(`{"mediaType": "application/vnd.dev.sigstore.trustedroot+json;version=0.1", "tlogs": [{"publicKey": {"rawBytes": "AAAA"}}]}`))
+	assert.ErrorIs(t, err, ErrInvalidTrustedRoot)
+}
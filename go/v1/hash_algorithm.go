@@ -0,0 +1,299 @@
+/*
+Registry of custom hash algorithms for DigestSets, and classification of
+hash algorithms by strength.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrInvalidHashAlgorithm    = errors.New("invalid hash algorithm registration")
+	ErrHashAlgorithmRegistered = errors.New("hash algorithm already registered")
+	ErrWeakDigestSet           = errors.New("digest set only has digests of weak algorithms")
+)
+
+// DigestEncoding is the string encoding of an algorithm's digests in a
+// DigestSet.
+type DigestEncoding string
+
+const (
+	EncodingHex       DigestEncoding = "hex"
+	EncodingBase64    DigestEncoding = "base64"
+	EncodingBase64URL DigestEncoding = "base64url"
+)
+
+// DecodeString decodes a digest. Hex digests may be in either case.
+func (e DigestEncoding) DecodeString(s string) ([]byte, error) {
+	switch e {
+	case EncodingHex:
+		return hex.DecodeString(s)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unknown digest encoding %q", e)
+	}
+}
+
+// EncodeToString encodes a digest, in lowercase for hex.
+func (e DigestEncoding) EncodeToString(b []byte) string {
+	switch e {
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	default:
+		return hex.EncodeToString(b)
+	}
+}
+
+// Strength classifies hash algorithms by their resistance to collisions.
+type Strength int
+
+const (
+	// StrengthUnknown is the strength of custom algorithms that were not
+	// registered, or were registered without a strength.
+	StrengthUnknown Strength = iota
+	// StrengthWeak algorithms have practical collision attacks, e.g. md5
+	// and sha1.
+	StrengthWeak
+	StrengthStrong
+)
+
+func (s Strength) String() string {
+	switch s {
+	case StrengthWeak:
+		return "weak"
+	case StrengthStrong:
+		return "strong"
+	default:
+		return "unknown"
+	}
+}
+
+// HashAlgorithmSpec describes a hash algorithm, to register custom ones
+// with RegisterHashAlgorithm.
+type HashAlgorithmSpec struct {
+	// Sizes are the accepted digest sizes in bytes. They are only empty for
+	// the shake algorithms of digest_set.md, which accept any positive size.
+	Sizes []int
+	// Encoding defaults to EncodingHex.
+	Encoding DigestEncoding
+	Strength Strength
+	// New, if set, lets digests of the algorithm be computed, and so
+	// artifacts be matched against them.
+	New func() hash.Hash
+}
+
+var registry = struct {
+	sync.RWMutex
+	specs map[HashAlgorithm]HashAlgorithmSpec
+}{specs: map[HashAlgorithm]HashAlgorithmSpec{}}
+
+// RegisterHashAlgorithm registers a custom hash algorithm, whose digests
+// Validate then checks like those of the algorithms of digest_set.md. It
+// is meant to be called from init functions.
+//
+// The sizes, encoding and strength of the algorithms of digest_set.md are
+// fixed, but a New function can be registered for them, e.g. to compute
+// sm3 or gost digests with a third-party implementation.
+func RegisterHashAlgorithm(name HashAlgorithm, spec HashAlgorithmSpec) error {
+	if name == "" {
+		return fmt.Errorf("%w: name required", ErrInvalidHashAlgorithm)
+	}
+
+	if builtin, ok := builtinHashAlgorithm(name); ok {
+		return registerHashFunc(name, builtin, spec)
+	}
+
+	if len(spec.Sizes) == 0 || slices.ContainsFunc(spec.Sizes, func(size int) bool { return size <= 0 }) {
+		return fmt.Errorf("%w: %s: positive digest sizes required", ErrInvalidHashAlgorithm, name)
+	}
+
+	if spec.Encoding == "" {
+		spec.Encoding = EncodingHex
+	}
+	if _, err := spec.Encoding.DecodeString(""); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidHashAlgorithm, name, err)
+	}
+
+	if spec.New != nil && !slices.Contains(spec.Sizes, spec.New().Size()) {
+		return fmt.Errorf("%w: %s: hash size %d is not an accepted digest size", ErrInvalidHashAlgorithm, name, spec.New().Size())
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.specs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrHashAlgorithmRegistered, name)
+	}
+
+	spec.Sizes = slices.Clone(spec.Sizes)
+	registry.specs[name] = spec
+
+	return nil
+}
+
+// registerHashFunc registers the New function of an algorithm of
+// digest_set.md, which must be the only field of the spec.
+func registerHashFunc(name HashAlgorithm, builtin, spec HashAlgorithmSpec) error {
+	if spec.New == nil || len(spec.Sizes) > 0 || spec.Encoding != "" || spec.Strength != StrengthUnknown {
+		return fmt.Errorf("%w: %s: only a New function can be registered for an algorithm of digest_set.md", ErrInvalidHashAlgorithm, name)
+	}
+
+	if size := spec.New().Size(); !name.isExtendableOutput() && !slices.Contains(builtin.Sizes, size) {
+		return fmt.Errorf("%w: %s: hash size %d is not an accepted digest size", ErrInvalidHashAlgorithm, name, size)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.specs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrHashAlgorithmRegistered, name)
+	}
+
+	registry.specs[name] = HashAlgorithmSpec{New: spec.New}
+
+	return nil
+}
+
+// builtinHashAlgorithm returns the spec of an algorithm of digest_set.md,
+// without a New function.
+func builtinHashAlgorithm(name HashAlgorithm) (HashAlgorithmSpec, bool) {
+	sizes := name.digestLengths()
+	if len(sizes) == 0 && !name.isExtendableOutput() {
+		return HashAlgorithmSpec{}, false
+	}
+
+	strength := StrengthStrong
+	if name == AlgorithmMD5 || name == AlgorithmSHA1 {
+		strength = StrengthWeak
+	}
+
+	return HashAlgorithmSpec{Sizes: sizes, Encoding: EncodingHex, Strength: strength}, true
+}
+
+// LookupHashAlgorithm returns the spec of an algorithm of digest_set.md or
+// of a registered one. Those of digest_set.md only have a New function if
+// one was registered, as this package does not compute digests.
+func LookupHashAlgorithm(name HashAlgorithm) (HashAlgorithmSpec, bool) {
+	builtin, isBuiltin := builtinHashAlgorithm(name)
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	spec, ok := registry.specs[name]
+	if isBuiltin {
+		builtin.New = spec.New
+		return builtin, true
+	}
+
+	return spec, ok
+}
+
+// Encoding returns the encoding of the algorithm's digests. Unknown
+// algorithms are assumed to be hex-encoded, like those of digest_set.md.
+func (algo HashAlgorithm) Encoding() DigestEncoding {
+	if spec, ok := LookupHashAlgorithm(algo); ok {
+		return spec.Encoding
+	}
+
+	return EncodingHex
+}
+
+// DigestStrength returns the strength of a digest in a DigestSet. It
+// depends on the digest for the git object algorithms, whose SHA-1 digests
+// are weak and SHA-256 ones strong.
+func DigestStrength(alg, digest string) Strength {
+	name := HashAlgorithm(alg)
+	switch name {
+	case AlgorithmGitBlob, AlgorithmGitCommit, AlgorithmGitTag, AlgorithmGitTree:
+		if len(digest) == hex.EncodedLen(AlgorithmSHA1.HexLength()) {
+			return StrengthWeak
+		}
+	}
+
+	spec, ok := LookupHashAlgorithm(name)
+	if !ok {
+		return StrengthUnknown
+	}
+
+	return spec.Strength
+}
+
+// IsWeakDigestSet indicates if the digests of known strength of a
+// DigestSet, of which there is at least one, are all weak. Digests of
+// unknown strength do not make a DigestSet any stronger.
+func IsWeakDigestSet(digests map[string]string) bool {
+	weak := false
+	for alg, digest := range digests {
+		switch DigestStrength(alg, digest) {
+		case StrengthStrong:
+			return false
+		case StrengthWeak:
+			weak = true
+		}
+	}
+
+	return weak
+}
+
+type validateOptions struct {
+	rejectWeak bool
+	reportWeak func(*ResourceDescriptor)
+}
+
+// ValidateOption configures ValidateWithOptions.
+type ValidateOption func(*validateOptions)
+
+// RejectWeakDigests makes validation fail with ErrWeakDigestSet on
+// ResourceDescriptors with a weak DigestSet, as IsWeakDigestSet reports.
+func RejectWeakDigests() ValidateOption {
+	return func(o *validateOptions) {
+		o.rejectWeak = true
+	}
+}
+
+// ReportWeakDigests calls report with each ResourceDescriptor that has a
+// weak DigestSet, and lets its validation go on.
+func ReportWeakDigests(report func(*ResourceDescriptor)) ValidateOption {
+	return func(o *validateOptions) {
+		o.reportWeak = report
+	}
+}
+
+func newValidateOptions(opts []ValidateOption) *validateOptions {
+	o := &validateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// checkStrength applies the weak digest options to a ResourceDescriptor.
+func (o *validateOptions) checkStrength(d *ResourceDescriptor) error {
+	if !IsWeakDigestSet(d.GetDigest()) {
+		return nil
+	}
+
+	if o.reportWeak != nil {
+		o.reportWeak(d)
+	}
+
+	if o.rejectWeak {
+		return fmt.Errorf("%w (%v)", ErrWeakDigestSet, d.GetDigest())
+	}
+
+	return nil
+}
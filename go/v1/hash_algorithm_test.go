@@ -0,0 +1,240 @@
+/*
+Tests for the custom hash algorithm registry and digest strengths.
+*/
+
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBase64Algorithm HashAlgorithm = "testSha256Base64"
+
+// registered once, as the registry lives as long as the test binary
+var errRegisterTestAlgorithm = RegisterHashAlgorithm(testBase64Algorithm, HashAlgorithmSpec{
+	Sizes:    []int{sha256.Size},
+	Encoding: EncodingBase64,
+	Strength: StrengthStrong,
+	New:      sha256.New,
+})
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	assert.NoError(t, errRegisterTestAlgorithm)
+
+	spec, ok := LookupHashAlgorithm(testBase64Algorithm)
+	assert.True(t, ok)
+	assert.Equal(t, []int{sha256.Size}, spec.Sizes)
+	assert.Equal(t, EncodingBase64, testBase64Algorithm.Encoding())
+
+	sum := sha256.Sum256([]byte("hello"))
+	tests := map[string]struct {
+		digest      string
+		expectedErr error
+	}{
+		"valid": {
+			digest: base64.StdEncoding.EncodeToString(sum[:]),
+		},
+		"hex": {
+			digest:      "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+			expectedErr: ErrIncorrectDigestLength,
+		},
+		"invalid base64": {
+			digest:      "not base64!",
+			expectedErr: ErrInvalidDigestString,
+		},
+		"short": {
+			digest:      base64.StdEncoding.EncodeToString(sum[:20]),
+			expectedErr: ErrIncorrectDigestLength,
+		},
+	}
+
+	for name, test := range tests {
+		rd := &ResourceDescriptor{Digest: map[string]string{testBase64Algorithm.String(): test.digest}}
+		err := rd.Validate()
+		if test.expectedErr == nil {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+
+	// only hex digests are reported as not hex-encoded
+	err := (&ResourceDescriptor{Digest: map[string]string{testBase64Algorithm.String(): "not base64!"}}).Validate()
+	assert.NotErrorIs(t, err, ErrInvalidDigestEncoding)
+	assert.ErrorContains(t, err, "want base64")
+	err = (&ResourceDescriptor{Digest: map[string]string{"sha256": "not hex"}}).Validate()
+	assert.NotErrorIs(t, err, ErrInvalidDigestString)
+	assert.EqualError(t, err, "digest is not valid hex-encoded string (sha256: not hex)")
+}
+
+// sha256 stands in for a gost implementation
+var errRegisterGOST = RegisterHashAlgorithm(AlgorithmGOST, HashAlgorithmSpec{New: sha256.New})
+
+func TestRegisterSpecHashFunc(t *testing.T) {
+	assert.NoError(t, errRegisterGOST)
+
+	spec, ok := LookupHashAlgorithm(AlgorithmGOST)
+	assert.True(t, ok)
+	assert.NotNil(t, spec.New)
+	assert.Equal(t, []int{32, 64}, spec.Sizes)
+	assert.Equal(t, EncodingHex, spec.Encoding)
+	assert.Equal(t, StrengthStrong, spec.Strength)
+
+	spec, ok = LookupHashAlgorithm(AlgorithmSM3)
+	assert.True(t, ok)
+	assert.Nil(t, spec.New)
+}
+
+func TestRegisterHashAlgorithmErrors(t *testing.T) {
+	tests := map[string]struct {
+		name        HashAlgorithm
+		spec        HashAlgorithmSpec
+		expectedErr error
+	}{
+		"no name": {
+			spec:        HashAlgorithmSpec{Sizes: []int{32}},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"spec algorithm sizes": {
+			name:        AlgorithmSHA256,
+			spec:        HashAlgorithmSpec{Sizes: []int{32}, New: sha256.New},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"spec algorithm encoding": {
+			name:        AlgorithmSM3,
+			spec:        HashAlgorithmSpec{Encoding: EncodingBase64, New: sha256.New},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"spec algorithm without New": {
+			name:        AlgorithmSM3,
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"spec algorithm hash of another size": {
+			name:        AlgorithmSM3,
+			spec:        HashAlgorithmSpec{New: func() hash.Hash { return sha256.New224() }},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"spec algorithm New registered twice": {
+			name:        AlgorithmGOST,
+			spec:        HashAlgorithmSpec{New: sha256.New},
+			expectedErr: ErrHashAlgorithmRegistered,
+		},
+		"registered twice": {
+			name:        testBase64Algorithm,
+			spec:        HashAlgorithmSpec{Sizes: []int{32}},
+			expectedErr: ErrHashAlgorithmRegistered,
+		},
+		"no sizes": {
+			name:        "testNoSizes",
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"zero size": {
+			name:        "testZeroSize",
+			spec:        HashAlgorithmSpec{Sizes: []int{32, 0}},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"unknown encoding": {
+			name:        "testBase32",
+			spec:        HashAlgorithmSpec{Sizes: []int{32}, Encoding: "base32"},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+		"hash of another size": {
+			name:        "testSha224",
+			spec:        HashAlgorithmSpec{Sizes: []int{32}, New: func() hash.Hash { return sha256.New224() }},
+			expectedErr: ErrInvalidHashAlgorithm,
+		},
+	}
+
+	for name, test := range tests {
+		err := RegisterHashAlgorithm(test.name, test.spec)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+
+	_, ok := LookupHashAlgorithm("testNoSizes")
+	assert.False(t, ok)
+}
+
+func TestDigestStrength(t *testing.T) {
+	tests := map[string]struct {
+		alg      string
+		digest   string
+		expected Strength
+	}{
+		"md5":            {alg: "md5", expected: StrengthWeak},
+		"sha1":           {alg: "sha1", expected: StrengthWeak},
+		"sha256":         {alg: "sha256", expected: StrengthStrong},
+		"sm3":            {alg: "sm3", expected: StrengthStrong},
+		"sha1 gitCommit": {alg: "gitCommit", digest: strings.Repeat("a", 40), expected: StrengthWeak},
+		"sha256 gitTree": {alg: "gitTree", digest: strings.Repeat("a", 64), expected: StrengthStrong},
+		"registered":     {alg: testBase64Algorithm.String(), expected: StrengthStrong},
+		"unregistered":   {alg: "myCustomAlgorithm", expected: StrengthUnknown},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.expected, DigestStrength(test.alg, test.digest), fmt.Sprintf("unexpected strength in test '%s'", name))
+	}
+}
+
+func TestIsWeakDigestSet(t *testing.T) {
+	tests := map[string]struct {
+		digests  map[string]string
+		expected bool
+	}{
+		"md5 only":        {digests: map[string]string{"md5": "a"}, expected: true},
+		"md5 and sha1":    {digests: map[string]string{"md5": "a", "sha1": "b"}, expected: true},
+		"md5 and custom":  {digests: map[string]string{"md5": "a", "myCustomAlgorithm": "b"}, expected: true},
+		"md5 and sha256":  {digests: map[string]string{"md5": "a", "sha256": "b"}, expected: false},
+		"custom only":     {digests: map[string]string{"myCustomAlgorithm": "b"}, expected: false},
+		"no digests":      {digests: map[string]string{}, expected: false},
+		"sha1 git commit": {digests: map[string]string{"gitCommit": strings.Repeat("a", 40)}, expected: true},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.expected, IsWeakDigestSet(test.digests), fmt.Sprintf("unexpected result in test '%s'", name))
+	}
+}
+
+func TestValidateWithOptions(t *testing.T) {
+	md5Only := &ResourceDescriptor{Name: "fooly.apk", Digest: map[string]string{"md5": strings.Repeat("ab", 16)}}
+	both := &ResourceDescriptor{Name: "barly.apk", Digest: map[string]string{
+		"md5":    strings.Repeat("ab", 16),
+		"sha256": strings.Repeat("ab", 32),
+	}}
+	nameOnly := &ResourceDescriptor{Name: "bazly.apk"}
+
+	// weak digests are valid by default
+	assert.NoError(t, md5Only.Validate())
+	assert.NoError(t, md5Only.ValidateWithOptions())
+
+	assert.ErrorIs(t, md5Only.ValidateWithOptions(RejectWeakDigests()), ErrWeakDigestSet)
+	assert.NoError(t, both.ValidateWithOptions(RejectWeakDigests()))
+	assert.NoError(t, nameOnly.ValidateWithOptions(RejectWeakDigests()))
+
+	reported := []*ResourceDescriptor{}
+	report := ReportWeakDigests(func(rd *ResourceDescriptor) { reported = append(reported, rd) })
+	for _, rd := range []*ResourceDescriptor{md5Only, both, nameOnly} {
+		assert.NoError(t, rd.ValidateWithOptions(report))
+	}
+	assert.Equal(t, []*ResourceDescriptor{md5Only}, reported)
+
+	// other validation errors come first
+	invalid := &ResourceDescriptor{Digest: map[string]string{"md5": "abcd"}}
+	assert.ErrorIs(t, invalid.ValidateWithOptions(RejectWeakDigests()), ErrIncorrectDigestLength)
+
+	// statements apply the options to their subjects
+	s := createTestStatement(t)
+	s.Subject = []*ResourceDescriptor{both, md5Only}
+	assert.NoError(t, s.Validate())
+	assert.ErrorIs(t, s.ValidateWithOptions(RejectWeakDigests()), ErrWeakDigestSet)
+
+	reported = []*ResourceDescriptor{}
+	assert.NoError(t, s.ValidateWithOptions(report))
+	assert.Equal(t, []*ResourceDescriptor{md5Only}, reported)
+}
@@ -5,7 +5,6 @@ Wrapper APIs for in-toto attestation ResourceDescriptor protos.
 package v1
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"slices"
@@ -16,7 +15,10 @@ import (
 var (
 	ErrIncorrectDigestLength = errors.New("digest has incorrect length")
 	ErrInvalidDigestEncoding = errors.New("digest is not valid hex-encoded string")
-	ErrRDRequiredField       = errors.New("at least one of name, URI, or digest are required")
+	// ErrInvalidDigestString is the encoding error of the registered
+	// algorithms whose digests are not hex-encoded.
+	ErrInvalidDigestString = errors.New("digest is not a validly encoded string")
+	ErrRDRequiredField     = errors.New("at least one of name, URI, or digest are required")
 )
 
 type HashAlgorithm string
@@ -34,6 +36,13 @@ const (
 	AlgorithmSHA3_256   HashAlgorithm = "sha3_256"
 	AlgorithmSHA3_384   HashAlgorithm = "sha3_384"
 	AlgorithmSHA3_512   HashAlgorithm = "sha3_512"
+	AlgorithmSHAKE128   HashAlgorithm = "shake128"
+	AlgorithmSHAKE256   HashAlgorithm = "shake256"
+	AlgorithmBLAKE2b    HashAlgorithm = "blake2b"
+	AlgorithmBLAKE2s    HashAlgorithm = "blake2s"
+	AlgorithmRIPEMD160  HashAlgorithm = "ripemd160"
+	AlgorithmSM3        HashAlgorithm = "sm3"
+	AlgorithmGOST       HashAlgorithm = "gost"
 	AlgorithmGitBlob    HashAlgorithm = "gitBlob"
 	AlgorithmGitCommit  HashAlgorithm = "gitCommit"
 	AlgorithmGitTag     HashAlgorithm = "gitTag"
@@ -56,6 +65,13 @@ var HashAlgorithms = map[string]HashAlgorithm{
 	"sha3_256":   AlgorithmSHA3_256,
 	"sha3_384":   AlgorithmSHA3_384,
 	"sha3_512":   AlgorithmSHA3_512,
+	"shake128":   AlgorithmSHAKE128,
+	"shake256":   AlgorithmSHAKE256,
+	"blake2b":    AlgorithmBLAKE2b,
+	"blake2s":    AlgorithmBLAKE2s,
+	"ripemd160":  AlgorithmRIPEMD160,
+	"sm3":        AlgorithmSM3,
+	"gost":       AlgorithmGOST,
 	"gitBlob":    AlgorithmGitBlob,
 	"gitCommit":  AlgorithmGitCommit,
 	"gitTag":     AlgorithmGitTag,
@@ -63,16 +79,18 @@ var HashAlgorithms = map[string]HashAlgorithm{
 	"dirHash":    AlgorithmDirHash,
 }
 
-// HexLength returns the expected length of an algorithm's hash when hexencoded
+// HexLength returns the expected length of an algorithm's hash when hexencoded.
+// It is 0 for the shake and blake2 algorithms, whose digests have no fixed
+// length.
 func (algo HashAlgorithm) HexLength() int {
 	switch algo {
 	case AlgorithmMD5:
 		return 16
-	case AlgorithmSHA1, AlgorithmGitBlob, AlgorithmGitCommit, AlgorithmGitTag, AlgorithmGitTree:
+	case AlgorithmSHA1, AlgorithmRIPEMD160, AlgorithmGitBlob, AlgorithmGitCommit, AlgorithmGitTag, AlgorithmGitTree:
 		return 20
 	case AlgorithmSHA224, AlgorithmSHA512_224, AlgorithmSHA3_224:
 		return 28
-	case AlgorithmSHA256, AlgorithmSHA512_256, AlgorithmSHA3_256, AlgorithmDirHash:
+	case AlgorithmSHA256, AlgorithmSHA512_256, AlgorithmSHA3_256, AlgorithmSM3, AlgorithmGOST, AlgorithmDirHash:
 		return 32
 	case AlgorithmSHA384, AlgorithmSHA3_384:
 		return 48
@@ -92,11 +110,21 @@ func (algo HashAlgorithm) String() string {
 //
 // Most algorithms have exactly one. The git object algorithms have two: git
 // names objects with either a SHA-1 or a SHA-256 hash, and digest_set.md
-// accepts both, telling them apart by length.
+// accepts both, telling them apart by length. So does gost, for the 256 and
+// 512 bit variants of GOST R 34.11-2012. BLAKE2b and BLAKE2s digests may
+// have any size up to 64 and 32 bytes, e.g. BLAKE2b-256, and the shake
+// algorithms, which are extendable-output functions, have none: any positive
+// size is accepted.
 func (algo HashAlgorithm) digestLengths() []int {
 	switch algo {
 	case AlgorithmGitBlob, AlgorithmGitCommit, AlgorithmGitTag, AlgorithmGitTree:
 		return []int{20, 32}
+	case AlgorithmGOST:
+		return []int{32, 64}
+	case AlgorithmBLAKE2b:
+		return sizesUpTo(64)
+	case AlgorithmBLAKE2s:
+		return sizesUpTo(32)
 	}
 
 	if size := algo.HexLength(); size > 0 {
@@ -106,28 +134,69 @@ func (algo HashAlgorithm) digestLengths() []int {
 	return nil
 }
 
-// Indicates if a given hash algorithm is supported by default and returns the
-// digest sizes in bytes that it accepts, if supported.
+// isExtendableOutput indicates if an algorithm's digests may have any
+// positive size.
+func (algo HashAlgorithm) isExtendableOutput() bool {
+	return algo == AlgorithmSHAKE128 || algo == AlgorithmSHAKE256
+}
+
+// sizesUpTo returns the sizes from 1 to maxSize bytes.
+func sizesUpTo(maxSize int) []int {
+	sizes := make([]int, 0, maxSize)
+	for size := 1; size <= maxSize; size++ {
+		sizes = append(sizes, size)
+	}
+
+	return sizes
+}
+
+// Indicates if a given hash algorithm is supported, by default or because
+// it was registered, and returns its spec, if supported.
 //
 // SHA digest sizes from https://nvlpubs.nist.gov/nistpubs/FIPS/NIST.FIPS.202.pdf
 // MD5 digest size from https://www.rfc-editor.org/rfc/rfc1321.html#section-1
-func isSupportedAlgorithm(algString string) (bool, []int) {
-	sizes := HashAlgorithm(algString).digestLengths()
-	return len(sizes) > 0, sizes
+// SHAKE digests may have any size, per FIPS 202, BLAKE2 digests the sizes
+// allowed by https://www.rfc-editor.org/rfc/rfc7693.html, and the SM3
+// digest size is from https://www.rfc-editor.org/rfc/rfc8998.html
+func isSupportedAlgorithm(algString string) (bool, HashAlgorithmSpec) {
+	spec, ok := LookupHashAlgorithm(HashAlgorithm(algString))
+	return ok, spec
 }
 
 // formatSizes renders the accepted digest sizes for an error message, e.g.
-// "20" or "20 or 32".
+// "20", "20 or 32" or "1 to 64".
 func formatSizes(sizes []int) string {
-	strs := make([]string, 0, len(sizes))
-	for _, size := range sizes {
-		strs = append(strs, strconv.Itoa(size))
+	if len(sizes) == 0 {
+		return "a positive number of"
+	}
+
+	strs := []string{}
+	for i := 0; i < len(sizes); {
+		j := i
+		for j+1 < len(sizes) && sizes[j+1] == sizes[j]+1 {
+			j++
+		}
+
+		if j-i >= 2 {
+			strs = append(strs, fmt.Sprintf("%d to %d", sizes[i], sizes[j]))
+		} else {
+			for _, size := range sizes[i : j+1] {
+				strs = append(strs, strconv.Itoa(size))
+			}
+		}
+		i = j + 1
 	}
 
 	return strings.Join(strs, " or ")
 }
 
 func (d *ResourceDescriptor) Validate() error {
+	return d.ValidateWithOptions()
+}
+
+// ValidateWithOptions validates the ResourceDescriptor as Validate does,
+// and applies the given options to its DigestSet.
+func (d *ResourceDescriptor) ValidateWithOptions(opts ...ValidateOption) error {
 	// at least one of name, URI or digest are required
 	if d.GetName() == "" && d.GetUri() == "" && len(d.GetDigest()) == 0 {
 		return ErrRDRequiredField
@@ -139,22 +208,26 @@ func (d *ResourceDescriptor) Validate() error {
 			// Per https://github.com/in-toto/attestation/blob/main/spec/v1/digest_set.md
 			// check encoding and length for supported algorithms;
 			// use of custom, unsupported algorithms is allowed and does not not generate validation errors.
-			supported, sizes := isSupportedAlgorithm(alg)
+			supported, spec := isSupportedAlgorithm(alg)
 			if supported {
-				// the in-toto spec expects a hex-encoded string in DigestSets for supported algorithms
-				hashBytes, err := hex.DecodeString(digest)
+				// the in-toto spec expects a hex-encoded string in DigestSets for supported algorithms,
+				// and registered algorithms declare their own encoding
+				hashBytes, err := spec.Encoding.DecodeString(digest)
 
-				if err != nil {
+				if err != nil && spec.Encoding == EncodingHex {
 					return fmt.Errorf("%w (%s: %s)", ErrInvalidDigestEncoding, alg, digest)
+				} else if err != nil {
+					return fmt.Errorf("%w: want %s (%s: %s)", ErrInvalidDigestString, spec.Encoding, alg, digest)
 				}
 
-				// check the length of the digest
-				if !slices.Contains(sizes, len(hashBytes)) {
-					return fmt.Errorf("%w: got %d bytes, want %s bytes (%s: %s)", ErrIncorrectDigestLength, len(hashBytes), formatSizes(sizes), alg, digest)
+				// check the length of the digest; extendable-output
+				// algorithms accept any positive length
+				if len(hashBytes) == 0 || (len(spec.Sizes) > 0 && !slices.Contains(spec.Sizes, len(hashBytes))) {
+					return fmt.Errorf("%w: got %d bytes, want %s bytes (%s: %s)", ErrIncorrectDigestLength, len(hashBytes), formatSizes(spec.Sizes), alg, digest)
 				}
 			}
 		}
 	}
 
-	return nil
+	return newValidateOptions(opts).checkStrength(d)
 }
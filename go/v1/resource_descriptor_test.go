@@ -5,6 +5,8 @@ Tests for in-toto attestation ResourceDescriptor protos.
 package v1
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -137,3 +139,84 @@ func TestBadGitCommitDigestLength(t *testing.T) {
 	err = got.Validate()
 	assert.ErrorIs(t, err, ErrIncorrectDigestLength, "did not get expected error when validating ResourceDescriptor with incorrect gitCommit digest length")
 }
+
+func BenchmarkResourceDescriptorValidate(b *testing.B) {
+	rd := &ResourceDescriptor{}
+	if err := protojson.Unmarshal([]byte(supportedRdDigest), rd); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := rd.Validate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSpecAlgorithmDigestLengths(t *testing.T) {
+	tests := map[string]struct {
+		alg          HashAlgorithm
+		digestLength int
+		expectedErr  error
+	}{
+		"shake128":            {alg: AlgorithmSHAKE128, digestLength: 32},
+		"shake256":            {alg: AlgorithmSHAKE256, digestLength: 64},
+		"blake2b":             {alg: AlgorithmBLAKE2b, digestLength: 64},
+		"blake2s":             {alg: AlgorithmBLAKE2s, digestLength: 32},
+		"ripemd160":           {alg: AlgorithmRIPEMD160, digestLength: 20},
+		"sm3":                 {alg: AlgorithmSM3, digestLength: 32},
+		"gost 256":            {alg: AlgorithmGOST, digestLength: 32},
+		"gost 512":            {alg: AlgorithmGOST, digestLength: 64},
+		"256-bit shake256":    {alg: AlgorithmSHAKE256, digestLength: 32},
+		"long shake128":       {alg: AlgorithmSHAKE128, digestLength: 100},
+		"blake2b-256":         {alg: AlgorithmBLAKE2b, digestLength: 32},
+		"blake2s-128":         {alg: AlgorithmBLAKE2s, digestLength: 16},
+		"empty shake256":      {alg: AlgorithmSHAKE256, digestLength: 0, expectedErr: ErrIncorrectDigestLength},
+		"long blake2b":        {alg: AlgorithmBLAKE2b, digestLength: 65, expectedErr: ErrIncorrectDigestLength},
+		"long blake2s":        {alg: AlgorithmBLAKE2s, digestLength: 64, expectedErr: ErrIncorrectDigestLength},
+		"long ripemd160":      {alg: AlgorithmRIPEMD160, digestLength: 32, expectedErr: ErrIncorrectDigestLength},
+		"sha256 sized as sm3": {alg: AlgorithmSM3, digestLength: 20, expectedErr: ErrIncorrectDigestLength},
+		"gost 384":            {alg: AlgorithmGOST, digestLength: 48, expectedErr: ErrIncorrectDigestLength},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.alg, HashAlgorithms[test.alg.String()], fmt.Sprintf("algorithm not indexed in test '%s'", name))
+
+		rd := &ResourceDescriptor{Digest: map[string]string{test.alg.String(): strings.Repeat("ab", test.digestLength)}}
+		err := rd.Validate()
+		if test.expectedErr == nil {
+			assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name))
+		} else {
+			assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+		}
+	}
+
+	// the encoding of sm3, gost and shake digests is checked too
+	for _, alg := range []string{"sm3", "gost", "shake256"} {
+		rd := &ResourceDescriptor{Digest: map[string]string{alg: strings.Repeat("xy", 32)}}
+		assert.ErrorIs(t, rd.Validate(), ErrInvalidDigestEncoding, fmt.Sprintf("expected error for %s", alg))
+	}
+
+	// BLAKE2b-256 of "abc", as computed by Python's hashlib.blake2b
+	rd := &ResourceDescriptor{Digest: map[string]string{"blake2b": "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"}}
+	assert.NoError(t, rd.Validate())
+}
+
+func TestFormatSizes(t *testing.T) {
+	tests := map[string]struct {
+		sizes    []int
+		expected string
+	}{
+		"one":        {sizes: []int{20}, expected: "20"},
+		"two":        {sizes: []int{20, 32}, expected: "20 or 32"},
+		"adjacent":   {sizes: []int{31, 32}, expected: "31 or 32"},
+		"range":      {sizes: sizesUpTo(64), expected: "1 to 64"},
+		"range and":  {sizes: []int{1, 2, 3, 8}, expected: "1 to 3 or 8"},
+		"any length": {expected: "a positive number of"},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.expected, formatSizes(test.sizes), fmt.Sprintf("unexpected sizes in test '%s'", name))
+	}
+}
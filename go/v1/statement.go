@@ -21,6 +21,13 @@ var (
 )
 
 func (s *Statement) Validate() error {
+	return s.ValidateWithOptions()
+}
+
+// ValidateWithOptions validates the Statement as Validate does, and applies
+// the given options to the DigestSets of its subjects, e.g. to reject
+// subjects with only md5 digests.
+func (s *Statement) ValidateWithOptions(opts ...ValidateOption) error {
 	if !s.isValidType() {
 		return ErrInvalidStatementType
 	}
@@ -32,7 +39,7 @@ func (s *Statement) Validate() error {
 	// check all resource descriptors in the subject
 	subject := s.GetSubject()
 	for _, rd := range subject {
-		if err := rd.Validate(); err != nil {
+		if err := rd.ValidateWithOptions(opts...); err != nil {
 			return err
 		}
 
@@ -5,7 +5,10 @@ Tests for in-toto attestation ResourceDescriptor protos.
 package v1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -158,3 +161,32 @@ func TestBadStatementPredicate(t *testing.T) {
 		assert.ErrorIs(t, err, test.err, fmt.Sprintf("%s in test '%s'", test.noErrMessage, name))
 	}
 }
+
+func BenchmarkStatementValidate(b *testing.B) {
+	for _, n := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprintf("subjects=%d", n), func(b *testing.B) {
+			subjects := make([]*ResourceDescriptor, n)
+			for i := range subjects {
+				sum := sha256.Sum256([]byte(strconv.Itoa(i)))
+				subjects[i] = &ResourceDescriptor{
+					Name:   fmt.Sprintf("artifact-%d", i),
+					Digest: map[string]string{"sha256": hex.EncodeToString(sum[:]), "gitCommit": hex.EncodeToString(sum[:20])},
+				}
+			}
+
+			pred, err := structpb.NewStruct(map[string]interface{}{"keyObj": map[string]interface{}{"subKey": "subVal"}})
+			if err != nil {
+				b.Fatal(err)
+			}
+			s := &Statement{Type: StatementTypeUri, Subject: subjects, PredicateType: "thePredicate", Predicate: pred}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := s.Validate(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Artifact digesting for subject matching, and for producers filling in the
+DigestSets of ResourceDescriptors.
+*/
+
+package validation
+
+import (
+	"context"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"golang.org/x/crypto/blake2b"
+	_ "golang.org/x/crypto/blake2s"
+	_ "golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+)
+
+var ErrNotRegularFile = errors.New("not a regular file")
+
+// hashFuncs maps the digest algorithms that can be computed to their
+// implementations. sm3 and gost digests are validated, but can only be
+// computed once a third-party implementation is registered with
+// ita1.RegisterHashAlgorithm. blake2s digests can only be computed at their
+// full size.
+var hashFuncs = map[ita1.HashAlgorithm]func() hash.Hash{
+	ita1.AlgorithmMD5:        crypto.MD5.New,
+	ita1.AlgorithmSHA1:       crypto.SHA1.New,
+	ita1.AlgorithmSHA224:     crypto.SHA224.New,
+	ita1.AlgorithmSHA256:     crypto.SHA256.New,
+	ita1.AlgorithmSHA384:     crypto.SHA384.New,
+	ita1.AlgorithmSHA512:     crypto.SHA512.New,
+	ita1.AlgorithmSHA512_224: crypto.SHA512_224.New,
+	ita1.AlgorithmSHA512_256: crypto.SHA512_256.New,
+	ita1.AlgorithmSHA3_224:   crypto.SHA3_224.New,
+	ita1.AlgorithmSHA3_256:   crypto.SHA3_256.New,
+	ita1.AlgorithmSHA3_384:   crypto.SHA3_384.New,
+	ita1.AlgorithmSHA3_512:   crypto.SHA3_512.New,
+	ita1.AlgorithmSHAKE128:   func() hash.Hash { return sha3.NewShake128() },
+	ita1.AlgorithmSHAKE256:   func() hash.Hash { return sha3.NewShake256() },
+	ita1.AlgorithmBLAKE2b:    crypto.BLAKE2b_512.New,
+	ita1.AlgorithmBLAKE2s:    crypto.BLAKE2s_256.New,
+	ita1.AlgorithmRIPEMD160:  crypto.RIPEMD160.New,
+}
+
+// Digests are the digests of an artifact under each algorithm, as computed
+// by ComputeDigests. An algorithm may have digests of several sizes.
+type Digests map[ita1.HashAlgorithm][][]byte
+
+// hashFunc returns the implementation of a digest algorithm, built in or
+// registered with ita1.RegisterHashAlgorithm.
+func hashFunc(alg ita1.HashAlgorithm) (func() hash.Hash, bool) {
+	if hf, ok := hashFuncs[alg]; ok {
+		return hf, true
+	}
+
+	spec, ok := ita1.LookupHashAlgorithm(alg)
+	return spec.New, ok && spec.New != nil
+}
+
+// isExtendableOutput indicates if an algorithm's digest of any size is a
+// prefix of its longer digests.
+func isExtendableOutput(alg ita1.HashAlgorithm) bool {
+	return alg == ita1.AlgorithmSHAKE128 || alg == ita1.AlgorithmSHAKE256
+}
+
+// subjectDigestSizes returns the sizes of the subjects' shake and blake2b
+// digests, whose size is chosen by the producer.
+func subjectDigestSizes(subjects []*ita1.ResourceDescriptor) map[ita1.HashAlgorithm][]int {
+	sizes := map[ita1.HashAlgorithm][]int{}
+	for _, s := range subjects {
+		for alg, value := range s.GetDigest() {
+			name := ita1.HashAlgorithm(alg)
+			if !isExtendableOutput(name) && name != ita1.AlgorithmBLAKE2b {
+				continue
+			}
+
+			d, err := hex.DecodeString(value)
+			if err != nil || len(d) == 0 || slices.Contains(sizes[name], len(d)) {
+				continue
+			}
+			sizes[name] = append(sizes[name], len(d))
+		}
+	}
+
+	return sizes
+}
+
+// ComputeDigests reads r once and returns its digest under each algorithm.
+// shake and blake2b digests have their default size and, to match the given
+// subjects with MatchSubjects, the sizes of the subjects' digests.
+func ComputeDigests(r io.Reader, algs []ita1.HashAlgorithm, subjects ...*ita1.ResourceDescriptor) (Digests, error) {
+	sizes := subjectDigestSizes(subjects)
+
+	type hasher struct {
+		alg ita1.HashAlgorithm
+		h   hash.Hash
+	}
+	hashers := make([]hasher, 0, len(algs))
+	writers := make([]io.Writer, 0, len(algs))
+	seen := map[ita1.HashAlgorithm]bool{}
+	for _, alg := range algs {
+		if seen[alg] {
+			continue
+		}
+		seen[alg] = true
+
+		hf, ok := hashFunc(alg)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedDigestAlgorithm, alg)
+		}
+		hs := []hash.Hash{hf()}
+		if alg == ita1.AlgorithmBLAKE2b {
+			// the size is a parameter of blake2b, so each size is a hash of its own
+			for _, size := range sizes[alg] {
+				if size == blake2b.Size {
+					continue
+				}
+				if h, err := blake2b.New(size, nil); err == nil {
+					hs = append(hs, h)
+				}
+			}
+		}
+
+		for _, h := range hs {
+			hashers = append(hashers, hasher{alg: alg, h: h})
+			writers = append(writers, h)
+		}
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, err
+	}
+
+	digests := make(Digests, len(seen))
+	for _, h := range hashers {
+		d := h.h.Sum(nil)
+		if shake, ok := h.h.(sha3.ShakeHash); ok && isExtendableOutput(h.alg) {
+			// a longer output extends a shorter one, so the longest is enough
+			d = make([]byte, slices.Max(append(sizes[h.alg], shake.Size())))
+			if _, err := shake.Read(d); err != nil {
+				return nil, err
+			}
+		}
+		digests[h.alg] = append(digests[h.alg], d)
+	}
+
+	return digests, nil
+}
+
+// DigestSet reads r once and returns a DigestSet with its digest under each
+// algorithm, hex-encoded unless a registered algorithm has another encoding,
+// as expected in ResourceDescriptor.Digest.
+//
+// Only algorithms over a file's content can be computed from a stream,
+// i.e. the md5, sha1, sha2, sha3, shake, blake2 and ripemd160 families.
+func DigestSet(r io.Reader, algs ...ita1.HashAlgorithm) (map[string]string, error) {
+	if len(algs) == 0 {
+		return nil, ErrDigestAlgorithmRequired
+	}
+
+	digests, err := ComputeDigests(r, algs)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]string, len(digests))
+	for alg, d := range digests {
+		set[alg.String()] = alg.Encoding().EncodeToString(d[0])
+	}
+
+	return set, nil
+}
+
+// DigestFile returns the DigestSet of the named file.
+func DigestFile(name string, algs ...ita1.HashAlgorithm) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return digestRegularFile(f, name, algs)
+}
+
+func digestRegularFile(f fs.File, name string, algs []ita1.HashAlgorithm) (map[string]string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegularFile, name)
+	}
+
+	return DigestSet(f, algs...)
+}
+
+// checkAlgorithms fails early on algorithms that cannot be computed, before
+// any file is read.
+func checkAlgorithms(algs []ita1.HashAlgorithm) error {
+	if len(algs) == 0 {
+		return ErrDigestAlgorithmRequired
+	}
+
+	for _, alg := range algs {
+		if _, ok := hashFunc(alg); !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupportedDigestAlgorithm, alg)
+		}
+	}
+
+	return nil
+}
+
+// DigestFiles digests the named files of fsys on one worker per CPU, and
+// returns a ResourceDescriptor named after each file, in the given order.
+// It stops at the first file that cannot be digested, or when ctx is done.
+func DigestFiles(ctx context.Context, fsys fs.FS, names []string, algs ...ita1.HashAlgorithm) ([]*ita1.ResourceDescriptor, error) {
+	if err := checkAlgorithms(algs); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rds := make([]*ita1.ResourceDescriptor, len(names))
+	var firstErr error
+	var once sync.Once
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), len(names)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				set, err := digestFSFile(fsys, names[i], algs)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				rds[i] = &ita1.ResourceDescriptor{Name: names[i], Digest: set}
+			}
+		}()
+	}
+
+queue:
+	for i := range names {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return rds, nil
+}
+
+func digestFSFile(fsys fs.FS, name string, algs []ita1.HashAlgorithm) (map[string]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return digestRegularFile(f, name, algs)
+}
+
+// DigestDir digests every regular file in fsys, as DigestFiles does, and
+// returns their ResourceDescriptors in the order fs.WalkDir visits them,
+// named by their slash-separated paths. Symbolic links and other special
+// files are skipped.
+func DigestDir(ctx context.Context, fsys fs.FS, algs ...ita1.HashAlgorithm) ([]*ita1.ResourceDescriptor, error) {
+	if err := checkAlgorithms(algs); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			names = append(names, name)
+		}
+
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return DigestFiles(ctx, fsys, names, algs...)
+}
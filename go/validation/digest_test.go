@@ -0,0 +1,189 @@
+/*
+Tests for artifact digesting.
+*/
+
+package validation
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func allHashAlgorithms() []ita1.HashAlgorithm {
+	algs := make([]ita1.HashAlgorithm, 0, len(hashFuncs))
+	for alg := range hashFuncs {
+		algs = append(algs, alg)
+	}
+
+	return algs
+}
+
+func TestDigestSet(t *testing.T) {
+	md5Sum := md5.Sum([]byte(testArtifact))
+	sha1Sum := sha1.Sum([]byte(testArtifact))
+	sha512Sum := sha512.Sum512([]byte(testArtifact))
+
+	set, err := DigestSet(strings.NewReader(testArtifact), allHashAlgorithms()...)
+	assert.NoError(t, err)
+	assert.Len(t, set, len(hashFuncs))
+	assert.Equal(t, hex.EncodeToString(md5Sum[:]), set["md5"])
+	assert.Equal(t, hex.EncodeToString(sha1Sum[:]), set["sha1"])
+	assert.Equal(t, sha256Hex(testArtifact), set["sha256"])
+	assert.Equal(t, hex.EncodeToString(sha512Sum[:]), set["sha512"])
+
+	// every computed DigestSet is valid
+	assert.NoError(t, (&ita1.ResourceDescriptor{Digest: set}).Validate())
+
+	// FIPS 202 test vector
+	set, err = DigestSet(strings.NewReader("abc"), ita1.AlgorithmSHA3_256, ita1.AlgorithmSHA3_256)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sha3_256": "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"}, set)
+}
+
+func TestDigestSetTestVectors(t *testing.T) {
+	// the digests of "abc" from FIPS 202, RFC 7693 and the RIPEMD-160 paper
+	tests := map[ita1.HashAlgorithm]string{
+		ita1.AlgorithmSHAKE128:  "5881092dd818bf5cf8a3ddb793fbcba74097d5c526a6d35f97b83351940f2cc8",
+		ita1.AlgorithmSHAKE256:  "483366601360a8771c6863080cc4114d8db44530f8f1e1ee4f94ea37e78b5739d5a15bef186a5386c75744c0527e1faa9f8726e462a12a4feb06bd8801e751e4",
+		ita1.AlgorithmBLAKE2b:   "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923",
+		ita1.AlgorithmBLAKE2s:   "508c5e8c327c14e2e1a72ba34eeb452f37458b209ed63a294d999b4c86675982",
+		ita1.AlgorithmRIPEMD160: "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc",
+	}
+
+	for alg, expected := range tests {
+		set, err := DigestSet(strings.NewReader("abc"), alg)
+		assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", alg))
+		assert.Equal(t, map[string]string{alg.String(): expected}, set, fmt.Sprintf("unexpected digest in test '%s'", alg))
+	}
+}
+
+func TestDigestSetErrors(t *testing.T) {
+	readErr := errors.New("disk on fire")
+
+	tests := map[string]struct {
+		r           io.Reader
+		algs        []ita1.HashAlgorithm
+		expectedErr error
+	}{
+		"no algorithm": {
+			expectedErr: ErrDigestAlgorithmRequired,
+		},
+		"tree algorithm": {
+			algs:        []ita1.HashAlgorithm{ita1.AlgorithmSHA256, ita1.AlgorithmGitTree},
+			expectedErr: ErrUnsupportedDigestAlgorithm,
+		},
+		"sm3": {
+			algs:        []ita1.HashAlgorithm{ita1.AlgorithmSM3},
+			expectedErr: ErrUnsupportedDigestAlgorithm,
+		},
+		"custom algorithm": {
+			algs:        []ita1.HashAlgorithm{"myCustomAlgorithm"},
+			expectedErr: ErrUnsupportedDigestAlgorithm,
+		},
+		"read error": {
+			r:           &failingReader{err: readErr},
+			algs:        []ita1.HashAlgorithm{ita1.AlgorithmSHA256},
+			expectedErr: readErr,
+		},
+	}
+
+	for name, test := range tests {
+		if test.r == nil {
+			test.r = strings.NewReader(testArtifact)
+		}
+
+		_, err := DigestSet(test.r, test.algs...)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestDigestFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "fooly.apk")
+	assert.NoError(t, os.WriteFile(name, []byte(testArtifact), 0o644))
+
+	set, err := DigestFile(name, ita1.AlgorithmSHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sha256": sha256Hex(testArtifact)}, set)
+
+	_, err = DigestFile(dir, ita1.AlgorithmSHA256)
+	assert.ErrorIs(t, err, ErrNotRegularFile)
+
+	_, err = DigestFile(filepath.Join(dir, "missing"), ita1.AlgorithmSHA256)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestDigestDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"fooly.apk":         {Data: []byte(testArtifact)},
+		"lib/b.so":          {Data: []byte("b")},
+		"lib/a.so":          {Data: []byte("a")},
+		"lib/empty":         {Mode: fs.ModeDir},
+		"lib/current":       {Data: []byte("a.so"), Mode: fs.ModeSymlink},
+		"lib/nested/c.json": {Data: []byte("{}")},
+	}
+
+	rds, err := DigestDir(context.Background(), fsys, ita1.AlgorithmSHA256, ita1.AlgorithmSHA512)
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, rd := range rds {
+		names = append(names, rd.GetName())
+		assert.NoError(t, rd.Validate())
+		assert.Equal(t, sha256Hex(string(fsys[rd.GetName()].Data)), rd.GetDigest()["sha256"])
+		assert.Len(t, rd.GetDigest(), 2)
+	}
+	assert.Equal(t, []string{"fooly.apk", "lib/a.so", "lib/b.so", "lib/nested/c.json"}, names)
+}
+
+func TestDigestFiles(t *testing.T) {
+	fsys := fstest.MapFS{}
+	names := []string{}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("file-%d", i)
+		fsys[name] = &fstest.MapFile{Data: []byte(name)}
+		names = append(names, name)
+	}
+
+	rds, err := DigestFiles(context.Background(), fsys, names, ita1.AlgorithmSHA256)
+	assert.NoError(t, err)
+	assert.Len(t, rds, len(names))
+	for i, rd := range rds {
+		assert.Equal(t, names[i], rd.GetName())
+		assert.Equal(t, sha256Hex(names[i]), rd.GetDigest()["sha256"])
+	}
+
+	_, err = DigestFiles(context.Background(), fsys, append(names, "missing"), ita1.AlgorithmSHA256)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = DigestFiles(context.Background(), fsys, names, ita1.AlgorithmGitBlob)
+	assert.ErrorIs(t, err, ErrUnsupportedDigestAlgorithm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DigestFiles(ctx, fsys, names, ita1.AlgorithmSHA256)
+	assert.ErrorIs(t, err, context.Canceled)
+}
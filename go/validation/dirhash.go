@@ -0,0 +1,237 @@
+/*
+Computation of the dirHash digest algorithm of spec/v1/digest_set.md, i.e.
+the Go module directory Hash1, over directory trees and archives.
+*/
+
+package validation
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+// h1Prefix prefixes the base64 form of a dirHash in go.sum files and the
+// Go checksum database.
+const h1Prefix = "h1:"
+
+var (
+	ErrInvalidArchivePath = errors.New("archive entry path escapes the archive")
+	ErrUnsupportedName    = errors.New("file names with newlines cannot be dirHashed")
+	ErrInvalidH1          = errors.New("invalid h1 checksum")
+)
+
+// dirHash returns the hex dirHash of files, given the sha256 sum of each by
+// slash-separated path: the sha256 of the `sha256sum` lines of the files in
+// byte-wise order.
+func dirHash(sums map[string][]byte) (string, error) {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		if strings.Contains(name, "\n") {
+			return "", fmt.Errorf("%w: %q", ErrUnsupportedName, name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%x  %s\n", sums[name], name)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sha256Sum(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// DirHash returns the dirHash of the regular files in fsys, named by their
+// slash-separated paths joined to prefix. Symbolic links and other special
+// files are skipped, as they are in the `find . -type f` of the spec.
+//
+// The go command hashes an extracted module with the prefix
+// `<module>@<version>`, so that the dirHash matches the one of its zip.
+func DirHash(fsys fs.FS, prefix string) (string, error) {
+	sums := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		sum, err := sha256Sum(f)
+		if err != nil {
+			return err
+		}
+		sums[path.Join(prefix, name)] = sum
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return dirHash(sums)
+}
+
+// archivePath returns the path an archive entry is extracted to, relative
+// to the extraction directory.
+func archivePath(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "/"))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidArchivePath, name)
+	}
+
+	return clean, nil
+}
+
+// DirHashZip returns the dirHash of the regular files in a zip archive of
+// the given size, as if it were extracted to an empty directory. For a Go
+// module zip, as served by module proxies, it is the dirHash of the module.
+func DirHashZip(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", err
+	}
+
+	sums := map[string][]byte{}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		name, err := archivePath(f.Name)
+		if err != nil {
+			return "", err
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		sum, err := sha256Sum(rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+
+		// later entries overwrite earlier ones, as when extracting
+		sums[name] = sum
+	}
+
+	return dirHash(sums)
+}
+
+// DirHashTar returns the dirHash of the regular files in an uncompressed
+// tar archive, as if it were extracted to an empty directory. Hard links
+// are files with the content of their target.
+func DirHashTar(r io.Reader) (string, error) {
+	tr := tar.NewReader(r)
+
+	sums := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeLink {
+			continue
+		}
+
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return "", err
+		}
+
+		if hdr.Typeflag == tar.TypeLink {
+			target, err := archivePath(hdr.Linkname)
+			if err != nil {
+				return "", err
+			}
+
+			sum, ok := sums[target]
+			if !ok {
+				return "", fmt.Errorf("%w: hard link %q to a missing file %q", ErrInvalidArchivePath, hdr.Name, hdr.Linkname)
+			}
+			sums[name] = sum
+			continue
+		}
+
+		sum, err := sha256Sum(tr)
+		if err != nil {
+			return "", err
+		}
+		sums[name] = sum
+	}
+
+	return dirHash(sums)
+}
+
+// DirHashToH1 converts a hex dirHash to the `h1:<base64>` form of go.sum
+// files and the Go checksum database.
+func DirHashToH1(digest string) (string, error) {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("%w: dirHash %q", ErrInvalidH1, digest)
+	}
+
+	return h1Prefix + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// DirHashFromH1 converts an `h1:<base64>` checksum to a hex dirHash, as
+// expected in a DigestSet.
+func DirHashFromH1(h1 string) (string, error) {
+	encoded, ok := strings.CutPrefix(h1, h1Prefix)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrInvalidH1, h1)
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sum) != sha256.Size {
+		return "", fmt.Errorf("%w: %q", ErrInvalidH1, h1)
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// GoModuleResourceDescriptor returns a ResourceDescriptor for a version of
+// a Go module, identified by its purl and by its h1 checksum from go.sum
+// or the Go checksum database.
+func GoModuleResourceDescriptor(module, version, h1 string) (*ita1.ResourceDescriptor, error) {
+	digest, err := DirHashFromH1(h1)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &ita1.ResourceDescriptor{
+		Uri:    fmt.Sprintf("pkg:golang/%s@%s", module, version),
+		Digest: map[string]string{ita1.AlgorithmDirHash.String(): digest},
+	}
+
+	return rd, rd.Validate()
+}
@@ -0,0 +1,224 @@
+/*
+Tests for dirHash computation.
+*/
+
+package validation
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The module of the spec/v1/digest_set.md example.
+const (
+	specModuleH1      = "h1:Khu2En+0gcYPZ2kuIihfswbzxv/mIHXgzPZ018Oty48="
+	specModuleDirHash = "2a1bb6127fb481c60f67692e22285fb306f3c6ffe62075e0ccf674d7c3adcb8f"
+)
+
+const testModulePrefix = "example.com/hello@v1.0.0"
+
+// testModuleFiles hold a module whose dirHash, with and without the module
+// prefix, was computed with the `sha256sum` command of the spec.
+var testModuleFiles = map[string]string{
+	"go.mod":    "module example.com/hello\n\ngo 1.23\n",
+	"main.go":   "package main\n\nfunc main() {}\n",
+	"Z.txt":     "Zebra\n",
+	"sub/a.txt": "a\n",
+	"sub/B.txt": "B\n",
+}
+
+const (
+	testModuleDirHash     = "f25365758acab063195974b57411f4ae8de0329f77c2d5d3eaee0252b8b6efe1"
+	testModuleRootDirHash = "87e9903bf42dfad1f997cdfbaf0352ee78ea9bd1cf9711727ae1322db3266397"
+)
+
+func TestDirHashSpecExample(t *testing.T) {
+	sums := map[string][]byte{}
+	for name, sum := range map[string]string{
+		"github.com/marklodato/go-hello-world@v0.0.1/README.md": "3a137eef6458bfb76bb2c63fc29ffc7166604d2d2e09ed9d8250a534122a8364",
+		"github.com/marklodato/go-hello-world@v0.0.1/go.mod":    "28e7c942a036902d981759d0bf5704d2bfc7cb500caf68b84711b234af01c6a5",
+		"github.com/marklodato/go-hello-world@v0.0.1/main.go":   "ddc4da627d9a9f45fb29641a1b185d6f53287ecfd921aacbf4fe54b7a86fe8d1",
+	} {
+		b, err := hex.DecodeString(sum)
+		assert.NoError(t, err)
+		sums[name] = b
+	}
+
+	digest, err := dirHash(sums)
+	assert.NoError(t, err)
+	assert.Equal(t, specModuleDirHash, digest)
+
+	h1, err := DirHashToH1(digest)
+	assert.NoError(t, err)
+	assert.Equal(t, specModuleH1, h1)
+
+	digest, err = DirHashFromH1(specModuleH1)
+	assert.NoError(t, err)
+	assert.Equal(t, specModuleDirHash, digest)
+}
+
+func TestDirHash(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty":        {Mode: fs.ModeDir},
+		"link-to-main": {Data: []byte("main.go"), Mode: fs.ModeSymlink},
+	}
+	for name, content := range testModuleFiles {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	digest, err := DirHash(fsys, testModulePrefix)
+	assert.NoError(t, err)
+	assert.Equal(t, testModuleDirHash, digest)
+
+	digest, err = DirHash(fsys, "")
+	assert.NoError(t, err)
+	assert.Equal(t, testModuleRootDirHash, digest)
+
+	sub, err := fs.Sub(fsys, "sub")
+	assert.NoError(t, err)
+	digest, err = DirHash(sub, testModulePrefix+"/sub")
+	assert.NoError(t, err)
+	assert.NotEqual(t, testModuleDirHash, digest)
+
+	_, err = DirHash(fstest.MapFS{"two\nlines": {}}, "")
+	assert.ErrorIs(t, err, ErrUnsupportedName)
+}
+
+func TestDirHashZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create(testModulePrefix + "/sub/")
+	assert.NoError(t, err)
+	for name, content := range testModuleFiles {
+		w, err := zw.Create(testModulePrefix + "/" + name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	digest, err := DirHashZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, testModuleDirHash, digest)
+
+	_, err = DirHashZip(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+}
+
+func TestDirHashTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeEntry := func(hdr *tar.Header, content string) {
+		hdr.Size = int64(len(content))
+		hdr.Mode = 0o644
+		assert.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+
+	writeEntry(&tar.Header{Name: "./", Typeflag: tar.TypeDir}, "")
+	writeEntry(&tar.Header{Name: "./" + testModulePrefix + "/", Typeflag: tar.TypeDir}, "")
+	writeEntry(&tar.Header{Name: "./" + testModulePrefix + "/Z.txt", Typeflag: tar.TypeReg}, "replaced below")
+	for name, content := range testModuleFiles {
+		writeEntry(&tar.Header{Name: "./" + testModulePrefix + "/" + name, Typeflag: tar.TypeReg}, content)
+	}
+	writeEntry(&tar.Header{Name: "./" + testModulePrefix + "/link", Typeflag: tar.TypeSymlink, Linkname: "main.go"}, "")
+	assert.NoError(t, tw.Close())
+
+	digest, err := DirHashTar(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, testModuleDirHash, digest)
+
+	// a hard link is a file of its own
+	buf.Reset()
+	tw = tar.NewWriter(&buf)
+	writeEntry(&tar.Header{Name: "go.mod", Typeflag: tar.TypeReg}, testModuleFiles["go.mod"])
+	writeEntry(&tar.Header{Name: "go.mod.bak", Typeflag: tar.TypeLink, Linkname: "go.mod"}, "")
+	assert.NoError(t, tw.Close())
+
+	digest, err = DirHashTar(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	expected, err := DirHash(fstest.MapFS{
+		"go.mod":     {Data: []byte(testModuleFiles["go.mod"])},
+		"go.mod.bak": {Data: []byte(testModuleFiles["go.mod"])},
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, digest)
+}
+
+func TestDirHashArchiveErrors(t *testing.T) {
+	tarOf := func(hdrs ...*tar.Header) *bytes.Reader {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			assert.NoError(t, tw.WriteHeader(hdr))
+		}
+		assert.NoError(t, tw.Close())
+		return bytes.NewReader(buf.Bytes())
+	}
+
+	tests := map[string]struct {
+		archive     *bytes.Reader
+		expectedErr error
+	}{
+		"parent path": {
+			archive:     tarOf(&tar.Header{Name: "../evil", Typeflag: tar.TypeReg}),
+			expectedErr: ErrInvalidArchivePath,
+		},
+		"nested parent path": {
+			archive:     tarOf(&tar.Header{Name: "/a/../../evil", Typeflag: tar.TypeReg}),
+			expectedErr: ErrInvalidArchivePath,
+		},
+		"dangling hard link": {
+			archive:     tarOf(&tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "missing"}),
+			expectedErr: ErrInvalidArchivePath,
+		},
+		"newline": {
+			archive:     tarOf(&tar.Header{Name: "two\nlines", Typeflag: tar.TypeReg}),
+			expectedErr: ErrUnsupportedName,
+		},
+	}
+
+	for name, test := range tests {
+		_, err := DirHashTar(test.archive)
+		assert.ErrorIs(t, err, test.expectedErr, fmt.Sprintf("expected error in test '%s'", name))
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	_, err := zw.Create("../evil")
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	_, err = DirHashZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.ErrorIs(t, err, ErrInvalidArchivePath)
+}
+
+func TestDirHashH1Errors(t *testing.T) {
+	for _, h1 := range []string{"", specModuleDirHash, "h2:Khu2En+0gcYPZ2kuIihfswbzxv/mIHXgzPZ018Oty48=", "h1:Khu2En+0gcYPZ2ku", "h1:not base64!"} {
+		_, err := DirHashFromH1(h1)
+		assert.ErrorIs(t, err, ErrInvalidH1, fmt.Sprintf("expected error for %q", h1))
+	}
+
+	for _, digest := range []string{"", "2a1bb6", specModuleH1, specModuleDirHash + "00"} {
+		_, err := DirHashToH1(digest)
+		assert.ErrorIs(t, err, ErrInvalidH1, fmt.Sprintf("expected error for %q", digest))
+	}
+}
+
+func TestGoModuleResourceDescriptor(t *testing.T) {
+	rd, err := GoModuleResourceDescriptor("github.com/marklodato/go-hello-world", "v0.0.1", specModuleH1)
+	assert.NoError(t, err)
+	assert.Equal(t, "pkg:golang/github.com/marklodato/go-hello-world@v0.0.1", rd.GetUri())
+	assert.Equal(t, map[string]string{"dirHash": specModuleDirHash}, rd.GetDigest())
+
+	_, err = GoModuleResourceDescriptor("github.com/marklodato/go-hello-world", "v0.0.1", specModuleDirHash)
+	assert.ErrorIs(t, err, ErrInvalidH1)
+}
@@ -0,0 +1,205 @@
+/*
+Implementation of the attestation validation model in docs/validation.md.
+*/
+
+package validation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/in-toto/attestation/go/dsse"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Step identifies the step of the validation model at which an attestation
+// was rejected.
+type Step string
+
+const (
+	StepDecodeEnvelope  Step = "decode envelope"
+	StepMatchAttesters  Step = "match attesters"
+	StepPayloadType     Step = "check payloadType"
+	StepDecodeStatement Step = "decode statement"
+	StepStatementType   Step = "check statement type"
+	StepMatchSubjects   Step = "match subjects"
+)
+
+var (
+	ErrArtifactRequired           = errors.New("artifact required")
+	ErrDigestAlgorithmRequired    = errors.New("at least one acceptable digest algorithm required")
+	ErrUnsupportedDigestAlgorithm = errors.New("digest algorithm cannot be computed")
+	ErrUnsupportedPayloadType     = errors.New("payloadType is not an in-toto Statement media type")
+	ErrNoMatchedSubjects          = errors.New("no subject matches the artifact")
+)
+
+// RejectionError reports why, and at which step, an attestation was
+// rejected. The underlying reason is available via errors.Is and errors.As.
+type RejectionError struct {
+	Step Step
+	Err  error
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("attestation rejected at step %q: %v", e.Step, e.Err)
+}
+
+func (e *RejectionError) Unwrap() error {
+	return e.Err
+}
+
+func reject(step Step, err error) error {
+	return &RejectionError{Step: step, Err: err}
+}
+
+// Result is the output of the validation model, to be fed into a policy
+// engine.
+type Result struct {
+	PredicateType   string
+	Predicate       *structpb.Struct
+	MatchedSubjects []*ita1.ResourceDescriptor
+	AttesterNames   []string
+	// PayloadTypeMismatch is set if the envelope's predicate-specific
+	// payloadType disagrees with PredicateType. It does not cause a
+	// rejection, as only the predicateType is authoritative.
+	PayloadTypeMismatch *dsse.PayloadTypeMismatch
+}
+
+// Verifier verifies single attestations about single artifacts.
+type Verifier struct {
+	recognizedAttesters        []dsse.Attester
+	acceptableDigestAlgorithms []ita1.HashAlgorithm
+	validateOptions            []ita1.ValidateOption
+}
+
+// NewVerifier creates a Verifier that trusts the given attesters and
+// matches subjects using the given digest algorithms, which default to
+// sha256 if none are given.
+func NewVerifier(recognizedAttesters []dsse.Attester, acceptableDigestAlgorithms ...ita1.HashAlgorithm) (*Verifier, error) {
+	if len(recognizedAttesters) == 0 {
+		return nil, dsse.ErrAttesterRequired
+	}
+
+	for _, a := range recognizedAttesters {
+		if err := a.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(acceptableDigestAlgorithms) == 0 {
+		acceptableDigestAlgorithms = []ita1.HashAlgorithm{ita1.AlgorithmSHA256}
+	}
+
+	for _, alg := range acceptableDigestAlgorithms {
+		if _, ok := hashFunc(alg); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedDigestAlgorithm, alg)
+		}
+	}
+
+	return &Verifier{
+		recognizedAttesters:        recognizedAttesters,
+		acceptableDigestAlgorithms: acceptableDigestAlgorithms,
+	}, nil
+}
+
+// SetValidateOptions sets the options applied to the validation of each
+// Statement, e.g. ita1.RejectWeakDigests to reject subjects with only weak
+// digests at StepDecodeStatement.
+func (v *Verifier) SetValidateOptions(opts ...ita1.ValidateOption) {
+	v.validateOptions = opts
+}
+
+// Verify runs the validation model over a JSON-encoded envelope and the
+// artifact it is expected to describe. Any rejection is reported as a
+// *RejectionError.
+func (v *Verifier) Verify(ctx context.Context, artifact io.Reader, attestation []byte) (*Result, error) {
+	if artifact == nil {
+		return nil, ErrArtifactRequired
+	}
+
+	// Envelope layer
+	env, err := dsse.Parse(attestation)
+	if err != nil {
+		return nil, reject(StepDecodeEnvelope, err)
+	}
+
+	attesterNames, err := env.AttesterNames(ctx, v.recognizedAttesters...)
+	if err != nil {
+		return nil, reject(StepMatchAttesters, err)
+	}
+
+	// Statement layer
+	if !dsse.IsInTotoPayloadType(env.PayloadType) {
+		return nil, reject(StepPayloadType, fmt.Errorf("%w: %q", ErrUnsupportedPayloadType, env.PayloadType))
+	}
+
+	statement, err := env.StatementWithOptions(v.validateOptions...)
+	if errors.Is(err, ita1.ErrInvalidStatementType) {
+		return nil, reject(StepStatementType, err)
+	} else if err != nil {
+		return nil, reject(StepDecodeStatement, err)
+	}
+
+	// Validate accepts the legacy v0.1 type, but the model requires v1
+	if statement.GetType() != ita1.StatementTypeUri {
+		return nil, reject(StepStatementType, fmt.Errorf("%w: %q", ita1.ErrInvalidStatementType, statement.GetType()))
+	}
+
+	digests, err := ComputeDigests(artifact, v.acceptableDigestAlgorithms, statement.GetSubject()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to digest artifact: %w", err)
+	}
+
+	matched := MatchSubjects(statement.GetSubject(), digests)
+	if len(matched) == 0 {
+		return nil, reject(StepMatchSubjects, ErrNoMatchedSubjects)
+	}
+
+	return &Result{
+		PredicateType:       statement.GetPredicateType(),
+		Predicate:           statement.GetPredicate(),
+		MatchedSubjects:     matched,
+		AttesterNames:       attesterNames,
+		PayloadTypeMismatch: env.PayloadTypeMismatch(statement),
+	}, nil
+}
+
+// MatchSubjects returns the subjects with at least one digest equal to one
+// of the artifact's digests, as computed by ComputeDigests. A shake digest
+// matches a longer one it is a prefix of.
+func MatchSubjects(subjects []*ita1.ResourceDescriptor, digests Digests) []*ita1.ResourceDescriptor {
+	matched := []*ita1.ResourceDescriptor{}
+	for _, s := range subjects {
+		if matchesDigests(s, digests) {
+			matched = append(matched, s)
+		}
+	}
+
+	return matched
+}
+
+func matchesDigests(s *ita1.ResourceDescriptor, digests Digests) bool {
+	for alg, value := range s.GetDigest() {
+		name := ita1.HashAlgorithm(alg)
+		want, err := name.Encoding().DecodeString(value)
+		if err != nil || len(want) == 0 {
+			continue
+		}
+
+		for _, got := range digests[name] {
+			if isExtendableOutput(name) && len(want) < len(got) {
+				got = got[:len(want)]
+			}
+
+			if bytes.Equal(got, want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
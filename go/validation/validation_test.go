@@ -0,0 +1,362 @@
+/*
+Tests for the attestation validation model.
+*/
+
+package validation
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/in-toto/attestation/go/dsse"
+	"github.com/in-toto/attestation/go/internal/testutil"
+	"github.com/in-toto/attestation/go/signature"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const testArtifact = "fooly.apk contents"
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func createTestStatement(t *testing.T, subjects ...*ita1.ResourceDescriptor) *ita1.Statement {
+	t.Helper()
+
+	pred, err := structpb.NewStruct(map[string]interface{}{"keyObj": "theValue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       subjects,
+		PredicateType: "https://example.com/thePredicate/v1",
+		Predicate:     pred,
+	}
+}
+
+func createTestAttestation(t *testing.T, st *ita1.Statement, signers ...signature.Signer) []byte {
+	t.Helper()
+
+	env, err := dsse.NewEnvelope(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.Sign(context.Background(), signers...); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	alice := testutil.NewSigner(t)
+	bob := testutil.NewSigner(t)
+
+	matching := &ita1.ResourceDescriptor{Name: "fooly.apk", Digest: map[string]string{"sha256": sha256Hex(testArtifact)}}
+	other := &ita1.ResourceDescriptor{Name: "other", Digest: map[string]string{"sha256": sha256Hex("other")}}
+	st := createTestStatement(t, other, matching)
+	att := createTestAttestation(t, st, bob, alice)
+
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: alice}, {Name: "bob", Verifier: bob}})
+	assert.NoError(t, err)
+
+	got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, st.GetPredicateType(), got.PredicateType)
+	assert.Equal(t, "theValue", got.Predicate.GetFields()["keyObj"].GetStringValue())
+	assert.Equal(t, []string{"alice", "bob"}, got.AttesterNames)
+	if assert.Len(t, got.MatchedSubjects, 1) {
+		assert.Equal(t, "fooly.apk", got.MatchedSubjects[0].GetName())
+	}
+	assert.Nil(t, got.PayloadTypeMismatch)
+}
+
+func TestVerifyPayloadTypeMismatch(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	subject := &ita1.ResourceDescriptor{Digest: map[string]string{"sha256": sha256Hex(testArtifact)}}
+
+	env, err := dsse.NewEnvelope(createTestStatement(t, subject))
+	assert.NoError(t, err)
+	env.PayloadType = "application/vnd.in-toto.provenance+json"
+	assert.NoError(t, env.Sign(context.Background(), signer))
+	att, err := json.Marshal(env)
+	assert.NoError(t, err)
+
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}})
+	assert.NoError(t, err)
+
+	// a mismatch is reported, but the predicateType is still authoritative
+	got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "https://example.com/thePredicate/v1", got.PredicateType)
+	if assert.NotNil(t, got.PayloadTypeMismatch) {
+		assert.Equal(t, "provenance", got.PayloadTypeMismatch.PredicateName)
+		assert.Equal(t, dsse.PayloadType, got.PayloadTypeMismatch.ExpectedPayloadType)
+	}
+}
+
+func TestVerifyDigestAlgorithms(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	attesters := []dsse.Attester{{Name: "alice", Verifier: signer}}
+
+	sum := sha512.Sum512([]byte(testArtifact))
+	subject := &ita1.ResourceDescriptor{Digest: map[string]string{
+		"sha512": strings.ToUpper(hex.EncodeToString(sum[:])),
+		"sha256": sha256Hex("something else"),
+	}}
+	att := createTestAttestation(t, createTestStatement(t, subject), signer)
+
+	// sha256 alone does not match
+	v, err := NewVerifier(attesters)
+	assert.NoError(t, err)
+	_, err = v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.ErrorIs(t, err, ErrNoMatchedSubjects)
+
+	// sha512 does, and hex decoding is case-insensitive
+	v, err = NewVerifier(attesters, ita1.AlgorithmSHA256, ita1.AlgorithmSHA512)
+	assert.NoError(t, err)
+	got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.NoError(t, err)
+	assert.Len(t, got.MatchedSubjects, 1)
+}
+
+func TestVerifyVariableLengthDigests(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	attesters := []dsse.Attester{{Name: "alice", Verifier: signer}}
+
+	blake2b256 := blake2b.Sum256([]byte(testArtifact))
+	shake128 := make([]byte, 16)
+	sha3.ShakeSum128(shake128, []byte(testArtifact))
+	shake256 := make([]byte, 100)
+	sha3.ShakeSum256(shake256, []byte(testArtifact))
+
+	tests := map[string]struct {
+		alg    ita1.HashAlgorithm
+		digest []byte
+	}{
+		"blake2b-256": {
+			alg:    ita1.AlgorithmBLAKE2b,
+			digest: blake2b256[:],
+		},
+		"16-byte shake128": {
+			alg:    ita1.AlgorithmSHAKE128,
+			digest: shake128,
+		},
+		"100-byte shake256": {
+			alg:    ita1.AlgorithmSHAKE256,
+			digest: shake256,
+		},
+	}
+
+	for name, test := range tests {
+		match := &ita1.ResourceDescriptor{Name: "fooly.apk", Digest: map[string]string{string(test.alg): hex.EncodeToString(test.digest)}}
+		test.digest[0] ^= 0xff
+		other := &ita1.ResourceDescriptor{Name: "other", Digest: map[string]string{string(test.alg): hex.EncodeToString(test.digest)}}
+		att := createTestAttestation(t, createTestStatement(t, other, match), signer)
+
+		v, err := NewVerifier(attesters, test.alg)
+		assert.NoError(t, err)
+		got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+		if assert.NoError(t, err, fmt.Sprintf("unexpected error in test '%s'", name)) {
+			assert.Equal(t, []*ita1.ResourceDescriptor{match}, got.MatchedSubjects, fmt.Sprintf("wrong subjects in test '%s'", name))
+		}
+	}
+}
+
+func TestVerifyRejectsWeakDigests(t *testing.T) {
+	signer := testutil.NewSigner(t)
+
+	sum := md5.Sum([]byte(testArtifact))
+	subject := &ita1.ResourceDescriptor{Digest: map[string]string{"md5": hex.EncodeToString(sum[:])}}
+	att := createTestAttestation(t, createTestStatement(t, subject), signer)
+
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, ita1.AlgorithmMD5)
+	assert.NoError(t, err)
+
+	// weak digests are accepted by default
+	_, err = v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.NoError(t, err)
+
+	v.SetValidateOptions(ita1.RejectWeakDigests())
+	_, err = v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.ErrorIs(t, err, ita1.ErrWeakDigestSet)
+	var rejection *RejectionError
+	if assert.True(t, errors.As(err, &rejection)) {
+		assert.Equal(t, StepDecodeStatement, rejection.Step)
+	}
+}
+
+// testRegisteredAlgorithm is sha256 under another name, base64-encoded.
+const testRegisteredAlgorithm ita1.HashAlgorithm = "testSha256Base64"
+
+// registered once, as the registry lives as long as the test binary
+var (
+	errRegisterTestAlgorithm = ita1.RegisterHashAlgorithm(testRegisteredAlgorithm, ita1.HashAlgorithmSpec{
+		Sizes:    []int{sha256.Size},
+		Encoding: ita1.EncodingBase64,
+		Strength: ita1.StrengthStrong,
+		New:      sha256.New,
+	})
+	errRegisterValidatedOnly = ita1.RegisterHashAlgorithm("testValidatedOnly", ita1.HashAlgorithmSpec{Sizes: []int{32}})
+	// sha256 stands in for a gost implementation
+	errRegisterGOST = ita1.RegisterHashAlgorithm(ita1.AlgorithmGOST, ita1.HashAlgorithmSpec{New: sha256.New})
+)
+
+func TestVerifyRegisteredAlgorithm(t *testing.T) {
+	assert.NoError(t, errRegisterTestAlgorithm)
+	assert.NoError(t, errRegisterValidatedOnly)
+
+	sum := sha256.Sum256([]byte(testArtifact))
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+
+	set, err := DigestSet(strings.NewReader(testArtifact), testRegisteredAlgorithm)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{testRegisteredAlgorithm.String(): digest}, set)
+
+	signer := testutil.NewSigner(t)
+	subject := &ita1.ResourceDescriptor{Digest: set}
+	att := createTestAttestation(t, createTestStatement(t, subject), signer)
+
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, testRegisteredAlgorithm)
+	assert.NoError(t, err)
+	got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.NoError(t, err)
+	assert.Len(t, got.MatchedSubjects, 1)
+
+	// registered algorithms without an implementation can only be validated
+	_, err = NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, "testValidatedOnly")
+	assert.ErrorIs(t, err, ErrUnsupportedDigestAlgorithm)
+}
+
+func TestVerifyRegisteredSpecAlgorithm(t *testing.T) {
+	assert.NoError(t, errRegisterGOST)
+
+	signer := testutil.NewSigner(t)
+	subject := &ita1.ResourceDescriptor{Digest: map[string]string{string(ita1.AlgorithmGOST): sha256Hex(testArtifact)}}
+	att := createTestAttestation(t, createTestStatement(t, subject), signer)
+
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, ita1.AlgorithmGOST)
+	assert.NoError(t, err)
+	got, err := v.Verify(context.Background(), strings.NewReader(testArtifact), att)
+	assert.NoError(t, err)
+	assert.Len(t, got.MatchedSubjects, 1)
+
+	// spec algorithms without a registered implementation cannot be computed
+	_, err = NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, ita1.AlgorithmSM3)
+	assert.ErrorIs(t, err, ErrUnsupportedDigestAlgorithm)
+}
+
+func TestVerifyRejections(t *testing.T) {
+	signer := testutil.NewSigner(t)
+	v, err := NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}})
+	assert.NoError(t, err)
+
+	subject := &ita1.ResourceDescriptor{Digest: map[string]string{"sha256": sha256Hex(testArtifact)}}
+	st := createTestStatement(t, subject)
+
+	legacy := createTestStatement(t, subject)
+	legacy.Type = "https://in-toto.io/Statement/v0.1"
+
+	nonInToto := &dsse.Envelope{PayloadType: "application/vnd.novulz+cbor", Payload: []byte("c")}
+	assert.NoError(t, nonInToto.Sign(context.Background(), signer))
+	nonInTotoAtt, _ := json.Marshal(nonInToto)
+
+	badPayload := &dsse.Envelope{PayloadType: dsse.PayloadType, Payload: []byte("{")}
+	assert.NoError(t, badPayload.Sign(context.Background(), signer))
+	badPayloadAtt, _ := json.Marshal(badPayload)
+
+	tests := map[string]struct {
+		attestation []byte
+		artifact    string
+		step        Step
+		err         error
+	}{
+		"not an envelope": {
+			attestation: []byte(`not json`),
+			step:        StepDecodeEnvelope,
+		},
+		"unknown attester": {
+			attestation: createTestAttestation(t, st, testutil.NewSigner(t)),
+			step:        StepMatchAttesters,
+			err:         dsse.ErrNoRecognizedAttester,
+		},
+		"unsupported payloadType": {
+			attestation: nonInTotoAtt,
+			step:        StepPayloadType,
+			err:         ErrUnsupportedPayloadType,
+		},
+		"bad statement": {
+			attestation: badPayloadAtt,
+			step:        StepDecodeStatement,
+		},
+		"legacy statement type": {
+			attestation: createTestAttestation(t, legacy, signer),
+			step:        StepStatementType,
+			err:         ita1.ErrInvalidStatementType,
+		},
+		"wrong artifact": {
+			attestation: createTestAttestation(t, st, signer),
+			artifact:    "tampered",
+			step:        StepMatchSubjects,
+			err:         ErrNoMatchedSubjects,
+		},
+	}
+
+	for name, test := range tests {
+		artifact := test.artifact
+		if artifact == "" {
+			artifact = testArtifact
+		}
+
+		_, err := v.Verify(context.Background(), strings.NewReader(artifact), test.attestation)
+
+		var rejection *RejectionError
+		if !assert.True(t, errors.As(err, &rejection), fmt.Sprintf("no rejection in test '%s': %v", name, err)) {
+			continue
+		}
+		assert.Equal(t, test.step, rejection.Step, fmt.Sprintf("wrong step in test '%s'", name))
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, fmt.Sprintf("wrong reason in test '%s'", name))
+		}
+	}
+}
+
+func TestNewVerifierErrors(t *testing.T) {
+	signer := testutil.NewSigner(t)
+
+	_, err := NewVerifier(nil)
+	assert.ErrorIs(t, err, dsse.ErrAttesterRequired)
+
+	_, err = NewVerifier([]dsse.Attester{{Verifier: signer}})
+	assert.ErrorIs(t, err, dsse.ErrAttesterNameRequired)
+
+	_, err = NewVerifier([]dsse.Attester{{Name: "alice", Verifier: signer}}, ita1.AlgorithmGitCommit)
+	assert.ErrorIs(t, err, ErrUnsupportedDigestAlgorithm)
+}